@@ -33,20 +33,53 @@ type AppRolloutSpec struct {
 	SourceAppRevisionName string `json:"sourceAppRevisionName,omitempty"`
 
 	// The list of component to upgrade in the application.
-	// We only support single component application so far
-	// TODO: (RZ) Support multiple components in an application
+	// We upgrade all the common components of the source and target application if it's empty
 	// +optional
 	ComponentList []string `json:"componentList,omitempty"`
 
+	// ComponentRolloutPolicy defines how to roll out the components when there are more than one
+	// +kubebuilder:validation:Enum=Lockstep;Ordered
+	// +optional
+	ComponentRolloutPolicy ComponentRolloutPolicy `json:"componentRolloutPolicy,omitempty"`
+
 	// RolloutPlan is the details on how to rollout the resources
 	RolloutPlan v1alpha1.RolloutPlan `json:"rolloutPlan"`
 
-	// RevertOnDelete revert the rollout when the rollout CR is deleted
-	// It will remove the target app from the kubernetes if it's set to true
+	// RevertOnDelete revert the rollout when the rollout CR is deleted before it finishes
+	// The source app becomes the active revision again if it's set to true and its workload is scaled back up while the
+	// target workload is scaled down, otherwise the target app completes the upgrade on its own
 	// +optional
 	RevertOnDelete *bool `json:"revertOnDelete,omitempty"`
+
+	// RollbackOnFailure scales the source back up and the target down automatically when the rollout fails
+	// It only works when there is a source application to roll back to and a single component to roll out
+	// +optional
+	RollbackOnFailure *bool `json:"rollbackOnFailure,omitempty"`
 }
 
+// ComponentRolloutPolicy defines how to roll out multiple components
+type ComponentRolloutPolicy string
+
+const (
+	// LockstepComponentRollout rolls out all the components together, no component moves on to the next batch
+	// until all of them finish the current one. This is the default policy
+	LockstepComponentRollout ComponentRolloutPolicy = "Lockstep"
+	// OrderedComponentRollout rolls out the components one after another in the order of the component list
+	OrderedComponentRollout ComponentRolloutPolicy = "Ordered"
+)
+
+// RollbackState is the state of the automatic rollback after a rollout failed
+type RollbackState string
+
+const (
+	// RollingBackState means we are moving all the replicas back to the source
+	RollingBackState RollbackState = "rollingBack"
+	// RollbackSucceedState means all the replicas are back to the source
+	RollbackSucceedState RollbackState = "rollbackSucceed"
+	// RollbackFailedState means we cannot roll back, the client has to decide what to do
+	RollbackFailedState RollbackState = "rollbackFailed"
+)
+
 // AppRolloutStatus defines the observed state of AppRollout
 type AppRolloutStatus struct {
 	v1alpha1.RolloutStatus `json:",inline"`
@@ -58,6 +91,59 @@ type AppRolloutStatus struct {
 	// LastSourceAppRevision contains the name of the app that we need to upgrade from.
 	// We will restart the rollout if this is not the same as the spec
 	LastSourceAppRevision string `json:"LastSourceAppRevision,omitempty"`
+
+	// EffectiveSourceAppRevision is the application revision that the rollout actually upgrades from when it differs
+	// from the spec, that is when a modified rollout restarted from the revision that serves the application
+	// +optional
+	EffectiveSourceAppRevision string `json:"effectiveSourceAppRevision,omitempty"`
+
+	// RollbackState is the state of the automatic rollback, it is only set when the rollout failed
+	// and RollbackOnFailure is true
+	// +optional
+	RollbackState RollbackState `json:"rollbackState,omitempty"`
+
+	// RollbackStatus is the status of the rollout plan that moves the replicas back to the source
+	// +optional
+	RollbackStatus *v1alpha1.RolloutStatus `json:"rollbackStatus,omitempty"`
+
+	// History records the events that restarted the rollout, the oldest entries are dropped
+	// +optional
+	History []AppRolloutHistoryEntry `json:"history,omitempty"`
+
+	// ComponentStatuses records the rollout status of each component when the rollout upgrades more than one
+	// component. The rollout status above sums them up in that case
+	// +optional
+	ComponentStatuses []ComponentRolloutStatus `json:"componentStatuses,omitempty"`
+}
+
+// ComponentRolloutStatus is the rollout status of one component
+type ComponentRolloutStatus struct {
+	// ComponentName is the name of the component
+	ComponentName string `json:"componentName"`
+
+	v1alpha1.RolloutStatus `json:",inline"`
+}
+
+// AppRolloutHistoryEntry records an event that restarted the rollout
+type AppRolloutHistoryEntry struct {
+	// Event that restarted the rollout
+	Event v1alpha1.RolloutEvent `json:"event"`
+
+	// SourceAppRevision is the source of the rollout before it restarted
+	// +optional
+	SourceAppRevision string `json:"sourceAppRevision,omitempty"`
+
+	// TargetAppRevision is the target of the rollout before it restarted
+	TargetAppRevision string `json:"targetAppRevision"`
+
+	// RollingState is the state of the rollout when it restarted
+	RollingState v1alpha1.RollingState `json:"rollingState"`
+
+	// CurrentBatch is the batch the rollout was working on when it restarted
+	CurrentBatch int32 `json:"currentBatch"`
+
+	// Time when the rollout restarted
+	Time metav1.Time `json:"time"`
 }
 
 // AppRollout is the Schema for the AppRollout API
@@ -59,23 +59,49 @@ type AppStatus struct {
 	// LatestRevision of the application configuration it generates
 	// +optional
 	LatestRevision *Revision `json:"latestRevision,omitempty"`
+
+	// LastUpgradedTargetAppRevision contains the name of the application revision that the rollout plan upgrades to
+	// +optional
+	LastUpgradedTargetAppRevision string `json:"lastTargetAppRevision,omitempty"`
+
+	// LastSourceAppRevision contains the name of the application revision that the rollout plan upgrades from
+	// +optional
+	LastSourceAppRevision string `json:"lastSourceAppRevision,omitempty"`
 }
 
 // ApplicationComponentStatus record the health status of App component
 type ApplicationComponentStatus struct {
 	Name    string                   `json:"name"`
 	Healthy bool                     `json:"healthy"`
+	State   HealthState              `json:"state,omitempty"`
+	Reason  string                   `json:"reason,omitempty"`
 	Message string                   `json:"message,omitempty"`
 	Traits  []ApplicationTraitStatus `json:"traits,omitempty"`
 }
 
 // ApplicationTraitStatus records the trait health status
 type ApplicationTraitStatus struct {
-	Type    string `json:"type"`
-	Healthy bool   `json:"healthy"`
-	Message string `json:"message,omitempty"`
+	Type    string      `json:"type"`
+	Healthy bool        `json:"healthy"`
+	State   HealthState `json:"state,omitempty"`
+	Reason  string      `json:"reason,omitempty"`
+	Message string      `json:"message,omitempty"`
 }
 
+// HealthState is the state reported by the health policy of a component or trait
+type HealthState string
+
+const (
+	// HealthStateHealthy means the component or trait is running as expected
+	HealthStateHealthy HealthState = "healthy"
+	// HealthStateProgressing means the component or trait is not ready yet but is expected to become healthy
+	HealthStateProgressing HealthState = "progressing"
+	// HealthStateDegraded means the component or trait is working with reduced capability
+	HealthStateDegraded HealthState = "degraded"
+	// HealthStateUnhealthy means the component or trait is not working
+	HealthStateUnhealthy HealthState = "unhealthy"
+)
+
 // ApplicationTrait defines the trait of application
 type ApplicationTrait struct {
 	Name string `json:"name"`
@@ -99,11 +125,25 @@ type ApplicationComponent struct {
 	Scopes map[string]string `json:"scopes,omitempty"`
 }
 
+// ApplicationScope defines an application-level scope that all the components of the application join
+type ApplicationScope struct {
+	// Name is the name of the scope instance
+	Name string `json:"name"`
+	// Type is the name of the `ScopeDefinition`
+	Type string `json:"type"`
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Properties runtime.RawExtension `json:"properties,omitempty"`
+}
+
 // ApplicationSpec is the spec of Application
 type ApplicationSpec struct {
 	Components []ApplicationComponent `json:"components"`
 
-	// TODO(wonderflow): we should have application level scopes supported here
+	// Scopes define the application-level scopes, every component of the application joins them.
+	// The scope is rendered from the template of its `ScopeDefinition` if there is one,
+	// otherwise it refers to an existing scope instance with the same name
+	// +optional
+	Scopes []ApplicationScope `json:"scopes,omitempty"`
 
 	// RolloutPlan is the details on how to rollout the resources
 	// The controller simply replace the old resources with the new one if there is no rollout plan involved
@@ -26,8 +26,14 @@ import (
 // CUE defines the encapsulation in CUE format
 type CUE struct {
 	// Template defines the abstraction template data of the capability, it will replace the old CUE template in extension field.
-	// Template is a required field if CUE is defined in Capability Definition.
-	Template string `json:"template"`
+	// Either Template or TemplateURI is required if CUE is defined in Capability Definition.
+	Template string `json:"template,omitempty"`
+	// TemplateURI refers to the template stored outside the definition, it takes precedence over Template.
+	// It can be a http(s) URL, a file in a git repository like git::https://github.com/org/repo.git//webservice.cue?ref=v1.0.0,
+	// or an OCI artifact like oci://ghcr.io/org/templates/webservice:v1.0.0.
+	TemplateURI string `json:"templateURI,omitempty"`
+	// TemplateDigest pins the content of the template fetched from TemplateURI, in the form of sha256:<hex>.
+	TemplateDigest string `json:"templateDigest,omitempty"`
 }
 
 // Schematic defines the encapsulation of this capability(workload/trait/scope),
@@ -246,6 +252,10 @@ type ScopeDefinitionSpec struct {
 	// multiple instances of this kind of scope.
 	AllowComponentOverlap bool `json:"allowComponentOverlap"`
 
+	// Schematic defines the data format and template of the encapsulation of the scope
+	// +optional
+	Schematic *Schematic `json:"schematic,omitempty"`
+
 	// Extension is used for extension needs by OAM platform builders
 	// +optional
 	// +kubebuilder:pruning:PreserveUnknownFields
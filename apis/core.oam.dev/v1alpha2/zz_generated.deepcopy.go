@@ -21,8 +21,8 @@ limitations under the License.
 package v1alpha2
 
 import (
-	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
-	standard_oam_devv1alpha1 "github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	corev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -53,6 +53,22 @@ func (in *AppRollout) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppRolloutHistoryEntry) DeepCopyInto(out *AppRolloutHistoryEntry) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppRolloutHistoryEntry.
+func (in *AppRolloutHistoryEntry) DeepCopy() *AppRolloutHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(AppRolloutHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppRolloutList) DeepCopyInto(out *AppRolloutList) {
 	*out = *in
@@ -99,6 +115,11 @@ func (in *AppRolloutSpec) DeepCopyInto(out *AppRolloutSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.RollbackOnFailure != nil {
+		in, out := &in.RollbackOnFailure, &out.RollbackOnFailure
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppRolloutSpec.
@@ -115,6 +136,25 @@ func (in *AppRolloutSpec) DeepCopy() *AppRolloutSpec {
 func (in *AppRolloutStatus) DeepCopyInto(out *AppRolloutStatus) {
 	*out = *in
 	in.RolloutStatus.DeepCopyInto(&out.RolloutStatus)
+	if in.RollbackStatus != nil {
+		in, out := &in.RollbackStatus, &out.RollbackStatus
+		*out = new(v1alpha1.RolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]AppRolloutHistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ComponentStatuses != nil {
+		in, out := &in.ComponentStatuses, &out.ComponentStatuses
+		*out = make([]ComponentRolloutStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppRolloutStatus.
@@ -133,7 +173,7 @@ func (in *AppStatus) DeepCopyInto(out *AppStatus) {
 	in.RolloutStatus.DeepCopyInto(&out.RolloutStatus)
 	if in.Components != nil {
 		in, out := &in.Components, &out.Components
-		*out = make([]v1alpha1.TypedReference, len(*in))
+		*out = make([]corev1alpha1.TypedReference, len(*in))
 		copy(*out, *in)
 	}
 	if in.Services != nil {
@@ -425,6 +465,22 @@ func (in *ApplicationList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationScope) DeepCopyInto(out *ApplicationScope) {
+	*out = *in
+	in.Properties.DeepCopyInto(&out.Properties)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationScope.
+func (in *ApplicationScope) DeepCopy() *ApplicationScope {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationSpec) DeepCopyInto(out *ApplicationSpec) {
 	*out = *in
@@ -435,9 +491,16 @@ func (in *ApplicationSpec) DeepCopyInto(out *ApplicationSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]ApplicationScope, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.RolloutPlan != nil {
 		in, out := &in.RolloutPlan, &out.RolloutPlan
-		*out = new(standard_oam_devv1alpha1.RolloutPlan)
+		*out = new(v1alpha1.RolloutPlan)
 		(*in).DeepCopyInto(*out)
 	}
 }
@@ -754,6 +817,22 @@ func (in *ComponentParameterValue) DeepCopy() *ComponentParameterValue {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentRolloutStatus) DeepCopyInto(out *ComponentRolloutStatus) {
+	*out = *in
+	in.RolloutStatus.DeepCopyInto(&out.RolloutStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentRolloutStatus.
+func (in *ComponentRolloutStatus) DeepCopy() *ComponentRolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentRolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComponentScope) DeepCopyInto(out *ComponentScope) {
 	*out = *in
@@ -1187,7 +1266,7 @@ func (in *ContainerizedWorkloadStatus) DeepCopyInto(out *ContainerizedWorkloadSt
 	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
-		*out = make([]v1alpha1.TypedReference, len(*in))
+		*out = make([]corev1alpha1.TypedReference, len(*in))
 		copy(*out, *in)
 	}
 }
@@ -1547,7 +1626,7 @@ func (in *HealthScopeSpec) DeepCopyInto(out *HealthScopeSpec) {
 	}
 	if in.WorkloadReferences != nil {
 		in, out := &in.WorkloadReferences, &out.WorkloadReferences
-		*out = make([]v1alpha1.TypedReference, len(*in))
+		*out = make([]corev1alpha1.TypedReference, len(*in))
 		copy(*out, *in)
 	}
 }
@@ -1832,6 +1911,11 @@ func (in *ScopeDefinitionList) DeepCopyObject() runtime.Object {
 func (in *ScopeDefinitionSpec) DeepCopyInto(out *ScopeDefinitionSpec) {
 	*out = *in
 	out.Reference = in.Reference
+	if in.Schematic != nil {
+		in, out := &in.Schematic, &out.Schematic
+		*out = new(Schematic)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Extension != nil {
 		in, out := &in.Extension, &out.Extension
 		*out = new(runtime.RawExtension)
@@ -2,6 +2,7 @@ package v1alpha1
 
 import (
 	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
@@ -115,6 +116,37 @@ type RolloutPlan struct {
 	// before complete the process
 	// +optional
 	CanaryMetric []CanaryMetric `json:"canaryMetric,omitempty"`
+
+	// TrafficRouting shifts the traffic between the source and the target services
+	// according to the traffic weight of each batch, it only works with a rollout of a single component
+	// +optional
+	TrafficRouting *TrafficRouting `json:"trafficRouting,omitempty"`
+}
+
+// TrafficRoutingProvider is the type of the resource that routes the traffic
+type TrafficRoutingProvider string
+
+const (
+	// IstioTrafficRouting shifts the traffic with an Istio VirtualService
+	IstioTrafficRouting TrafficRoutingProvider = "istio"
+	// SMITrafficRouting shifts the traffic with an SMI TrafficSplit
+	SMITrafficRouting TrafficRoutingProvider = "smi"
+)
+
+// TrafficRouting describes the resource that splits the traffic between the source and the target
+type TrafficRouting struct {
+	// Provider is the type of the resource that routes the traffic
+	// +kubebuilder:validation:Enum=istio;smi
+	Provider TrafficRoutingProvider `json:"provider"`
+
+	// Name of the VirtualService or TrafficSplit, it has to be in the same namespace as the workloads
+	Name string `json:"name"`
+
+	// SourceService is the service that selects the pods of the source workload
+	SourceService string `json:"sourceService"`
+
+	// TargetService is the service that selects the pods of the target workload
+	TargetService string `json:"targetService"`
 }
 
 // RolloutBatch is used to describe how the each batch rollout should be
@@ -150,8 +182,30 @@ type RolloutBatch struct {
 	// before moving to the next batch
 	// +optional
 	CanaryMetric []CanaryMetric `json:"canaryMetric,omitempty"`
+
+	// RequireApproval holds the rollout after this batch is ready until someone approves or rejects it
+	// It has no effect on the last batch
+	// +optional
+	RequireApproval bool `json:"requireApproval,omitempty"`
+
+	// TrafficWeight is the percentage of the traffic that goes to the target service after this batch
+	// The traffic stays the same if it's not set. It only applies when the plan has traffic routing
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	TrafficWeight *int32 `json:"trafficWeight,omitempty"`
 }
 
+// BatchApprovalDecision is the manual decision on a rollout batch that requires approval
+type BatchApprovalDecision string
+
+const (
+	// BatchApproved means the rollout can move on to the next batch
+	BatchApproved BatchApprovalDecision = "approved"
+	// BatchRejected means the rollout should fail
+	BatchRejected BatchApprovalDecision = "rejected"
+)
+
 // RolloutWebhook holds the reference to external checks used for canary analysis
 type RolloutWebhook struct {
 	// Type of this webhook
@@ -172,6 +226,46 @@ type RolloutWebhook struct {
 	// Metadata (key-value pairs) for this webhook
 	// +optional
 	Metadata *map[string]string `json:"metadata,omitempty"`
+
+	// TimeoutSeconds is the timeout of one call to the webhook, default is 10 seconds
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// RetryLimit is the number of times we call the webhook again when the call fails or the webhook asks
+	// for a retry before we fail the rollout. The rollout keeps retrying if it's not set
+	// +optional
+	RetryLimit *int32 `json:"retryLimit,omitempty"`
+
+	// SigningSecretRef references the key of a secret that holds the HMAC-SHA256 key to sign the request with
+	// the signature of the request body is sent in the X-Vela-Signature header. The secret has to be in the namespace
+	// of the rollout
+	// +optional
+	SigningSecretRef *runtimev1alpha1.SecretKeySelector `json:"signingSecretRef,omitempty"`
+}
+
+// WebhookDecision is the decision of a webhook on the rollout
+type WebhookDecision string
+
+const (
+	// WebhookProceed means the rollout can move on
+	WebhookProceed WebhookDecision = "proceed"
+	// WebhookRetry means the webhook should be called again later, it counts towards the retry limit
+	WebhookRetry WebhookDecision = "retry"
+	// WebhookPause means the rollout should wait with the BatchPaused condition set and call the webhook
+	// again later without a limit, the condition is cleared once the webhook lets the rollout proceed
+	WebhookPause WebhookDecision = "pause"
+	// WebhookAbort means the rollout should fail
+	WebhookAbort WebhookDecision = "abort"
+)
+
+// RolloutWebhookResponse is the body a webhook can reply with to decide on the rollout
+// the decision is derived from the http status if the body does not contain one
+type RolloutWebhookResponse struct {
+	// Decision of the webhook
+	Decision WebhookDecision `json:"decision,omitempty"`
+
+	// Message explains the decision
+	Message string `json:"message,omitempty"`
 }
 
 // RolloutWebhookPayload holds the info and metadata sent to webhooks
@@ -189,23 +283,51 @@ type RolloutWebhookPayload struct {
 	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
+// MetricProviderType is the type of the metric provider
+type MetricProviderType string
+
+const (
+	// PrometheusMetricProvider queries the metrics through a Prometheus compatible HTTP API
+	PrometheusMetricProvider MetricProviderType = "prometheus"
+)
+
 // CanaryMetric holds the reference to metrics used for canary analysis
 type CanaryMetric struct {
 	// Name of the metric
 	Name string `json:"name"`
 
-	// Interval represents the windows size
+	// Interval represents the windows size, default is 1m.
+	// The metric has to stay within the expected range over the whole interval
 	Interval string `json:"interval,omitempty"`
 
+	// Query is the query sent to the metric provider, it has to return a single series.
+	// The query is a go template with the `.Name` and `.Namespace` of the target workload and the `.Interval` available
+	// +optional
+	Query string `json:"query,omitempty"`
+
+	// MetricProvider is where we query the metric from
+	// +optional
+	MetricProvider *MetricProvider `json:"metricProvider,omitempty"`
+
 	// Range value accepted for this metric
 	// +optional
 	MetricsRange *MetricsExpectedRange `json:"metricsRange,omitempty"`
 
-	// TemplateRef references a metric template object
+	// TemplateRef references a metric template object, it's not supported yet
 	// +optional
 	TemplateRef *runtimev1alpha1.TypedReference `json:"templateRef,omitempty"`
 }
 
+// MetricProvider describes how to reach a metric server
+type MetricProvider struct {
+	// Type of the metric provider, default is prometheus
+	// +optional
+	Type MetricProviderType `json:"type,omitempty"`
+
+	// Address of the metric server, ex: http://prometheus.monitoring:9090
+	Address string `json:"address"`
+}
+
 // MetricsExpectedRange defines the range used for metrics validation
 type MetricsExpectedRange struct {
 	// Minimum value
@@ -251,4 +373,62 @@ type RolloutStatus struct {
 
 	// UpgradedReadyReplicas is the number of Pods upgraded by the rollout controller that have a Ready Condition.
 	UpgradedReadyReplicas int32 `json:"upgradedReadyReplicas"`
+
+	// MetricEvaluations records the last evaluation result of each canary metric
+	// +optional
+	MetricEvaluations []CanaryMetricEvaluation `json:"metricEvaluations,omitempty"`
+
+	// WebhookStatuses records the last decision of each rollout webhook
+	// +optional
+	WebhookStatuses []RolloutWebhookStatus `json:"webhookStatuses,omitempty"`
+}
+
+// RolloutWebhookStatus is the result of calling a rollout webhook
+type RolloutWebhookStatus struct {
+	// Name of the webhook
+	Name string `json:"name"`
+
+	// Phase of the rollout when the webhook is called
+	Phase string `json:"phase"`
+
+	// Decision of the webhook
+	Decision WebhookDecision `json:"decision"`
+
+	// Message contains the details of the decision
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Retries is the number of consecutive calls that failed or asked for a retry
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
+
+	// LastCallTime is the last time the webhook is called
+	LastCallTime metav1.Time `json:"lastCallTime"`
+}
+
+// CanaryMetricEvaluation is the result of evaluating a canary metric
+type CanaryMetricEvaluation struct {
+	// Name of the metric
+	Name string `json:"name"`
+
+	// Batch is the batch during which the metric is evaluated
+	Batch int32 `json:"batch"`
+
+	// Value is the value returned by the metric provider
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// Succeeded indicates if the value is within the expected range
+	Succeeded bool `json:"succeeded"`
+
+	// Message contains the details of the evaluation
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Retries is the number of consecutive evaluations that failed to get the value of the metric
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
+
+	// LastEvaluationTime is the last time the metric is evaluated
+	LastEvaluationTime metav1.Time `json:"lastEvaluationTime"`
 }
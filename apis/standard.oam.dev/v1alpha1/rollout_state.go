@@ -76,6 +76,10 @@ const (
 	BatchInitializing runtimev1alpha1.ConditionType = "BatchInitializing"
 	// BatchPaused
 	BatchPaused runtimev1alpha1.ConditionType = "BatchPaused"
+	// RolloutWebhookDecided records the last decision of the rollout webhooks
+	RolloutWebhookDecided runtimev1alpha1.ConditionType = "RolloutWebhookDecided"
+	// BatchApproval
+	BatchApproval runtimev1alpha1.ConditionType = "BatchApproval"
 	// BatchVerifying
 	BatchVerifying runtimev1alpha1.ConditionType = "BatchVerifying"
 	// BatchRolloutFailed
@@ -187,6 +191,52 @@ func (r *RolloutStatus) ResetStatus() {
 	r.CurrentBatch = 0
 	r.UpgradedReplicas = 0
 	r.UpgradedReadyReplicas = 0
+	r.MetricEvaluations = nil
+	r.WebhookStatuses = nil
+}
+
+// RecordMetricEvaluation records the result of a canary metric evaluation, replacing the last result
+// of the metric with the same name
+func (r *RolloutStatus) RecordMetricEvaluation(evaluation CanaryMetricEvaluation) {
+	for i, existing := range r.MetricEvaluations {
+		if existing.Name == evaluation.Name {
+			r.MetricEvaluations[i] = evaluation
+			return
+		}
+	}
+	r.MetricEvaluations = append(r.MetricEvaluations, evaluation)
+}
+
+// GetMetricEvaluation returns the last evaluation result of the canary metric with the given name
+func (r *RolloutStatus) GetMetricEvaluation(name string) *CanaryMetricEvaluation {
+	for i, existing := range r.MetricEvaluations {
+		if existing.Name == name {
+			return &r.MetricEvaluations[i]
+		}
+	}
+	return nil
+}
+
+// RecordWebhookStatus records the result of a webhook call, replacing the last result of the webhook
+// with the same name in the same phase
+func (r *RolloutStatus) RecordWebhookStatus(webhookStatus RolloutWebhookStatus) {
+	for i, existing := range r.WebhookStatuses {
+		if existing.Name == webhookStatus.Name && existing.Phase == webhookStatus.Phase {
+			r.WebhookStatuses[i] = webhookStatus
+			return
+		}
+	}
+	r.WebhookStatuses = append(r.WebhookStatuses, webhookStatus)
+}
+
+// GetWebhookStatus returns the last result of the webhook with the given name in the given phase
+func (r *RolloutStatus) GetWebhookStatus(name, phase string) *RolloutWebhookStatus {
+	for i, existing := range r.WebhookStatuses {
+		if existing.Name == name && existing.Phase == phase {
+			return &r.WebhookStatuses[i]
+		}
+	}
+	return nil
 }
 
 // SetRolloutCondition sets the supplied condition, replacing any existing condition
@@ -235,6 +285,14 @@ func (r *RolloutStatus) StateTransition(event RolloutEvent) {
 		panic(fmt.Errorf(invalidRollingStateTransition, rollingState, event))
 	}
 
+	// the rollout starts over in whatever state it is when the workload is modified
+	// it's up to the caller to clean up the workloads before that
+	if event == WorkloadModifiedEvent {
+		r.SetRolloutCondition(NewNegativeCondition(r.getRolloutConditionType(), "Rollout Spec is modified"))
+		r.ResetStatus()
+		return
+	}
+
 	switch rollingState {
 	case VerifyingSpecState:
 		if event == RollingSpecVerifiedEvent {
@@ -273,20 +331,7 @@ func (r *RolloutStatus) StateTransition(event RolloutEvent) {
 		}
 		panic(fmt.Errorf(invalidRollingStateTransition, rollingState, event))
 
-	case RolloutSucceedState:
-		if event == WorkloadModifiedEvent {
-			r.SetRolloutCondition(NewNegativeCondition(r.getRolloutConditionType(), "Rollout Spec is modified"))
-			r.ResetStatus()
-			return
-		}
-		panic(fmt.Errorf(invalidRollingStateTransition, rollingState, event))
-
-	case RolloutFailedState:
-		if event == WorkloadModifiedEvent {
-			r.SetRolloutCondition(NewNegativeCondition(r.getRolloutConditionType(), "Rollout Spec is modified"))
-			r.ResetStatus()
-			return
-		}
+	case RolloutSucceedState, RolloutFailedState:
 		panic(fmt.Errorf(invalidRollingStateTransition, rollingState, event))
 
 	default:
@@ -38,15 +38,17 @@ type CRDInfo struct {
 
 // Capability defines the content of a capability
 type Capability struct {
-	Name           string      `json:"name"`
-	Type           CapType     `json:"type"`
-	CueTemplate    string      `json:"template,omitempty"`
-	CueTemplateURI string      `json:"templateURI,omitempty"`
-	Parameters     []Parameter `json:"parameters,omitempty"`
-	CrdName        string      `json:"crdName,omitempty"`
-	Center         string      `json:"center,omitempty"`
-	Status         string      `json:"status,omitempty"`
-	Description    string      `json:"description,omitempty"`
+	Name           string  `json:"name"`
+	Type           CapType `json:"type"`
+	CueTemplate    string  `json:"template,omitempty"`
+	CueTemplateURI string  `json:"templateURI,omitempty"`
+	// CueTemplateDigest pins the content of the template fetched from CueTemplateURI
+	CueTemplateDigest string      `json:"templateDigest,omitempty"`
+	Parameters        []Parameter `json:"parameters,omitempty"`
+	CrdName           string      `json:"crdName,omitempty"`
+	Center            string      `json:"center,omitempty"`
+	Status            string      `json:"status,omitempty"`
+	Description       string      `json:"description,omitempty"`
 
 	// trait only
 	AppliesTo []string `json:"appliesTo,omitempty"`
@@ -1,5 +1,7 @@
 package types
 
+import corev1 "k8s.io/api/core/v1"
+
 const (
 	// DefaultKubeVelaNS defines the default KubeVela namespace in Kubernetes
 	DefaultKubeVelaNS = "vela-system"
@@ -37,8 +39,43 @@ type EnvMeta struct {
 	// Below are not arguments, should be auto-generated
 	Issuer  string `json:"issuer"`
 	Current string `json:"current,omitempty"`
+	// Source is where the env is stored, the envs created by older versions of vela are stored locally
+	Source string `json:"source,omitempty"`
+
+	Policy *EnvPolicy `json:"policy,omitempty"`
 }
 
+// EnvPolicy is the policy of an env, it's applied to the namespace of the env and the applications deployed to it
+type EnvPolicy struct {
+	// DefaultTraits are attached to every component deployed to the env unless the component has a trait of the same type
+	DefaultTraits []EnvTrait `json:"defaultTraits,omitempty"`
+	// ResourceQuota is the hard limit of the ResourceQuota created in the namespace of the env
+	ResourceQuota corev1.ResourceList `json:"resourceQuota,omitempty"`
+	// LimitRange is the limits of the LimitRange created in the namespace of the env
+	LimitRange []corev1.LimitRangeItem `json:"limitRange,omitempty"`
+	// NamespaceLabels and NamespaceAnnotations are required on the namespace of the env
+	NamespaceLabels      map[string]string `json:"namespaceLabels,omitempty"`
+	NamespaceAnnotations map[string]string `json:"namespaceAnnotations,omitempty"`
+	// AllowedWorkloads and AllowedTraits are the workload and trait types allowed in the env, all types are allowed if empty
+	AllowedWorkloads []string `json:"allowedWorkloads,omitempty"`
+	AllowedTraits    []string `json:"allowedTraits,omitempty"`
+}
+
+// EnvTrait is a trait attached to the components by an env
+type EnvTrait struct {
+	Name       string                 `json:"name"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+const (
+	// EnvSourceCluster means the env is stored in the cluster and shared by everyone using the cluster
+	EnvSourceCluster = "cluster"
+	// EnvSourceLocal means the env is stored in the local vela home directory, it should be migrated to the cluster
+	EnvSourceLocal = "local"
+	// EnvSourceBuiltin means the env is the built-in default env which isn't stored anywhere
+	EnvSourceBuiltin = "built-in"
+)
+
 const (
 	// TagCommandType used for tag cli category
 	TagCommandType = "commandType"
@@ -36,6 +36,8 @@ import (
 	oamv1alpha2 "github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/pkg/controller/utils"
 	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/terraform"
+	"github.com/oam-dev/kubevela/pkg/utils/remote"
 	"github.com/oam-dev/kubevela/pkg/utils/system"
 	oamwebhook "github.com/oam-dev/kubevela/pkg/webhook/core.oam.dev"
 	velawebhook "github.com/oam-dev/kubevela/pkg/webhook/standard.oam.dev"
@@ -97,11 +99,19 @@ func main() {
 		"For the purpose of some production environment that workload or trait should not be affected if no spec change, available options: on, off, force.")
 	flag.StringVar(&controllerArgs.CustomRevisionHookURL, "custom-revision-hook-url", "",
 		"custom-revision-hook-url is a webhook url which will let KubeVela core to call with applicationConfiguration and component info and return a customized component revision")
+	flag.StringVar(&controllerArgs.TerraformImage, "terraform-image", terraform.DefaultImage,
+		"The image of the Jobs that apply the Terraform components, it must have both terraform and sh")
+	flag.StringVar(&controllerArgs.TerraformServiceAccount, "terraform-service-account", "",
+		"The service account of the Jobs that apply the Terraform components, it must be able to manage the Secrets and Leases to store the terraform state")
+	flag.StringVar(&controllerArgs.TerraformCredentialSecret, "terraform-credential-secret", "",
+		"The Secret in the namespace of the application whose data are exported as environment variables to terraform, like the credentials of the cloud provider")
 	flag.StringVar(&disableCaps, "disable-caps", "", "To be disabled builtin capability list.")
 	flag.StringVar(&storageDriver, "storage-driver", "Local", "Application file save to the storage driver")
 	flag.DurationVar(&syncPeriod, "informer-re-sync-interval", 5*time.Minute,
 		"controller shared informer lister full re-sync period")
 	flag.StringVar(&oam.SystemDefinitonNamespace, "system-definition-namespace", "vela-system", "define the namespace of the system-level definition")
+	flag.DurationVar(&remote.DefaultCache.TTL, "template-cache-ttl", remote.DefaultTTL,
+		"how long the definition templates fetched from templateURI are cached before fetched again, templates pinned by templateDigest are cached forever")
 	flag.Parse()
 
 	// setup logging
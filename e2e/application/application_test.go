@@ -2,6 +2,7 @@ package e2e
 
 import (
 	context2 "context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -54,6 +55,15 @@ var ApplicationStatusContext = func(context string, applicationName string, work
 			gomega.Expect(output).To(gomega.ContainSubstring(applicationName))
 			// TODO(roywang) add more assertion to check health status
 		})
+
+		ginkgo.It("should get status for the application in json", func() {
+			cli := fmt.Sprintf("vela status %s -o json", applicationName)
+			output, err := e2e.Exec(cli)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			report := map[string]interface{}{}
+			gomega.Expect(json.Unmarshal([]byte(output), &report)).Should(gomega.Succeed())
+			gomega.Expect(report["name"]).To(gomega.Equal(applicationName))
+		})
 	})
 }
 
@@ -24,6 +24,7 @@ var _ = ginkgo.Describe("Env", func() {
 			gomega.Expect(err).NotTo(gomega.HaveOccurred())
 			gomega.Expect(output).To(gomega.ContainSubstring("NAME"))
 			gomega.Expect(output).To(gomega.ContainSubstring("NAMESPACE"))
+			gomega.Expect(output).To(gomega.ContainSubstring("SOURCE"))
 			gomega.Expect(output).To(gomega.ContainSubstring(envName))
 			gomega.Expect(output).To(gomega.ContainSubstring(envName2))
 		})
@@ -8,6 +8,7 @@ output: {
 
 		template: {
 			metadata: labels: {
+				"app.oam.dev/name":      context.appName
 				"app.oam.dev/component": context.name
 			}
 
@@ -52,7 +52,7 @@ func (l *Local) GetConfigData(configName, envName string) ([]map[string]string,
 
 // Namespace return namespace from env
 func (l *Local) Namespace(envName string) (string, error) {
-	env, err := env2.GetEnvByName(envName)
+	env, err := env2.GetEnvByNameLocally(envName)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,422 @@
+package appfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/cue/token"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/dsl/model"
+	"github.com/oam-dev/kubevela/pkg/dsl/process"
+)
+
+const (
+	contextRef          = "context"
+	componentsRef       = process.ContextComponents
+	componentOutputsRef = process.ContextComponentOutputs
+)
+
+// dependencies returns the names of the components that the templates of the workload and its traits refer to
+// by `context.components.<name>` or `context.componentOutputs.<name>`
+func (wl *Workload) dependencies() ([]string, error) {
+	templates := []string{wl.Template}
+	for _, tr := range wl.Traits {
+		templates = append(templates, tr.Template)
+	}
+	var deps []string
+	found := map[string]bool{}
+	for _, template := range templates {
+		if len(template) == 0 {
+			continue
+		}
+		f, err := parser.ParseFile("-", template)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid template of component %s", wl.Name)
+		}
+		ast.Walk(f, func(node ast.Node) bool {
+			expr, ok := node.(ast.Expr)
+			if !ok {
+				return true
+			}
+			if path, ok := referencePath(expr); ok {
+				if path, ok = componentPath(path); ok && !found[path[2].key] {
+					found[path[2].key] = true
+					deps = append(deps, path[2].key)
+				}
+			}
+			return true
+		}, nil)
+	}
+	return deps, nil
+}
+
+// SortWorkloads returns the workloads in an order that every workload comes after the workloads it refers to,
+// the workloads keep the order in the application otherwise. A workload can't refer to a Terraform workload.
+func SortWorkloads(workloads []*Workload) ([]*Workload, error) {
+	byName := make(map[string]*Workload, len(workloads))
+	for _, wl := range workloads {
+		byName[wl.Name] = wl
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(workloads))
+	sorted := make([]*Workload, 0, len(workloads))
+	var visit func(wl *Workload, path []string) error
+	visit = func(wl *Workload, path []string) error {
+		path = append(path, wl.Name)
+		switch state[wl.Name] {
+		case visiting:
+			return errors.Errorf("the components form a dependency cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[wl.Name] = visiting
+		deps, err := wl.dependencies()
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			depWorkload, ok := byName[dep]
+			if !ok {
+				return errors.Errorf("the component %s refers to the component %s which does not exist", wl.Name, dep)
+			}
+			if depWorkload.CapabilityCategory == types.TerraformCategory {
+				// the Terraform components are applied by the terraform executor, they don't render anything to refer to
+				return errors.Errorf("the component %s refers to the Terraform component %s, "+
+					"read its outputs from the secret written by the terraform executor instead", wl.Name, dep)
+			}
+			if err := visit(depWorkload, path); err != nil {
+				return err
+			}
+		}
+		state[wl.Name] = visited
+		sorted = append(sorted, wl)
+		return nil
+	}
+	for _, wl := range workloads {
+		if err := visit(wl, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// newRenderedComponent collects the resources rendered in the context so that the other components can refer to them
+func newRenderedComponent(pCtx process.Context) process.RenderedComponent {
+	base, assists := pCtx.Output()
+	rendered := process.RenderedComponent{
+		Output:  base,
+		Outputs: make(map[string]model.Instance, len(assists)),
+	}
+	for _, assist := range assists {
+		if assist.Name != "" {
+			rendered.Outputs[assist.Name] = assist.Ins
+		}
+	}
+	return rendered
+}
+
+// runtimeReference is a reference to a field of the main workload of another component
+// whose value is only known after the component is applied, e.g. the status of the workload
+type runtimeReference struct {
+	// component is the name of the component referred to
+	component string
+	// fieldPath is the path of the field in the main workload of the component referred to
+	fieldPath string
+	// toFieldPath is the path of the field that refers to the value
+	toFieldPath string
+}
+
+// dataOutputName returns the name of the DataOutput that emits the value referred to
+func (ref runtimeReference) dataOutputName() string {
+	return fmt.Sprintf("%s-%s", ref.component, ref.fieldPath)
+}
+
+func (ref runtimeReference) dataOutput() v1alpha2.DataOutput {
+	return v1alpha2.DataOutput{Name: ref.dataOutputName(), FieldPath: ref.fieldPath}
+}
+
+func (ref runtimeReference) dataInput() v1alpha2.DataInput {
+	return v1alpha2.DataInput{
+		ValueFrom:    v1alpha2.DataInputValueFrom{DataOutputName: ref.dataOutputName()},
+		ToFieldPaths: []string{ref.toFieldPath},
+	}
+}
+
+// EvalContexts renders the workloads and traits of the application in the order of their dependencies,
+// the fields that refer to the runtime values of other components are removed from the rendered resources.
+// It returns the contexts keyed by the name of the components. The contexts rendered by
+// GenerateApplicationConfiguration are reused so that the processing steps, which may send requests and read
+// the cluster, don't run again.
+func (af *Appfile) EvalContexts(cli client.Reader, ns string) (map[string]process.Context, error) {
+	if af.contexts != nil {
+		return af.contexts, nil
+	}
+	workloads, err := SortWorkloads(af.Workloads)
+	if err != nil {
+		return nil, err
+	}
+	contexts := make(map[string]process.Context, len(workloads))
+	rendered := make(map[string]process.RenderedComponent, len(workloads))
+	for _, wl := range workloads {
+		pCtx := process.NewContext(wl.Name, af.Name, af.RevisionName)
+		pCtx.SetClient(cli, ns)
+		pCtx.SetAppInfo(af.AppInfo(rendered))
+		if err := wl.EvalContext(pCtx); err != nil {
+			return nil, errors.WithMessagef(err, "app=%s, comp=%s, evaluate context error", af.Name, wl.Name)
+		}
+		for _, tr := range wl.Traits {
+			if err := tr.EvalContext(pCtx); err != nil {
+				return nil, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, evaluate context error", af.Name, wl.Name, tr.Name)
+			}
+		}
+		if _, _, err := extractContextReferences(pCtx); err != nil {
+			return nil, errors.WithMessagef(err, "app=%s, comp=%s, resolve the references to other components", af.Name, wl.Name)
+		}
+		contexts[wl.Name] = pCtx
+		rendered[wl.Name] = newRenderedComponent(pCtx)
+	}
+	return contexts, nil
+}
+
+// extractContextReferences removes the fields that refer to the runtime values of other components from the
+// resources rendered in the context, it returns the references of the main workload and the auxiliaries by their index
+func extractContextReferences(pCtx process.Context) ([]runtimeReference, map[int][]runtimeReference, error) {
+	base, assists := pCtx.Output()
+	base, workloadRefs, err := extractRuntimeReferences(base)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "invalid output")
+	}
+	pCtx.SetBase(base)
+	auxiliaryRefs := map[int][]runtimeReference{}
+	for i := range assists {
+		ins, refs, err := extractRuntimeReferences(assists[i].Ins)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "invalid outputs(resource=%s)", assists[i].Name)
+		}
+		if len(refs) > 0 {
+			// the auxiliaries share the same underlying array with the context
+			assists[i].Ins = ins
+			auxiliaryRefs[i] = refs
+		}
+	}
+	return workloadRefs, auxiliaryRefs, nil
+}
+
+// extractRuntimeReferences removes the fields whose value is a reference to `context.components.<name>.output`
+// that can't be resolved when rendering, the fields will be filled by DataInputs once the values are ready
+func extractRuntimeReferences(ins model.Instance) (model.Instance, []runtimeReference, error) {
+	if ins == nil {
+		return ins, nil, nil
+	}
+	f, err := parser.ParseFile("-", ins.String(), parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	var refs []runtimeReference
+	f.Decls, err = extractFromDecls(f.Decls, nil, &refs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(refs) == 0 {
+		return ins, nil, nil
+	}
+	b, err := format.Node(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	var r cue.Runtime
+	inst, err := r.Compile("-", string(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	var extracted model.Instance
+	if ins.IsBase() {
+		extracted, err = model.NewBase(inst.Value())
+	} else {
+		extracted, err = model.NewOther(inst.Value())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return extracted, refs, nil
+}
+
+func extractFromDecls(decls []ast.Decl, path []pathSegment, refs *[]runtimeReference) ([]ast.Decl, error) {
+	var kept []ast.Decl
+	for _, decl := range decls {
+		field, ok := decl.(*ast.Field)
+		if !ok {
+			kept = append(kept, decl)
+			continue
+		}
+		fieldPath := append(append([]pathSegment{}, path...), pathSegment{key: unquote(labelName(field.Label))})
+		ref, isRef, err := extractFromExpr(field.Value, fieldPath, refs)
+		if err != nil {
+			return nil, err
+		}
+		if isRef {
+			*refs = append(*refs, *ref)
+			continue
+		}
+		kept = append(kept, decl)
+	}
+	return kept, nil
+}
+
+// extractFromExpr returns the runtime reference if the expression is a reference to the main workload of a component,
+// it removes the runtime references from the struct and list in the expression
+func extractFromExpr(expr ast.Expr, path []pathSegment, refs *[]runtimeReference) (*runtimeReference, bool, error) {
+	switch x := expr.(type) {
+	case *ast.StructLit:
+		elts, err := extractFromDecls(x.Elts, path, refs)
+		if err != nil {
+			return nil, false, err
+		}
+		x.Elts = elts
+		return nil, false, nil
+	case *ast.ListLit:
+		for i, elt := range x.Elts {
+			eltPath := append(append([]pathSegment{}, path...), pathSegment{index: i, isIndex: true})
+			ref, isRef, err := extractFromExpr(elt, eltPath, refs)
+			if err != nil {
+				return nil, false, err
+			}
+			if isRef {
+				return nil, false, errors.Errorf("%s refers to the runtime value %s of the component %s, "+
+					"a list item can't refer to the runtime value", toFieldPath(eltPath), ref.fieldPath, ref.component)
+			}
+		}
+		return nil, false, nil
+	}
+
+	if refPath, ok := referencePath(expr); ok {
+		if refPath, ok = componentPath(refPath); ok {
+			if len(refPath) < 5 || refPath[3].key != process.OutputFieldName {
+				return nil, false, errors.Errorf("%s refers to a value of the component %s which is not in its output",
+					toFieldPath(path), refPath[2].key)
+			}
+			return &runtimeReference{
+				component:   refPath[2].key,
+				fieldPath:   toFieldPath(refPath[4:]),
+				toFieldPath: toFieldPath(path),
+			}, true, nil
+		}
+	}
+
+	var refErr error
+	ast.Walk(expr, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && ident.Name == contextRef && refErr == nil {
+			refErr = errors.Errorf("%s refers to a runtime value of other components in an expression, "+
+				"only the value of a field can refer to it", toFieldPath(path))
+		}
+		return refErr == nil
+	}, nil)
+	return nil, false, refErr
+}
+
+// componentPath returns the path of a reference to another component in the form of `context.components.<name>...`,
+// `context.componentOutputs.<name>` is the same as `context.components.<name>.output`
+func componentPath(path []pathSegment) ([]pathSegment, bool) {
+	if len(path) < 3 || path[0].key != contextRef {
+		return nil, false
+	}
+	switch path[1].key {
+	case componentsRef:
+		return path, true
+	case componentOutputsRef:
+		normalized := []pathSegment{path[0], {key: componentsRef}, path[2], {key: process.OutputFieldName}}
+		return append(normalized, path[3:]...), true
+	}
+	return nil, false
+}
+
+// pathSegment is a segment of a field path, either a field of an object or an index of a list
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// referencePath returns the path of a reference like `a.b["c"][0]`
+func referencePath(expr ast.Expr) ([]pathSegment, bool) {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		return []pathSegment{{key: unquote(x.Name)}}, true
+	case *ast.SelectorExpr:
+		path, ok := referencePath(x.X)
+		if !ok {
+			return nil, false
+		}
+		return append(path, pathSegment{key: unquote(x.Sel.Name)}), true
+	case *ast.IndexExpr:
+		path, ok := referencePath(x.X)
+		if !ok {
+			return nil, false
+		}
+		lit, ok := x.Index.(*ast.BasicLit)
+		if !ok {
+			return nil, false
+		}
+		switch lit.Kind {
+		case token.INT:
+			index, err := strconv.Atoi(lit.Value)
+			if err != nil {
+				return nil, false
+			}
+			return append(path, pathSegment{index: index, isIndex: true}), true
+		case token.STRING:
+			return append(path, pathSegment{key: unquote(lit.Value)}), true
+		}
+	}
+	return nil, false
+}
+
+// toFieldPath converts the path into the format of the field path of a Kubernetes object, e.g. `spec.ports[0].port`
+func toFieldPath(path []pathSegment) string {
+	var b strings.Builder
+	for _, seg := range path {
+		switch {
+		case seg.isIndex:
+			fmt.Fprintf(&b, "[%d]", seg.index)
+		case strings.ContainsAny(seg.key, ".[]"):
+			fmt.Fprintf(&b, "[%s]", seg.key)
+		default:
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(seg.key)
+		}
+	}
+	return b.String()
+}
+
+func labelName(label ast.Label) string {
+	switch l := label.(type) {
+	case *ast.Ident:
+		return l.Name
+	case *ast.BasicLit:
+		return l.Value
+	}
+	return ""
+}
+
+func unquote(s string) string {
+	if v, err := strconv.Unquote(s); err == nil {
+		return v
+	}
+	return s
+}
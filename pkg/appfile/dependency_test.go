@@ -0,0 +1,220 @@
+/*
+Copyright 2020 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package appfile
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/dsl/model"
+)
+
+func TestSortWorkloads(t *testing.T) {
+	refer := func(names ...string) string {
+		template := "output: {\n"
+		for _, name := range names {
+			template += fmt.Sprintf("  %s: context.components[%q].output.metadata.name\n", strings.ReplaceAll(name, "-", "_"), name)
+		}
+		return template + "}"
+	}
+	testCases := map[string]struct {
+		workloads []*Workload
+		want      []string
+		wantErr   string
+	}{
+		"keep the order without dependencies": {
+			workloads: []*Workload{{Name: "b"}, {Name: "a"}, {Name: "c"}},
+			want:      []string{"b", "a", "c"},
+		},
+		"dependencies come first": {
+			workloads: []*Workload{
+				{Name: "frontend", Template: refer("backend", "my-db")},
+				{Name: "backend", Template: refer("my-db")},
+				{Name: "my-db"},
+			},
+			want: []string{"my-db", "backend", "frontend"},
+		},
+		"dependencies by the component outputs": {
+			workloads: []*Workload{
+				{Name: "frontend", Template: "output: host: context.componentOutputs.backend.spec.host"},
+				{Name: "backend"},
+			},
+			want: []string{"backend", "frontend"},
+		},
+		"dependencies of traits": {
+			workloads: []*Workload{
+				{Name: "frontend", Traits: []*Trait{{Name: "ingress", Template: "patch: " + refer("backend")}}},
+				{Name: "backend"},
+			},
+			want: []string{"backend", "frontend"},
+		},
+		"cycle": {
+			workloads: []*Workload{
+				{Name: "frontend", Template: refer("backend")},
+				{Name: "backend", Template: refer("frontend")},
+			},
+			wantErr: "the components form a dependency cycle: frontend -> backend -> frontend",
+		},
+		"refer to itself": {
+			workloads: []*Workload{{Name: "frontend", Template: refer("frontend")}},
+			wantErr:   "the components form a dependency cycle: frontend -> frontend",
+		},
+		"refer to a terraform component": {
+			workloads: []*Workload{
+				{Name: "frontend", Template: "output: bucket: context.components.oss.output.bucket"},
+				{Name: "oss", CapabilityCategory: types.TerraformCategory},
+			},
+			wantErr: "the component frontend refers to the Terraform component oss, " +
+				"read its outputs from the secret written by the terraform executor instead",
+		},
+		"unknown component": {
+			workloads: []*Workload{{Name: "frontend", Template: refer("backend")}},
+			wantErr:   "the component frontend refers to the component backend which does not exist",
+		},
+	}
+	for name, tc := range testCases {
+		got, err := SortWorkloads(tc.workloads)
+		if len(tc.wantErr) != 0 {
+			assert.EqualError(t, err, tc.wantErr, name)
+			continue
+		}
+		assert.NoError(t, err, name)
+		var names []string
+		for _, wl := range got {
+			names = append(names, wl.Name)
+		}
+		assert.Equal(t, tc.want, names, name)
+	}
+}
+
+func TestExtractRuntimeReferences(t *testing.T) {
+	testCases := map[string]struct {
+		output   string
+		want     string
+		wantRefs []runtimeReference
+		wantErr  string
+	}{
+		"no reference": {
+			output: `spec: replicas: 1`,
+			want:   `{"spec":{"replicas":1}}`,
+		},
+		"references": {
+			output: `
+metadata: annotations: "app.oam.dev/ip": context.components.backend.output.status.loadBalancer.ingress[0].ip
+spec: {
+	replicas: 1
+	host: context.components["my-db"].output.status.host
+}`,
+			want: `{"metadata":{"annotations":{}},"spec":{"replicas":1}}`,
+			wantRefs: []runtimeReference{
+				{component: "backend", fieldPath: "status.loadBalancer.ingress[0].ip", toFieldPath: "metadata.annotations[app.oam.dev/ip]"},
+				{component: "my-db", fieldPath: "status.host", toFieldPath: "spec.host"},
+			},
+		},
+		"reference by the component outputs": {
+			output: `spec: host: context.componentOutputs["my-db"].status.host`,
+			want:   `{"spec":{}}`,
+			wantRefs: []runtimeReference{
+				{component: "my-db", fieldPath: "status.host", toFieldPath: "spec.host"},
+			},
+		},
+		"reference in an expression": {
+			output:  `spec: host: "http://\(context.components.backend.output.status.ip)"`,
+			wantErr: "spec.host refers to a runtime value of other components in an expression",
+		},
+		"reference to the auxiliary": {
+			output:  `spec: host: context.components.backend.outputs.service.status.ip`,
+			wantErr: "spec.host refers to a value of the component backend which is not in its output",
+		},
+		"reference as a list item": {
+			output:  `spec: hosts: [context.components.backend.output.status.ip]`,
+			wantErr: "spec.hosts[0] refers to the runtime value status.ip of the component backend",
+		},
+	}
+	for name, tc := range testCases {
+		var r cue.Runtime
+		inst, err := r.Compile("-", `context: components: {backend: output: {}, "my-db": output: {}}
+context: componentOutputs: {backend: {}, "my-db": {}}
+output: {`+tc.output+`}`)
+		assert.NoError(t, err, name)
+		base, err := model.NewBase(inst.Lookup("output"))
+		assert.NoError(t, err, name)
+		got, refs, err := extractRuntimeReferences(base)
+		if len(tc.wantErr) != 0 {
+			assert.Error(t, err, name)
+			assert.Contains(t, err.Error(), tc.wantErr, name)
+			continue
+		}
+		assert.NoError(t, err, name)
+		assert.Equal(t, tc.wantRefs, refs, name)
+		js, err := got.Compile()
+		assert.NoError(t, err, name)
+		assert.Equal(t, tc.want, string(js), name)
+	}
+}
+
+func TestEvalContextsReusesRenderedContexts(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"region":"us-east-1"}`)
+	}))
+	defer s.Close()
+
+	af := &Appfile{
+		Name:         "myapp",
+		RevisionName: "myapp-v1",
+		Workloads: []*Workload{{
+			Name: "frontend",
+			Type: "worker",
+			Template: fmt.Sprintf(`
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	metadata: labels: region: processing.steps.cluster.output.region
+}
+processing: steps: cluster: {
+	http: {
+		method: "GET"
+		url:    "%s"
+		request: {
+			header: {}
+			trailer: {}
+		}
+	}
+	output: region?: string
+}`, s.URL),
+		}},
+	}
+	_, _, err := NewApplicationParser(nil, nil).GenerateApplicationConfiguration(af, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	contexts, err := af.EvalContexts(nil, "default")
+	assert.NoError(t, err)
+	assert.Contains(t, contexts, "frontend")
+	// the processing steps don't run again for the status of the application
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
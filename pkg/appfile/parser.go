@@ -8,6 +8,7 @@ import (
 	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
 	"github.com/pkg/errors"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -22,6 +23,7 @@ import (
 	"github.com/oam-dev/kubevela/pkg/oam"
 	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
 	"github.com/oam-dev/kubevela/pkg/oam/util"
+	"github.com/oam-dev/kubevela/pkg/terraform"
 )
 
 const (
@@ -62,6 +64,16 @@ func (wl *Workload) GetUserConfigName() string {
 	return ts
 }
 
+// joinScope adds the scope to the workload unless the workload is already in it
+func (wl *Workload) joinScope(scope Scope) {
+	for _, sc := range wl.Scopes {
+		if sc == scope {
+			return
+		}
+	}
+	wl.Scopes = append(wl.Scopes, scope)
+}
+
 // EvalContext eval workload template and set result to context
 func (wl *Workload) EvalContext(ctx process.Context) error {
 	return definition.NewWorkloadAbstractEngine(wl.Name).Params(wl.Params).Complete(ctx, wl.Template)
@@ -73,7 +85,7 @@ func (wl *Workload) EvalStatus(ctx process.Context, cli client.Client, ns string
 }
 
 // EvalHealth eval workload health check
-func (wl *Workload) EvalHealth(ctx process.Context, client client.Client, namespace string) (bool, error) {
+func (wl *Workload) EvalHealth(ctx process.Context, client client.Client, namespace string) (*definition.HealthResult, error) {
 	return definition.NewWorkloadAbstractEngine(wl.Name).HealthCheck(ctx, client, namespace, wl.HealthCheckPolicy)
 }
 
@@ -83,6 +95,22 @@ type Scope struct {
 	GVK  schema.GroupVersionKind
 }
 
+// ApplicationScope is an application-level scope that all the workloads join
+type ApplicationScope struct {
+	Name   string
+	Type   string
+	GVK    schema.GroupVersionKind
+	Params map[string]interface{}
+
+	// Template is empty if the scope refers to an existing scope instance
+	Template string
+}
+
+// EvalContext eval scope template and set result to context
+func (scope *ApplicationScope) EvalContext(ctx process.Context) error {
+	return definition.NewScopeAbstractEngine(scope.Name).Params(scope.Params).Complete(ctx, scope.Template)
+}
+
 // Trait is ComponentTrait
 type Trait struct {
 	// The Name is name of TraitDefinition, actually it's a type of the trait instance
@@ -106,7 +134,7 @@ func (trait *Trait) EvalStatus(ctx process.Context, cli client.Client, ns string
 }
 
 // EvalHealth eval trait health check
-func (trait *Trait) EvalHealth(ctx process.Context, client client.Client, namespace string) (bool, error) {
+func (trait *Trait) EvalHealth(ctx process.Context, client client.Client, namespace string) (*definition.HealthResult, error) {
 	return definition.NewTraitAbstractEngine(trait.Name).HealthCheck(ctx, client, namespace, trait.HealthCheckPolicy)
 }
 
@@ -114,7 +142,35 @@ func (trait *Trait) EvalHealth(ctx process.Context, client client.Client, namesp
 type Appfile struct {
 	Name         string
 	RevisionName string
+	RevisionNum  int64
+	Labels       map[string]string
+	Annotations  map[string]string
 	Workloads    []*Workload
+	Scopes       []*ApplicationScope
+
+	// contexts are the contexts that GenerateApplicationConfiguration renders the components in, keyed by the names
+	// of the components
+	contexts map[string]process.Context
+}
+
+// AppInfo returns the information of the application for the templates of its components,
+// rendered are the components rendered before
+func (af *Appfile) AppInfo(rendered map[string]process.RenderedComponent) process.AppInfo {
+	components := make([]string, 0, len(af.Workloads))
+	for _, wl := range af.Workloads {
+		components = append(components, wl.Name)
+	}
+	renderedComponents := make(map[string]process.RenderedComponent, len(rendered))
+	for name, comp := range rendered {
+		renderedComponents[name] = comp
+	}
+	return process.AppInfo{
+		RevisionNum:        af.RevisionNum,
+		Labels:             af.Labels,
+		Annotations:        af.Annotations,
+		Components:         components,
+		RenderedComponents: renderedComponents,
+	}
 }
 
 // TemplateValidate validate Template format
@@ -148,8 +204,25 @@ func (p *Parser) GenerateAppFile(ctx context.Context, name string, app *v1alpha2
 		}
 		wds = append(wds, wd)
 	}
+	for _, appScope := range app.Spec.Scopes {
+		scope, err := p.parseScope(ctx, appScope)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "parse scope(%s)", appScope.Name)
+		}
+		appfile.Scopes = append(appfile.Scopes, scope)
+		// every workload joins the application-level scopes
+		for _, wd := range wds {
+			wd.joinScope(Scope{Name: scope.Name, GVK: scope.GVK})
+		}
+	}
 	appfile.Workloads = wds
-	appfile.RevisionName, _ = utils.GetAppNextRevision(app)
+	// the components must not depend on each other
+	if _, err := SortWorkloads(wds); err != nil {
+		return nil, err
+	}
+	appfile.RevisionName, appfile.RevisionNum = utils.GetAppNextRevision(app)
+	appfile.Labels = app.GetLabels()
+	appfile.Annotations = app.GetAnnotations()
 	return appfile, nil
 }
 
@@ -198,6 +271,31 @@ func (p *Parser) parseWorkload(ctx context.Context, comp v1alpha2.ApplicationCom
 	return workload, nil
 }
 
+func (p *Parser) parseScope(ctx context.Context, appScope v1alpha2.ApplicationScope) (*ApplicationScope, error) {
+	templ, err := util.LoadTemplate(ctx, p.client, appScope.Type, types.TypeScope)
+	if kerrors.IsNotFound(err) {
+		return nil, errors.Errorf("scope definition of %s not found", appScope.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	gvk, err := util.GetGVKFromDefinition(p.dm, templ.Reference)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := util.RawExtension2Map(&appScope.Properties)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "fail to parse properties of scope %s", appScope.Name)
+	}
+	return &ApplicationScope{
+		Name:     appScope.Name,
+		Type:     appScope.Type,
+		GVK:      gvk,
+		Params:   properties,
+		Template: templ.TemplateStr,
+	}, nil
+}
+
 func (p *Parser) parseTrait(ctx context.Context, name string, properties map[string]interface{}) (*Trait, error) {
 	templ, err := util.LoadTemplate(ctx, p.client, name, types.TypeTrait)
 	if kerrors.IsNotFound(err) {
@@ -230,45 +328,172 @@ func (p *Parser) GenerateApplicationConfiguration(app *Appfile, ns string) (*v1a
 	}
 	appconfig.Labels[oam.LabelAppName] = app.Name
 
-	var components []*v1alpha2.Component
-	for _, wl := range app.Workloads {
-		var comp *v1alpha2.Component
-		var acComp *v1alpha2.ApplicationConfigurationComponent
-		var err error
-
+	// the components are rendered in the order of their dependencies so that they can refer to the components
+	// rendered before them
+	workloads, err := SortWorkloads(app.Workloads)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make(map[string]*renderedComponent, len(workloads))
+	rendered := make(map[string]process.RenderedComponent, len(workloads))
+	contexts := make(map[string]process.Context, len(workloads))
+	for _, wl := range workloads {
+		var result *renderedComponent
 		switch wl.CapabilityCategory {
+		case types.TerraformCategory:
+			// the Terraform components are applied by the terraform executor instead of the ApplicationConfiguration
+			continue
 		case types.HelmCategory:
-			comp, acComp, err = generateComponentFromHelmModule(p.client, p.dm, wl, app.Name, app.RevisionName, ns)
+			result, err = generateComponentFromHelmModule(p.client, p.dm, wl, app.Name, app.RevisionName, ns,
+				app.AppInfo(rendered))
 			if err != nil {
 				return nil, nil, err
 			}
 		default:
-			comp, acComp, err = generateComponentFromCUEModule(p.client, wl, app.Name, app.RevisionName, ns)
+			result, err = generateComponentFromCUEModule(p.client, wl, app.Name, app.RevisionName, ns,
+				app.AppInfo(rendered))
 			if err != nil {
 				return nil, nil, err
 			}
 		}
-		components = append(components, comp)
-		appconfig.Spec.Components = append(appconfig.Spec.Components, *acComp)
+		results[wl.Name] = result
+		rendered[wl.Name] = result.context
+		contexts[wl.Name] = result.processContext
+	}
+	app.contexts = contexts
+
+	// the components emit the runtime values that the other components refer to
+	for _, wl := range workloads {
+		if results[wl.Name] == nil {
+			continue
+		}
+		for _, ref := range results[wl.Name].runtimeRefs {
+			results[ref.component].addDataOutput(ref.dataOutput())
+		}
+	}
+
+	var components []*v1alpha2.Component
+	for _, wl := range app.Workloads {
+		if results[wl.Name] == nil {
+			continue
+		}
+		components = append(components, results[wl.Name].comp)
+		appconfig.Spec.Components = append(appconfig.Spec.Components, *results[wl.Name].acComp)
 	}
 	return appconfig, components, nil
 }
 
-func generateComponentFromCUEModule(c client.Client, wl *Workload, appName, revision, ns string) (*v1alpha2.Component, *v1alpha2.ApplicationConfigurationComponent, error) {
-	pCtx, err := PrepareProcessContext(c, wl, appName, revision, ns)
+// GenerateTerraformConfigurations renders the Terraform components into Terraform configurations in the JSON syntax
+func (p *Parser) GenerateTerraformConfigurations(app *Appfile, ns string) ([]*terraform.Configuration, error) {
+	var confs []*terraform.Configuration
+	for _, wl := range app.Workloads {
+		if wl.CapabilityCategory != types.TerraformCategory {
+			continue
+		}
+		pCtx, err := PrepareProcessContext(p.client, wl, app.Name, app.RevisionName, ns, app.AppInfo(nil))
+		if err != nil {
+			return nil, err
+		}
+		base, _ := pCtx.Output()
+		data, err := base.Compile()
+		if err != nil {
+			return nil, errors.Wrapf(err, "evaluate terraform configuration comp=%s app=%s", wl.Name, app.Name)
+		}
+		confs = append(confs, &terraform.Configuration{
+			Name:      wl.Name,
+			Namespace: ns,
+			AppName:   app.Name,
+			JSON:      data,
+		})
+	}
+	return confs, nil
+}
+
+// GenerateScopes renders the application-level scopes that have a template into scope objects,
+// the scopes without a template refer to existing scope instances so there is nothing to render
+func (p *Parser) GenerateScopes(app *Appfile, ns string) ([]*unstructured.Unstructured, error) {
+	var scopes []*unstructured.Unstructured
+	for _, scope := range app.Scopes {
+		if len(scope.Template) == 0 {
+			continue
+		}
+		pCtx := process.NewContext(scope.Name, app.Name, app.RevisionName)
+		pCtx.SetClient(p.client, ns)
+		pCtx.SetAppInfo(app.AppInfo(nil))
+		if err := scope.EvalContext(pCtx); err != nil {
+			return nil, errors.Wrapf(err, "evaluate template scope=%s app=%s", scope.Name, app.Name)
+		}
+		base, _ := pCtx.Output()
+		obj, err := base.Unstructured()
+		if err != nil {
+			return nil, errors.Wrapf(err, "evaluate base template scope=%s app=%s", scope.Name, app.Name)
+		}
+		if obj.GroupVersionKind() != scope.GVK {
+			return nil, errors.Errorf("the scope %s renders a %s instead of a %s", scope.Name,
+				obj.GroupVersionKind().String(), scope.GVK.String())
+		}
+		// the components refer to the scope by its name
+		obj.SetName(scope.Name)
+		obj.SetNamespace(ns)
+		util.AddLabels(obj, map[string]string{oam.LabelAppName: app.Name})
+		scopes = append(scopes, obj)
+	}
+	return scopes, nil
+}
+
+// renderedComponent is the result of rendering the workload and traits of a component
+type renderedComponent struct {
+	comp   *v1alpha2.Component
+	acComp *v1alpha2.ApplicationConfigurationComponent
+	// context is what the components rendered after it can refer to
+	context process.RenderedComponent
+	// processContext is the context that the component is rendered in
+	processContext process.Context
+	// runtimeRefs are the references to the runtime values of other components
+	runtimeRefs []runtimeReference
+}
+
+// addDataOutput adds the DataOutput to the component if it doesn't have one with the same name
+func (rc *renderedComponent) addDataOutput(output v1alpha2.DataOutput) {
+	for _, existing := range rc.acComp.DataOutputs {
+		if existing.Name == output.Name {
+			return
+		}
+	}
+	rc.acComp.DataOutputs = append(rc.acComp.DataOutputs, output)
+}
+
+func generateComponentFromCUEModule(c client.Client, wl *Workload, appName, revision, ns string,
+	info process.AppInfo) (*renderedComponent, error) {
+	pCtx, err := PrepareProcessContext(c, wl, appName, revision, ns, info)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	for _, tr := range wl.Traits {
 		if err := tr.EvalContext(pCtx); err != nil {
-			return nil, nil, errors.Wrapf(err, "evaluate template trait=%s app=%s", tr.Name, wl.Name)
+			return nil, errors.Wrapf(err, "evaluate template trait=%s app=%s", tr.Name, wl.Name)
 		}
 	}
+	// the runtime values of other components are filled by DataInputs once they are ready
+	workloadRefs, auxiliaryRefs, err := extractContextReferences(pCtx)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "resolve the references to other components app=%s comp=%s", appName, wl.Name)
+	}
 	var comp *v1alpha2.Component
 	var acComp *v1alpha2.ApplicationConfigurationComponent
 	comp, acComp, err = evalWorkloadWithContext(pCtx, wl, appName, wl.Name)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	runtimeRefs := workloadRefs
+	for _, ref := range workloadRefs {
+		acComp.DataInputs = append(acComp.DataInputs, ref.dataInput())
+	}
+	for i, refs := range auxiliaryRefs {
+		for _, ref := range refs {
+			acComp.Traits[i].DataInputs = append(acComp.Traits[i].DataInputs, ref.dataInput())
+		}
+		runtimeRefs = append(runtimeRefs, refs...)
 	}
 	comp.Name = wl.Name
 	acComp.ComponentName = comp.Name
@@ -288,13 +513,20 @@ func generateComponentFromCUEModule(c client.Client, wl *Workload, appName, revi
 	comp.Labels[oam.LabelAppName] = appName
 	comp.SetGroupVersionKind(v1alpha2.ComponentGroupVersionKind)
 
-	return comp, acComp, nil
+	return &renderedComponent{
+		comp:           comp,
+		acComp:         acComp,
+		context:        newRenderedComponent(pCtx),
+		processContext: pCtx,
+		runtimeRefs:    runtimeRefs,
+	}, nil
 }
 
-func generateComponentFromHelmModule(c client.Client, dm discoverymapper.DiscoveryMapper, wl *Workload, appName, revision, ns string) (*v1alpha2.Component, *v1alpha2.ApplicationConfigurationComponent, error) {
+func generateComponentFromHelmModule(c client.Client, dm discoverymapper.DiscoveryMapper, wl *Workload, appName, revision, ns string,
+	info process.AppInfo) (*renderedComponent, error) {
 	targetWokrloadGVK, err := util.GetGVKFromDefinition(dm, wl.DefinitionReference)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// NOTE this is a hack way to enable using CUE module capabilities on Helm module workload
@@ -306,28 +538,28 @@ output: {
 }`, targetWokrloadGVK.GroupVersion().String(), targetWokrloadGVK.Kind)
 
 	// re-use the way CUE module generates comp & acComp
-	comp, acComp, err := generateComponentFromCUEModule(c, wl, appName, revision, ns)
+	result, err := generateComponentFromCUEModule(c, wl, appName, revision, ns, info)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	release, repo, err := helm.RenderHelmReleaseAndHelmRepo(wl.Helm, wl.Name, appName, ns, wl.Params)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	rlsBytes, err := json.Marshal(release.Object)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	repoBytes, err := json.Marshal(repo.Object)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	comp.Spec.Helm = &v1alpha2.Helm{
+	result.comp.Spec.Helm = &v1alpha2.Helm{
 		Release:    runtime.RawExtension{Raw: rlsBytes},
 		Repository: runtime.RawExtension{Raw: repoBytes},
 	}
-	return comp, acComp, nil
+	return result, nil
 }
 
 // evalWorkloadWithContext evaluate the workload's template to generate component and ACComponent
@@ -372,8 +604,11 @@ func evalWorkloadWithContext(pCtx process.Context, wl *Workload, appName, compNa
 }
 
 // PrepareProcessContext prepares a DSL process Context
-func PrepareProcessContext(k8sClient client.Client, wl *Workload, applicationName, revision string, namespace string) (process.Context, error) {
+func PrepareProcessContext(k8sClient client.Client, wl *Workload, applicationName, revision string, namespace string,
+	info process.AppInfo) (process.Context, error) {
 	pCtx := process.NewContext(wl.Name, applicationName, revision)
+	pCtx.SetClient(k8sClient, namespace)
+	pCtx.SetAppInfo(info)
 	userConfig := wl.GetUserConfigName()
 	if userConfig != "" {
 		cg := config.Configmap{Client: k8sClient}
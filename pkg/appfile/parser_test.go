@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"testing"
 
 	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
@@ -38,6 +39,7 @@ import (
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	oamtypes "github.com/oam-dev/kubevela/apis/types"
 	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/oam/mock"
 	"github.com/oam-dev/kubevela/pkg/oam/util"
 )
 
@@ -662,3 +664,231 @@ var _ = Describe("Test appfile parser to parse helm module", func() {
 	})
 
 })
+
+const scopeDefinition = `
+apiVersion: core.oam.dev/v1alpha2
+kind: ScopeDefinition
+metadata:
+  name: healthscope
+spec:
+  definitionRef:
+    name: healthscopes.core.oam.dev
+  allowComponentOverlap: true
+  schematic:
+    cue:
+      template: |
+        output: {
+        	apiVersion: "core.oam.dev/v1alpha2"
+        	kind:       "HealthScope"
+        	spec: probeTimeout: parameter.timeout
+        }
+        parameter: {
+        	timeout: *10 | int
+        }`
+
+const appWithScopesYaml = `
+apiVersion: core.oam.dev/v1alpha2
+kind: Application
+metadata:
+  name: application-sample
+spec:
+  components:
+    - name: myweb
+      type: worker
+      settings:
+        image: "busybox"
+      scopes:
+        healthscope: app-health
+    - name: mybackend
+      type: worker
+      settings:
+        image: "busybox"
+  scopes:
+    - name: app-health
+      type: healthscope
+      properties:
+        timeout: 5
+`
+
+func TestParseApplicationScopes(t *testing.T) {
+	o := v1alpha2.Application{}
+	assert.NoError(t, yaml.Unmarshal([]byte(appWithScopesYaml), &o))
+	tclient := test.MockClient{
+		MockGet: func(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+			switch o := obj.(type) {
+			case *v1alpha2.WorkloadDefinition:
+				wd, err := util.UnMarshalStringToWorkloadDefinition(workloadDefinition)
+				if err != nil {
+					return err
+				}
+				*o = *wd
+			case *v1alpha2.ScopeDefinition:
+				return yaml.Unmarshal([]byte(scopeDefinition), o)
+			}
+			return nil
+		},
+	}
+	dm := mock.NewMockDiscoveryMapper()
+	dm.MockKindsFor = mock.NewMockKindsFor("HealthScope", "v1alpha2")
+	p := NewApplicationParser(&tclient, dm)
+	healthScopeGVK := schema.GroupVersionKind{Group: "core.oam.dev", Version: "v1alpha2", Kind: "HealthScope"}
+
+	af, err := p.GenerateAppFile(context.TODO(), "test", &o)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(af.Scopes))
+	assert.Equal(t, healthScopeGVK, af.Scopes[0].GVK)
+	assert.Equal(t, map[string]interface{}{"timeout": float64(5)}, af.Scopes[0].Params)
+	// every component joins the application-level scope once
+	for _, wl := range af.Workloads {
+		assert.Equal(t, []Scope{{Name: "app-health", GVK: healthScopeGVK}}, wl.Scopes)
+	}
+
+	ac, _, err := p.GenerateApplicationConfiguration(af, "default")
+	assert.NoError(t, err)
+	for _, acc := range ac.Spec.Components {
+		assert.Equal(t, []v1alpha2.ComponentScope{{ScopeReference: v1alpha1.TypedReference{
+			APIVersion: "core.oam.dev/v1alpha2",
+			Kind:       "HealthScope",
+			Name:       "app-health",
+		}}}, acc.Scopes)
+	}
+
+	scopes, err := p.GenerateScopes(af, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(scopes))
+	assert.Equal(t, "app-health", scopes[0].GetName())
+	assert.Equal(t, "default", scopes[0].GetNamespace())
+	assert.Equal(t, "test", scopes[0].GetLabels()[oam.LabelAppName])
+	timeout, _, _ := unstructured.NestedInt64(scopes[0].Object, "spec", "probeTimeout")
+	assert.Equal(t, int64(5), timeout)
+}
+
+func TestGenerateApplicationConfigurationWithAppInfo(t *testing.T) {
+	app := &Appfile{
+		Name:         "myapp",
+		RevisionName: "myapp-v2",
+		RevisionNum:  2,
+		Labels:       map[string]string{"team": "web"},
+		Workloads: []*Workload{
+			{
+				Name: "frontend",
+				Type: "worker",
+				Template: `
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	metadata: labels: team: context.appLabels.team
+	spec: template: metadata: annotations: {
+		revision:   "\(context.appRevisionNum)"
+		components: "\(len(context.components))"
+		backend:    context.components.backend.output.spec.clusterIP
+		port:       "\(context.components.backend.outputs.service.spec.port)"
+	}
+	spec: template: spec: containers: [{
+		name: "main"
+		env: [{
+			name:  "BACKEND_IP"
+			value: context.components.backend.output.status.podIP
+		}]
+	}]
+}`,
+			},
+			{
+				Name: "backend",
+				Type: "worker",
+				Template: `
+output: {
+	apiVersion: "v1"
+	kind:       "Pod"
+	metadata: {
+		name:      context.name
+		namespace: context.namespace
+	}
+	spec: clusterIP: "10.0.0.1"
+}
+outputs: service: {
+	apiVersion: "v1"
+	kind:       "Service"
+	spec: port: 80
+}`,
+			},
+		},
+	}
+	p := NewApplicationParser(&test.MockClient{}, mock.NewMockDiscoveryMapper())
+	ac, comps, err := p.GenerateApplicationConfiguration(app, "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(comps))
+
+	// the components keep the order in the application
+	frontend, err := util.RawExtension2Unstructured(&comps[0].Spec.Workload)
+	assert.NoError(t, err)
+	assert.Equal(t, "web", frontend.GetLabels()["team"])
+	annotations, _, _ := unstructured.NestedStringMap(frontend.Object, "spec", "template", "metadata", "annotations")
+	assert.Equal(t, map[string]string{"revision": "2", "components": "2", "backend": "10.0.0.1", "port": "80"}, annotations)
+	// the runtime value is filled by the DataInput
+	containers, _, _ := unstructured.NestedSlice(frontend.Object, "spec", "template", "spec", "containers")
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"name": "main",
+		"env":  []interface{}{map[string]interface{}{"name": "BACKEND_IP"}},
+	}}, containers)
+	assert.Equal(t, []v1alpha2.DataInput{{
+		ValueFrom:    v1alpha2.DataInputValueFrom{DataOutputName: "backend-status.podIP"},
+		ToFieldPaths: []string{"spec.template.spec.containers[0].env[0].value"},
+	}}, ac.Spec.Components[0].DataInputs)
+
+	backend, err := util.RawExtension2Unstructured(&comps[1].Spec.Workload)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", backend.GetNamespace())
+	assert.Equal(t, []v1alpha2.DataOutput{{Name: "backend-status.podIP", FieldPath: "status.podIP"}},
+		ac.Spec.Components[1].DataOutputs)
+}
+
+func TestGenerateTerraformConfigurations(t *testing.T) {
+	app := &Appfile{
+		Name:         "myapp",
+		RevisionName: "myapp-v1",
+		Workloads: []*Workload{
+			{
+				Name: "frontend",
+				Type: "worker",
+				Template: `
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+}`,
+			},
+			{
+				Name:               "sample-oss",
+				Type:               "alibaba-oss",
+				CapabilityCategory: oamtypes.TerraformCategory,
+				Params:             map[string]interface{}{"bucket": "vela-website"},
+				Template: `
+output: {
+	resource: alicloud_oss_bucket: "bucket-acl": {
+		bucket: parameter.bucket
+		acl:    "private"
+	}
+	output: BUCKET_NAME: value: "\(context.name).\(parameter.bucket)"
+}
+parameter: bucket: string`,
+			},
+		},
+	}
+	p := NewApplicationParser(&test.MockClient{}, mock.NewMockDiscoveryMapper())
+	ac, comps, err := p.GenerateApplicationConfiguration(app, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(comps))
+	assert.Equal(t, "frontend", comps[0].Name)
+	assert.Equal(t, 1, len(ac.Spec.Components))
+
+	confs, err := p.GenerateTerraformConfigurations(app, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(confs))
+	assert.Equal(t, "sample-oss", confs[0].Name)
+	assert.Equal(t, "default", confs[0].Namespace)
+	assert.Equal(t, "myapp", confs[0].AppName)
+	assert.JSONEq(t, `{
+	"resource": {"alicloud_oss_bucket": {"bucket-acl": {"bucket": "vela-website", "acl": "private"}}},
+	"output": {"BUCKET_NAME": {"value": "sample-oss.vela-website"}}
+}`, string(confs[0].JSON))
+}
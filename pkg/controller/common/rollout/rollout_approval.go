@@ -0,0 +1,85 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+// FormatBatchApproval returns the value of the batch approval annotation
+func FormatBatchApproval(batch int32, decision v1alpha1.BatchApprovalDecision) string {
+	return fmt.Sprintf("%d:%s", batch, decision)
+}
+
+// ParseBatchApproval parses the value of the batch approval annotation
+func ParseBatchApproval(value string) (int32, v1alpha1.BatchApprovalDecision, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return 0, "", fmt.Errorf("the batch approval `%s` is not in the form of <batch>:<decision>", value)
+	}
+	batch, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return 0, "", fmt.Errorf("the batch approval `%s` has an invalid batch number", value)
+	}
+	decision := v1alpha1.BatchApprovalDecision(parts[1])
+	if decision != v1alpha1.BatchApproved && decision != v1alpha1.BatchRejected {
+		return 0, "", fmt.Errorf("the batch approval `%s` has an unknown decision", value)
+	}
+	return int32(batch), decision, nil
+}
+
+// SetBatchApproval records the manual decision on a batch in the object that owns the rollout
+func SetBatchApproval(obj oam.Object, batch int32, decision v1alpha1.BatchApprovalDecision) {
+	oamutil.AddAnnotations(obj, map[string]string{
+		oam.AnnotationRolloutBatchApproval: FormatBatchApproval(batch, decision),
+	})
+}
+
+// getBatchApproval returns the manual decision on the given batch, it's empty if nobody made a decision yet
+func getBatchApproval(obj oam.Object, batch int32) v1alpha1.BatchApprovalDecision {
+	value, exist := obj.GetAnnotations()[oam.AnnotationRolloutBatchApproval]
+	if !exist {
+		return ""
+	}
+	approvedBatch, decision, err := ParseBatchApproval(value)
+	if err != nil {
+		klog.ErrorS(err, "ignore the invalid batch approval", "object", klog.KObj(obj))
+		return ""
+	}
+	// a decision on the other batches does not count
+	if approvedBatch != batch {
+		return ""
+	}
+	return decision
+}
+
+// ClearStaleBatchApproval removes the manual decision from the object that owns the rollout once it no longer applies,
+// that is the batch it decides on is consumed or the rollout is not rolling in batches, e.g. it has reset or restarted.
+// Otherwise a decision on a batch would carry over to the same batch of the next rollout
+func ClearStaleBatchApproval(ctx context.Context, c client.Client, obj oam.Object, status *v1alpha1.RolloutStatus) error {
+	value, exist := obj.GetAnnotations()[oam.AnnotationRolloutBatchApproval]
+	if !exist {
+		return nil
+	}
+	// leave the invalid decision to the user, it never counts anyway
+	batch, _, err := ParseBatchApproval(value)
+	if err != nil {
+		return nil
+	}
+	if status.RollingState == v1alpha1.RollingInBatchesState && status.CurrentBatch == batch {
+		return nil
+	}
+	klog.InfoS("clear the stale batch approval", "object", klog.KObj(obj), "approval", value,
+		"rollout state", status.RollingState, "current batch", status.CurrentBatch)
+	patch := client.MergeFrom(obj.DeepCopyObject())
+	oamutil.RemoveAnnotations(obj, []string{oam.AnnotationRolloutBatchApproval})
+	return c.Patch(ctx, obj, patch)
+}
@@ -0,0 +1,248 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestParseBatchApproval(t *testing.T) {
+	tests := map[string]struct {
+		value        string
+		wantBatch    int32
+		wantDecision v1alpha1.BatchApprovalDecision
+		wantErr      bool
+	}{
+		"approved": {
+			value:        "1:approved",
+			wantBatch:    1,
+			wantDecision: v1alpha1.BatchApproved,
+		},
+		"rejected": {
+			value:        "0:rejected",
+			wantBatch:    0,
+			wantDecision: v1alpha1.BatchRejected,
+		},
+		"no batch": {
+			value:   "approved",
+			wantErr: true,
+		},
+		"invalid batch": {
+			value:   "first:approved",
+			wantErr: true,
+		},
+		"unknown decision": {
+			value:   "1:maybe",
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			batch, decision, err := ParseBatchApproval(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantBatch, batch)
+			assert.Equal(t, tt.wantDecision, decision)
+			assert.Equal(t, tt.value, FormatBatchApproval(batch, decision))
+		})
+	}
+}
+
+func TestTryMovingToNextBatch(t *testing.T) {
+	tests := map[string]struct {
+		requireApproval bool
+		batchPartition  *int32
+		approval        string
+		wantState       v1alpha1.RollingState
+		wantBatch       int32
+	}{
+		"no approval required": {
+			wantState: v1alpha1.RollingInBatchesState,
+			wantBatch: 2,
+		},
+		"held by the batch partition": {
+			batchPartition: pointer.Int32Ptr(1),
+			wantState:      v1alpha1.RollingInBatchesState,
+			wantBatch:      1,
+		},
+		"waiting for approval": {
+			requireApproval: true,
+			wantState:       v1alpha1.RollingInBatchesState,
+			wantBatch:       1,
+		},
+		"approved a previous batch": {
+			requireApproval: true,
+			approval:        "0:approved",
+			wantState:       v1alpha1.RollingInBatchesState,
+			wantBatch:       1,
+		},
+		"approved": {
+			requireApproval: true,
+			approval:        "1:approved",
+			wantState:       v1alpha1.RollingInBatchesState,
+			wantBatch:       2,
+		},
+		"approved but held by the batch partition": {
+			requireApproval: true,
+			batchPartition:  pointer.Int32Ptr(1),
+			approval:        "1:approved",
+			wantState:       v1alpha1.RollingInBatchesState,
+			wantBatch:       1,
+		},
+		"rejected": {
+			requireApproval: true,
+			approval:        "1:rejected",
+			wantState:       v1alpha1.RolloutFailingState,
+			wantBatch:       1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			parent := v1alpha1.PodSpecWorkload{
+				ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "namespace"},
+			}
+			if len(tt.approval) != 0 {
+				parent.SetAnnotations(map[string]string{oam.AnnotationRolloutBatchApproval: tt.approval})
+			}
+			r := &Controller{
+				recorder:         event.NewNopRecorder(),
+				parentController: &parent,
+				rolloutSpec: &v1alpha1.RolloutPlan{
+					BatchPartition: tt.batchPartition,
+					RolloutBatches: []v1alpha1.RolloutBatch{{}, {RequireApproval: tt.requireApproval}, {}},
+				},
+				rolloutStatus: &v1alpha1.RolloutStatus{
+					RollingState:      v1alpha1.RollingInBatchesState,
+					BatchRollingState: v1alpha1.BatchReadyState,
+					CurrentBatch:      1,
+				},
+			}
+			r.tryMovingToNextBatch()
+			assert.Equal(t, tt.wantState, r.rolloutStatus.RollingState)
+			assert.Equal(t, tt.wantBatch, r.rolloutStatus.CurrentBatch)
+		})
+	}
+}
+
+func TestClearStaleBatchApproval(t *testing.T) {
+	ctx := context.TODO()
+	tests := map[string]struct {
+		approval  string
+		status    v1alpha1.RolloutStatus
+		wantClear bool
+	}{
+		"no approval": {
+			status: v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutSucceedState},
+		},
+		"waiting for the approved batch": {
+			approval: "1:approved",
+			status:   v1alpha1.RolloutStatus{RollingState: v1alpha1.RollingInBatchesState, CurrentBatch: 1},
+		},
+		"moved past the approved batch": {
+			approval:  "1:approved",
+			status:    v1alpha1.RolloutStatus{RollingState: v1alpha1.RollingInBatchesState, CurrentBatch: 2},
+			wantClear: true,
+		},
+		"rejected batch failing": {
+			approval:  "1:rejected",
+			status:    v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutFailingState, CurrentBatch: 1},
+			wantClear: true,
+		},
+		"rollout succeeded": {
+			approval:  "2:approved",
+			status:    v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutSucceedState, CurrentBatch: 2},
+			wantClear: true,
+		},
+		"rollout restarted": {
+			approval:  "0:approved",
+			status:    v1alpha1.RolloutStatus{RollingState: v1alpha1.VerifyingSpecState},
+			wantClear: true,
+		},
+		"invalid approval": {
+			approval: "yes",
+			status:   v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutSucceedState},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			appRollout := &v1alpha2.AppRollout{
+				ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default"},
+			}
+			if len(tt.approval) != 0 {
+				appRollout.SetAnnotations(map[string]string{oam.AnnotationRolloutBatchApproval: tt.approval})
+			}
+			c := fake.NewFakeClientWithScheme(common.Scheme, appRollout.DeepCopy())
+			assert.NoError(t, ClearStaleBatchApproval(ctx, c, appRollout, &tt.status))
+			got := &v1alpha2.AppRollout{}
+			assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "rollout"}, got))
+			_, exist := got.GetAnnotations()[oam.AnnotationRolloutBatchApproval]
+			assert.Equal(t, len(tt.approval) != 0 && !tt.wantClear, exist)
+		})
+	}
+}
+
+func TestBatchApprovalDoesNotCarryOver(t *testing.T) {
+	ctx := context.TODO()
+	appRollout := &v1alpha2.AppRollout{
+		ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default"},
+	}
+	c := fake.NewFakeClientWithScheme(common.Scheme, appRollout.DeepCopy())
+	newController := func(status *v1alpha1.RolloutStatus) *Controller {
+		return &Controller{
+			client:           c,
+			recorder:         event.NewNopRecorder(),
+			parentController: appRollout,
+			rolloutSpec: &v1alpha1.RolloutPlan{
+				RolloutBatches: []v1alpha1.RolloutBatch{{RequireApproval: true}, {}},
+			},
+			rolloutStatus: status,
+		}
+	}
+	readyAtBatch0 := v1alpha1.RolloutStatus{
+		RollingState:      v1alpha1.RollingInBatchesState,
+		BatchRollingState: v1alpha1.BatchReadyState,
+	}
+
+	clearStale := func(status *v1alpha1.RolloutStatus) {
+		assert.NoError(t, ClearStaleBatchApproval(ctx, c, appRollout, status))
+	}
+
+	// the first rollout is approved at batch 0 and moves on, the approval is consumed
+	first := readyAtBatch0
+	SetBatchApproval(appRollout, 0, v1alpha1.BatchApproved)
+	assert.NoError(t, c.Update(ctx, appRollout))
+	newController(&first).tryMovingToNextBatch()
+	assert.Equal(t, int32(1), first.CurrentBatch)
+	clearStale(&first)
+	assert.NotContains(t, appRollout.GetAnnotations(), oam.AnnotationRolloutBatchApproval)
+
+	// the approval of a rollout that restarts before it moves on is cleared by the restart
+	restarted := readyAtBatch0
+	SetBatchApproval(appRollout, 0, v1alpha1.BatchApproved)
+	assert.NoError(t, c.Update(ctx, appRollout))
+	restarted.ResetStatus()
+	clearStale(&restarted)
+	assert.NotContains(t, appRollout.GetAnnotations(), oam.AnnotationRolloutBatchApproval)
+
+	// the second rollout pauses at batch 0 until it gets its own approval
+	second := readyAtBatch0
+	newController(&second).tryMovingToNextBatch()
+	assert.Equal(t, v1alpha1.RollingInBatchesState, second.RollingState)
+	assert.Equal(t, int32(0), second.CurrentBatch)
+	assert.Equal(t, corev1.ConditionFalse, second.GetCondition(v1alpha1.BatchApproval).Status)
+}
@@ -0,0 +1,298 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+)
+
+// the default window size of a canary metric
+const defaultMetricInterval = "1m"
+
+// the number of samples of a canary metric compared over its interval
+const metricSamplesPerInterval = 10
+
+// the number of consecutive evaluations that fail to get the value of a canary metric before the rollout fails
+const maxMetricEvaluationRetries = 5
+
+// the timeout of one metric query
+const metricQueryTimeout = 10 * time.Second
+
+// metricQueryParameter is the data used to render the canary metric query template
+type metricQueryParameter struct {
+	Name      string
+	Namespace string
+	Interval  string
+}
+
+// prometheusResponse is the response of the prometheus range query API
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// prometheusSeries is one element of a prometheus matrix result
+type prometheusSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][]interface{}   `json:"values"`
+}
+
+// ValidateCanaryMetric checks if the canary metric can be evaluated by the rollout controller
+func ValidateCanaryMetric(metric v1alpha1.CanaryMetric) error {
+	if metric.TemplateRef != nil {
+		return fmt.Errorf("canary metric `%s` references a metric template which is not supported", metric.Name)
+	}
+	if metric.MetricProvider == nil || len(metric.Query) == 0 || len(metric.MetricProvider.Address) == 0 {
+		return fmt.Errorf("canary metric `%s` needs a query and the address of its metric provider", metric.Name)
+	}
+	if len(metric.MetricProvider.Type) != 0 && metric.MetricProvider.Type != v1alpha1.PrometheusMetricProvider {
+		return fmt.Errorf("canary metric `%s` has an unsupported metric provider type `%s`",
+			metric.Name, metric.MetricProvider.Type)
+	}
+	if _, err := metricInterval(metric); err != nil {
+		return err
+	}
+	if _, err := template.New("query").Parse(metric.Query); err != nil {
+		return fmt.Errorf("failed to parse the query of canary metric `%s`: %w", metric.Name, err)
+	}
+	if metric.MetricsRange != nil {
+		for _, bound := range []*intstr.IntOrString{metric.MetricsRange.Min, metric.MetricsRange.Max} {
+			if bound == nil {
+				continue
+			}
+			if _, err := metricBound(bound); err != nil {
+				return fmt.Errorf("canary metric `%s` has an invalid range: %w", metric.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// metricInterval returns the window size of the canary metric
+func metricInterval(metric v1alpha1.CanaryMetric) (time.Duration, error) {
+	interval := metric.Interval
+	if len(interval) == 0 {
+		interval = defaultMetricInterval
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("canary metric `%s` has an invalid interval `%s`, it has to be a positive duration like 30s or 5m",
+			metric.Name, metric.Interval)
+	}
+	return d, nil
+}
+
+// evaluateCanaryMetric queries the metric provider over the interval of the metric against the workload and checks
+// if every sample is within the expected range. It returns the first sample out of range, or the last sample if they
+// are all within range, and an error only if we cannot get the value of the metric
+func evaluateCanaryMetric(ctx context.Context, workload metav1.Object,
+	metric v1alpha1.CanaryMetric) (float64, bool, string, error) {
+	interval, err := metricInterval(metric)
+	if err != nil {
+		return 0, false, "", err
+	}
+	// render the interval as is, a prometheus duration doesn't accept the compound form of a go duration
+	intervalStr := metric.Interval
+	if len(intervalStr) == 0 {
+		intervalStr = defaultMetricInterval
+	}
+	query, err := renderMetricQuery(metric.Query, metricQueryParameter{
+		Name:      workload.GetName(),
+		Namespace: workload.GetNamespace(),
+		Interval:  intervalStr,
+	})
+	if err != nil {
+		return 0, false, "", err
+	}
+	end := time.Now()
+	values, err := queryPrometheus(ctx, metric.MetricProvider.Address, query, end.Add(-interval), end,
+		metricQueryStep(interval))
+	if err != nil {
+		return 0, false, "", err
+	}
+	for _, value := range values {
+		passed, msg, err := checkMetricRange(value, metric.MetricsRange)
+		if err != nil {
+			return 0, false, "", err
+		}
+		if !passed {
+			return value, false, msg, nil
+		}
+	}
+	return values[len(values)-1], true, "", nil
+}
+
+// metricQueryStep returns the resolution of the range query of a canary metric
+func metricQueryStep(interval time.Duration) time.Duration {
+	step := interval / metricSamplesPerInterval
+	if step < time.Second {
+		return time.Second
+	}
+	return step
+}
+
+// renderMetricQuery renders the query template with the workload information
+func renderMetricQuery(query string, param metricQueryParameter) (string, error) {
+	t, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the metric query `%s`: %w", query, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, param); err != nil {
+		return "", fmt.Errorf("failed to render the metric query `%s`: %w", query, err)
+	}
+	return buf.String(), nil
+}
+
+// queryPrometheus issues a range query against a Prometheus compatible HTTP API and returns the values of the single
+// series it is expected to return, ordered by time
+func queryPrometheus(ctx context.Context, address, query string, start, end time.Time,
+	step time.Duration) ([]float64, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(address, "/") + "/api/v1/query_range")
+	if err != nil {
+		return nil, err
+	}
+	q := endpoint.Query()
+	q.Set("query", query)
+	q.Set("start", strconv.FormatInt(start.Unix(), 10))
+	q.Set("end", strconv.FormatInt(end.Unix(), 10))
+	q.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+	endpoint.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, metricQueryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Body.Close()
+	}()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var resp prometheusResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse the metric server response, http status = %d: %w", r.StatusCode, err)
+	}
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("the metric query failed with %s: %s", resp.ErrorType, resp.Error)
+	}
+	if resp.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("the metric query `%s` returns an unsupported result type `%s`",
+			query, resp.Data.ResultType)
+	}
+	var series []prometheusSeries
+	if err := json.Unmarshal(resp.Data.Result, &series); err != nil {
+		return nil, err
+	}
+	if len(series) != 1 {
+		return nil, fmt.Errorf("the metric query `%s` is expected to return one series but got %d",
+			query, len(series))
+	}
+	if len(series[0].Values) == 0 {
+		return nil, fmt.Errorf("the metric query `%s` returns no sample", query)
+	}
+	values := make([]float64, 0, len(series[0].Values))
+	for _, sample := range series[0].Values {
+		// the sample is a [timestamp, "value"] pair
+		if len(sample) != 2 {
+			return nil, fmt.Errorf("the metric query `%s` returns a malformed sample %v", query, sample)
+		}
+		valueStr, ok := sample[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("the metric query `%s` returns a malformed sample %v", query, sample)
+		}
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// checkMetricRange checks if the value is within the expected range, the bound can be an int or a float string
+func checkMetricRange(value float64, metricsRange *v1alpha1.MetricsExpectedRange) (bool, string, error) {
+	if metricsRange == nil {
+		return true, "", nil
+	}
+	if metricsRange.Min != nil {
+		min, err := metricBound(metricsRange.Min)
+		if err != nil {
+			return false, "", err
+		}
+		if value < min {
+			return false, fmt.Sprintf("the metric value %v is less than the minimum %v", value, min), nil
+		}
+	}
+	if metricsRange.Max != nil {
+		max, err := metricBound(metricsRange.Max)
+		if err != nil {
+			return false, "", err
+		}
+		if value > max {
+			return false, fmt.Sprintf("the metric value %v is greater than the maximum %v", value, max), nil
+		}
+	}
+	return true, "", nil
+}
+
+func metricBound(bound *intstr.IntOrString) (float64, error) {
+	if bound.Type == intstr.Int {
+		return float64(bound.IntVal), nil
+	}
+	v, err := strconv.ParseFloat(bound.StrVal, 64)
+	if err != nil {
+		return 0, fmt.Errorf("the metric range bound `%s` is not a number", bound.StrVal)
+	}
+	return v, nil
+}
+
+// gatherAllCanaryMetrics returns the rollout level canary metrics and the current batch specific ones
+func gatherAllCanaryMetrics(rolloutSpec *v1alpha1.RolloutPlan, currentBatch int) []v1alpha1.CanaryMetric {
+	metrics := append([]v1alpha1.CanaryMetric{}, rolloutSpec.CanaryMetric...)
+	if currentBatch < len(rolloutSpec.RolloutBatches) {
+		metrics = append(metrics, rolloutSpec.RolloutBatches[currentBatch].CanaryMetric...)
+	}
+	return metrics
+}
+
+// newMetricEvaluation records the result of a canary metric
+func newMetricEvaluation(name string, batch int32, value float64, succeeded bool,
+	msg string) v1alpha1.CanaryMetricEvaluation {
+	klog.InfoS("evaluated a canary metric", "metric", name, "batch", batch, "value", value,
+		"succeeded", succeeded, "message", msg)
+	return v1alpha1.CanaryMetricEvaluation{
+		Name:               name,
+		Batch:              batch,
+		Value:              strconv.FormatFloat(value, 'f', -1, 64),
+		Succeeded:          succeeded,
+		Message:            msg,
+		LastEvaluationTime: metav1.Now(),
+	}
+}
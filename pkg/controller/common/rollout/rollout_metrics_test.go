@@ -0,0 +1,334 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+)
+
+// newFakePrometheus returns a prometheus compatible server that answers every range query with the given body
+// and records the last query it received
+func newFakePrometheus(body string, lastQuery *url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/query_range" || req.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if lastQuery != nil {
+			*lastQuery = req.URL.Query()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func matrixResponse(values ...string) string {
+	samples := make([]string, len(values))
+	for i, v := range values {
+		samples[i] = fmt.Sprintf(`[%d,"%s"]`, 1610000000+i*6, v)
+	}
+	return fmt.Sprintf(`{"status":"success","data":{"resultType":"matrix","result":[`+
+		`{"metric":{"app":"test"},"values":[%s]}]}}`, strings.Join(samples, ","))
+}
+
+func Test_queryPrometheus(t *testing.T) {
+	ctx := context.TODO()
+	end := time.Unix(1610000060, 0)
+	tests := map[string]struct {
+		body    string
+		want    []float64
+		wantErr bool
+	}{
+		"matrix result": {
+			body: matrixResponse("0.995", "0.99", "1"),
+			want: []float64{0.995, 0.99, 1},
+		},
+		"empty matrix": {
+			body:    `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			wantErr: true,
+		},
+		"series without samples": {
+			body:    `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[]}]}}`,
+			wantErr: true,
+		},
+		"multiple series": {
+			body: `{"status":"success","data":{"resultType":"matrix","result":[` +
+				`{"metric":{},"values":[[1,"1"]]},{"metric":{},"values":[[1,"2"]]}]}}`,
+			wantErr: true,
+		},
+		"vector result": {
+			body:    `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			wantErr: true,
+		},
+		"query error": {
+			body:    `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			wantErr: true,
+		},
+		"not a number": {
+			body:    matrixResponse("1", "abc"),
+			wantErr: true,
+		},
+		"not json": {
+			body:    "oops",
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var lastQuery url.Values
+			server := newFakePrometheus(tt.body, &lastQuery)
+			defer server.Close()
+			got, err := queryPrometheus(ctx, server.URL, "up", end.Add(-time.Minute), end, 6*time.Second)
+			assert.Equal(t, "up", lastQuery.Get("query"))
+			assert.Equal(t, "1610000000", lastQuery.Get("start"))
+			assert.Equal(t, "1610000060", lastQuery.Get("end"))
+			assert.Equal(t, "6", lastQuery.Get("step"))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateCanaryMetric(t *testing.T) {
+	invalid := intstr.FromString("high")
+	valid := v1alpha1.CanaryMetric{
+		Name:           "error-rate",
+		Interval:       "5m",
+		Query:          `rate(errors{app="{{ .Name }}"}[{{ .Interval }}])`,
+		MetricProvider: &v1alpha1.MetricProvider{Address: "http://prometheus:9090"},
+	}
+	tests := map[string]struct {
+		modify  func(metric *v1alpha1.CanaryMetric)
+		wantErr bool
+	}{
+		"valid": {
+			modify: func(metric *v1alpha1.CanaryMetric) {},
+		},
+		"default interval": {
+			modify: func(metric *v1alpha1.CanaryMetric) { metric.Interval = "" },
+		},
+		"template reference": {
+			modify: func(metric *v1alpha1.CanaryMetric) {
+				metric.TemplateRef = &runtimev1alpha1.TypedReference{Kind: "MetricTemplate", Name: "error-rate"}
+			},
+			wantErr: true,
+		},
+		"no query": {
+			modify:  func(metric *v1alpha1.CanaryMetric) { metric.Query = "" },
+			wantErr: true,
+		},
+		"no provider": {
+			modify:  func(metric *v1alpha1.CanaryMetric) { metric.MetricProvider = nil },
+			wantErr: true,
+		},
+		"no provider address": {
+			modify:  func(metric *v1alpha1.CanaryMetric) { metric.MetricProvider = &v1alpha1.MetricProvider{} },
+			wantErr: true,
+		},
+		"unsupported provider": {
+			modify: func(metric *v1alpha1.CanaryMetric) {
+				metric.MetricProvider = &v1alpha1.MetricProvider{Type: "datadog", Address: "http://datadog"}
+			},
+			wantErr: true,
+		},
+		"invalid interval": {
+			modify:  func(metric *v1alpha1.CanaryMetric) { metric.Interval = "five minutes" },
+			wantErr: true,
+		},
+		"invalid query template": {
+			modify:  func(metric *v1alpha1.CanaryMetric) { metric.Query = "{{ .Name" },
+			wantErr: true,
+		},
+		"invalid range": {
+			modify: func(metric *v1alpha1.CanaryMetric) {
+				metric.MetricsRange = &v1alpha1.MetricsExpectedRange{Max: &invalid}
+			},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			metric := *valid.DeepCopy()
+			tt.modify(&metric)
+			err := ValidateCanaryMetric(metric)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
+
+func Test_checkMetricRange(t *testing.T) {
+	min := intstr.FromInt(10)
+	max := intstr.FromString("99.5")
+	invalid := intstr.FromString("high")
+	tests := map[string]struct {
+		value        float64
+		metricsRange *v1alpha1.MetricsExpectedRange
+		want         bool
+		wantErr      bool
+	}{
+		"no range": {
+			value: 1000,
+			want:  true,
+		},
+		"within range": {
+			value:        50,
+			metricsRange: &v1alpha1.MetricsExpectedRange{Min: &min, Max: &max},
+			want:         true,
+		},
+		"on the bound": {
+			value:        99.5,
+			metricsRange: &v1alpha1.MetricsExpectedRange{Min: &min, Max: &max},
+			want:         true,
+		},
+		"less than min": {
+			value:        9.9,
+			metricsRange: &v1alpha1.MetricsExpectedRange{Min: &min},
+			want:         false,
+		},
+		"greater than max": {
+			value:        99.6,
+			metricsRange: &v1alpha1.MetricsExpectedRange{Max: &max},
+			want:         false,
+		},
+		"invalid bound": {
+			value:        1,
+			metricsRange: &v1alpha1.MetricsExpectedRange{Max: &invalid},
+			wantErr:      true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _, err := checkMetricRange(tt.value, tt.metricsRange)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_evaluateCanaryMetrics(t *testing.T) {
+	ctx := context.TODO()
+	rollout := v1alpha2.AppRollout{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rollout",
+			Namespace: "namespace",
+		},
+	}
+	workload := &unstructured.Unstructured{}
+	workload.SetName("name")
+	workload.SetNamespace("namespace")
+	max := intstr.FromString("0.5")
+	var lastQuery url.Values
+	newController := func(address string, batchMetric []v1alpha1.CanaryMetric) *Controller {
+		return &Controller{
+			recorder:         event.NewNopRecorder(),
+			parentController: &rollout,
+			targetWorkload:   workload,
+			rolloutSpec: &v1alpha1.RolloutPlan{
+				CanaryMetric: []v1alpha1.CanaryMetric{{
+					Name:           "error-rate",
+					Query:          `rate(errors{namespace="{{ .Namespace }}"}[{{ .Interval }}])`,
+					MetricProvider: &v1alpha1.MetricProvider{Address: address},
+					MetricsRange:   &v1alpha1.MetricsExpectedRange{Max: &max},
+				}},
+				RolloutBatches: []v1alpha1.RolloutBatch{{CanaryMetric: batchMetric}},
+			},
+			rolloutStatus: &v1alpha1.RolloutStatus{
+				RollingState:      v1alpha1.RollingInBatchesState,
+				BatchRollingState: v1alpha1.BatchVerifyingState,
+			},
+		}
+	}
+
+	t.Run("metrics in range over the interval", func(t *testing.T) {
+		server := newFakePrometheus(matrixResponse("0.1", "0.3", "0.2"), &lastQuery)
+		defer server.Close()
+		r := newController(server.URL, []v1alpha1.CanaryMetric{{
+			Name:           "latency",
+			Interval:       "5m",
+			Query:          `latency{app="{{ .Name }}"}[{{ .Interval }}]`,
+			MetricProvider: &v1alpha1.MetricProvider{Type: v1alpha1.PrometheusMetricProvider, Address: server.URL},
+		}})
+		assert.True(t, r.evaluateCanaryMetrics(ctx))
+		assert.Equal(t, `latency{app="name"}[5m]`, lastQuery.Get("query"))
+		assert.Equal(t, "30", lastQuery.Get("step"))
+		start, _ := strconv.ParseInt(lastQuery.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(lastQuery.Get("end"), 10, 64)
+		assert.Equal(t, int64(300), end-start)
+		assert.Equal(t, v1alpha1.RollingInBatchesState, r.rolloutStatus.RollingState)
+		assert.Len(t, r.rolloutStatus.MetricEvaluations, 2)
+		assert.True(t, r.rolloutStatus.MetricEvaluations[0].Succeeded)
+		assert.Equal(t, "0.2", r.rolloutStatus.MetricEvaluations[0].Value)
+	})
+
+	t.Run("metric out of range in the interval fails the rollout", func(t *testing.T) {
+		server := newFakePrometheus(matrixResponse("0.1", "0.8", "0.2"), &lastQuery)
+		defer server.Close()
+		r := newController(server.URL, nil)
+		assert.False(t, r.evaluateCanaryMetrics(ctx))
+		assert.Equal(t, `rate(errors{namespace="namespace"}[1m])`, lastQuery.Get("query"))
+		assert.Equal(t, v1alpha1.RolloutFailingState, r.rolloutStatus.RollingState)
+		assert.Len(t, r.rolloutStatus.MetricEvaluations, 1)
+		assert.False(t, r.rolloutStatus.MetricEvaluations[0].Succeeded)
+		assert.Equal(t, "0.8", r.rolloutStatus.MetricEvaluations[0].Value)
+	})
+
+	t.Run("unreachable metric server retries until the limit", func(t *testing.T) {
+		server := newFakePrometheus(matrixResponse("0.1"), nil)
+		server.Close()
+		r := newController(server.URL, nil)
+		for i := 1; i <= maxMetricEvaluationRetries; i++ {
+			assert.False(t, r.evaluateCanaryMetrics(ctx))
+			assert.Equal(t, v1alpha1.RollingInBatchesState, r.rolloutStatus.RollingState)
+			assert.Len(t, r.rolloutStatus.MetricEvaluations, 1)
+			assert.False(t, r.rolloutStatus.MetricEvaluations[0].Succeeded)
+			assert.Equal(t, int32(i), r.rolloutStatus.MetricEvaluations[0].Retries)
+		}
+		assert.False(t, r.evaluateCanaryMetrics(ctx))
+		assert.Equal(t, v1alpha1.RolloutFailingState, r.rolloutStatus.RollingState)
+	})
+
+	t.Run("the retries start over in a new batch", func(t *testing.T) {
+		server := newFakePrometheus(matrixResponse("0.1"), nil)
+		server.Close()
+		r := newController(server.URL, nil)
+		r.rolloutStatus.MetricEvaluations = []v1alpha1.CanaryMetricEvaluation{{
+			Name: "error-rate", Batch: 0, Retries: maxMetricEvaluationRetries,
+		}}
+		r.rolloutStatus.CurrentBatch = 1
+		r.rolloutSpec.RolloutBatches = append(r.rolloutSpec.RolloutBatches, v1alpha1.RolloutBatch{})
+		assert.False(t, r.evaluateCanaryMetrics(ctx))
+		assert.Equal(t, v1alpha1.RollingInBatchesState, r.rolloutStatus.RollingState)
+		assert.Equal(t, int32(1), r.rolloutStatus.MetricEvaluations[0].Retries)
+	})
+
+	t.Run("metric without a query fails the rollout", func(t *testing.T) {
+		r := newController("http://prometheus:9090", nil)
+		r.rolloutSpec.CanaryMetric[0].Query = ""
+		assert.False(t, r.evaluateCanaryMetrics(ctx))
+		assert.Equal(t, v1alpha1.RolloutFailingState, r.rolloutStatus.RollingState)
+	})
+}
@@ -2,13 +2,17 @@ package rollout
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"time"
 
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	kruisev1 "github.com/openkruise/kruise-api/apps/v1alpha1"
 	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -18,6 +22,7 @@ import (
 
 	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
 	"github.com/oam-dev/kubevela/pkg/controller/common"
+	"github.com/oam-dev/kubevela/pkg/controller/common/rollout/traffic"
 	"github.com/oam-dev/kubevela/pkg/controller/common/rollout/workloads"
 	"github.com/oam-dev/kubevela/pkg/oam"
 )
@@ -110,7 +115,7 @@ func (r *Controller) Reconcile(ctx context.Context) (res reconcile.Result, statu
 		}
 
 	case v1alpha1.InitializingState:
-		if err = r.initializeRollout(ctx); err == nil {
+		if r.initializeRollout(ctx) {
 			initialized, err := workloadController.Initialize(ctx)
 			if err != nil {
 				r.rolloutStatus.RolloutFailing(err.Error())
@@ -123,12 +128,12 @@ func (r *Controller) Reconcile(ctx context.Context) (res reconcile.Result, statu
 		r.reconcileBatchInRolling(ctx, workloadController)
 
 	case v1alpha1.RolloutFailingState:
-		if succeed := workloadController.Finalize(ctx, false); succeed {
+		if r.finalizeTraffic(ctx, false) && workloadController.Finalize(ctx, false) {
 			r.finalizeRollout(ctx)
 		}
 
 	case v1alpha1.FinalisingState:
-		if succeed := workloadController.Finalize(ctx, true); succeed {
+		if r.finalizeTraffic(ctx, true) && workloadController.Finalize(ctx, true) {
 			r.finalizeRollout(ctx)
 		}
 
@@ -145,6 +150,45 @@ func (r *Controller) Reconcile(ctx context.Context) (res reconcile.Result, statu
 	return res, r.rolloutStatus
 }
 
+// FinalizeModifiedRollout stops a rollout whose target or source is modified in the middle of it so that it
+// can start over. The batch in progress is finished first so that its pods are not left half upgraded, a broken
+// batch fails the rollout as usual. The batch of a paused rollout is abandoned as it would never finish.
+// It returns true once the workloads are released and it's safe to restart the rollout
+func (r *Controller) FinalizeModifiedRollout(ctx context.Context) (bool, *v1alpha1.RolloutStatus) {
+	klog.InfoS("finalize the modified rollout", "rollout state", r.rolloutStatus.RollingState,
+		"batch rolling state", r.rolloutStatus.BatchRollingState, "current batch", r.rolloutStatus.CurrentBatch)
+	workloadController, err := r.GetWorkloadController()
+	if err != nil {
+		// we never touched a workload we don't support
+		return true, r.rolloutStatus
+	}
+
+	switch r.rolloutStatus.RollingState {
+	case v1alpha1.VerifyingSpecState, v1alpha1.RolloutSucceedState, v1alpha1.RolloutFailedState:
+		// the workloads are not claimed by the rollout
+		return true, r.rolloutStatus
+
+	case v1alpha1.RollingInBatchesState:
+		if !r.rolloutSpec.Paused && batchInProgress(r.rolloutStatus.BatchRollingState) {
+			// the batch either becomes ready or fails the rollout, we finalize it either way after that
+			klog.InfoS("finish the batch in progress before the rollout starts over",
+				"current batch", r.rolloutStatus.CurrentBatch)
+			r.reconcileBatchInRolling(ctx, workloadController)
+			return false, r.rolloutStatus
+		}
+	}
+
+	// only a rollout that has finished all its batches is finalized as a success
+	succeed := r.rolloutStatus.RollingState == v1alpha1.FinalisingState
+	return r.finalizeTraffic(ctx, succeed) && workloadController.Finalize(ctx, succeed), r.rolloutStatus
+}
+
+// batchInProgress checks if the batch has started to upgrade the pods but is not ready yet
+func batchInProgress(state v1alpha1.BatchRollingState) bool {
+	return state == v1alpha1.BatchInRollingState || state == v1alpha1.BatchVerifyingState ||
+		state == v1alpha1.BatchFinalizingState
+}
+
 // reconcile logic when we are in the middle of rollout, we have to go through finalizing state before succeed or fail
 func (r *Controller) reconcileBatchInRolling(ctx context.Context, workloadController workloads.WorkloadController) {
 	if r.rolloutSpec.Paused {
@@ -169,19 +213,18 @@ func (r *Controller) reconcileBatchInRolling(ctx context.Context, workloadContro
 		upgradeDone, err := workloadController.RolloutOneBatchPods(ctx)
 		if err != nil {
 			r.rolloutStatus.RolloutFailing(err.Error())
-		} else if upgradeDone {
+		} else if upgradeDone && r.shiftBatchTraffic(ctx) {
 			r.rolloutStatus.StateTransition(v1alpha1.RolloutOneBatchEvent)
 		}
 
 	case v1alpha1.BatchVerifyingState:
 		// verifying if the application is ready to roll
 		// need to check if they meet the availability requirements in the rollout spec.
-		// TODO: evaluate any metrics/analysis
 		// TODO: We may need to go back to rollout again if the size of the resource can change behind our back
 		verified, err := workloadController.CheckOneBatchPods(ctx)
 		if err != nil {
 			r.rolloutStatus.RolloutFailing(err.Error())
-		} else if verified {
+		} else if verified && r.evaluateCanaryMetrics(ctx) {
 			r.rolloutStatus.StateTransition(v1alpha1.OneBatchAvailableEvent)
 		}
 
@@ -204,25 +247,20 @@ func (r *Controller) reconcileBatchInRolling(ctx context.Context, workloadContro
 	}
 }
 
-// all the common initialize work before we rollout
-// TODO: fail the rollout if the webhook call is explicitly rejected (through http status code)
-func (r *Controller) initializeRollout(ctx context.Context) error {
+// all the common initialize work before we rollout, returns true only if all the webhooks let us proceed
+func (r *Controller) initializeRollout(ctx context.Context) bool {
 	// call the pre-rollout webhooks
 	for _, rw := range r.rolloutSpec.RolloutWebhooks {
 		if rw.Type == v1alpha1.InitializeRolloutHook {
-			err := callWebhook(ctx, r.parentController, string(v1alpha1.InitializingState), rw)
-			if err != nil {
-				klog.ErrorS(err, "failed to invoke a webhook",
-					"webhook name", rw.Name, "webhook end point", rw.URL)
-				r.rolloutStatus.RolloutRetry("failed to invoke a webhook")
-				return err
+			if !r.invokeWebhook(ctx, string(v1alpha1.InitializingState), rw) {
+				return false
 			}
 			klog.InfoS("successfully invoked a pre rollout webhook", "webhook name", rw.Name, "webhook end point",
 				rw.URL)
 		}
 	}
 
-	return nil
+	return true
 }
 
 // all the common initialize work before we rollout one batch of resources
@@ -231,11 +269,7 @@ func (r *Controller) initializeOneBatch(ctx context.Context) {
 	// call all the pre-batch rollout webhooks
 	for _, rh := range rolloutHooks {
 		if rh.Type == v1alpha1.PreBatchRolloutHook {
-			err := callWebhook(ctx, r.parentController, string(v1alpha1.BatchInitializingState), rh)
-			if err != nil {
-				klog.ErrorS(err, "failed to invoke a webhook",
-					"webhook name", rh.Name, "webhook end point", rh.URL)
-				r.rolloutStatus.RolloutRetry("failed to invoke a webhook")
+			if !r.invokeWebhook(ctx, string(v1alpha1.BatchInitializingState), rh) {
 				return
 			}
 			klog.InfoS("successfully invoked a pre batch webhook", "webhook name", rh.Name, "webhook end point",
@@ -245,6 +279,152 @@ func (r *Controller) initializeOneBatch(ctx context.Context) {
 	r.rolloutStatus.StateTransition(v1alpha1.InitializedOneBatchEvent)
 }
 
+// evaluate all the canary metrics that apply to the current batch against the target workload, returns true only if
+// all of them are in range. The rollout fails if any metric is out of its expected range, cannot be evaluated or fails
+// to get its value too many times in a row
+func (r *Controller) evaluateCanaryMetrics(ctx context.Context) bool {
+	currentBatch := r.rolloutStatus.CurrentBatch
+	for _, metric := range gatherAllCanaryMetrics(r.rolloutSpec, int(currentBatch)) {
+		if err := ValidateCanaryMetric(metric); err != nil {
+			r.rolloutStatus.RecordMetricEvaluation(newMetricEvaluation(metric.Name, currentBatch, 0,
+				false, err.Error()))
+			r.rolloutStatus.RolloutFailing(err.Error())
+			return false
+		}
+		value, succeeded, msg, err := evaluateCanaryMetric(ctx, r.targetWorkload, metric)
+		if err != nil {
+			klog.ErrorS(err, "failed to evaluate a canary metric", "metric name", metric.Name,
+				"current batch", currentBatch)
+			evaluation := newMetricEvaluation(metric.Name, currentBatch, value, false, err.Error())
+			evaluation.Retries = 1
+			if last := r.rolloutStatus.GetMetricEvaluation(metric.Name); last != nil && last.Batch == currentBatch {
+				evaluation.Retries = last.Retries + 1
+			}
+			r.rolloutStatus.RecordMetricEvaluation(evaluation)
+			if evaluation.Retries > maxMetricEvaluationRetries {
+				r.recorder.Event(r.parentController, event.Warning("Canary metric failed",
+					fmt.Errorf("metric %s cannot be evaluated in batch %d: %w", metric.Name, currentBatch, err)))
+				r.rolloutStatus.RolloutFailing(fmt.Sprintf("failed to evaluate the canary metric %s %d times in a row",
+					metric.Name, evaluation.Retries))
+			} else {
+				r.rolloutStatus.RolloutRetry(fmt.Sprintf("failed to evaluate the canary metric %s", metric.Name))
+			}
+			return false
+		}
+		r.rolloutStatus.RecordMetricEvaluation(newMetricEvaluation(metric.Name, currentBatch, value,
+			succeeded, msg))
+		if !succeeded {
+			r.recorder.Event(r.parentController, event.Warning("Canary metric failed",
+				fmt.Errorf("metric %s failed in batch %d: %s", metric.Name, currentBatch, msg)))
+			r.rolloutStatus.RolloutFailing(fmt.Sprintf("the canary metric %s is out of range: %s",
+				metric.Name, msg))
+			return false
+		}
+	}
+	return true
+}
+
+// invoke a webhook and act on its decision, returns true only if the webhook lets the rollout proceed
+func (r *Controller) invokeWebhook(ctx context.Context, phase string, rw v1alpha1.RolloutWebhook) bool {
+	resp, err := r.callWebhook(ctx, phase, rw)
+	if err != nil {
+		klog.ErrorS(err, "failed to invoke a webhook", "webhook name", rw.Name, "webhook end point", rw.URL)
+		resp = &v1alpha1.RolloutWebhookResponse{Decision: v1alpha1.WebhookRetry, Message: err.Error()}
+	}
+	webhookStatus := v1alpha1.RolloutWebhookStatus{
+		Name:         rw.Name,
+		Phase:        phase,
+		Decision:     resp.Decision,
+		Message:      resp.Message,
+		LastCallTime: metav1.Now(),
+	}
+	if resp.Decision == v1alpha1.WebhookRetry {
+		webhookStatus.Retries = 1
+		if last := r.rolloutStatus.GetWebhookStatus(rw.Name, phase); last != nil {
+			webhookStatus.Retries = last.Retries + 1
+		}
+	}
+	r.rolloutStatus.RecordWebhookStatus(webhookStatus)
+
+	msg := fmt.Sprintf("webhook %s decided to %s", rw.Name, resp.Decision)
+	if len(resp.Message) != 0 {
+		msg = fmt.Sprintf("%s: %s", msg, resp.Message)
+	}
+	condition := v1alpha1.NewNegativeCondition(v1alpha1.RolloutWebhookDecided, msg)
+	condition.Reason = runtimev1alpha1.ConditionReason(resp.Decision)
+	if resp.Decision == v1alpha1.WebhookProceed {
+		condition.Status = corev1.ConditionTrue
+	}
+	r.rolloutStatus.SetConditions(condition)
+	klog.InfoS("a webhook made a decision", "webhook name", rw.Name, "phase", phase,
+		"decision", resp.Decision, "message", resp.Message)
+
+	switch resp.Decision {
+	case v1alpha1.WebhookProceed:
+		if paused := r.rolloutStatus.GetCondition(v1alpha1.BatchPaused); paused.Status == corev1.ConditionTrue &&
+			paused.Reason == runtimev1alpha1.ConditionReason(v1alpha1.WebhookPause) {
+			r.recorder.Event(r.parentController, event.Normal("Rollout resumed by webhook", msg))
+			r.rolloutStatus.SetConditions(v1alpha1.NewNegativeCondition(v1alpha1.BatchPaused, msg))
+		}
+		return true
+
+	case v1alpha1.WebhookPause:
+		// the rollout stays paused until the webhook decides otherwise, the webhook is called again
+		// on the next reconcile but unlike a retry it never counts towards the retry limit
+		r.recorder.Event(r.parentController, event.Normal("Rollout paused by webhook", msg))
+		paused := v1alpha1.NewPositiveCondition(v1alpha1.BatchPaused)
+		paused.Reason = runtimev1alpha1.ConditionReason(v1alpha1.WebhookPause)
+		paused.Message = msg
+		r.rolloutStatus.SetConditions(paused)
+		r.rolloutStatus.RolloutRetry(msg)
+
+	case v1alpha1.WebhookAbort:
+		r.abortByWebhook(msg)
+
+	default:
+		if rw.RetryLimit != nil && webhookStatus.Retries > *rw.RetryLimit {
+			r.abortByWebhook(fmt.Sprintf("%s, exceeded the retry limit %d", msg, *rw.RetryLimit))
+		} else {
+			r.rolloutStatus.RolloutRetry(msg)
+		}
+	}
+	return false
+}
+
+// call a webhook with the signing key if there is one
+func (r *Controller) callWebhook(ctx context.Context, phase string,
+	rw v1alpha1.RolloutWebhook) (*v1alpha1.RolloutWebhookResponse, error) {
+	var signingKey []byte
+	if rw.SigningSecretRef != nil {
+		// never sign with a secret out of the namespace of the rollout, the webhook URL is chosen by the user
+		namespace := r.parentController.GetNamespace()
+		if len(rw.SigningSecretRef.Namespace) != 0 && rw.SigningSecretRef.Namespace != namespace {
+			return nil, fmt.Errorf("the signing secret of the webhook has to be in the namespace %s", namespace)
+		}
+		var secret corev1.Secret
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace,
+			Name: rw.SigningSecretRef.Name}, &secret); err != nil {
+			return nil, fmt.Errorf("failed to get the signing secret of the webhook: %w", err)
+		}
+		signingKey = secret.Data[rw.SigningSecretRef.Key]
+		if len(signingKey) == 0 {
+			return nil, fmt.Errorf("the signing secret of the webhook has no key %s", rw.SigningSecretRef.Key)
+		}
+	}
+	return callWebhook(ctx, r.parentController, phase, rw, signingKey)
+}
+
+// a webhook explicitly blocks the rollout, the workload is already finalized if we are finalizing
+func (r *Controller) abortByWebhook(msg string) {
+	r.recorder.Event(r.parentController, event.Warning("Rollout aborted by webhook", errors.New(msg)))
+	if r.rolloutStatus.RollingState == v1alpha1.FinalisingState ||
+		r.rolloutStatus.RollingState == v1alpha1.RolloutFailingState {
+		r.rolloutStatus.RolloutFailed(msg)
+		return
+	}
+	r.rolloutStatus.RolloutFailing(msg)
+}
+
 func (r *Controller) gatherAllWebhooks() []v1alpha1.RolloutWebhook {
 	// we go through the rollout level webhooks first
 	rolloutHooks := r.rolloutSpec.RolloutWebhooks
@@ -257,13 +437,36 @@ func (r *Controller) gatherAllWebhooks() []v1alpha1.RolloutWebhook {
 
 // check if we can move to the next batch
 func (r *Controller) tryMovingToNextBatch() {
-	if r.rolloutSpec.BatchPartition == nil || *r.rolloutSpec.BatchPartition > r.rolloutStatus.CurrentBatch {
-		klog.InfoS("ready to rollout the next batch", "current batch", r.rolloutStatus.CurrentBatch)
-		r.rolloutStatus.StateTransition(v1alpha1.BatchRolloutApprovedEvent)
-	} else {
+	currentBatch := r.rolloutStatus.CurrentBatch
+	if r.rolloutSpec.BatchPartition != nil && *r.rolloutSpec.BatchPartition <= currentBatch {
 		klog.V(common.LogDebug).InfoS("the current batch is waiting to move on", "current batch",
-			r.rolloutStatus.CurrentBatch)
+			currentBatch)
+		return
+	}
+	if int(currentBatch) < len(r.rolloutSpec.RolloutBatches) &&
+		r.rolloutSpec.RolloutBatches[currentBatch].RequireApproval {
+		switch getBatchApproval(r.parentController, currentBatch) {
+		case v1alpha1.BatchApproved:
+			klog.InfoS("the current batch is approved", "current batch", currentBatch)
+			r.recorder.Event(r.parentController, event.Normal("Batch Approved",
+				fmt.Sprintf("Batch %d is approved to move on", currentBatch)))
+			r.rolloutStatus.SetConditions(v1alpha1.NewPositiveCondition(v1alpha1.BatchApproval))
+		case v1alpha1.BatchRejected:
+			klog.InfoS("the current batch is rejected", "current batch", currentBatch)
+			r.recorder.Event(r.parentController, event.Warning("Batch Rejected",
+				fmt.Errorf("batch %d is rejected", currentBatch)))
+			r.rolloutStatus.RolloutFailing(fmt.Sprintf("batch %d is rejected", currentBatch))
+			return
+		default:
+			klog.V(common.LogDebug).InfoS("the current batch is waiting for approval", "current batch",
+				currentBatch)
+			r.rolloutStatus.SetConditions(v1alpha1.NewNegativeCondition(v1alpha1.BatchApproval,
+				fmt.Sprintf("batch %d is waiting for approval", currentBatch)))
+			return
+		}
 	}
+	klog.InfoS("ready to rollout the next batch", "current batch", currentBatch)
+	r.rolloutStatus.StateTransition(v1alpha1.BatchRolloutApprovedEvent)
 }
 
 func (r *Controller) finalizeOneBatch(ctx context.Context) {
@@ -271,11 +474,7 @@ func (r *Controller) finalizeOneBatch(ctx context.Context) {
 	// call all the post-batch rollout webhooks
 	for _, rh := range rolloutHooks {
 		if rh.Type == v1alpha1.PostBatchRolloutHook {
-			err := callWebhook(ctx, r.parentController, string(v1alpha1.BatchFinalizingState), rh)
-			if err != nil {
-				klog.ErrorS(err, "failed to invoke a webhook",
-					"webhook name", rh.Name, "webhook end point", rh.URL)
-				r.rolloutStatus.RolloutRetry("failed to invoke a webhook")
+			if !r.invokeWebhook(ctx, string(v1alpha1.BatchFinalizingState), rh) {
 				return
 			}
 			klog.InfoS("successfully invoked a post batch webhook", "webhook name", rh.Name, "webhook end point",
@@ -304,11 +503,7 @@ func (r *Controller) finalizeRollout(ctx context.Context) {
 	// call the post-rollout webhooks
 	for _, rw := range r.rolloutSpec.RolloutWebhooks {
 		if rw.Type == v1alpha1.FinalizeRolloutHook {
-			err := callWebhook(ctx, r.parentController, string(r.rolloutStatus.RollingState), rw)
-			if err != nil {
-				klog.ErrorS(err, "failed to invoke a webhook",
-					"webhook name", rw.Name, "webhook end point", rw.URL)
-				r.rolloutStatus.RolloutRetry("failed to invoke a post rollout webhook")
+			if !r.invokeWebhook(ctx, string(r.rolloutStatus.RollingState), rw) {
 				return
 			}
 			klog.InfoS("successfully invoked a post rollout webhook", "webhook name", rw.Name, "webhook end point",
@@ -365,6 +560,53 @@ func (r *Controller) validateRollingBatchStatus(totalSize int) error {
 	return nil
 }
 
+// shift the traffic to the weight of the current batch once its pods are upgraded,
+// returns true if there is nothing to shift or the traffic is shifted
+func (r *Controller) shiftBatchTraffic(ctx context.Context) bool {
+	currentBatch := r.rolloutStatus.CurrentBatch
+	if r.rolloutSpec.TrafficRouting == nil || int(currentBatch) >= len(r.rolloutSpec.RolloutBatches) ||
+		r.rolloutSpec.RolloutBatches[currentBatch].TrafficWeight == nil {
+		return true
+	}
+	trafficController, err := r.GetTrafficController()
+	if err != nil {
+		r.rolloutStatus.RolloutFailing(err.Error())
+		return false
+	}
+	shifted, err := trafficController.ShiftTraffic(ctx, *r.rolloutSpec.RolloutBatches[currentBatch].TrafficWeight)
+	if err != nil {
+		r.rolloutStatus.RolloutFailing(err.Error())
+		return false
+	}
+	return shifted
+}
+
+// route all the traffic to the target if the rollout succeeded or back to the source if not,
+// returns true if there is no traffic routing or the traffic is reset
+func (r *Controller) finalizeTraffic(ctx context.Context, succeed bool) bool {
+	if r.rolloutSpec.TrafficRouting == nil {
+		return true
+	}
+	trafficController, err := r.GetTrafficController()
+	if err != nil {
+		// we never touched the traffic we don't support
+		return true
+	}
+	finalized, err := trafficController.Finalize(ctx, succeed)
+	if err != nil {
+		// we are already finalizing, retry until someone fixes the traffic routing
+		r.rolloutStatus.RolloutRetry(err.Error())
+		return false
+	}
+	return finalized
+}
+
+// GetTrafficController picks the right traffic controller to shift the traffic between the source and the target
+func (r *Controller) GetTrafficController() (traffic.Controller, error) {
+	return traffic.NewTrafficController(r.client, r.recorder, r.parentController, r.rolloutSpec.TrafficRouting,
+		r.targetWorkload.GetNamespace())
+}
+
 // GetWorkloadController pick the right workload controller to work on the workload
 func (r *Controller) GetWorkloadController() (workloads.WorkloadController, error) {
 	kind := r.targetWorkload.GetObjectKind().GroupVersionKind().Kind
@@ -390,6 +632,10 @@ func (r *Controller) GetWorkloadController() (workloads.WorkloadController, erro
 			return workloads.NewDeploymentController(r.client, r.recorder, r.parentController,
 				r.rolloutSpec, r.rolloutStatus, source, target), nil
 		}
+		if r.targetWorkload.GetKind() == reflect.TypeOf(apps.StatefulSet{}).Name() {
+			return workloads.NewStatefulSetController(r.client, r.recorder, r.parentController,
+				r.rolloutSpec, r.rolloutStatus, target), nil
+		}
 	}
 	return nil, fmt.Errorf("the workload kind `%s` is not supported", kind)
 }
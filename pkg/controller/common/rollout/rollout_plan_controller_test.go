@@ -0,0 +1,192 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/controller/common/rollout/traffic"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestFinalizeModifiedRollout(t *testing.T) {
+	ctx := context.TODO()
+	appRollout := &v1alpha2.AppRollout{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha2.SchemeGroupVersion.String(),
+			Kind:       v1alpha2.AppRolloutKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default", UID: "rollout-uid"},
+	}
+	newDeployment := func(name string) *apps.Deployment {
+		return &apps.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "default",
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(appRollout, v1alpha2.AppRolloutKindVersionKind)},
+			},
+			Spec: apps.DeploymentSpec{Replicas: pointer.Int32Ptr(1)},
+		}
+	}
+	newWorkload := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(apps.SchemeGroupVersion.WithKind("Deployment"))
+		u.SetNamespace("default")
+		u.SetName(name)
+		return u
+	}
+	tests := map[string]struct {
+		status           v1alpha1.RolloutStatus
+		paused           bool
+		wantFinalized    bool
+		wantReleased     bool
+		wantRollingState v1alpha1.RollingState
+	}{
+		"verifying the spec": {
+			status:        v1alpha1.RolloutStatus{RollingState: v1alpha1.VerifyingSpecState},
+			wantFinalized: true,
+		},
+		"initializing": {
+			status:        v1alpha1.RolloutStatus{RollingState: v1alpha1.InitializingState},
+			wantFinalized: true,
+			wantReleased:  true,
+		},
+		"between two batches": {
+			status: v1alpha1.RolloutStatus{
+				RollingState:      v1alpha1.RollingInBatchesState,
+				BatchRollingState: v1alpha1.BatchReadyState,
+			},
+			wantFinalized: true,
+			wantReleased:  true,
+		},
+		"in the middle of a batch": {
+			status: v1alpha1.RolloutStatus{
+				RollingState:           v1alpha1.RollingInBatchesState,
+				BatchRollingState:      v1alpha1.BatchInRollingState,
+				RolloutTargetTotalSize: 2,
+			},
+		},
+		"finalizing a batch": {
+			status: v1alpha1.RolloutStatus{
+				RollingState:           v1alpha1.RollingInBatchesState,
+				BatchRollingState:      v1alpha1.BatchFinalizingState,
+				RolloutTargetTotalSize: 2,
+			},
+			// the last batch is done so the rollout is finalized as a success next time
+			wantRollingState: v1alpha1.FinalisingState,
+		},
+		"in the middle of a paused batch": {
+			status: v1alpha1.RolloutStatus{
+				RollingState:      v1alpha1.RollingInBatchesState,
+				BatchRollingState: v1alpha1.BatchVerifyingState,
+			},
+			paused:        true,
+			wantFinalized: true,
+			wantReleased:  true,
+		},
+		"finalizing": {
+			status:        v1alpha1.RolloutStatus{RollingState: v1alpha1.FinalisingState},
+			wantFinalized: true,
+			wantReleased:  true,
+		},
+		"failing": {
+			status:        v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutFailingState},
+			wantFinalized: true,
+			wantReleased:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(common.Scheme, newDeployment("source"), newDeployment("target"))
+			rolloutSpec := &v1alpha1.RolloutPlan{
+				Paused:         tt.paused,
+				RolloutBatches: []v1alpha1.RolloutBatch{{Replicas: intstr.FromString("100%")}},
+			}
+			r := NewRolloutPlanController(c, appRollout, event.NewNopRecorder(), rolloutSpec, &tt.status,
+				newWorkload("target"), newWorkload("source"))
+			finalized, status := r.FinalizeModifiedRollout(ctx)
+			assert.Equal(t, tt.wantFinalized, finalized)
+			if len(tt.wantRollingState) == 0 {
+				tt.wantRollingState = tt.status.RollingState
+			}
+			assert.Equal(t, tt.wantRollingState, status.RollingState)
+			for _, name := range []string{"source", "target"} {
+				var deploy apps.Deployment
+				assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &deploy))
+				if tt.wantReleased {
+					assert.Nil(t, metav1.GetControllerOf(&deploy))
+				} else {
+					assert.NotNil(t, metav1.GetControllerOf(&deploy))
+				}
+			}
+		})
+	}
+}
+
+func TestShiftBatchTraffic(t *testing.T) {
+	ctx := context.TODO()
+	appRollout := &v1alpha2.AppRollout{ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default"}}
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(apps.SchemeGroupVersion.WithKind("Deployment"))
+	target.SetNamespace("default")
+	target.SetName("target")
+	routing := &v1alpha1.TrafficRouting{Provider: v1alpha1.SMITrafficRouting, Name: "reviews",
+		SourceService: "reviews-v1", TargetService: "reviews-v2"}
+	weight := int32(30)
+	tests := map[string]struct {
+		routing      *v1alpha1.TrafficRouting
+		weight       *int32
+		trafficSplit bool
+		wantShifted  bool
+	}{
+		"no traffic routing": {
+			weight:      &weight,
+			wantShifted: true,
+		},
+		"no traffic weight in the batch": {
+			routing:     routing,
+			wantShifted: true,
+		},
+		"the traffic split is not there yet": {
+			routing: routing,
+			weight:  &weight,
+		},
+		"shift the traffic": {
+			routing:      routing,
+			weight:       &weight,
+			trafficSplit: true,
+			wantShifted:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(common.Scheme)
+			if tt.trafficSplit {
+				ts := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+				ts.SetGroupVersionKind(traffic.TrafficSplitGroupVersionKind)
+				ts.SetNamespace("default")
+				ts.SetName("reviews")
+				assert.NoError(t, c.Create(ctx, ts))
+			}
+			rolloutSpec := &v1alpha1.RolloutPlan{
+				TrafficRouting: tt.routing,
+				RolloutBatches: []v1alpha1.RolloutBatch{{TrafficWeight: tt.weight}, {}},
+			}
+			r := NewRolloutPlanController(c, appRollout, event.NewNopRecorder(), rolloutSpec,
+				&v1alpha1.RolloutStatus{RollingState: v1alpha1.RollingInBatchesState}, target, nil)
+			assert.Equal(t, tt.wantShifted, r.shiftBatchTraffic(ctx))
+			assert.Equal(t, v1alpha1.RollingInBatchesState, r.rolloutStatus.RollingState)
+		})
+	}
+}
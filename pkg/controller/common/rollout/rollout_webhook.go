@@ -3,11 +3,15 @@ package rollout
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
@@ -16,8 +20,22 @@ import (
 	"github.com/oam-dev/kubevela/pkg/controller/common"
 )
 
-// issue an http call to the an end ponit
-func makeHTTPRequest(ctx context.Context, webhookEndPoint, method string, payload interface{}) ([]byte, int, error) {
+// the default timeout of one webhook call
+const defaultWebhookTimeout = 10 * time.Second
+
+// webhookSignatureHeader is the http header that carries the HMAC-SHA256 signature of the request body
+const webhookSignatureHeader = "X-Vela-Signature"
+
+// signPayload returns the HMAC-SHA256 signature of the payload in the form of sha256=<hex digest>
+func signPayload(signingKey, payload []byte) string {
+	mac := hmac.New(sha256.New, signingKey)
+	_, _ = mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// issue an http call to the an end ponit, the request is signed if there is a signing key
+func makeHTTPRequest(ctx context.Context, webhookEndPoint, method string, payload interface{},
+	signingKey []byte) ([]byte, int, error) {
 	payloadBin, err := json.Marshal(payload)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
@@ -28,11 +46,21 @@ func makeHTTPRequest(ctx context.Context, webhookEndPoint, method string, payloa
 		return nil, http.StatusInternalServerError, err
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), method, hook.String(), bytes.NewBuffer(payloadBin))
-	if err != nil {
+	newRequest := func() (*http.Request, error) {
+		// the body can only be read once so we need a new request for every try
+		req, err := http.NewRequestWithContext(ctx, method, hook.String(), bytes.NewBuffer(payloadBin))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if len(signingKey) != 0 {
+			req.Header.Set(webhookSignatureHeader, signPayload(signingKey, payloadBin))
+		}
+		return req, nil
+	}
+	if _, err := newRequest(); err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	// issue request with retry
 	var r *http.Response
@@ -42,8 +70,11 @@ func makeHTTPRequest(ctx context.Context, webhookEndPoint, method string, payloa
 			// not sure what not to retry on
 			return true
 		}, func() error {
-			var requestErr error
-			r, requestErr = http.DefaultClient.Do(req.WithContext(ctx))
+			req, requestErr := newRequest()
+			if requestErr != nil {
+				return requestErr
+			}
+			r, requestErr = http.DefaultClient.Do(req)
 			defer func() {
 				if r != nil {
 					_ = r.Body.Close()
@@ -72,9 +103,25 @@ func makeHTTPRequest(ctx context.Context, webhookEndPoint, method string, payloa
 	return body, r.StatusCode, nil
 }
 
-// callWebhook does a HTTP POST to an external service and
-// returns an error if the response status code is non-2xx
-func callWebhook(ctx context.Context, resource klog.KMetadata, phase string, rw v1alpha1.RolloutWebhook) error {
+// parseWebhookResponse returns the decision in the webhook response body, it returns nil if the body
+// does not contain a decision
+func parseWebhookResponse(body []byte) (*v1alpha1.RolloutWebhookResponse, error) {
+	var resp v1alpha1.RolloutWebhookResponse
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Decision) == 0 {
+		return nil, nil
+	}
+	switch resp.Decision {
+	case v1alpha1.WebhookProceed, v1alpha1.WebhookRetry, v1alpha1.WebhookPause, v1alpha1.WebhookAbort:
+		return &resp, nil
+	default:
+		return nil, fmt.Errorf("the webhook replies with an unknown decision `%s`", resp.Decision)
+	}
+}
+
+// callWebhook does a HTTP call to an external service and returns its decision on the rollout
+// the decision in the body of a 2xx response wins, otherwise a non-expected status code returns an error
+func callWebhook(ctx context.Context, resource klog.KMetadata, phase string, rw v1alpha1.RolloutWebhook,
+	signingKey []byte) (*v1alpha1.RolloutWebhookResponse, error) {
 	payload := v1alpha1.RolloutWebhookPayload{
 		Name:      resource.GetName(),
 		Namespace: resource.GetNamespace(),
@@ -88,16 +135,32 @@ func callWebhook(ctx context.Context, resource klog.KMetadata, phase string, rw
 	if len(rw.Method) == 0 {
 		rw.Method = http.MethodPost
 	}
-	_, status, err := makeHTTPRequest(ctx, rw.URL, rw.Method, payload)
+	timeout := defaultWebhookTimeout
+	if rw.TimeoutSeconds != nil {
+		timeout = time.Duration(*rw.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	body, status, err := makeHTTPRequest(ctx, rw.URL, rw.Method, payload, signingKey)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// only a successful reply can decide on the rollout, an error page may well contain anything
+	if status >= http.StatusOK && status < http.StatusMultipleChoices {
+		resp, err := parseWebhookResponse(body)
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil {
+			return resp, nil
+		}
 	}
 	if len(rw.ExpectedStatus) == 0 {
 		if status > http.StatusAccepted {
 			err := fmt.Errorf("we fail the webhook request based on status, http status = %d", status)
-			return err
+			return nil, err
 		}
-		return nil
+		return &v1alpha1.RolloutWebhookResponse{Decision: v1alpha1.WebhookProceed}, nil
 	}
 	// check if the returned status is expected
 	accepted := false
@@ -110,7 +173,7 @@ func callWebhook(ctx context.Context, resource klog.KMetadata, phase string, rw
 	if !accepted {
 		err := fmt.Errorf("http request to the webhook not accepeted, http status = %d", status)
 		klog.V(common.LogDebug).InfoS("the status is not expected", "expected status", rw.ExpectedStatus)
-		return err
+		return nil, err
 	}
-	return nil
+	return &v1alpha1.RolloutWebhookResponse{Decision: v1alpha1.WebhookProceed}, nil
 }
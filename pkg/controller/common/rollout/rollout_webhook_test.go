@@ -2,13 +2,25 @@ package rollout
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
 	"github.com/oam-dev/kubevela/pkg/oam"
@@ -101,7 +113,7 @@ func Test_MakeHTTPRequest(t *testing.T) {
 			if len(tt.url) == 0 {
 				tt.url = mockUrl
 			}
-			gotReply, gotCode, gotErr := makeHTTPRequest(ctx, "http://"+tt.url, tt.method, tt.payload)
+			gotReply, gotCode, gotErr := makeHTTPRequest(ctx, "http://"+tt.url, tt.method, tt.payload, nil)
 			if gotCode != tt.want.statusCode {
 				t.Errorf("\n%s\nr.Reconcile(...): want code `%d`, got code:`%d`\n", testName, tt.want.statusCode,
 					gotCode)
@@ -200,7 +212,7 @@ func Test_callWebhook(t *testing.T) {
 			testServer := NewMock(http.MethodPost, tt.returnedStatusCode, body)
 			defer testServer.Close()
 
-			gotErr := callWebhook(ctx, tt.args.resource, tt.args.phase, tt.args.rw)
+			_, gotErr := callWebhook(ctx, tt.args.resource, tt.args.phase, tt.args.rw, nil)
 			if (tt.wantErr == nil && gotErr != nil) || (tt.wantErr != nil && gotErr == nil) {
 				t.Errorf("\n%s\nr.Reconcile(...): want error `%s`, got error:`%s`\n", name, tt.wantErr, gotErr)
 			}
@@ -226,3 +238,285 @@ func NewMock(method string, statusCode int, body string) *httptest.Server {
 	ts.Start()
 	return ts
 }
+
+// newDecisionServer returns a webhook server that replies with the given status and body
+// and records the signature header of the last request
+func newDecisionServer(statusCode int, body string, signature *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if signature != nil {
+			*signature = req.Header.Get(webhookSignatureHeader)
+		}
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func Test_callWebhookDecision(t *testing.T) {
+	ctx := context.TODO()
+	res := v1alpha1.PodSpecWorkload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+	}
+	tests := map[string]struct {
+		statusCode   int
+		body         string
+		wantDecision v1alpha1.WebhookDecision
+		wantMessage  string
+		wantErr      bool
+	}{
+		"no decision in the body": {
+			statusCode:   http.StatusOK,
+			body:         "all good",
+			wantDecision: v1alpha1.WebhookProceed,
+		},
+		"proceed": {
+			statusCode:   http.StatusOK,
+			body:         `{"decision":"proceed"}`,
+			wantDecision: v1alpha1.WebhookProceed,
+		},
+		"pause": {
+			statusCode:   http.StatusOK,
+			body:         `{"decision":"pause","message":"change freeze"}`,
+			wantDecision: v1alpha1.WebhookPause,
+			wantMessage:  "change freeze",
+		},
+		"abort": {
+			statusCode:   http.StatusOK,
+			body:         `{"decision":"abort","message":"not approved"}`,
+			wantDecision: v1alpha1.WebhookAbort,
+			wantMessage:  "not approved",
+		},
+		"decision with a client error status is not trusted": {
+			statusCode: http.StatusForbidden,
+			body:       `{"decision":"proceed"}`,
+			wantErr:    true,
+		},
+		"decision with a server error status is not trusted": {
+			statusCode: http.StatusServiceUnavailable,
+			body:       `{"decision":"proceed"}`,
+			wantErr:    true,
+		},
+		"client error without a decision": {
+			statusCode: http.StatusForbidden,
+			body:       `{"message":"not approved"}`,
+			wantErr:    true,
+		},
+		"unknown decision": {
+			statusCode: http.StatusOK,
+			body:       `{"decision":"maybe"}`,
+			wantErr:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := newDecisionServer(tt.statusCode, tt.body, nil)
+			defer server.Close()
+			resp, err := callWebhook(ctx, &res, string(v1alpha1.InitializingState),
+				v1alpha1.RolloutWebhook{URL: server.URL}, nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantDecision, resp.Decision)
+			assert.Equal(t, tt.wantMessage, resp.Message)
+		})
+	}
+}
+
+func Test_callWebhookSignature(t *testing.T) {
+	ctx := context.TODO()
+	res := v1alpha1.PodSpecWorkload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+	}
+	var signature string
+	server := newDecisionServer(http.StatusOK, "", &signature)
+	defer server.Close()
+	rw := v1alpha1.RolloutWebhook{URL: server.URL}
+	_, err := callWebhook(ctx, &res, string(v1alpha1.InitializingState), rw, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, signature)
+
+	key := []byte("secret")
+	_, err = callWebhook(ctx, &res, string(v1alpha1.InitializingState), rw, key)
+	assert.NoError(t, err)
+	payload, _ := json.Marshal(v1alpha1.RolloutWebhookPayload{
+		Name:      "name",
+		Namespace: "namespace",
+		Phase:     string(v1alpha1.InitializingState),
+	})
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+}
+
+func Test_callWebhookTimeout(t *testing.T) {
+	ctx := context.TODO()
+	res := v1alpha1.PodSpecWorkload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(2 * time.Second)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	_, err := callWebhook(ctx, &res, string(v1alpha1.InitializingState),
+		v1alpha1.RolloutWebhook{URL: server.URL, TimeoutSeconds: pointer.Int32Ptr(1)}, nil)
+	assert.Error(t, err)
+}
+
+func Test_invokeWebhook(t *testing.T) {
+	ctx := context.TODO()
+	res := v1alpha1.PodSpecWorkload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+		},
+	}
+	signingSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-key", Namespace: "namespace"},
+		Data:       map[string][]byte{"key": []byte("secret")},
+	}
+	newController := func(state v1alpha1.RollingState) *Controller {
+		return &Controller{
+			client:           fake.NewFakeClientWithScheme(scheme.Scheme, &signingSecret),
+			recorder:         event.NewNopRecorder(),
+			parentController: &res,
+			rolloutSpec:      &v1alpha1.RolloutPlan{},
+			rolloutStatus: &v1alpha1.RolloutStatus{
+				RollingState:      state,
+				BatchRollingState: v1alpha1.BatchInitializingState,
+			},
+		}
+	}
+	decisionCondition := func(r *Controller) runtimev1alpha1.Condition {
+		return r.rolloutStatus.GetCondition(v1alpha1.RolloutWebhookDecided)
+	}
+
+	t.Run("proceed", func(t *testing.T) {
+		var signature string
+		server := newDecisionServer(http.StatusOK, `{"decision":"proceed"}`, &signature)
+		defer server.Close()
+		r := newController(v1alpha1.InitializingState)
+		rw := v1alpha1.RolloutWebhook{Name: "change", URL: server.URL,
+			SigningSecretRef: &runtimev1alpha1.SecretKeySelector{
+				SecretReference: runtimev1alpha1.SecretReference{Name: "webhook-key", Namespace: "namespace"},
+				Key:             "key",
+			}}
+		assert.True(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState), rw))
+		assert.NotEmpty(t, signature)
+		assert.Equal(t, corev1.ConditionTrue, decisionCondition(r).Status)
+		assert.Equal(t, v1alpha1.WebhookProceed, r.rolloutStatus.WebhookStatuses[0].Decision)
+	})
+
+	t.Run("missing signing secret retries", func(t *testing.T) {
+		server := newDecisionServer(http.StatusOK, `{"decision":"proceed"}`, nil)
+		defer server.Close()
+		r := newController(v1alpha1.InitializingState)
+		rw := v1alpha1.RolloutWebhook{Name: "change", URL: server.URL,
+			SigningSecretRef: &runtimev1alpha1.SecretKeySelector{
+				SecretReference: runtimev1alpha1.SecretReference{Name: "missing", Namespace: "namespace"},
+				Key:             "key",
+			}}
+		assert.False(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState), rw))
+		assert.Equal(t, v1alpha1.InitializingState, r.rolloutStatus.RollingState)
+		assert.Equal(t, v1alpha1.WebhookRetry, r.rolloutStatus.WebhookStatuses[0].Decision)
+	})
+
+	t.Run("signing secret out of the rollout namespace is never read", func(t *testing.T) {
+		var signature string
+		server := newDecisionServer(http.StatusOK, `{"decision":"proceed"}`, &signature)
+		defer server.Close()
+		r := newController(v1alpha1.InitializingState)
+		rw := v1alpha1.RolloutWebhook{Name: "change", URL: server.URL,
+			SigningSecretRef: &runtimev1alpha1.SecretKeySelector{
+				SecretReference: runtimev1alpha1.SecretReference{Name: "webhook-key", Namespace: "kube-system"},
+				Key:             "key",
+			}}
+		assert.False(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState), rw))
+		assert.Empty(t, signature)
+		assert.Equal(t, v1alpha1.WebhookRetry, r.rolloutStatus.WebhookStatuses[0].Decision)
+	})
+
+	t.Run("pause never fails the rollout", func(t *testing.T) {
+		server := newDecisionServer(http.StatusOK, `{"decision":"pause","message":"change freeze"}`, nil)
+		defer server.Close()
+		r := newController(v1alpha1.InitializingState)
+		rw := v1alpha1.RolloutWebhook{Name: "change", URL: server.URL, RetryLimit: pointer.Int32Ptr(0)}
+		for i := 0; i < 3; i++ {
+			assert.False(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState), rw))
+		}
+		assert.Equal(t, v1alpha1.InitializingState, r.rolloutStatus.RollingState)
+		assert.Equal(t, corev1.ConditionFalse, decisionCondition(r).Status)
+		assert.Equal(t, "webhook change decided to pause: change freeze", decisionCondition(r).Message)
+		assert.Equal(t, int32(0), r.rolloutStatus.WebhookStatuses[0].Retries)
+		paused := r.rolloutStatus.GetCondition(v1alpha1.BatchPaused)
+		assert.Equal(t, corev1.ConditionTrue, paused.Status)
+		assert.Equal(t, "webhook change decided to pause: change freeze", paused.Message)
+	})
+
+	t.Run("proceed resumes a paused rollout", func(t *testing.T) {
+		pause := newDecisionServer(http.StatusOK, `{"decision":"pause"}`, nil)
+		defer pause.Close()
+		proceed := newDecisionServer(http.StatusOK, `{"decision":"proceed"}`, nil)
+		defer proceed.Close()
+		r := newController(v1alpha1.InitializingState)
+		assert.False(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState),
+			v1alpha1.RolloutWebhook{Name: "change", URL: pause.URL}))
+		assert.Equal(t, corev1.ConditionTrue, r.rolloutStatus.GetCondition(v1alpha1.BatchPaused).Status)
+		assert.True(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState),
+			v1alpha1.RolloutWebhook{Name: "change", URL: proceed.URL}))
+		assert.Equal(t, corev1.ConditionFalse, r.rolloutStatus.GetCondition(v1alpha1.BatchPaused).Status)
+	})
+
+	t.Run("proceed leaves a rollout paused by the user alone", func(t *testing.T) {
+		server := newDecisionServer(http.StatusOK, `{"decision":"proceed"}`, nil)
+		defer server.Close()
+		r := newController(v1alpha1.InitializingState)
+		r.rolloutStatus.SetConditions(v1alpha1.NewPositiveCondition(v1alpha1.BatchPaused))
+		assert.True(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState),
+			v1alpha1.RolloutWebhook{Name: "change", URL: server.URL}))
+		assert.Equal(t, corev1.ConditionTrue, r.rolloutStatus.GetCondition(v1alpha1.BatchPaused).Status)
+	})
+
+	t.Run("abort fails the rollout", func(t *testing.T) {
+		server := newDecisionServer(http.StatusOK, `{"decision":"abort","message":"not approved"}`, nil)
+		defer server.Close()
+		r := newController(v1alpha1.InitializingState)
+		rw := v1alpha1.RolloutWebhook{Name: "change", URL: server.URL}
+		assert.False(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState), rw))
+		assert.Equal(t, v1alpha1.RolloutFailingState, r.rolloutStatus.RollingState)
+		assert.Equal(t, runtimev1alpha1.ConditionReason(v1alpha1.WebhookAbort), decisionCondition(r).Reason)
+	})
+
+	t.Run("abort when finalizing fails the rollout right away", func(t *testing.T) {
+		server := newDecisionServer(http.StatusOK, `{"decision":"abort"}`, nil)
+		defer server.Close()
+		r := newController(v1alpha1.FinalisingState)
+		rw := v1alpha1.RolloutWebhook{Name: "change", URL: server.URL}
+		assert.False(t, r.invokeWebhook(ctx, string(v1alpha1.FinalisingState), rw))
+		assert.Equal(t, v1alpha1.RolloutFailedState, r.rolloutStatus.RollingState)
+	})
+
+	t.Run("retry until the limit", func(t *testing.T) {
+		server := newDecisionServer(http.StatusOK, `{"decision":"retry"}`, nil)
+		defer server.Close()
+		r := newController(v1alpha1.InitializingState)
+		rw := v1alpha1.RolloutWebhook{Name: "change", URL: server.URL, RetryLimit: pointer.Int32Ptr(2)}
+		for i := 0; i < 2; i++ {
+			assert.False(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState), rw))
+			assert.Equal(t, v1alpha1.InitializingState, r.rolloutStatus.RollingState)
+		}
+		assert.False(t, r.invokeWebhook(ctx, string(v1alpha1.InitializingState), rw))
+		assert.Equal(t, v1alpha1.RolloutFailingState, r.rolloutStatus.RollingState)
+		assert.Equal(t, int32(3), r.rolloutStatus.WebhookStatuses[0].Retries)
+	})
+}
@@ -0,0 +1,54 @@
+package traffic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// the weight that routes all the traffic to one side
+const fullWeight = 100
+
+// Controller is the interface that all types of traffic routing controller implement
+type Controller interface {
+	// ShiftTraffic routes the weight percentage of the traffic to the target service and the rest to the source
+	// it returns if the traffic is shifted or we should retry
+	ShiftTraffic(ctx context.Context, weight int32) (bool, error)
+
+	// Finalize routes all the traffic to the target service if the rollout succeeded
+	// or back to the source service if it failed, it returns if the traffic is reset or we should retry
+	Finalize(ctx context.Context, succeed bool) (bool, error)
+}
+
+// NewTrafficController picks the right traffic controller for the traffic routing of a rollout plan
+func NewTrafficController(client client.Client, recorder event.Recorder, parentController oam.Object,
+	routing *v1alpha1.TrafficRouting, namespace string) (Controller, error) {
+	switch routing.Provider {
+	case v1alpha1.IstioTrafficRouting:
+		return NewVirtualServiceController(client, recorder, parentController, routing, namespace), nil
+	case v1alpha1.SMITrafficRouting:
+		return NewTrafficSplitController(client, recorder, parentController, routing, namespace), nil
+	default:
+		return nil, fmt.Errorf("the traffic routing provider `%s` is not supported", routing.Provider)
+	}
+}
+
+// finalWeight returns the weight of the target service when the rollout is over
+func finalWeight(succeed bool) int32 {
+	if succeed {
+		return fullWeight
+	}
+	return 0
+}
+
+// routingGone checks if the resource that routes the traffic is deleted or its kind is not installed at all
+func routingGone(err error) bool {
+	return apierrors.IsNotFound(err) || meta.IsNoMatchError(err)
+}
@@ -0,0 +1,194 @@
+package traffic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+var parent = &v1alpha2.AppRollout{ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default",
+	UID: "rollout-uid"}}
+
+func newVirtualService(destinations ...map[string]interface{}) *unstructured.Unstructured {
+	route := make([]interface{}, len(destinations))
+	for i, d := range destinations {
+		route[i] = d
+	}
+	vs := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"hosts": []interface{}{"reviews"},
+			"http":  []interface{}{map[string]interface{}{"route": route}},
+		},
+	}}
+	vs.SetGroupVersionKind(VirtualServiceGroupVersionKind)
+	vs.SetNamespace("default")
+	vs.SetName("reviews")
+	return vs
+}
+
+func newDestination(host string, weight int64) map[string]interface{} {
+	return map[string]interface{}{
+		"destination": map[string]interface{}{"host": host},
+		"weight":      weight,
+	}
+}
+
+func getRoute(t assert.TestingT, c client.Client) []interface{} {
+	vs := &unstructured.Unstructured{}
+	vs.SetGroupVersionKind(VirtualServiceGroupVersionKind)
+	assert.NoError(t, c.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "reviews"}, vs))
+	httpRoutes, _, _ := unstructured.NestedSlice(vs.Object, "spec", "http")
+	route, _, _ := unstructured.NestedSlice(httpRoutes[0].(map[string]interface{}), "route")
+	return route
+}
+
+func TestVirtualServiceShiftTraffic(t *testing.T) {
+	routing := &v1alpha1.TrafficRouting{Provider: v1alpha1.IstioTrafficRouting, Name: "reviews",
+		SourceService: "reviews-v1", TargetService: "reviews-v2"}
+	tests := map[string]struct {
+		vs          *unstructured.Unstructured
+		weight      int32
+		wantShifted bool
+		wantErr     bool
+		wantRoute   []interface{}
+	}{
+		"shift part of the traffic": {
+			vs: newVirtualService(newDestination("reviews-v1", 100),
+				newDestination("reviews-v2.default.svc.cluster.local", 0)),
+			weight:      20,
+			wantShifted: true,
+			wantRoute: []interface{}{newDestination("reviews-v1", 80),
+				newDestination("reviews-v2.default.svc.cluster.local", 20)},
+		},
+		"the route does not go to the target": {
+			vs:      newVirtualService(newDestination("reviews-v1", 100)),
+			weight:  20,
+			wantErr: true,
+		},
+		"the virtual service is not there": {
+			weight: 20,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(common.Scheme)
+			if tt.vs != nil {
+				assert.NoError(t, c.Create(context.TODO(), tt.vs))
+			}
+			tc, err := NewTrafficController(c, event.NewNopRecorder(), parent, routing, "default")
+			assert.NoError(t, err)
+			shifted, err := tc.ShiftTraffic(context.TODO(), tt.weight)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantShifted, shifted)
+			if tt.wantRoute != nil {
+				assert.Equal(t, tt.wantRoute, getRoute(t, c))
+			}
+		})
+	}
+}
+
+func TestTrafficSplitFinalize(t *testing.T) {
+	routing := &v1alpha1.TrafficRouting{Provider: v1alpha1.SMITrafficRouting, Name: "reviews",
+		SourceService: "reviews-v1", TargetService: "reviews-v2"}
+	tests := map[string]struct {
+		succeed      bool
+		wantBackends []interface{}
+	}{
+		"the rollout succeeded": {
+			succeed: true,
+			wantBackends: []interface{}{
+				map[string]interface{}{"service": "reviews-v1", "weight": int64(0)},
+				map[string]interface{}{"service": "reviews-v2", "weight": int64(100)},
+			},
+		},
+		"the rollout failed": {
+			wantBackends: []interface{}{
+				map[string]interface{}{"service": "reviews-v1", "weight": int64(100)},
+				map[string]interface{}{"service": "reviews-v2", "weight": int64(0)},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ts := &unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"service": "reviews",
+					"backends": []interface{}{
+						map[string]interface{}{"service": "reviews-v1", "weight": int64(50)},
+					},
+				},
+			}}
+			ts.SetGroupVersionKind(TrafficSplitGroupVersionKind)
+			ts.SetNamespace("default")
+			ts.SetName("reviews")
+			c := fake.NewFakeClientWithScheme(common.Scheme)
+			assert.NoError(t, c.Create(context.TODO(), ts))
+			tc, err := NewTrafficController(c, event.NewNopRecorder(), parent, routing, "default")
+			assert.NoError(t, err)
+			finalized, err := tc.Finalize(context.TODO(), tt.succeed)
+			assert.NoError(t, err)
+			assert.True(t, finalized)
+
+			got := &unstructured.Unstructured{}
+			got.SetGroupVersionKind(TrafficSplitGroupVersionKind)
+			assert.NoError(t, c.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "reviews"}, got))
+			backends, _, _ := unstructured.NestedSlice(got.Object, "spec", "backends")
+			assert.Equal(t, tt.wantBackends, backends)
+		})
+	}
+}
+
+func TestNewTrafficController(t *testing.T) {
+	_, err := NewTrafficController(nil, event.NewNopRecorder(), parent,
+		&v1alpha1.TrafficRouting{Provider: "linkerd"}, "default")
+	assert.Error(t, err)
+}
+
+// errorClient fails to get any object
+type errorClient struct {
+	client.Client
+}
+
+func (c *errorClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	return errors.New("the api server is down")
+}
+
+func TestFinalizeWithoutTrafficRouting(t *testing.T) {
+	for _, provider := range []v1alpha1.TrafficRoutingProvider{v1alpha1.IstioTrafficRouting,
+		v1alpha1.SMITrafficRouting} {
+		t.Run(string(provider), func(t *testing.T) {
+			routing := &v1alpha1.TrafficRouting{Provider: provider, Name: "reviews",
+				SourceService: "reviews-v1", TargetService: "reviews-v2"}
+			c := fake.NewFakeClientWithScheme(common.Scheme)
+			tc, err := NewTrafficController(c, event.NewNopRecorder(), parent, routing, "default")
+			assert.NoError(t, err)
+			// the rollout waits for the routing resource to show up
+			shifted, err := tc.ShiftTraffic(context.TODO(), 20)
+			assert.NoError(t, err)
+			assert.False(t, shifted)
+			// there is no traffic to route once the routing resource is deleted
+			finalized, err := tc.Finalize(context.TODO(), true)
+			assert.NoError(t, err)
+			assert.True(t, finalized)
+
+			// any other error is retried
+			tc, err = NewTrafficController(&errorClient{c}, event.NewNopRecorder(), parent, routing, "default")
+			assert.NoError(t, err)
+			finalized, err = tc.Finalize(context.TODO(), true)
+			assert.NoError(t, err)
+			assert.False(t, finalized)
+		})
+	}
+}
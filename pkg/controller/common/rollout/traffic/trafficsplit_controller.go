@@ -0,0 +1,116 @@
+package traffic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// TrafficSplitGroupVersionKind is the GVK of the SMI TrafficSplit
+var TrafficSplitGroupVersionKind = schema.GroupVersionKind{
+	Group:   "split.smi-spec.io",
+	Version: "v1alpha2",
+	Kind:    "TrafficSplit",
+}
+
+// TrafficSplitController shifts the traffic by the weights of the backends in an SMI TrafficSplit
+type TrafficSplitController struct {
+	client           client.Client
+	recorder         event.Recorder
+	parentController oam.Object
+
+	routing        *v1alpha1.TrafficRouting
+	namespacedName types.NamespacedName
+}
+
+// NewTrafficSplitController creates a new TrafficSplit traffic controller
+func NewTrafficSplitController(client client.Client, recorder event.Recorder, parentController oam.Object,
+	routing *v1alpha1.TrafficRouting, namespace string) *TrafficSplitController {
+	return &TrafficSplitController{
+		client:           client,
+		recorder:         recorder,
+		parentController: parentController,
+		routing:          routing,
+		namespacedName:   types.NamespacedName{Namespace: namespace, Name: routing.Name},
+	}
+}
+
+// ShiftTraffic sets the weights of the source and the target backends, it adds the backends that are missing
+func (c *TrafficSplitController) ShiftTraffic(ctx context.Context, weight int32) (bool, error) {
+	var ts unstructured.Unstructured
+	ts.SetGroupVersionKind(TrafficSplitGroupVersionKind)
+	if err := c.client.Get(ctx, c.namespacedName, &ts); err != nil {
+		// don't fail the rollout just because of we can't get the resource
+		klog.ErrorS(err, "cannot get the traffic split", "traffic split", c.namespacedName)
+		return false, nil
+	}
+	return c.shiftTraffic(ctx, &ts, weight)
+}
+
+// shiftTraffic sets the weights in the traffic split and saves it
+func (c *TrafficSplitController) shiftTraffic(ctx context.Context, ts *unstructured.Unstructured,
+	weight int32) (bool, error) {
+	backends, _, err := unstructured.NestedSlice(ts.Object, "spec", "backends")
+	if err != nil {
+		return false, fmt.Errorf("the traffic split %s has invalid backends: %w", c.namespacedName, err)
+	}
+	weights := map[string]int64{
+		c.routing.SourceService: int64(fullWeight - weight),
+		c.routing.TargetService: int64(weight),
+	}
+	for _, backend := range backends {
+		backend, ok := backend.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		service, _, _ := unstructured.NestedString(backend, "service")
+		if w, exist := weights[service]; exist {
+			backend["weight"] = w
+			delete(weights, service)
+		}
+	}
+	for _, service := range []string{c.routing.SourceService, c.routing.TargetService} {
+		if w, exist := weights[service]; exist {
+			backends = append(backends, map[string]interface{}{"service": service, "weight": w})
+		}
+	}
+	tsPatch := client.MergeFrom(ts.DeepCopy())
+	if err := unstructured.SetNestedSlice(ts.Object, backends, "spec", "backends"); err != nil {
+		return false, err
+	}
+	if err := c.client.Patch(ctx, ts, tsPatch, client.FieldOwner(c.parentController.GetUID())); err != nil {
+		c.recorder.Event(c.parentController, event.Warning("Failed to shift the traffic", err))
+		return false, nil
+	}
+	klog.InfoS("shifted the traffic", "traffic split", c.namespacedName, "target service",
+		c.routing.TargetService, "weight", weight)
+	c.recorder.Event(c.parentController, event.Normal("Traffic Shifted",
+		fmt.Sprintf("%d%% of the traffic goes to the service %s", weight, c.routing.TargetService)))
+	return true, nil
+}
+
+// Finalize routes all the traffic to one of the services depending on the result of the rollout,
+// there is nothing to finalize if the traffic split is gone
+func (c *TrafficSplitController) Finalize(ctx context.Context, succeed bool) (bool, error) {
+	var ts unstructured.Unstructured
+	ts.SetGroupVersionKind(TrafficSplitGroupVersionKind)
+	if err := c.client.Get(ctx, c.namespacedName, &ts); err != nil {
+		if routingGone(err) {
+			klog.InfoS("the traffic split is gone, there is no traffic to finalize",
+				"traffic split", c.namespacedName)
+			return true, nil
+		}
+		klog.ErrorS(err, "cannot get the traffic split", "traffic split", c.namespacedName)
+		return false, nil
+	}
+	return c.shiftTraffic(ctx, &ts, finalWeight(succeed))
+}
@@ -0,0 +1,75 @@
+package traffic
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+)
+
+var _ = Describe("TrafficSplit traffic controller", func() {
+	ctx := context.TODO()
+	routing := &v1alpha1.TrafficRouting{Provider: v1alpha1.SMITrafficRouting, Name: "reviews",
+		SourceService: "reviews-v1", TargetService: "reviews-v2"}
+	var controller *TrafficSplitController
+	var ts *unstructured.Unstructured
+
+	getBackends := func() []interface{} {
+		got := &unstructured.Unstructured{}
+		got.SetGroupVersionKind(TrafficSplitGroupVersionKind)
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: "reviews"}, got)).Should(Succeed())
+		backends, _, _ := unstructured.NestedSlice(got.Object, "spec", "backends")
+		return backends
+	}
+
+	BeforeEach(func() {
+		controller = NewTrafficSplitController(k8sClient, event.NewNopRecorder(), parent, routing, "default")
+		ts = &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"service": "reviews",
+				"backends": []interface{}{
+					map[string]interface{}{"service": "reviews-v1", "weight": int64(100)},
+				},
+			},
+		}}
+		ts.SetGroupVersionKind(TrafficSplitGroupVersionKind)
+		ts.SetNamespace("default")
+		ts.SetName("reviews")
+		Expect(k8sClient.Create(ctx, ts)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(ctx, ts)).Should(SatisfyAny(Succeed(), WithTransform(routingGone, BeTrue())))
+	})
+
+	It("shifts the traffic of a batch and finalizes it", func() {
+		shifted, err := controller.ShiftTraffic(ctx, 20)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(shifted).Should(BeTrue())
+		Expect(getBackends()).Should(Equal([]interface{}{
+			map[string]interface{}{"service": "reviews-v1", "weight": int64(80)},
+			map[string]interface{}{"service": "reviews-v2", "weight": int64(20)},
+		}))
+
+		finalized, err := controller.Finalize(ctx, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(finalized).Should(BeTrue())
+		Expect(getBackends()).Should(Equal([]interface{}{
+			map[string]interface{}{"service": "reviews-v1", "weight": int64(0)},
+			map[string]interface{}{"service": "reviews-v2", "weight": int64(100)},
+		}))
+	})
+
+	It("finalizes a deleted traffic split right away", func() {
+		Expect(k8sClient.Delete(ctx, ts)).Should(Succeed())
+		finalized, err := controller.Finalize(ctx, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(finalized).Should(BeTrue())
+	})
+})
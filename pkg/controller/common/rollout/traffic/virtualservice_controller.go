@@ -0,0 +1,148 @@
+package traffic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// VirtualServiceGroupVersionKind is the GVK of the Istio VirtualService
+var VirtualServiceGroupVersionKind = schema.GroupVersionKind{
+	Group:   "networking.istio.io",
+	Version: "v1alpha3",
+	Kind:    "VirtualService",
+}
+
+// VirtualServiceController shifts the traffic by the weights of the http routes in an Istio VirtualService
+type VirtualServiceController struct {
+	client           client.Client
+	recorder         event.Recorder
+	parentController oam.Object
+
+	routing        *v1alpha1.TrafficRouting
+	namespacedName types.NamespacedName
+}
+
+// NewVirtualServiceController creates a new VirtualService traffic controller
+func NewVirtualServiceController(client client.Client, recorder event.Recorder, parentController oam.Object,
+	routing *v1alpha1.TrafficRouting, namespace string) *VirtualServiceController {
+	return &VirtualServiceController{
+		client:           client,
+		recorder:         recorder,
+		parentController: parentController,
+		routing:          routing,
+		namespacedName:   types.NamespacedName{Namespace: namespace, Name: routing.Name},
+	}
+}
+
+// ShiftTraffic sets the weights of the destinations in every http route that goes to both services
+func (c *VirtualServiceController) ShiftTraffic(ctx context.Context, weight int32) (bool, error) {
+	var vs unstructured.Unstructured
+	vs.SetGroupVersionKind(VirtualServiceGroupVersionKind)
+	if err := c.client.Get(ctx, c.namespacedName, &vs); err != nil {
+		// don't fail the rollout just because of we can't get the resource
+		klog.ErrorS(err, "cannot get the virtual service", "virtual service", c.namespacedName)
+		return false, nil
+	}
+	return c.shiftTraffic(ctx, &vs, weight)
+}
+
+// shiftTraffic sets the weights in the virtual service and saves it
+func (c *VirtualServiceController) shiftTraffic(ctx context.Context, vs *unstructured.Unstructured,
+	weight int32) (bool, error) {
+	httpRoutes, _, err := unstructured.NestedSlice(vs.Object, "spec", "http")
+	if err != nil {
+		return false, fmt.Errorf("the virtual service %s has invalid http routes: %w", c.namespacedName, err)
+	}
+	found := false
+	for _, httpRoute := range httpRoutes {
+		httpRoute, ok := httpRoute.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		destinations, _, err := unstructured.NestedSlice(httpRoute, "route")
+		if err != nil {
+			continue
+		}
+		if c.setWeights(destinations, weight) {
+			found = true
+			if err := unstructured.SetNestedSlice(httpRoute, destinations, "route"); err != nil {
+				return false, err
+			}
+		}
+	}
+	if !found {
+		return false, fmt.Errorf("the virtual service %s has no http route to both the service %s and %s",
+			c.namespacedName, c.routing.SourceService, c.routing.TargetService)
+	}
+	vsPatch := client.MergeFrom(vs.DeepCopy())
+	if err := unstructured.SetNestedSlice(vs.Object, httpRoutes, "spec", "http"); err != nil {
+		return false, err
+	}
+	if err := c.client.Patch(ctx, vs, vsPatch, client.FieldOwner(c.parentController.GetUID())); err != nil {
+		c.recorder.Event(c.parentController, event.Warning("Failed to shift the traffic", err))
+		return false, nil
+	}
+	klog.InfoS("shifted the traffic", "virtual service", c.namespacedName, "target service",
+		c.routing.TargetService, "weight", weight)
+	c.recorder.Event(c.parentController, event.Normal("Traffic Shifted",
+		fmt.Sprintf("%d%% of the traffic goes to the service %s", weight, c.routing.TargetService)))
+	return true, nil
+}
+
+// Finalize routes all the traffic to one of the services depending on the result of the rollout,
+// there is nothing to finalize if the virtual service is gone
+func (c *VirtualServiceController) Finalize(ctx context.Context, succeed bool) (bool, error) {
+	var vs unstructured.Unstructured
+	vs.SetGroupVersionKind(VirtualServiceGroupVersionKind)
+	if err := c.client.Get(ctx, c.namespacedName, &vs); err != nil {
+		if routingGone(err) {
+			klog.InfoS("the virtual service is gone, there is no traffic to finalize",
+				"virtual service", c.namespacedName)
+			return true, nil
+		}
+		klog.ErrorS(err, "cannot get the virtual service", "virtual service", c.namespacedName)
+		return false, nil
+	}
+	return c.shiftTraffic(ctx, &vs, finalWeight(succeed))
+}
+
+// setWeights sets the weights of the source and the target destinations, it returns false
+// if the route does not go to both of them
+func (c *VirtualServiceController) setWeights(destinations []interface{}, weight int32) bool {
+	var source, target map[string]interface{}
+	for _, d := range destinations {
+		d, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		host, _, _ := unstructured.NestedString(d, "destination", "host")
+		switch {
+		case matchHost(host, c.routing.SourceService):
+			source = d
+		case matchHost(host, c.routing.TargetService):
+			target = d
+		}
+	}
+	if source == nil || target == nil {
+		return false
+	}
+	source["weight"] = int64(fullWeight - weight)
+	target["weight"] = int64(weight)
+	return true
+}
+
+// matchHost checks if the host of a destination is the service, the host can be the short name or the FQDN
+func matchHost(host, service string) bool {
+	return host == service || strings.HasPrefix(host, service+".")
+}
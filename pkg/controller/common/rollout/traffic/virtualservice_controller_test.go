@@ -0,0 +1,52 @@
+package traffic
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+)
+
+var _ = Describe("VirtualService traffic controller", func() {
+	ctx := context.TODO()
+	routing := &v1alpha1.TrafficRouting{Provider: v1alpha1.IstioTrafficRouting, Name: "reviews",
+		SourceService: "reviews-v1", TargetService: "reviews-v2"}
+	var controller *VirtualServiceController
+	var vs *unstructured.Unstructured
+
+	BeforeEach(func() {
+		controller = NewVirtualServiceController(k8sClient, event.NewNopRecorder(), parent, routing, "default")
+		vs = newVirtualService(newDestination("reviews-v1", 100), newDestination("reviews-v2", 0))
+		Expect(k8sClient.Create(ctx, vs)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sClient.Delete(ctx, vs)).Should(SatisfyAny(Succeed(), WithTransform(routingGone, BeTrue())))
+	})
+
+	It("shifts the traffic of a batch and finalizes it", func() {
+		shifted, err := controller.ShiftTraffic(ctx, 20)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(shifted).Should(BeTrue())
+		Expect(getRoute(GinkgoT(), k8sClient)).Should(Equal([]interface{}{
+			newDestination("reviews-v1", 80), newDestination("reviews-v2", 20)}))
+
+		finalized, err := controller.Finalize(ctx, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(finalized).Should(BeTrue())
+		Expect(getRoute(GinkgoT(), k8sClient)).Should(Equal([]interface{}{
+			newDestination("reviews-v1", 100), newDestination("reviews-v2", 0)}))
+	})
+
+	It("finalizes a deleted virtual service right away", func() {
+		Expect(k8sClient.Delete(ctx, vs)).Should(Succeed())
+		finalized, err := controller.Finalize(ctx, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(finalized).Should(BeTrue())
+	})
+})
@@ -13,7 +13,6 @@ import (
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
 	"github.com/oam-dev/kubevela/pkg/oam"
 )
@@ -106,7 +105,7 @@ func (c *CloneSetController) Initialize(ctx context.Context) (bool, error) {
 	}
 
 	if controller := metav1.GetControllerOf(c.cloneSet); controller != nil {
-		if controller.Kind == v1alpha2.AppRolloutKind && controller.APIVersion == v1alpha2.SchemeGroupVersion.String() {
+		if isParentController(*controller, c.parentController) {
 			// it's already there
 			return true, nil
 		}
@@ -114,7 +113,7 @@ func (c *CloneSetController) Initialize(ctx context.Context) (bool, error) {
 	// add the parent controller to the owner of the cloneset
 	// before kicking start the update and start from every pod in the old version
 	clonePatch := client.MergeFrom(c.cloneSet.DeepCopyObject())
-	ref := metav1.NewControllerRef(c.parentController, v1alpha2.AppRolloutKindVersionKind)
+	ref := newParentControllerRef(c.parentController)
 	c.cloneSet.SetOwnerReferences(append(c.cloneSet.GetOwnerReferences(), *ref))
 	c.cloneSet.Spec.UpdateStrategy.Paused = false
 	c.cloneSet.Spec.UpdateStrategy.Partition = &intstr.IntOrString{Type: intstr.Int, IntVal: totalReplicas}
@@ -200,7 +199,7 @@ func (c *CloneSetController) Finalize(ctx context.Context, succeed bool) bool {
 	// remove the parent controller from the resources' owner list
 	var newOwnerList []metav1.OwnerReference
 	for _, owner := range c.cloneSet.GetOwnerReferences() {
-		if owner.Kind == v1alpha2.AppRolloutKind && owner.APIVersion == v1alpha2.SchemeGroupVersion.String() {
+		if isParentController(owner, c.parentController) {
 			continue
 		}
 		newOwnerList = append(newOwnerList, owner)
@@ -266,24 +265,5 @@ func (c *CloneSetController) fetchCloneSet(ctx context.Context) error {
 }
 
 func (c *CloneSetController) calculateNewPodTarget(cloneSetSize int) int {
-	currentBatch := int(c.rolloutStatus.CurrentBatch)
-	newPodTarget := 0
-	if currentBatch == len(c.rolloutSpec.RolloutBatches)-1 {
-		newPodTarget = cloneSetSize
-		// special handle the last batch, we ignore the rest of the batch in case there are rounding errors
-		klog.InfoS("use the cloneset size as the total pod target for the last rolling batch",
-			"current batch", currentBatch, "new version pod target", newPodTarget)
-	} else {
-		for i, r := range c.rolloutSpec.RolloutBatches {
-			batchSize, _ := intstr.GetValueFromIntOrPercent(&r.Replicas, cloneSetSize, true)
-			if i <= currentBatch {
-				newPodTarget += batchSize
-			} else {
-				break
-			}
-		}
-		klog.InfoS("Calculated the number of new version pod", "current batch", currentBatch,
-			"new version pod target", newPodTarget)
-	}
-	return newPodTarget
+	return calculateNewPodTarget(c.rolloutSpec, int(c.rolloutStatus.CurrentBatch), cloneSetSize)
 }
@@ -3,9 +3,13 @@ package workloads
 import (
 	"fmt"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
 
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
 )
 
 // VerifySumOfBatchSizes verifies that the the sum of all the batch replicas is valid given the total replica
@@ -36,3 +40,42 @@ func VerifySumOfBatchSizes(rolloutSpec *v1alpha1.RolloutPlan, totalReplicas int3
 	}
 	return nil
 }
+
+// calculateNewPodTarget calculates the total number of pods that should be upgraded after the current batch
+// for workloads that are upgraded in place
+func calculateNewPodTarget(rolloutSpec *v1alpha1.RolloutPlan, currentBatch, workloadSize int) int {
+	newPodTarget := 0
+	if currentBatch == len(rolloutSpec.RolloutBatches)-1 {
+		newPodTarget = workloadSize
+		// special handle the last batch, we ignore the rest of the batch in case there are rounding errors
+		klog.InfoS("use the workload size as the total pod target for the last rolling batch",
+			"current batch", currentBatch, "new version pod target", newPodTarget)
+	} else {
+		for i, r := range rolloutSpec.RolloutBatches {
+			batchSize, _ := intstr.GetValueFromIntOrPercent(&r.Replicas, workloadSize, true)
+			if i <= currentBatch {
+				newPodTarget += batchSize
+			} else {
+				break
+			}
+		}
+		klog.InfoS("Calculated the number of new version pod", "current batch", currentBatch,
+			"new version pod target", newPodTarget)
+	}
+	return newPodTarget
+}
+
+// newParentControllerRef returns the controller reference to the parent of the rollout
+// the parent is an AppRollout unless its type meta says otherwise
+func newParentControllerRef(parentController oam.Object) *metav1.OwnerReference {
+	gvk := parentController.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvk = v1alpha2.AppRolloutKindVersionKind
+	}
+	return metav1.NewControllerRef(parentController, gvk)
+}
+
+// isParentController checks if the owner reference points to the parent of the rollout
+func isParentController(owner metav1.OwnerReference, parentController oam.Object) bool {
+	return owner.UID == parentController.GetUID()
+}
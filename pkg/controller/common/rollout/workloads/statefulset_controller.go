@@ -0,0 +1,343 @@
+package workloads
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// StatefulSetController is responsible for handling rollout StatefulSet type of workloads
+// It upgrades the pods in place by moving the RollingUpdate partition of the StatefulSet
+type StatefulSetController struct {
+	client           client.Client
+	recorder         event.Recorder
+	parentController oam.Object
+
+	rolloutSpec            *v1alpha1.RolloutPlan
+	rolloutStatus          *v1alpha1.RolloutStatus
+	workloadNamespacedName types.NamespacedName
+	statefulSet            *apps.StatefulSet
+}
+
+// NewStatefulSetController creates a new StatefulSet rollout controller
+func NewStatefulSetController(client client.Client, recorder event.Recorder, parentController oam.Object,
+	rolloutSpec *v1alpha1.RolloutPlan, rolloutStatus *v1alpha1.RolloutStatus,
+	workloadName types.NamespacedName) *StatefulSetController {
+	return &StatefulSetController{
+		client:                 client,
+		recorder:               recorder,
+		parentController:       parentController,
+		rolloutSpec:            rolloutSpec,
+		rolloutStatus:          rolloutStatus,
+		workloadNamespacedName: workloadName,
+	}
+}
+
+// VerifySpec verifies that the target rollout resource is consistent with the rollout spec
+func (c *StatefulSetController) VerifySpec(ctx context.Context) (bool, error) {
+	var verifyErr error
+
+	defer func() {
+		if verifyErr != nil {
+			klog.Error(verifyErr)
+			c.recorder.Event(c.parentController, event.Warning("VerifyFailed", verifyErr))
+		}
+	}()
+
+	// fetch the statefulset and get its current size
+	totalReplicas, verifyErr := c.size(ctx)
+	if verifyErr != nil {
+		// do not fail the rollout because we can't get the resource
+		c.rolloutStatus.RolloutRetry(verifyErr.Error())
+		// nolint: nilerr
+		return false, nil
+	}
+	// record the size
+	klog.InfoS("record the target size", "total replicas", totalReplicas)
+	c.rolloutStatus.RolloutTargetTotalSize = totalReplicas
+
+	// make sure that the updateRevision is different from what we have already done
+	targetHash := c.statefulSet.Status.UpdateRevision
+	if targetHash == c.rolloutStatus.LastAppliedPodTemplateIdentifier {
+		return false, fmt.Errorf("there is no difference between the source and target, hash = %s", targetHash)
+	}
+	// record the new pod template hash
+	c.rolloutStatus.NewPodTemplateIdentifier = targetHash
+
+	// check if the rollout batch replicas added up to the StatefulSet replicas
+	if verifyErr = c.verifyRolloutBatchReplicaValue(totalReplicas); verifyErr != nil {
+		return false, verifyErr
+	}
+
+	// we can only move the partition of a rolling update statefulset
+	if c.statefulSet.Spec.UpdateStrategy.Type != apps.RollingUpdateStatefulSetStrategyType {
+		return false, fmt.Errorf("the statefulset %s has an unsupported update strategy type %s",
+			c.statefulSet.GetName(), c.statefulSet.Spec.UpdateStrategy.Type)
+	}
+
+	// check if the statefulset is disabled, it is the case only if the partition covers all the pods
+	if c.partition() < totalReplicas {
+		return false, fmt.Errorf("the statefulset %s is in the middle of updating, need to be paused first",
+			c.statefulSet.GetName())
+	}
+
+	// check if the statefulset has any controller
+	if controller := metav1.GetControllerOf(c.statefulSet); controller != nil {
+		return false, fmt.Errorf("the statefulset %s has a controller owner %s",
+			c.statefulSet.GetName(), controller.String())
+	}
+
+	// mark the rollout verified
+	c.recorder.Event(c.parentController, event.Normal("Rollout Verified",
+		"Rollout spec and the StatefulSet resource are verified"))
+	return true, nil
+}
+
+// Initialize makes sure that the statefulset is under our control
+func (c *StatefulSetController) Initialize(ctx context.Context) (bool, error) {
+	totalReplicas, err := c.size(ctx)
+	if err != nil {
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false, nil
+	}
+
+	if controller := metav1.GetControllerOf(c.statefulSet); controller != nil {
+		if isParentController(*controller, c.parentController) {
+			// it's already there
+			return true, nil
+		}
+	}
+	// add the parent controller to the owner of the statefulset
+	// before kicking start the update and start from every pod in the old version
+	stsPatch := client.MergeFrom(c.statefulSet.DeepCopyObject())
+	ref := newParentControllerRef(c.parentController)
+	c.statefulSet.SetOwnerReferences(append(c.statefulSet.GetOwnerReferences(), *ref))
+	c.setPartition(totalReplicas)
+
+	// patch the StatefulSet
+	if err := c.client.Patch(ctx, c.statefulSet, stsPatch, client.FieldOwner(c.parentController.GetUID())); err != nil {
+		c.recorder.Event(c.parentController, event.Warning("Failed to the start the statefulset update", err))
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false, nil
+	}
+	// mark the rollout initialized
+	c.recorder.Event(c.parentController, event.Normal("Rollout Initialized", "Rollout resource are initialized"))
+	return true, nil
+}
+
+// RolloutOneBatchPods calculates the number of pods we can upgrade once according to the rollout spec
+// and then set the partition accordingly, return if we are done
+func (c *StatefulSetController) RolloutOneBatchPods(ctx context.Context) (bool, error) {
+	// calculate what's the total pods that should be upgraded given the currentBatch in the status
+	stsSize, err := c.size(ctx)
+	if err != nil {
+		// don't fail the rollout just because of we can't get the resource
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false, nil
+	}
+	newPodTarget := calculateNewPodTarget(c.rolloutSpec, int(c.rolloutStatus.CurrentBatch), int(stsSize))
+	// set the Partition as the desired number of pods in old revisions.
+	stsPatch := client.MergeFrom(c.statefulSet.DeepCopyObject())
+	c.setPartition(stsSize - int32(newPodTarget))
+	// patch the StatefulSet
+	if err := c.client.Patch(ctx, c.statefulSet, stsPatch, client.FieldOwner(c.parentController.GetUID())); err != nil {
+		c.recorder.Event(c.parentController, event.Warning("Failed to update the statefulset to upgrade", err))
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false, nil
+	}
+	// record the upgrade
+	klog.InfoS("upgraded one batch", "current batch", c.rolloutStatus.CurrentBatch)
+	c.recorder.Event(c.parentController, event.Normal("Batch Rollout",
+		fmt.Sprintf("Submitted upgrade quest for batch %d", c.rolloutStatus.CurrentBatch)))
+	c.rolloutStatus.UpgradedReplicas = int32(newPodTarget)
+	return true, nil
+}
+
+// CheckOneBatchPods checks to see if the pods are all available according to the rollout plan
+func (c *StatefulSetController) CheckOneBatchPods(ctx context.Context) (bool, error) {
+	if err := c.fetchStatefulSet(ctx); err != nil {
+		// don't fail the rollout just because of we can't get the resource
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false, nil
+	}
+	stsSize, err := c.size(ctx)
+	if err != nil {
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false, nil
+	}
+	newPodTarget := calculateNewPodTarget(c.rolloutSpec, int(c.rolloutStatus.CurrentBatch), int(stsSize))
+	// the statefulset status does not record how many updated pods are ready, so we count the pods ourselves
+	readyPodCount, err := c.countUpdatedReadyPods(ctx)
+	if err != nil {
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false, nil
+	}
+	currentBatch := c.rolloutSpec.RolloutBatches[c.rolloutStatus.CurrentBatch]
+	unavail := 0
+	if currentBatch.MaxUnavailable != nil {
+		unavail, _ = intstr.GetValueFromIntOrPercent(currentBatch.MaxUnavailable, int(stsSize), true)
+	}
+	klog.InfoS("checking the rolling out progress", "current batch", currentBatch,
+		"new pod count target", newPodTarget, "new ready pod count", readyPodCount,
+		"max unavailable pod allowed", unavail)
+	c.rolloutStatus.UpgradedReadyReplicas = int32(readyPodCount)
+	// we could overshoot in the revert case when many pods are already upgraded
+	if unavail+readyPodCount >= newPodTarget {
+		// record the successful upgrade
+		klog.InfoS("all pods in current batch are ready", "current batch", currentBatch)
+		c.recorder.Event(c.parentController, event.Normal("Batch Available",
+			fmt.Sprintf("Batch %d is available", c.rolloutStatus.CurrentBatch)))
+		c.rolloutStatus.LastAppliedPodTemplateIdentifier = c.rolloutStatus.NewPodTemplateIdentifier
+		return true, nil
+	}
+	// continue to verify
+	klog.InfoS("the batch is not ready yet", "current batch", currentBatch)
+	c.rolloutStatus.RolloutRetry("the batch is not ready yet")
+	return false, nil
+}
+
+// FinalizeOneBatch makes sure that the rollout status are updated correctly
+func (c *StatefulSetController) FinalizeOneBatch(ctx context.Context) (bool, error) {
+	// nothing to do for statefulset for now
+	return true, nil
+}
+
+// Finalize makes sure the StatefulSet is all upgraded if the rollout succeeded. Otherwise, the partition is moved back
+// to cover all the pods so that no more pods are upgraded and the StatefulSet is paused for the next rollout
+func (c *StatefulSetController) Finalize(ctx context.Context, succeed bool) bool {
+	stsSize, err := c.size(ctx)
+	if err != nil {
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false
+	}
+	stsPatch := client.MergeFrom(c.statefulSet.DeepCopyObject())
+	if succeed {
+		c.setPartition(0)
+	} else {
+		c.setPartition(stsSize)
+	}
+	// remove the parent controller from the resources' owner list
+	var newOwnerList []metav1.OwnerReference
+	for _, owner := range c.statefulSet.GetOwnerReferences() {
+		if isParentController(owner, c.parentController) {
+			continue
+		}
+		newOwnerList = append(newOwnerList, owner)
+	}
+	c.statefulSet.SetOwnerReferences(newOwnerList)
+	// patch the StatefulSet
+	if err := c.client.Patch(ctx, c.statefulSet, stsPatch, client.FieldOwner(c.parentController.GetUID())); err != nil {
+		c.recorder.Event(c.parentController, event.Warning("Failed to the finalize the statefulset", err))
+		c.rolloutStatus.RolloutRetry(err.Error())
+		return false
+	}
+	// mark the resource finalized
+	c.recorder.Event(c.parentController, event.Normal("Rollout Finalized",
+		fmt.Sprintf("Rollout resource are finalized, succeed := %t", succeed)))
+	return true
+}
+
+// ---------------------------------------------
+// The functions below are helper functions
+// ---------------------------------------------
+// size fetches the StatefulSet and returns the replicas (not the actual number of pods)
+func (c *StatefulSetController) size(ctx context.Context) (int32, error) {
+	if c.statefulSet == nil {
+		err := c.fetchStatefulSet(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	// default is 1
+	if c.statefulSet.Spec.Replicas == nil {
+		return 1, nil
+	}
+	return *c.statefulSet.Spec.Replicas, nil
+}
+
+// partition returns the current partition of the statefulset, default is 0 which means all pods are updated
+func (c *StatefulSetController) partition() int32 {
+	rollingUpdate := c.statefulSet.Spec.UpdateStrategy.RollingUpdate
+	if rollingUpdate == nil || rollingUpdate.Partition == nil {
+		return 0
+	}
+	return *rollingUpdate.Partition
+}
+
+func (c *StatefulSetController) setPartition(partition int32) {
+	c.statefulSet.Spec.UpdateStrategy.Type = apps.RollingUpdateStatefulSetStrategyType
+	if c.statefulSet.Spec.UpdateStrategy.RollingUpdate == nil {
+		c.statefulSet.Spec.UpdateStrategy.RollingUpdate = &apps.RollingUpdateStatefulSetStrategy{}
+	}
+	c.statefulSet.Spec.UpdateStrategy.RollingUpdate.Partition = pointer.Int32Ptr(partition)
+}
+
+// check if the replicas in all the rollout batches add up to the right number
+func (c *StatefulSetController) verifyRolloutBatchReplicaValue(totalReplicas int32) error {
+	// the target size has to be the same as the statefulset size
+	if c.rolloutSpec.TargetSize != nil && *c.rolloutSpec.TargetSize != totalReplicas {
+		return fmt.Errorf("the rollout plan is attempting to scale the statefulset, target = %d, statefulset size = %d",
+			*c.rolloutSpec.TargetSize, totalReplicas)
+	}
+	// use a common function to check if the sum of all the batches can match the statefulset size
+	err := VerifySumOfBatchSizes(c.rolloutSpec, totalReplicas)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *StatefulSetController) fetchStatefulSet(ctx context.Context) error {
+	// get the statefulSet
+	workload := apps.StatefulSet{}
+	err := c.client.Get(ctx, c.workloadNamespacedName, &workload)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			c.recorder.Event(c.parentController, event.Warning("Failed to get the StatefulSet", err))
+		}
+		return err
+	}
+	c.statefulSet = &workload
+	return nil
+}
+
+// countUpdatedReadyPods counts the pods that are both running the update revision and ready
+func (c *StatefulSetController) countUpdatedReadyPods(ctx context.Context) (int, error) {
+	selector, err := metav1.LabelSelectorAsSelector(c.statefulSet.Spec.Selector)
+	if err != nil {
+		return 0, err
+	}
+	var pods corev1.PodList
+	if err := c.client.List(ctx, &pods, client.InNamespace(c.statefulSet.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return 0, err
+	}
+	readyPodCount := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !metav1.IsControlledBy(pod, c.statefulSet) ||
+			pod.Labels[apps.ControllerRevisionHashLabelKey] != c.statefulSet.Status.UpdateRevision {
+			continue
+		}
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+				readyPodCount++
+				break
+			}
+		}
+	}
+	return readyPodCount, nil
+}
@@ -0,0 +1,257 @@
+package workloads
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+)
+
+var _ = Describe("StatefulSet rollout controller", func() {
+	ctx := context.Background()
+	const updateRevision = "sts-rev-2"
+	var namespace string
+	var appRollout v1alpha2.AppRollout
+	var sts apps.StatefulSet
+	var rolloutSpec *v1alpha1.RolloutPlan
+	var rolloutStatus *v1alpha1.RolloutStatus
+	var stsName types.NamespacedName
+
+	newController := func() *StatefulSetController {
+		return NewStatefulSetController(k8sClient, event.NewNopRecorder(), &appRollout, rolloutSpec,
+			rolloutStatus, stsName)
+	}
+
+	createPod := func(ordinal int, revision string, ready bool) {
+		readyStatus := corev1.ConditionFalse
+		if ready {
+			readyStatus = corev1.ConditionTrue
+		}
+		pod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%d", sts.Name, ordinal),
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app":                               sts.Name,
+					apps.ControllerRevisionHashLabelKey: revision,
+				},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(&sts,
+					apps.SchemeGroupVersion.WithKind("StatefulSet"))},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "nginx", Image: "nginx:1.19"}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &pod)).Should(Succeed())
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: readyStatus}}
+		Expect(k8sClient.Status().Update(ctx, &pod)).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		namespace = fmt.Sprintf("sts-rollout-%d", time.Now().UnixNano())
+		ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		Expect(k8sClient.Create(ctx, &ns)).Should(Succeed())
+
+		appRollout = v1alpha2.AppRollout{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: v1alpha2.SchemeGroupVersion.String(),
+				Kind:       v1alpha2.AppRolloutKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "sts-rollout", Namespace: namespace},
+			Spec: v1alpha2.AppRolloutSpec{
+				TargetAppRevisionName: "app-v2",
+				SourceAppRevisionName: "app-v1",
+			},
+		}
+		Expect(k8sClient.Create(ctx, &appRollout)).Should(Succeed())
+
+		sts = apps.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: namespace},
+			Spec: apps.StatefulSetSpec{
+				Replicas: pointer.Int32Ptr(5),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "nginx", Image: "nginx:1.19"}},
+					},
+				},
+				UpdateStrategy: apps.StatefulSetUpdateStrategy{
+					Type: apps.RollingUpdateStatefulSetStrategyType,
+					RollingUpdate: &apps.RollingUpdateStatefulSetStrategy{
+						Partition: pointer.Int32Ptr(5),
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, &sts)).Should(Succeed())
+		// there is no statefulset controller in the test environment, fake the status
+		sts.Status.Replicas = 5
+		sts.Status.UpdateRevision = updateRevision
+		sts.Status.CurrentRevision = "sts-rev-1"
+		Expect(k8sClient.Status().Update(ctx, &sts)).Should(Succeed())
+		stsName = types.NamespacedName{Namespace: namespace, Name: sts.Name}
+
+		rolloutSpec = &v1alpha1.RolloutPlan{
+			RolloutBatches: []v1alpha1.RolloutBatch{
+				{Replicas: intstr.FromInt(1)},
+				{Replicas: intstr.FromString("40%")},
+				{Replicas: intstr.FromString("40%")},
+			},
+		}
+		rolloutStatus = &v1alpha1.RolloutStatus{RollingState: v1alpha1.VerifyingSpecState}
+	})
+
+	AfterEach(func() {
+		ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		Expect(k8sClient.Delete(ctx, &ns)).Should(Succeed())
+	})
+
+	It("retries the verification if the statefulset does not exist", func() {
+		stsName.Name = "does-not-exist"
+		verified, err := newController().VerifySpec(ctx)
+		Expect(err).Should(BeNil())
+		Expect(verified).Should(BeFalse())
+	})
+
+	It("fails the verification if the batches do not add up", func() {
+		rolloutSpec.RolloutBatches = []v1alpha1.RolloutBatch{
+			{Replicas: intstr.FromInt(3)},
+			{Replicas: intstr.FromInt(3)},
+		}
+		verified, err := newController().VerifySpec(ctx)
+		Expect(err).ShouldNot(BeNil())
+		Expect(verified).Should(BeFalse())
+	})
+
+	It("fails the verification if the statefulset is already upgrading", func() {
+		sts.Spec.UpdateStrategy.RollingUpdate.Partition = pointer.Int32Ptr(2)
+		Expect(k8sClient.Update(ctx, &sts)).Should(Succeed())
+		verified, err := newController().VerifySpec(ctx)
+		Expect(err).ShouldNot(BeNil())
+		Expect(err.Error()).Should(ContainSubstring("need to be paused first"))
+		Expect(verified).Should(BeFalse())
+	})
+
+	It("fails the verification if there is nothing new to rollout", func() {
+		rolloutStatus.LastAppliedPodTemplateIdentifier = updateRevision
+		verified, err := newController().VerifySpec(ctx)
+		Expect(err).ShouldNot(BeNil())
+		Expect(verified).Should(BeFalse())
+	})
+
+	It("rolls out the statefulset batch by batch through its partition", func() {
+		By("verify the spec")
+		verified, err := newController().VerifySpec(ctx)
+		Expect(err).Should(BeNil())
+		Expect(verified).Should(BeTrue())
+		Expect(rolloutStatus.RolloutTargetTotalSize).Should(BeEquivalentTo(5))
+		Expect(rolloutStatus.NewPodTemplateIdentifier).Should(Equal(updateRevision))
+
+		By("initialize the statefulset")
+		initialized, err := newController().Initialize(ctx)
+		Expect(err).Should(BeNil())
+		Expect(initialized).Should(BeTrue())
+		Expect(k8sClient.Get(ctx, stsName, &sts)).Should(Succeed())
+		Expect(metav1.GetControllerOf(&sts)).ShouldNot(BeNil())
+		Expect(metav1.GetControllerOf(&sts).Kind).Should(Equal(v1alpha2.AppRolloutKind))
+		Expect(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).Should(BeEquivalentTo(5))
+
+		By("rollout the first batch")
+		done, err := newController().RolloutOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(done).Should(BeTrue())
+		Expect(rolloutStatus.UpgradedReplicas).Should(BeEquivalentTo(1))
+		Expect(k8sClient.Get(ctx, stsName, &sts)).Should(Succeed())
+		Expect(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).Should(BeEquivalentTo(4))
+
+		By("check the first batch before and after the upgraded pod is ready")
+		createPod(4, updateRevision, false)
+		createPod(3, "sts-rev-1", true)
+		ready, err := newController().CheckOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(ready).Should(BeFalse())
+		pod := corev1.Pod{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "db-4"}, &pod)).Should(Succeed())
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, &pod)).Should(Succeed())
+		ready, err = newController().CheckOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(ready).Should(BeTrue())
+		Expect(rolloutStatus.UpgradedReadyReplicas).Should(BeEquivalentTo(1))
+		Expect(rolloutStatus.LastAppliedPodTemplateIdentifier).Should(Equal(updateRevision))
+
+		By("rollout the second batch")
+		rolloutStatus.CurrentBatch = 1
+		done, err = newController().RolloutOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(done).Should(BeTrue())
+		Expect(rolloutStatus.UpgradedReplicas).Should(BeEquivalentTo(3))
+		Expect(k8sClient.Get(ctx, stsName, &sts)).Should(Succeed())
+		Expect(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).Should(BeEquivalentTo(2))
+
+		By("rollout the last batch")
+		rolloutStatus.CurrentBatch = 2
+		done, err = newController().RolloutOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(done).Should(BeTrue())
+		Expect(rolloutStatus.UpgradedReplicas).Should(BeEquivalentTo(5))
+		Expect(k8sClient.Get(ctx, stsName, &sts)).Should(Succeed())
+		Expect(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).Should(BeEquivalentTo(0))
+
+		By("finalize the rollout")
+		Expect(newController().Finalize(ctx, true)).Should(BeTrue())
+		Expect(k8sClient.Get(ctx, stsName, &sts)).Should(Succeed())
+		Expect(metav1.GetControllerOf(&sts)).Should(BeNil())
+	})
+
+	It("retries the batch if the statefulset does not exist", func() {
+		stsName.Name = "does-not-exist"
+		done, err := newController().RolloutOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(done).Should(BeFalse())
+		ready, err := newController().CheckOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(ready).Should(BeFalse())
+	})
+
+	It("pauses the statefulset if the rollout failed", func() {
+		initialized, err := newController().Initialize(ctx)
+		Expect(err).Should(BeNil())
+		Expect(initialized).Should(BeTrue())
+		done, err := newController().RolloutOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(done).Should(BeTrue())
+		Expect(newController().Finalize(ctx, false)).Should(BeTrue())
+		Expect(k8sClient.Get(ctx, stsName, &sts)).Should(Succeed())
+		Expect(metav1.GetControllerOf(&sts)).Should(BeNil())
+		Expect(*sts.Spec.UpdateStrategy.RollingUpdate.Partition).Should(BeEquivalentTo(5))
+	})
+
+	It("honors the max unavailable setting of the batch", func() {
+		rolloutSpec.RolloutBatches[0].MaxUnavailable = &intstr.IntOrString{Type: intstr.Int, IntVal: 1}
+		initialized, err := newController().Initialize(ctx)
+		Expect(err).Should(BeNil())
+		Expect(initialized).Should(BeTrue())
+		done, err := newController().RolloutOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(done).Should(BeTrue())
+		createPod(4, updateRevision, false)
+		ready, err := newController().CheckOneBatchPods(ctx)
+		Expect(err).Should(BeNil())
+		Expect(ready).Should(BeTrue())
+	})
+})
@@ -47,4 +47,14 @@ type Args struct {
 	// CustomRevisionHookURL is a webhook which will let oam-runtime to call with AC+Component info
 	// The webhook server will return a customized component revision for oam-runtime
 	CustomRevisionHookURL string
+
+	// TerraformImage is the image of the Jobs that apply the Terraform components
+	TerraformImage string
+
+	// TerraformServiceAccount is the service account of the Jobs that apply the Terraform components
+	TerraformServiceAccount string
+
+	// TerraformCredentialSecret is the Secret in the namespace of the application whose data are exported as
+	// environment variables to terraform, like the credentials of the cloud provider
+	TerraformCredentialSecret string
 }
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -33,11 +34,15 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	oamstd "github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
 	"github.com/oam-dev/kubevela/pkg/appfile"
+	"github.com/oam-dev/kubevela/pkg/controller/common/rollout"
 	core "github.com/oam-dev/kubevela/pkg/controller/core.oam.dev"
 	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
 	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+	"github.com/oam-dev/kubevela/pkg/terraform"
 	apply "github.com/oam-dev/kubevela/pkg/utils/apply"
+	"github.com/oam-dev/kubevela/pkg/utils/env"
 )
 
 // RolloutReconcileWaitTime is the time to wait before reconcile again an application still in rollout phase
@@ -47,9 +52,11 @@ const RolloutReconcileWaitTime = time.Second * 3
 type Reconciler struct {
 	client.Client
 	dm         discoverymapper.DiscoveryMapper
+	record     event.Recorder
 	Log        logr.Logger
 	Scheme     *runtime.Scheme
 	applicator apply.Applicator
+	terraform  terraform.Executor
 }
 
 // +kubebuilder:rbac:groups=core.oam.dev,resources=applications,verbs=get;list;watch;create;update;patch;delete
@@ -70,22 +77,36 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, err
 	}
 
-	// TODO: check finalizer
+	handler := &appHandler{r, app, applog}
 	if app.DeletionTimestamp != nil {
+		destroyed, err := handler.destroyTerraform(ctx)
+		if err != nil {
+			applog.Error(err, "[Handle destroy terraform]")
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+		if !destroyed {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
 		return ctrl.Result{}, nil
 	}
 
 	applog.Info("Start Rendering")
 
 	app.Status.Phase = v1alpha2.ApplicationRendering
-	handler := &appHandler{r, app, applog}
 
 	applog.Info("parse template")
 	// parse template
 	appParser := appfile.NewApplicationParser(r.Client, r.dm)
 
 	ctx = oamutil.SetNamespaceInCtx(ctx, app.Namespace)
-	appfile, err := appParser.GenerateAppFile(ctx, app.Name, app)
+	// the default traits of the envs are attached to a copy, they are not written back to the application
+	parsedApp, err := withEnvDefaultTraits(ctx, r, app)
+	if err != nil {
+		applog.Error(err, "[Handle env policies]")
+		app.Status.SetConditions(errorCondition("Parsed", err))
+		return handler.handleErr(err)
+	}
+	appfile, err := appParser.GenerateAppFile(ctx, app.Name, parsedApp)
 	if err != nil {
 		applog.Error(err, "[Handle Parse]")
 		app.Status.SetConditions(errorCondition("Parsed", err))
@@ -102,9 +123,34 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		app.Status.SetConditions(errorCondition("Built", err))
 		return handler.handleErr(err)
 	}
+	scopes, err := appParser.GenerateScopes(appfile, app.Namespace)
+	if err != nil {
+		applog.Error(err, "[Handle GenerateScopes]")
+		app.Status.SetConditions(errorCondition("Built", err))
+		return handler.handleErr(err)
+	}
+	tfConfs, err := appParser.GenerateTerraformConfigurations(appfile, app.Namespace)
+	if err != nil {
+		applog.Error(err, "[Handle GenerateTerraformConfigurations]")
+		app.Status.SetConditions(errorCondition("Built", err))
+		return handler.handleErr(err)
+	}
+	// the cloud resources have to be destroyed before the application is gone
+	if err := handler.registerTerraformFinalizer(ctx, tfConfs); err != nil {
+		applog.Error(err, "[Handle register terraform finalizer]")
+		app.Status.SetConditions(errorCondition("Built", err))
+		return handler.handleErr(err)
+	}
 	// pass the App label and annotation to ac except some app specific ones
 	oamutil.PassLabelAndAnnotation(app, ac)
 	app.Status.SetConditions(readyCondition("Built"))
+	applog.Info("apply the application-level scopes to the cluster")
+	// the scopes have to exist before the appConfig refers to them
+	if err := handler.applyScopes(ctx, scopes); err != nil {
+		applog.Error(err, "[Handle apply scopes]")
+		app.Status.SetConditions(errorCondition("Applied", err))
+		return handler.handleErr(err)
+	}
 	applog.Info("apply appConfig & component to the cluster")
 	// apply appConfig & component to the cluster
 	if err := handler.apply(ctx, ac, comps); err != nil {
@@ -113,11 +159,42 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return handler.handleErr(err)
 	}
 
+	applog.Info("apply the terraform components")
+	tfStatus, err := handler.applyTerraform(ctx, tfConfs)
+	if err != nil {
+		applog.Error(err, "[Handle apply terraform]")
+		app.Status.SetConditions(errorCondition("Applied", err))
+		return handler.handleErr(err)
+	}
+
 	app.Status.SetConditions(readyCondition("Applied"))
+	if app.Spec.RolloutPlan != nil {
+		applog.Info("roll out the latest application revision")
+		done, err := handler.handleRollout(ctx)
+		if err == nil {
+			// the application is patched through a copy so that the status we just reconciled is kept
+			err = rollout.ClearStaleBatchApproval(ctx, r, handler.rolloutParent(), &app.Status.RolloutStatus)
+		}
+		if err != nil {
+			applog.Error(err, "[Handle rollout]")
+			app.Status.SetConditions(errorCondition("Rollout", err))
+			return handler.handleErr(err)
+		}
+		if !done {
+			app.Status.Phase = v1alpha2.ApplicationRollingOut
+			return ctrl.Result{RequeueAfter: RolloutReconcileWaitTime}, r.UpdateStatus(ctx, app)
+		}
+		if app.Status.RollingState == oamstd.RolloutFailedState {
+			// the source keeps serving the application, we still check its health
+			app.Status.SetConditions(errorCondition("Rollout", errors.New("rollout failed")))
+		} else {
+			app.Status.SetConditions(readyCondition("Rollout"))
+		}
+	}
 	app.Status.Phase = v1alpha2.ApplicationHealthChecking
 	applog.Info("check application health status")
 	// check application health status
-	appCompStatus, healthy, err := handler.statusAggregate(appfile)
+	appCompStatus, healthy, err := handler.statusAggregate(appfile, tfStatus)
 	if err != nil {
 		applog.Error(err, "[status aggregate]")
 		app.Status.SetConditions(errorCondition("HealthCheck", err))
@@ -149,6 +226,8 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 
 // SetupWithManager install to manager
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.record = event.NewAPIRecorder(mgr.GetEventRecorderFor("Application")).
+		WithAnnotations("controller", "Application")
 	// If Application Own these two child objects, AC status change will notify application controller and recursively update AC again, and trigger application event again...
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha2.Application{}).
@@ -168,7 +247,7 @@ func (r *Reconciler) UpdateStatus(ctx context.Context, app *v1alpha2.Application
 }
 
 // Setup adds a controller that reconciles AppRollout.
-func Setup(mgr ctrl.Manager, _ core.Args, _ logging.Logger) error {
+func Setup(mgr ctrl.Manager, args core.Args, _ logging.Logger) error {
 	dm, err := discoverymapper.New(mgr.GetConfig())
 	if err != nil {
 		return fmt.Errorf("create discovery dm fail %w", err)
@@ -179,6 +258,33 @@ func Setup(mgr ctrl.Manager, _ core.Args, _ logging.Logger) error {
 		Scheme:     mgr.GetScheme(),
 		dm:         dm,
 		applicator: apply.NewAPIApplicator(mgr.GetClient()),
+		terraform:  newTerraformExecutor(mgr.GetClient(), args),
 	}
 	return reconciler.SetupWithManager(mgr)
 }
+
+func newTerraformExecutor(c client.Client, args core.Args) terraform.Executor {
+	executor := terraform.NewJobExecutor(c)
+	if args.TerraformImage != "" {
+		executor.Image = args.TerraformImage
+	}
+	executor.ServiceAccountName = args.TerraformServiceAccount
+	executor.CredentialSecret = args.TerraformCredentialSecret
+	return executor
+}
+
+// withEnvDefaultTraits returns a copy of the application with the default traits of the envs in its namespace attached
+func withEnvDefaultTraits(ctx context.Context, c client.Reader, app *v1alpha2.Application) (*v1alpha2.Application, error) {
+	policies, err := env.GetPoliciesByNamespace(ctx, c, app.Namespace)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot get the policies of the envs")
+	}
+	if len(policies) == 0 {
+		return app, nil
+	}
+	parsedApp := app.DeepCopy()
+	if err := env.InjectDefaultTraits(parsedApp, policies...); err != nil {
+		return nil, err
+	}
+	return parsedApp, nil
+}
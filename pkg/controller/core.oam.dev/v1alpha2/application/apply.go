@@ -8,12 +8,14 @@ import (
 
 	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	ctypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
@@ -21,13 +23,17 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/types"
 	"github.com/oam-dev/kubevela/pkg/appfile"
 	"github.com/oam-dev/kubevela/pkg/controller/utils"
-	"github.com/oam-dev/kubevela/pkg/dsl/process"
 	"github.com/oam-dev/kubevela/pkg/oam"
 	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+	"github.com/oam-dev/kubevela/pkg/terraform"
 )
 
+// terraformFinalizer keeps a deleted application until the cloud resources of its Terraform components are destroyed
+const terraformFinalizer = "finalizers.terraform.core.oam.dev"
+
 func errorCondition(tpy string, err error) runtimev1alpha1.Condition {
 	return runtimev1alpha1.Condition{
 		Type:               runtimev1alpha1.ConditionType(tpy),
@@ -111,31 +117,137 @@ func (h *appHandler) apply(ctx context.Context, ac *v1alpha2.ApplicationConfigur
 	return nil
 }
 
-func (h *appHandler) statusAggregate(appfile *appfile.Appfile) ([]v1alpha2.ApplicationComponentStatus, bool, error) {
+// applyScopes creates or updates the application-level scopes, they are owned by the application
+func (h *appHandler) applyScopes(ctx context.Context, scopes []*unstructured.Unstructured) error {
+	for _, scope := range scopes {
+		scope.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(h.app,
+			v1alpha2.ApplicationKindVersionKind)})
+		if err := h.r.applicator.Apply(ctx, scope); err != nil {
+			return errors.Wrapf(err, "cannot apply the scope %s", scope.GetName())
+		}
+		h.logger.Info("Applied an application-level scope", "kind", scope.GetKind(), "name", scope.GetName())
+	}
+	return nil
+}
+
+// applyTerraform hands the Terraform configurations to the terraform executor, the objects it creates except the
+// terraform state are owned by the application. It returns the statuses of the configurations by the component name.
+func (h *appHandler) applyTerraform(ctx context.Context, confs []*terraform.Configuration) (map[string]*terraform.Status, error) {
+	statuses := make(map[string]*terraform.Status, len(confs))
+	if len(confs) == 0 {
+		return statuses, nil
+	}
+	if h.r.terraform == nil {
+		return nil, errors.New("no terraform executor to apply the Terraform components")
+	}
+	for _, conf := range confs {
+		conf.Owner = *metav1.NewControllerRef(h.app, v1alpha2.ApplicationKindVersionKind)
+		status, err := h.r.terraform.Apply(ctx, conf)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot apply the Terraform component %s", conf.Name)
+		}
+		h.logger.Info("Applied a Terraform component", "component name", conf.Name, "state", status.State)
+		statuses[conf.Name] = status
+	}
+	return statuses, nil
+}
+
+// registerTerraformFinalizer registers the terraform finalizer on an application with Terraform components.
+// The application is updated through a copy so that the status we are reconciling is kept.
+func (h *appHandler) registerTerraformFinalizer(ctx context.Context, confs []*terraform.Configuration) error {
+	if len(confs) == 0 || meta.FinalizerExists(&h.app.ObjectMeta, terraformFinalizer) {
+		return nil
+	}
+	app := h.app.DeepCopy()
+	meta.AddFinalizer(&app.ObjectMeta, terraformFinalizer)
+	if err := h.r.Update(ctx, app); err != nil {
+		return errors.Wrap(err, "cannot register the terraform finalizer")
+	}
+	h.app.ObjectMeta = app.ObjectMeta
+	return nil
+}
+
+// destroyTerraform asks the terraform executor to destroy the Terraform components of a deleted application, every
+// component is passed since the application may not be parsed anymore. The finalizer is removed once all of them
+// are destroyed, it returns false until then.
+func (h *appHandler) destroyTerraform(ctx context.Context) (bool, error) {
+	if !meta.FinalizerExists(&h.app.ObjectMeta, terraformFinalizer) {
+		return true, nil
+	}
+	if h.r.terraform != nil {
+		destroyed := true
+		for _, comp := range h.app.Spec.Components {
+			done, err := h.r.terraform.Destroy(ctx, &terraform.Configuration{
+				Name:      comp.Name,
+				Namespace: h.app.Namespace,
+				AppName:   h.app.Name,
+				Owner:     *metav1.NewControllerRef(h.app, v1alpha2.ApplicationKindVersionKind),
+			})
+			if err != nil {
+				return false, errors.Wrapf(err, "cannot destroy the Terraform component %s", comp.Name)
+			}
+			if !done {
+				h.logger.Info("Destroying a Terraform component", "component name", comp.Name)
+				destroyed = false
+			}
+		}
+		if !destroyed {
+			return false, nil
+		}
+	}
+	meta.RemoveFinalizer(&h.app.ObjectMeta, terraformFinalizer)
+	return true, h.r.Update(ctx, h.app)
+}
+
+// terraformComponentStatus converts the status of applying a Terraform component to the component status, the
+// outputs are in the connection secret so they're kept out of the application status
+func terraformComponentStatus(name string, status *terraform.Status) v1alpha2.ApplicationComponentStatus {
+	compStatus := v1alpha2.ApplicationComponentStatus{Name: name, Message: status.Message}
+	switch status.State {
+	case terraform.Available:
+		compStatus.Healthy, compStatus.State = true, v1alpha2.HealthStateHealthy
+		if compStatus.Message == "" {
+			compStatus.Message = fmt.Sprintf("the outputs are written to Secret %s", name)
+		}
+	case terraform.Failed:
+		compStatus.State, compStatus.Reason = v1alpha2.HealthStateUnhealthy, "TerraformFailed"
+	default:
+		compStatus.State, compStatus.Reason = v1alpha2.HealthStateProgressing, "TerraformApplying"
+	}
+	return compStatus
+}
+
+func (h *appHandler) statusAggregate(appfile *appfile.Appfile, tfStatus map[string]*terraform.Status) ([]v1alpha2.ApplicationComponentStatus, bool, error) {
 	var appStatus []v1alpha2.ApplicationComponentStatus
 	var healthy = true
+	contexts, err := appfile.EvalContexts(h.r, h.app.Namespace)
+	if err != nil {
+		return nil, false, err
+	}
 	for _, wl := range appfile.Workloads {
-		var status = v1alpha2.ApplicationComponentStatus{
-			Name:    wl.Name,
-			Healthy: true,
-		}
-		pCtx := process.NewContext(wl.Name, appfile.Name, appfile.RevisionName)
-		if err := wl.EvalContext(pCtx); err != nil {
-			return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, evaluate context error", appfile.Name, wl.Name)
-		}
-		for _, tr := range wl.Traits {
-			if err := tr.EvalContext(pCtx); err != nil {
-				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, evaluate context error", appfile.Name, wl.Name, tr.Name)
+		if wl.CapabilityCategory == types.TerraformCategory {
+			// the traits don't apply to the Terraform components
+			if tfStatus[wl.Name] == nil {
+				return nil, false, errors.Errorf("app=%s, comp=%s, no status of the Terraform component", appfile.Name, wl.Name)
+			}
+			status := terraformComponentStatus(wl.Name, tfStatus[wl.Name])
+			if !status.Healthy {
+				healthy = false
 			}
+			appStatus = append(appStatus, status)
+			continue
+		}
+		var status = v1alpha2.ApplicationComponentStatus{
+			Name: wl.Name,
 		}
+		pCtx := contexts[wl.Name]
 
 		workloadHealth, err := wl.EvalHealth(pCtx, h.r, h.app.Namespace)
 		if err != nil {
 			return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, check health error", appfile.Name, wl.Name)
 		}
-		if !workloadHealth {
-			// TODO(wonderflow): we should add a custom way to let the template say why it's unhealthy, only a bool flag is not enough
-			status.Healthy = false
+		status.Healthy, status.State, status.Reason = workloadHealth.Healthy, workloadHealth.State, workloadHealth.Reason
+		if !workloadHealth.Healthy {
 			healthy = false
 		}
 
@@ -143,25 +255,29 @@ func (h *appHandler) statusAggregate(appfile *appfile.Appfile) ([]v1alpha2.Appli
 		if err != nil {
 			return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, evaluate workload status message error", appfile.Name, wl.Name)
 		}
+		if status.Message == "" {
+			status.Message = workloadHealth.Message
+		}
 		var traitStatusList []v1alpha2.ApplicationTraitStatus
 		for _, trait := range wl.Traits {
 			var traitStatus = v1alpha2.ApplicationTraitStatus{
-				Type:    trait.Name,
-				Healthy: true,
+				Type: trait.Name,
 			}
 			traitHealth, err := trait.EvalHealth(pCtx, h.r, h.app.Namespace)
 			if err != nil {
 				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, check health error", appfile.Name, wl.Name, trait.Name)
 			}
-			if !traitHealth {
-				// TODO(wonderflow): we should add a custom way to let the template say why it's unhealthy, only a bool flag is not enough
-				traitStatus.Healthy = false
+			traitStatus.Healthy, traitStatus.State, traitStatus.Reason = traitHealth.Healthy, traitHealth.State, traitHealth.Reason
+			if !traitHealth.Healthy {
 				healthy = false
 			}
 			traitStatus.Message, err = trait.EvalStatus(pCtx, h.r, h.app.Namespace)
 			if err != nil {
 				return nil, false, errors.WithMessagef(err, "app=%s, comp=%s, trait=%s, evaluate status message error", appfile.Name, wl.Name, trait.Name)
 			}
+			if traitStatus.Message == "" {
+				traitStatus.Message = traitHealth.Message
+			}
 			traitStatusList = append(traitStatusList, traitStatus)
 		}
 		status.Traits = traitStatusList
@@ -334,6 +450,11 @@ func (h *appHandler) createNewAppConfig(ctx context.Context, appConfig *v1alpha2
 			oam.AnnotationAppRollout: strconv.FormatBool(true),
 		}))
 	}
+	if h.app.Spec.RolloutPlan != nil {
+		if err := h.markRollingComponent(ctx, appConfig); err != nil {
+			return err
+		}
+	}
 
 	// record that last appConfig we created first in the app's status
 	// make sure that we persist the latest revision first
@@ -0,0 +1,254 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/controller/common"
+	"github.com/oam-dev/kubevela/pkg/controller/common/rollout"
+	"github.com/oam-dev/kubevela/pkg/controller/core.oam.dev/v1alpha2/applicationdeployment"
+	"github.com/oam-dev/kubevela/pkg/controller/utils"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+// rolloutSource returns the name of the application revision that serves the application before
+// it rolls out to the latest revision
+func rolloutSource(app *v1alpha2.Application) string {
+	status := app.Status
+	switch {
+	case len(status.LastUpgradedTargetAppRevision) == 0:
+		// the application never rolled out, the previous revision is the one serving
+		if status.LatestRevision == nil || status.LatestRevision.Revision <= 1 {
+			return ""
+		}
+		return utils.ConstructRevisionName(app.Name, status.LatestRevision.Revision-1)
+	case status.LatestRevision != nil && status.LastUpgradedTargetAppRevision == status.LatestRevision.Name:
+		// we are still working on the latest revision
+		return status.LastSourceAppRevision
+	case status.RollingState == v1alpha1.RolloutSucceedState:
+		return status.LastUpgradedTargetAppRevision
+	default:
+		// the last rollout never made it, its source is still serving
+		return status.LastSourceAppRevision
+	}
+}
+
+// rolloutInFlight checks if the rollout plan is still working on the last target
+func rolloutInFlight(status *v1alpha2.AppStatus) bool {
+	return len(status.LastUpgradedTargetAppRevision) != 0 &&
+		status.RollingState != v1alpha1.RolloutSucceedState && status.RollingState != v1alpha1.RolloutFailedState
+}
+
+// rollingComponent picks the component that the rollout plan upgrades, that is the component whose revision
+// is not in the source. The component has to exist in the source too unless there is no source at all.
+// It returns empty if there is nothing to roll and an error if more than one component changed since a rollout plan
+// only upgrades one component at a time
+func rollingComponent(targetApp, sourceApp *v1alpha2.ApplicationConfiguration) (string, error) {
+	if len(targetApp.Spec.Components) == 0 {
+		return "", nil
+	}
+	if sourceApp == nil {
+		return utils.ExtractComponentName(targetApp.Spec.Components[0].RevisionName), nil
+	}
+	sourceRevisions := make(map[string]string, len(sourceApp.Spec.Components))
+	for _, acc := range sourceApp.Spec.Components {
+		sourceRevisions[utils.ExtractComponentName(acc.RevisionName)] = acc.RevisionName
+	}
+	var changed []string
+	for _, acc := range targetApp.Spec.Components {
+		componentName := utils.ExtractComponentName(acc.RevisionName)
+		if revision, exist := sourceRevisions[componentName]; exist && revision != acc.RevisionName {
+			changed = append(changed, componentName)
+		}
+	}
+	switch len(changed) {
+	case 0:
+		return "", nil
+	case 1:
+		return changed[0], nil
+	default:
+		return "", fmt.Errorf("the rollout plan can only upgrade one component at a time but the components %s "+
+			"changed", strings.Join(changed, ", "))
+	}
+}
+
+// rollingComponents returns the components that are marked as rolling in the application revision
+func rollingComponents(appConfig *v1alpha2.ApplicationConfiguration) []string {
+	anc := appConfig.GetAnnotations()[oam.AnnotationRollingComponent]
+	if len(anc) == 0 {
+		return nil
+	}
+	return strings.Split(anc, common.RollingComponentsSep)
+}
+
+// markRollingComponent marks the component that the rollout plan upgrades in a new application revision
+// so that the appConfig controller only emits its workload as a template. We respect the rolling components
+// annotation that passes down from the application
+func (h *appHandler) markRollingComponent(ctx context.Context, appConfig *v1alpha2.ApplicationConfiguration) error {
+	if _, exist := appConfig.GetAnnotations()[oam.AnnotationRollingComponent]; exist {
+		return nil
+	}
+	sourceApp, err := h.getAppRevision(ctx, rolloutSource(h.app))
+	if err != nil {
+		return err
+	}
+	componentName, err := rollingComponent(appConfig, sourceApp)
+	if err != nil {
+		return err
+	}
+	if len(componentName) == 0 {
+		h.logger.Info("no component to roll out in the new application revision", "application revision",
+			appConfig.Name)
+		return nil
+	}
+	h.logger.Info("mark the rolling component", "application revision", appConfig.Name,
+		"component", componentName)
+	appConfig.SetAnnotations(oamutil.MergeMapOverrideWithDst(appConfig.GetAnnotations(), map[string]string{
+		oam.AnnotationRollingComponent: componentName,
+	}))
+	return nil
+}
+
+// getAppRevision returns the application revision with the given name, it returns nil if it does not exist
+func (h *appHandler) getAppRevision(ctx context.Context, name string) (*v1alpha2.ApplicationConfiguration, error) {
+	if len(name) == 0 {
+		return nil, nil
+	}
+	var appConfig v1alpha2.ApplicationConfiguration
+	if err := h.r.Get(ctx, ctypes.NamespacedName{Namespace: h.app.Namespace, Name: name}, &appConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &appConfig, nil
+}
+
+// handleRollout drives the rollout plan of the application from the application revision that serves the application
+// to the latest one. It returns true once the rollout reaches a terminal state
+func (h *appHandler) handleRollout(ctx context.Context) (bool, error) {
+	status := &h.app.Status
+	targetName := status.LatestRevision.Name
+	if status.LastUpgradedTargetAppRevision != targetName {
+		if rolloutInFlight(status) {
+			// wrap up what we are doing with the last target before we start over
+			finalized, err := h.finalizeModifiedRollout(ctx)
+			if err != nil || !finalized {
+				return false, err
+			}
+		}
+		sourceName := rolloutSource(h.app)
+		h.logger.Info("start to roll out a new application revision", "source", sourceName, "target", targetName)
+		status.StateTransition(v1alpha1.WorkloadModifiedEvent)
+		status.LastSourceAppRevision = sourceName
+		status.LastUpgradedTargetAppRevision = targetName
+	}
+	if status.RollingState == v1alpha1.RolloutSucceedState || status.RollingState == v1alpha1.RolloutFailedState {
+		return true, nil
+	}
+
+	targetApp, err := h.getAppRevision(ctx, targetName)
+	if err != nil || targetApp == nil {
+		return false, err
+	}
+	sourceApp, err := h.getAppRevision(ctx, status.LastSourceAppRevision)
+	if err != nil {
+		return false, err
+	}
+	componentList := rollingComponents(targetApp)
+	if len(componentList) == 0 {
+		// there is no workload to upgrade gradually, let the new revision take over right away
+		h.logger.Info("nothing to roll out, activate the application revision", "target", targetName)
+		if err := applicationdeployment.ActivateAppRevision(ctx, h.r, targetApp, sourceApp); err != nil {
+			return false, err
+		}
+		status.RollingState = v1alpha1.RolloutSucceedState
+		return true, nil
+	}
+	if targetApp.Status.RollingStatus != v1alpha2.RollingTemplated {
+		h.logger.Info("target app revision is not ready for rolling yet", "application revision", targetName)
+		return false, nil
+	}
+	targetWorkload, sourceWorkload, err := applicationdeployment.ExtractWorkloads(ctx, h.r, componentList,
+		targetApp, sourceApp)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			h.logger.Info("the workloads to upgrade are not there yet", "target", targetName,
+				"source", status.LastSourceAppRevision)
+			return false, nil
+		}
+		return false, err
+	}
+
+	rolloutPlanController := rollout.NewRolloutPlanController(h.r, h.rolloutParent(), h.r.record,
+		h.app.Spec.RolloutPlan, &status.RolloutStatus, targetWorkload, sourceWorkload)
+	_, rolloutStatus := rolloutPlanController.Reconcile(ctx)
+	status.RolloutStatus = *rolloutStatus
+	switch status.RollingState {
+	case v1alpha1.RolloutSucceedState:
+		h.logger.Info("rollout succeeded, activate the application revision", "source",
+			status.LastSourceAppRevision, "target", targetName)
+		if err := applicationdeployment.ActivateAppRevision(ctx, h.r, targetApp, sourceApp); err != nil {
+			return false, err
+		}
+		return true, nil
+	case v1alpha1.RolloutFailedState:
+		h.logger.Info("rollout failed, the source keeps serving the application", "source",
+			status.LastSourceAppRevision, "target", targetName)
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// finalizeModifiedRollout finishes the batch in progress with the workloads of the last target and source and
+// releases them. It returns true once it's safe to roll out the latest revision
+func (h *appHandler) finalizeModifiedRollout(ctx context.Context) (bool, error) {
+	status := &h.app.Status
+	h.logger.Info("a new application revision arrives in the middle of the rollout", "last source",
+		status.LastSourceAppRevision, "last target", status.LastUpgradedTargetAppRevision)
+	lastTarget, err := h.getAppRevision(ctx, status.LastUpgradedTargetAppRevision)
+	if err != nil || lastTarget == nil {
+		return true, err
+	}
+	lastSource, err := h.getAppRevision(ctx, status.LastSourceAppRevision)
+	if err != nil {
+		return false, err
+	}
+	if componentList := rollingComponents(lastTarget); len(componentList) != 0 {
+		targetWorkload, sourceWorkload, err := applicationdeployment.ExtractWorkloads(ctx, h.r, componentList,
+			lastTarget, lastSource)
+		if err == nil {
+			rolloutPlanController := rollout.NewRolloutPlanController(h.r, h.rolloutParent(), h.r.record,
+				h.app.Spec.RolloutPlan, &status.RolloutStatus, targetWorkload, sourceWorkload)
+			finalized, rolloutStatus := rolloutPlanController.FinalizeModifiedRollout(ctx)
+			status.RolloutStatus = *rolloutStatus
+			if !finalized {
+				return false, nil
+			}
+		} else {
+			// there is nothing to wrap up if the workloads are gone
+			h.logger.Error(err, "cannot fetch the workloads of the last rollout")
+		}
+	}
+	// the last target never made it, stop the appConfig controller from reconciling it
+	oamutil.RemoveAnnotations(lastTarget, []string{oam.AnnotationAppRollout})
+	oamutil.AddAnnotations(lastTarget, map[string]string{oam.AnnotationAppRevision: strconv.FormatBool(true)})
+	return true, h.r.Update(ctx, lastTarget)
+}
+
+// rolloutParent returns the application as the parent of the rollout, the rollout controller
+// claims the workloads on behalf of it
+func (h *appHandler) rolloutParent() *v1alpha2.Application {
+	parent := h.app.DeepCopy()
+	parent.SetGroupVersionKind(v1alpha2.ApplicationKindVersionKind)
+	return parent
+}
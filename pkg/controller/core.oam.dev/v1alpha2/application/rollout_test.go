@@ -0,0 +1,219 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestRolloutSource(t *testing.T) {
+	tests := map[string]struct {
+		status v1alpha2.AppStatus
+		want   string
+	}{
+		"the first revision": {
+			status: v1alpha2.AppStatus{LatestRevision: &v1alpha2.Revision{Name: "myapp-v1", Revision: 1}},
+		},
+		"never rolled out before": {
+			status: v1alpha2.AppStatus{LatestRevision: &v1alpha2.Revision{Name: "myapp-v3", Revision: 3}},
+			want:   "myapp-v2",
+		},
+		"rolling out the latest revision": {
+			status: v1alpha2.AppStatus{
+				RolloutStatus:                 v1alpha1.RolloutStatus{RollingState: v1alpha1.RollingInBatchesState},
+				LatestRevision:                &v1alpha2.Revision{Name: "myapp-v3", Revision: 3},
+				LastUpgradedTargetAppRevision: "myapp-v3",
+				LastSourceAppRevision:         "myapp-v1",
+			},
+			want: "myapp-v1",
+		},
+		"the last rollout succeeded": {
+			status: v1alpha2.AppStatus{
+				RolloutStatus:                 v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutSucceedState},
+				LatestRevision:                &v1alpha2.Revision{Name: "myapp-v3", Revision: 3},
+				LastUpgradedTargetAppRevision: "myapp-v2",
+				LastSourceAppRevision:         "myapp-v1",
+			},
+			want: "myapp-v2",
+		},
+		"the last rollout failed": {
+			status: v1alpha2.AppStatus{
+				RolloutStatus:                 v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutFailedState},
+				LatestRevision:                &v1alpha2.Revision{Name: "myapp-v3", Revision: 3},
+				LastUpgradedTargetAppRevision: "myapp-v2",
+				LastSourceAppRevision:         "myapp-v1",
+			},
+			want: "myapp-v1",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			app := &v1alpha2.Application{ObjectMeta: metav1.ObjectMeta{Name: "myapp"}, Status: tt.status}
+			assert.Equal(t, tt.want, rolloutSource(app))
+		})
+	}
+}
+
+func TestRollingComponent(t *testing.T) {
+	newAppConfig := func(revisions ...string) *v1alpha2.ApplicationConfiguration {
+		appConfig := &v1alpha2.ApplicationConfiguration{}
+		for _, revision := range revisions {
+			appConfig.Spec.Components = append(appConfig.Spec.Components,
+				v1alpha2.ApplicationConfigurationComponent{RevisionName: revision})
+		}
+		return appConfig
+	}
+	tests := map[string]struct {
+		target  *v1alpha2.ApplicationConfiguration
+		source  *v1alpha2.ApplicationConfiguration
+		want    string
+		wantErr bool
+	}{
+		"no source": {
+			target: newAppConfig("frontend-v1", "backend-v1"),
+			want:   "frontend",
+		},
+		"the second component changed": {
+			target: newAppConfig("frontend-v1", "backend-v2"),
+			source: newAppConfig("frontend-v1", "backend-v1"),
+			want:   "backend",
+		},
+		"a new component": {
+			target: newAppConfig("frontend-v1", "backend-v1"),
+			source: newAppConfig("frontend-v1"),
+		},
+		"more than one component changed": {
+			target:  newAppConfig("frontend-v2", "backend-v2"),
+			source:  newAppConfig("frontend-v1", "backend-v1"),
+			wantErr: true,
+		},
+		"nothing changed": {
+			target: newAppConfig("frontend-v1"),
+			source: newAppConfig("frontend-v1"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := rollingComponent(tt.target, tt.source)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHandleRollout(t *testing.T) {
+	ctx := context.TODO()
+	newAppConfig := func(name string, annotations map[string]string) *v1alpha2.ApplicationConfiguration {
+		return &v1alpha2.ApplicationConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+			Spec: v1alpha2.ApplicationConfigurationSpec{
+				Components: []v1alpha2.ApplicationConfigurationComponent{{RevisionName: "frontend-v1"}},
+			},
+		}
+	}
+	newApp := func(status v1alpha2.AppStatus) *v1alpha2.Application {
+		return &v1alpha2.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"},
+			Spec: v1alpha2.ApplicationSpec{
+				RolloutPlan: &v1alpha1.RolloutPlan{RolloutBatches: []v1alpha1.RolloutBatch{{}}},
+			},
+			Status: status,
+		}
+	}
+	getAppConfig := func(h *appHandler, name string) *v1alpha2.ApplicationConfiguration {
+		var appConfig v1alpha2.ApplicationConfiguration
+		assert.NoError(t, h.r.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &appConfig))
+		return &appConfig
+	}
+
+	t.Run("activate a revision without a rolling component", func(t *testing.T) {
+		app := newApp(v1alpha2.AppStatus{
+			RolloutStatus:                 v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutSucceedState},
+			LatestRevision:                &v1alpha2.Revision{Name: "myapp-v2", Revision: 2},
+			LastUpgradedTargetAppRevision: "myapp-v1",
+		})
+		h := &appHandler{
+			r: &Reconciler{
+				Client: fake.NewFakeClientWithScheme(common.Scheme, app,
+					newAppConfig("myapp-v1", nil),
+					newAppConfig("myapp-v2", map[string]string{oam.AnnotationAppRollout: "true"})),
+				record: event.NewNopRecorder(),
+			},
+			app:    app,
+			logger: ctrl.Log.WithName("test"),
+		}
+		done, err := h.handleRollout(ctx)
+		assert.NoError(t, err)
+		assert.True(t, done)
+		assert.Equal(t, v1alpha1.RolloutSucceedState, app.Status.RollingState)
+		assert.Equal(t, "myapp-v2", app.Status.LastUpgradedTargetAppRevision)
+		assert.Equal(t, "myapp-v1", app.Status.LastSourceAppRevision)
+		assert.NotContains(t, getAppConfig(h, "myapp-v2").GetAnnotations(), oam.AnnotationAppRollout)
+		assert.Equal(t, "true", getAppConfig(h, "myapp-v1").GetAnnotations()[oam.AnnotationAppRevision])
+	})
+
+	t.Run("wait for the target to be templated", func(t *testing.T) {
+		app := newApp(v1alpha2.AppStatus{
+			RolloutStatus:                 v1alpha1.RolloutStatus{RollingState: v1alpha1.RolloutSucceedState},
+			LatestRevision:                &v1alpha2.Revision{Name: "myapp-v2", Revision: 2},
+			LastUpgradedTargetAppRevision: "myapp-v1",
+		})
+		h := &appHandler{
+			r: &Reconciler{
+				Client: fake.NewFakeClientWithScheme(common.Scheme, app,
+					newAppConfig("myapp-v1", nil),
+					newAppConfig("myapp-v2", map[string]string{
+						oam.AnnotationAppRollout:       "true",
+						oam.AnnotationRollingComponent: "frontend",
+					})),
+				record: event.NewNopRecorder(),
+			},
+			app:    app,
+			logger: ctrl.Log.WithName("test"),
+		}
+		done, err := h.handleRollout(ctx)
+		assert.NoError(t, err)
+		assert.False(t, done)
+		assert.Equal(t, v1alpha1.VerifyingSpecState, app.Status.RollingState)
+		assert.Contains(t, getAppConfig(h, "myapp-v2").GetAnnotations(), oam.AnnotationAppRollout)
+	})
+
+	t.Run("retire the last target that never made it", func(t *testing.T) {
+		app := newApp(v1alpha2.AppStatus{
+			RolloutStatus:                 v1alpha1.RolloutStatus{RollingState: v1alpha1.VerifyingSpecState},
+			LatestRevision:                &v1alpha2.Revision{Name: "myapp-v3", Revision: 3},
+			LastUpgradedTargetAppRevision: "myapp-v2",
+			LastSourceAppRevision:         "myapp-v1",
+		})
+		h := &appHandler{
+			r: &Reconciler{
+				Client: fake.NewFakeClientWithScheme(common.Scheme, app,
+					newAppConfig("myapp-v1", nil),
+					newAppConfig("myapp-v2", map[string]string{oam.AnnotationAppRollout: "true"}),
+					newAppConfig("myapp-v3", map[string]string{oam.AnnotationAppRollout: "true"})),
+				record: event.NewNopRecorder(),
+			},
+			app:    app,
+			logger: ctrl.Log.WithName("test"),
+		}
+		done, err := h.handleRollout(ctx)
+		assert.NoError(t, err)
+		assert.True(t, done)
+		assert.Equal(t, "myapp-v3", app.Status.LastUpgradedTargetAppRevision)
+		assert.Equal(t, "myapp-v1", app.Status.LastSourceAppRevision)
+		lastTarget := getAppConfig(h, "myapp-v2")
+		assert.NotContains(t, lastTarget.GetAnnotations(), oam.AnnotationAppRollout)
+		assert.Equal(t, "true", lastTarget.GetAnnotations()[oam.AnnotationAppRevision])
+	})
+}
@@ -0,0 +1,162 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/appfile"
+	"github.com/oam-dev/kubevela/pkg/terraform"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestApplyTerraform(t *testing.T) {
+	ctx := context.TODO()
+	app := &v1alpha2.Application{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default", UID: "app-uid"}}
+	executor := &terraform.FakeExecutor{Statuses: map[string]*terraform.Status{
+		"sample-db": {State: terraform.Applying, Message: "terraform apply is running in Job sample-db-tf-apply"},
+	}}
+	h := &appHandler{r: &Reconciler{terraform: executor}, app: app, logger: ctrl.Log.WithName("test")}
+
+	statuses, err := h.applyTerraform(ctx, []*terraform.Configuration{
+		{Name: "sample-oss", Namespace: "default", AppName: "myapp", JSON: []byte(`{}`)},
+		{Name: "sample-db", Namespace: "default", AppName: "myapp", JSON: []byte(`{}`)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, terraform.Available, statuses["sample-oss"].State)
+	assert.Equal(t, terraform.Applying, statuses["sample-db"].State)
+	owner := executor.Applied["sample-oss"].Owner
+	assert.Equal(t, "myapp", owner.Name)
+	assert.Equal(t, v1alpha2.ApplicationKind, owner.Kind)
+	assert.True(t, *owner.Controller)
+
+	executor.Err = errors.New("boom")
+	_, err = h.applyTerraform(ctx, []*terraform.Configuration{{Name: "sample-oss"}})
+	assert.EqualError(t, err, "cannot apply the Terraform component sample-oss: boom")
+
+	h.r.terraform = nil
+	statuses, err = h.applyTerraform(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, statuses)
+	_, err = h.applyTerraform(ctx, []*terraform.Configuration{{Name: "sample-oss"}})
+	assert.EqualError(t, err, "no terraform executor to apply the Terraform components")
+}
+
+func TestTerraformStatusAggregate(t *testing.T) {
+	app := &v1alpha2.Application{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"}}
+	h := &appHandler{
+		r:      &Reconciler{Client: fake.NewFakeClientWithScheme(common.Scheme, app)},
+		app:    app,
+		logger: ctrl.Log.WithName("test"),
+	}
+	af := &appfile.Appfile{
+		Name: "myapp",
+		Workloads: []*appfile.Workload{{
+			Name:               "sample-oss",
+			Type:               "alibaba-oss",
+			CapabilityCategory: types.TerraformCategory,
+			Template:           `output: resource: alicloud_oss_bucket: "bucket-acl": acl: "private"`,
+		}},
+	}
+	testCases := map[string]struct {
+		status      *terraform.Status
+		wantHealthy bool
+		want        v1alpha2.ApplicationComponentStatus
+	}{
+		"applying": {
+			status: &terraform.Status{State: terraform.Applying, Message: "terraform apply is running in Job sample-oss-tf-apply"},
+			want: v1alpha2.ApplicationComponentStatus{
+				Name:    "sample-oss",
+				State:   v1alpha2.HealthStateProgressing,
+				Reason:  "TerraformApplying",
+				Message: "terraform apply is running in Job sample-oss-tf-apply",
+			},
+		},
+		"failed": {
+			status: &terraform.Status{State: terraform.Failed, Message: "terraform apply failed"},
+			want: v1alpha2.ApplicationComponentStatus{
+				Name:    "sample-oss",
+				State:   v1alpha2.HealthStateUnhealthy,
+				Reason:  "TerraformFailed",
+				Message: "terraform apply failed",
+			},
+		},
+		"available": {
+			status:      &terraform.Status{State: terraform.Available, Outputs: map[string]string{"BUCKET_NAME": "vela-website"}},
+			wantHealthy: true,
+			want: v1alpha2.ApplicationComponentStatus{
+				Name:    "sample-oss",
+				Healthy: true,
+				State:   v1alpha2.HealthStateHealthy,
+				Message: "the outputs are written to Secret sample-oss",
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			statuses, healthy, err := h.statusAggregate(af, map[string]*terraform.Status{"sample-oss": tc.status})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantHealthy, healthy)
+			assert.Equal(t, []v1alpha2.ApplicationComponentStatus{tc.want}, statuses)
+		})
+	}
+
+	_, _, err := h.statusAggregate(af, nil)
+	assert.EqualError(t, err, "app=myapp, comp=sample-oss, no status of the Terraform component")
+}
+
+func TestTerraformFinalizer(t *testing.T) {
+	ctx := context.TODO()
+	app := &v1alpha2.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"},
+		Spec: v1alpha2.ApplicationSpec{Components: []v1alpha2.ApplicationComponent{
+			{Name: "sample-oss", WorkloadType: "alibaba-oss"},
+			{Name: "frontend", WorkloadType: "webservice"},
+		}},
+	}
+	executor := &terraform.FakeExecutor{Destroying: map[string]bool{"sample-oss": true}}
+	c := fake.NewFakeClientWithScheme(common.Scheme, app.DeepCopy())
+	h := &appHandler{r: &Reconciler{Client: c, terraform: executor}, app: app, logger: ctrl.Log.WithName("test")}
+
+	// only the applications with Terraform components have the finalizer
+	assert.NoError(t, h.registerTerraformFinalizer(ctx, nil))
+	assert.Empty(t, app.Finalizers)
+	app.Status.Phase = v1alpha2.ApplicationRendering
+	assert.NoError(t, h.registerTerraformFinalizer(ctx, []*terraform.Configuration{{Name: "sample-oss"}}))
+	assert.Equal(t, []string{terraformFinalizer}, app.Finalizers)
+	assert.Equal(t, v1alpha2.ApplicationRendering, app.Status.Phase)
+	got := &v1alpha2.Application{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "myapp"}, got))
+	assert.Equal(t, []string{terraformFinalizer}, got.Finalizers)
+
+	// the finalizer is kept until every component is destroyed
+	destroyed, err := h.destroyTerraform(ctx)
+	assert.NoError(t, err)
+	assert.False(t, destroyed)
+	assert.Equal(t, "myapp", executor.Destroyed["sample-oss"].AppName)
+	assert.Equal(t, "myapp", executor.Destroyed["sample-oss"].Owner.Name)
+	assert.Contains(t, executor.Destroyed, "frontend")
+	assert.Equal(t, []string{terraformFinalizer}, app.Finalizers)
+
+	executor.Destroying = nil
+	destroyed, err = h.destroyTerraform(ctx)
+	assert.NoError(t, err)
+	assert.True(t, destroyed)
+	got = &v1alpha2.Application{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "myapp"}, got))
+	assert.Empty(t, got.Finalizers)
+
+	executor.Err = errors.New("boom")
+	meta.AddFinalizer(&app.ObjectMeta, terraformFinalizer)
+	_, err = h.destroyTerraform(ctx)
+	assert.EqualError(t, err, "cannot destroy the Terraform component sample-oss: boom")
+}
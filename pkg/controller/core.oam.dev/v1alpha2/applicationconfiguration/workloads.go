@@ -18,6 +18,8 @@ const (
 	cloneSetDisablePath            = "spec.updateStrategy.paused"
 	advancedStatefulSetDisablePath = "spec.updateStrategy.rollingUpdate.paused"
 	deploymentDisablePath          = "spec.paused"
+	statefulSetDisablePath         = "spec.updateStrategy.rollingUpdate.partition"
+	statefulSetReplicasPath        = "spec.replicas"
 )
 
 // SetAppWorkloadInstanceName sets the name of the workload instance depends on the component revision
@@ -38,6 +40,14 @@ func SetAppWorkloadInstanceName(componentName string, w *unstructured.Unstructur
 			return
 		}
 	}
+	if w.GroupVersionKind().Group == appsv1.GroupName &&
+		w.GetKind() == reflect.TypeOf(appsv1.StatefulSet{}).Name() {
+		// statefulset upgrades its pods in place through its rolling update partition
+		klog.InfoS("we reuse the component name for resources that support in-place upgrade",
+			"GVK", w.GroupVersionKind(), "instance name", componentName)
+		w.SetName(componentName)
+		return
+	}
 	// we assume that the rest of the resources do not support in-place upgrade
 	instanceName := utils.ConstructRevisionName(componentName, int64(revision))
 	klog.InfoS("we encountered an unknown resources, assume that it does not support in-place upgrade",
@@ -75,15 +85,30 @@ func prepWorkloadInstanceForRollout(workload *unstructured.Unstructured) error {
 				"kind", workload.GetKind(), "instance name", workload.GetName())
 			return nil
 		}
-	} else if workload.GroupVersionKind().Group == appsv1.GroupName &&
-		workload.GetKind() == reflect.TypeOf(appsv1.Deployment{}).Name() {
-		err := pv.SetBool(deploymentDisablePath, true)
-		if err != nil {
-			return err
+	} else if workload.GroupVersionKind().Group == appsv1.GroupName {
+		switch workload.GetKind() {
+		case reflect.TypeOf(appsv1.Deployment{}).Name():
+			err := pv.SetBool(deploymentDisablePath, true)
+			if err != nil {
+				return err
+			}
+			klog.InfoS("we render a deployment workload paused on the first time",
+				"kind", workload.GetKind(), "instance name", workload.GetName())
+			return nil
+		case reflect.TypeOf(appsv1.StatefulSet{}).Name():
+			// a statefulset does not upgrade any pod if the partition is no less than its replicas
+			replicas, err := pv.GetInteger(statefulSetReplicasPath)
+			if err != nil {
+				// the default replicas of a statefulset is 1
+				replicas = 1
+			}
+			if err = pv.SetValue(statefulSetDisablePath, replicas); err != nil {
+				return err
+			}
+			klog.InfoS("we render a statefulset workload paused on the first time",
+				"kind", workload.GetKind(), "instance name", workload.GetName())
+			return nil
 		}
-		klog.InfoS("we render a deployment workload paused on the first time",
-			"kind", workload.GetKind(), "instance name", workload.GetName())
-		return nil
 	}
 	klog.InfoS("we encountered an unknown resource, we don't know how to prepare it",
 		"GVK", workload.GroupVersionKind().String(), "instance name", workload.GetName())
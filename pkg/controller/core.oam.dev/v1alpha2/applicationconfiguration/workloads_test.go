@@ -54,6 +54,16 @@ func TestSetAppWorkloadInstanceName(t *testing.T) {
 			expName: "mysql",
 			reason:  "workloadName set in the template is ignored",
 		},
+		"apps statefulset case": {
+			compName: "mysql",
+			revision: 2,
+			w: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "StatefulSet",
+			}},
+			expName: "mysql",
+			reason:  "workloadName should be just the component name since statefulset upgrades in place",
+		},
 		"one resources same name case": {
 			compName: "mysql",
 			revision: 2,
@@ -110,8 +120,17 @@ func TestPrepWorkloadInstanceForRollout(t *testing.T) {
 	assert.True(t, exist)
 	assert.True(t, err == nil)
 	assert.True(t, value)
-	// Test other
+	// Test apps statefulset
 	workload.Kind = "StatefulSet"
 	w, _ = util.Object2Unstructured(workload)
+	assert.True(t, unstructured.SetNestedField(w.Object, int64(3), "spec", "replicas") == nil)
+	assert.True(t, prepWorkloadInstanceForRollout(w) == nil)
+	partition, exist, err := unstructured.NestedInt64(w.Object, "spec", "updateStrategy", "rollingUpdate", "partition")
+	assert.True(t, exist)
+	assert.True(t, err == nil)
+	assert.Equal(t, int64(3), partition)
+	// Test other
+	workload.Kind = "DaemonSet"
+	w, _ = util.Object2Unstructured(workload)
 	assert.True(t, strings.Contains(prepWorkloadInstanceForRollout(w).Error(), "we do not know how to prepare"))
 }
@@ -8,12 +8,12 @@ import (
 
 	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ktypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
-	"k8s.io/kubectl/pkg/util/slice"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -72,24 +72,37 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (res reconcile.Result, retErr e
 	}
 	klog.InfoS("Start to reconcile ", "appRollout", klog.KObj(&appRollout))
 
-	// TODO: check if the target/source has changed
-	r.handleFinalizer(&appRollout)
-	targetAppName := appRollout.Spec.TargetAppRevisionName
-	sourceAppName := appRollout.Spec.SourceAppRevisionName
-
 	ctx = oamutil.SetNamespaceInCtx(ctx, appRollout.Namespace)
 
+	if deleting, err := r.handleFinalizer(ctx, &appRollout); deleting || err != nil {
+		return ctrl.Result{}, err
+	}
+	targetAppName := appRollout.Spec.TargetAppRevisionName
+	sourceAppName := sourceAppRevision(&appRollout, appRollout.Spec.SourceAppRevisionName)
+
+	rollingBack := false
 	if appRollout.Status.RollingState == v1alpha1.RolloutSucceedState ||
 		appRollout.Status.RollingState == v1alpha1.RolloutFailedState {
 		if appRollout.Status.LastUpgradedTargetAppRevision == appRollout.Spec.TargetAppRevisionName &&
 			appRollout.Status.LastSourceAppRevision == appRollout.Spec.SourceAppRevisionName {
-			klog.InfoS("rollout terminated, no need to reconcile", "source", sourceAppName,
+			if !needRollback(&appRollout) {
+				klog.InfoS("rollout terminated, no need to reconcile", "source", sourceAppName,
+					"target", targetAppName)
+				return ctrl.Result{}, nil
+			}
+			if len(appRollout.Status.ComponentStatuses) != 0 {
+				return ctrl.Result{}, r.skipMultiComponentRollback(ctx, &appRollout)
+			}
+			rollingBack = true
+		} else {
+			klog.InfoS("rollout target changed, restart the rollout", "source", sourceAppName,
 				"target", targetAppName)
-			return ctrl.Result{}, nil
+			r.restartRollout(&appRollout)
+			sourceAppName = appRollout.Spec.SourceAppRevisionName
 		}
-		klog.InfoS("rollout target changed, restart the rollout", "source", sourceAppName,
-			"target", targetAppName)
-		appRollout.Status.StateTransition(v1alpha1.WorkloadModifiedEvent)
+	} else if rolloutModified(&appRollout) {
+		// wrap up what we are doing with the previous target/source before we start over
+		return r.finalizeModifiedRollout(ctx, &appRollout)
 	}
 
 	// Get the target application
@@ -137,74 +150,113 @@ func (r *Reconciler) Reconcile(req ctrl.Request) (res reconcile.Result, retErr e
 		}
 	}
 
-	targetWorkload, sourceWorkload, err := r.extractWorkloads(ctx, appRollout.Spec.ComponentList, &targetApp, sourceApp)
-	if err != nil {
-		klog.ErrorS(err, "cannot fetch the workloads to upgrade", "target application",
-			klog.KRef(req.Namespace, targetAppName), "source application", klog.KRef(req.Namespace, sourceAppName),
-			"commonComponent", appRollout.Spec.ComponentList)
-		return ctrl.Result{RequeueAfter: 5 * time.Second}, client.IgnoreNotFound(err)
-	}
-	klog.InfoS("get the target workload we need to work on", "targetWorkload", klog.KObj(targetWorkload))
+	var result ctrl.Result
+	if components := rolloutComponents(appRollout.Spec.ComponentList, &targetApp, sourceApp); len(components) > 1 {
+		// every component rolls out with its own rollout plan controller
+		var err error
+		if result, err = r.reconcileComponents(ctx, &appRollout, components, &targetApp, sourceApp); err != nil {
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, client.IgnoreNotFound(err)
+		}
+	} else {
+		targetWorkload, sourceWorkload, err := ExtractWorkloads(ctx, r, appRollout.Spec.ComponentList, &targetApp,
+			sourceApp)
+		if err != nil {
+			klog.ErrorS(err, "cannot fetch the workloads to upgrade", "target application",
+				klog.KRef(req.Namespace, targetAppName), "source application", klog.KRef(req.Namespace, sourceAppName),
+				"commonComponent", appRollout.Spec.ComponentList)
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, client.IgnoreNotFound(err)
+		}
+		klog.InfoS("get the target workload we need to work on", "targetWorkload", klog.KObj(targetWorkload))
 
-	if sourceWorkload != nil {
-		klog.InfoS("get the source workload we need to work on", "sourceWorkload", klog.KObj(sourceWorkload))
-	}
+		if sourceWorkload != nil {
+			klog.InfoS("get the source workload we need to work on", "sourceWorkload", klog.KObj(sourceWorkload))
+		}
 
-	// reconcile the rollout part of the spec given the target and source workload
-	rolloutPlanController := rollout.NewRolloutPlanController(r, &appRollout, r.record,
-		&appRollout.Spec.RolloutPlan, &appRollout.Status.RolloutStatus, targetWorkload, sourceWorkload)
-	result, rolloutStatus := rolloutPlanController.Reconcile(ctx)
-	// make sure that the new status is copied back
-	appRollout.Status.RolloutStatus = *rolloutStatus
-	appRollout.Status.LastUpgradedTargetAppRevision = targetAppName
-	appRollout.Status.LastSourceAppRevision = sourceAppName
-	if rolloutStatus.RollingState == v1alpha1.RolloutSucceedState {
-		if sourceApp != nil {
-			// mark the source app as an application revision only so that it stop being reconciled
-			oamutil.RemoveAnnotations(sourceApp, []string{oam.AnnotationAppRollout})
-			oamutil.AddAnnotations(sourceApp, map[string]string{oam.AnnotationAppRevision: strconv.FormatBool(true)})
-			if err := r.Update(ctx, sourceApp); err != nil {
-				klog.ErrorS(err, "cannot add the app revision annotation", "source application",
-					klog.KRef(req.Namespace, sourceAppName))
-				return ctrl.Result{}, err
-			}
+		if rollingBack {
+			return r.rollbackOnFailure(ctx, &appRollout, &targetApp, sourceApp, targetWorkload, sourceWorkload)
 		}
-		// remove the rollout annotation so that the target appConfig controller can take over the rest of the work
-		oamutil.RemoveAnnotations(&targetApp, []string{oam.AnnotationAppRollout})
-		if err := r.Update(ctx, &targetApp); err != nil {
-			klog.ErrorS(err, "cannot remove the rollout annotation", "target application",
-				klog.KRef(req.Namespace, targetAppName))
+
+		// reconcile the rollout part of the spec given the target and source workload
+		rolloutPlanController := rollout.NewRolloutPlanController(r, &appRollout, r.record,
+			&appRollout.Spec.RolloutPlan, &appRollout.Status.RolloutStatus, targetWorkload, sourceWorkload)
+		var rolloutStatus *v1alpha1.RolloutStatus
+		result, rolloutStatus = rolloutPlanController.Reconcile(ctx)
+		// make sure that the new status is copied back
+		appRollout.Status.RolloutStatus = *rolloutStatus
+	}
+	appRollout.Status.LastUpgradedTargetAppRevision = targetAppName
+	appRollout.Status.LastSourceAppRevision = appRollout.Spec.SourceAppRevisionName
+	if appRollout.Status.RollingState == v1alpha1.RolloutSucceedState {
+		if err := ActivateAppRevision(ctx, r, &targetApp, sourceApp); err != nil {
 			return ctrl.Result{}, err
 		}
 		klog.InfoS("rollout succeeded, record the source and target app revision", "source", sourceAppName,
 			"target", targetAppName)
 	}
+	if needRollback(&appRollout) {
+		// start to roll back right away
+		result = ctrl.Result{Requeue: true}
+	}
 	// update the appRollout status
 	return result, r.updateStatus(ctx, &appRollout)
 }
 
 // UpdateStatus updates v1alpha2.AppRollout's Status with retry.RetryOnConflict
+// and clears the batch approval that no longer applies to the updated status
 func (r *Reconciler) updateStatus(ctx context.Context, appRollout *oamv1alpha2.AppRollout, opts ...client.UpdateOption) error {
 	status := appRollout.DeepCopy().Status
-	return retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() (err error) {
 		if err = r.Get(ctx, client.ObjectKey{Namespace: appRollout.Namespace, Name: appRollout.Name}, appRollout); err != nil {
 			return
 		}
 		appRollout.Status = status
 		return r.Status().Update(ctx, appRollout, opts...)
-	})
+	}); err != nil {
+		return err
+	}
+	return rollout.ClearStaleBatchApproval(ctx, r, appRollout, &appRollout.Status.RolloutStatus)
+}
+
+// ActivateAppRevision lets the appConfig controller take over the active application revision
+// and marks the other one, if any, as an application revision only so that it stops being reconciled
+func ActivateAppRevision(ctx context.Context, c client.Client, activeApp,
+	inactiveApp *oamv1alpha2.ApplicationConfiguration) error {
+	if inactiveApp != nil {
+		oamutil.RemoveAnnotations(inactiveApp, []string{oam.AnnotationAppRollout})
+		oamutil.AddAnnotations(inactiveApp, map[string]string{oam.AnnotationAppRevision: strconv.FormatBool(true)})
+		if err := c.Update(ctx, inactiveApp); err != nil {
+			klog.ErrorS(err, "cannot add the app revision annotation", "application", klog.KObj(inactiveApp))
+			return err
+		}
+	}
+	// remove the rollout annotation so that the appConfig controller can take over the rest of the work
+	oamutil.RemoveAnnotations(activeApp, []string{oam.AnnotationAppRollout})
+	if err := c.Update(ctx, activeApp); err != nil {
+		klog.ErrorS(err, "cannot remove the rollout annotation", "application", klog.KObj(activeApp))
+		return err
+	}
+	return nil
 }
 
-func (r *Reconciler) handleFinalizer(appRollout *oamv1alpha2.AppRollout) {
+// handleFinalizer registers the finalizer on a live appRollout and cleans up a deleted one
+// it returns true if the appRollout is being deleted
+func (r *Reconciler) handleFinalizer(ctx context.Context, appRollout *oamv1alpha2.AppRollout) (bool, error) {
 	if appRollout.DeletionTimestamp.IsZero() {
-		if !slice.ContainsString(appRollout.Finalizers, appRolloutFinalizer, nil) {
-			// TODO: add finalizer
-			klog.Info("add finalizer")
+		if !meta.FinalizerExists(&appRollout.ObjectMeta, appRolloutFinalizer) {
+			klog.InfoS("register the finalizer", "appRollout", klog.KObj(appRollout))
+			meta.AddFinalizer(&appRollout.ObjectMeta, appRolloutFinalizer)
+			return false, r.Update(ctx, appRollout)
+		}
+		return false, nil
+	}
+	if meta.FinalizerExists(&appRollout.ObjectMeta, appRolloutFinalizer) {
+		if err := r.finalizeAppRollout(ctx, appRollout); err != nil {
+			return true, err
 		}
-	} else if slice.ContainsString(appRollout.Finalizers, appRolloutFinalizer, nil) {
-		// TODO: perform finalize
-		klog.Info("perform clean up")
+		meta.RemoveFinalizer(&appRollout.ObjectMeta, appRolloutFinalizer)
+		return true, r.Update(ctx, appRollout)
 	}
+	return true, nil
 }
 
 // SetupWithManager setup the controller with manager
@@ -0,0 +1,180 @@
+package applicationdeployment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	oamv1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/controller/common/rollout"
+)
+
+// rolloutTerminated checks if the rollout of a component reaches a terminal state
+func rolloutTerminated(status *v1alpha1.RolloutStatus) bool {
+	return status.RollingState == v1alpha1.RolloutSucceedState || status.RollingState == v1alpha1.RolloutFailedState
+}
+
+// syncComponentStatuses returns the status of each component in the order of the components to roll out
+func syncComponentStatuses(statuses []oamv1alpha2.ComponentRolloutStatus,
+	components []string) []oamv1alpha2.ComponentRolloutStatus {
+	existing := make(map[string]oamv1alpha2.ComponentRolloutStatus, len(statuses))
+	for _, cs := range statuses {
+		existing[cs.ComponentName] = cs
+	}
+	synced := make([]oamv1alpha2.ComponentRolloutStatus, len(components))
+	for i, componentName := range components {
+		synced[i] = existing[componentName]
+		synced[i].ComponentName = componentName
+	}
+	return synced
+}
+
+// lockstepPartition returns the last batch that every component can finish so that no component moves ahead
+// of the others. It returns nil if there is nothing to hold back
+func lockstepPartition(statuses []oamv1alpha2.ComponentRolloutStatus) *int32 {
+	var partition *int32
+	for _, cs := range statuses {
+		var batch int32
+		switch cs.RollingState {
+		case "", v1alpha1.VerifyingSpecState, v1alpha1.InitializingState:
+			batch = 0
+		case v1alpha1.RollingInBatchesState:
+			batch = cs.CurrentBatch
+			if cs.BatchRollingState == v1alpha1.BatchReadyState {
+				// the component is done with the current batch, it only waits for the others
+				batch++
+			}
+		default:
+			// the component is done with all the batches
+			continue
+		}
+		if partition == nil || batch < *partition {
+			partition = &batch
+		}
+	}
+	return partition
+}
+
+// failComponents stops the components that are still rolling out after one of them failed
+func failComponents(statuses []oamv1alpha2.ComponentRolloutStatus) {
+	var failed []string
+	for _, cs := range statuses {
+		if cs.RollingState == v1alpha1.RolloutFailingState || cs.RollingState == v1alpha1.RolloutFailedState {
+			failed = append(failed, cs.ComponentName)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	reason := fmt.Sprintf("the rollout of the component %s failed", strings.Join(failed, ","))
+	for i := range statuses {
+		status := &statuses[i].RolloutStatus
+		switch status.RollingState {
+		case "", v1alpha1.VerifyingSpecState:
+			// we never touched the workloads
+			status.RolloutFailed(reason)
+		case v1alpha1.InitializingState, v1alpha1.RollingInBatchesState:
+			status.RolloutFailing(reason)
+		}
+	}
+}
+
+// aggregateComponentStatuses sums up the status of all the components into the status of the rollout
+func aggregateComponentStatuses(status *oamv1alpha2.AppRolloutStatus) {
+	var targetSize, upgraded, upgradedReady int32
+	var inProgress, failed []string
+	var current *v1alpha1.RolloutStatus
+	for i := range status.ComponentStatuses {
+		cs := &status.ComponentStatuses[i]
+		if cs.RolloutTargetTotalSize > 0 {
+			targetSize += cs.RolloutTargetTotalSize
+		}
+		upgraded += cs.UpgradedReplicas
+		upgradedReady += cs.UpgradedReadyReplicas
+		if cs.RollingState == v1alpha1.RolloutFailingState || cs.RollingState == v1alpha1.RolloutFailedState {
+			failed = append(failed, cs.ComponentName)
+		}
+		if rolloutTerminated(&cs.RolloutStatus) {
+			continue
+		}
+		inProgress = append(inProgress, cs.ComponentName)
+		// the started component that is the furthest behind represents the rollout
+		if len(cs.RollingState) != 0 && (current == nil || cs.CurrentBatch < current.CurrentBatch) {
+			current = &cs.RolloutStatus
+		}
+	}
+	status.RolloutTargetTotalSize = targetSize
+	status.UpgradedReplicas = upgraded
+	status.UpgradedReadyReplicas = upgradedReady
+
+	switch {
+	case len(failed) != 0 && len(inProgress) == 0:
+		status.RolloutFailed(fmt.Sprintf("the rollout of the component %s failed", strings.Join(failed, ",")))
+	case len(failed) != 0:
+		status.RolloutFailing(fmt.Sprintf("the rollout of the component %s failed", strings.Join(failed, ",")))
+	case len(inProgress) == 0:
+		status.RollingState = v1alpha1.RolloutSucceedState
+		status.SetRolloutCondition(v1alpha1.NewPositiveCondition(v1alpha1.RolloutSucceed))
+	case current == nil:
+		status.RollingState = v1alpha1.VerifyingSpecState
+	default:
+		status.RollingState = current.RollingState
+		status.BatchRollingState = current.BatchRollingState
+		status.CurrentBatch = current.CurrentBatch
+	}
+}
+
+// reconcileComponents rolls out each component with its own rollout plan controller, either in lockstep
+// or one after another depending on the component rollout policy
+func (r *Reconciler) reconcileComponents(ctx context.Context, appRollout *oamv1alpha2.AppRollout,
+	components []string, targetApp, sourceApp *oamv1alpha2.ApplicationConfiguration) (ctrl.Result, error) {
+	statuses := syncComponentStatuses(appRollout.Status.ComponentStatuses, components)
+	failComponents(statuses)
+	ordered := appRollout.Spec.ComponentRolloutPolicy == oamv1alpha2.OrderedComponentRollout
+	partition := lockstepPartition(statuses)
+	previousSucceeded := true
+	for i := range statuses {
+		cs := &statuses[i]
+		succeeded := cs.RollingState == v1alpha1.RolloutSucceedState
+		if rolloutTerminated(&cs.RolloutStatus) {
+			previousSucceeded = previousSucceeded && succeeded
+			continue
+		}
+		if ordered && len(cs.RollingState) == 0 && !previousSucceeded {
+			klog.InfoS("the component is waiting for the previous components", "appRollout",
+				klog.KObj(appRollout), "component", cs.ComponentName)
+			previousSucceeded = false
+			continue
+		}
+		previousSucceeded = false
+		targetWorkload, sourceWorkload, err := ExtractComponentWorkloads(ctx, r, cs.ComponentName, targetApp,
+			sourceApp)
+		if err != nil {
+			klog.ErrorS(err, "cannot fetch the workloads of the component to upgrade", "component",
+				cs.ComponentName, "target application", klog.KObj(targetApp))
+			return ctrl.Result{}, err
+		}
+		rolloutPlan := appRollout.Spec.RolloutPlan.DeepCopy()
+		if !ordered && partition != nil &&
+			(rolloutPlan.BatchPartition == nil || *partition < *rolloutPlan.BatchPartition) {
+			rolloutPlan.BatchPartition = partition
+		}
+		klog.InfoS("reconcile the rollout of a component", "appRollout", klog.KObj(appRollout),
+			"component", cs.ComponentName, "rollout state", cs.RollingState)
+		rolloutPlanController := rollout.NewRolloutPlanController(r, appRollout, r.record, rolloutPlan,
+			&cs.RolloutStatus, targetWorkload, sourceWorkload)
+		_, rolloutStatus := rolloutPlanController.Reconcile(ctx)
+		cs.RolloutStatus = *rolloutStatus
+	}
+	appRollout.Status.ComponentStatuses = statuses
+	aggregateComponentStatuses(&appRollout.Status)
+	if rolloutTerminated(&appRollout.Status.RolloutStatus) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+}
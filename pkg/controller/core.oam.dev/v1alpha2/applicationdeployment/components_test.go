@@ -0,0 +1,154 @@
+package applicationdeployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/pointer"
+
+	oamv1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+)
+
+func newComponentStatus(name string, state v1alpha1.RollingState, batchState v1alpha1.BatchRollingState,
+	batch int32) oamv1alpha2.ComponentRolloutStatus {
+	return oamv1alpha2.ComponentRolloutStatus{
+		ComponentName: name,
+		RolloutStatus: v1alpha1.RolloutStatus{
+			RollingState:      state,
+			BatchRollingState: batchState,
+			CurrentBatch:      batch,
+		},
+	}
+}
+
+func TestSyncComponentStatuses(t *testing.T) {
+	statuses := []oamv1alpha2.ComponentRolloutStatus{
+		newComponentStatus("backend", v1alpha1.RollingInBatchesState, v1alpha1.BatchReadyState, 1),
+		newComponentStatus("cache", v1alpha1.RolloutSucceedState, "", 2),
+	}
+	synced := syncComponentStatuses(statuses, []string{"frontend", "backend"})
+	assert.Equal(t, []oamv1alpha2.ComponentRolloutStatus{
+		{ComponentName: "frontend"},
+		newComponentStatus("backend", v1alpha1.RollingInBatchesState, v1alpha1.BatchReadyState, 1),
+	}, synced)
+}
+
+func TestLockstepPartition(t *testing.T) {
+	tests := map[string]struct {
+		statuses []oamv1alpha2.ComponentRolloutStatus
+		want     *int32
+	}{
+		"a component has not started": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", v1alpha1.RollingInBatchesState, v1alpha1.BatchReadyState, 1),
+				newComponentStatus("backend", "", "", 0),
+			},
+			want: pointer.Int32Ptr(0),
+		},
+		"a component is in the middle of a batch": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", v1alpha1.RollingInBatchesState, v1alpha1.BatchReadyState, 1),
+				newComponentStatus("backend", v1alpha1.RollingInBatchesState, v1alpha1.BatchInRollingState, 1),
+			},
+			want: pointer.Int32Ptr(1),
+		},
+		"all components are done with the batch": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", v1alpha1.RollingInBatchesState, v1alpha1.BatchReadyState, 1),
+				newComponentStatus("backend", v1alpha1.RollingInBatchesState, v1alpha1.BatchReadyState, 1),
+			},
+			want: pointer.Int32Ptr(2),
+		},
+		"all components are past the batches": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", v1alpha1.FinalisingState, "", 2),
+				newComponentStatus("backend", v1alpha1.RolloutSucceedState, "", 2),
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, lockstepPartition(tt.statuses))
+		})
+	}
+}
+
+func TestFailComponents(t *testing.T) {
+	statuses := []oamv1alpha2.ComponentRolloutStatus{
+		newComponentStatus("frontend", v1alpha1.RolloutFailedState, "", 1),
+		newComponentStatus("backend", v1alpha1.RollingInBatchesState, v1alpha1.BatchInRollingState, 1),
+		newComponentStatus("cache", "", "", 0),
+		newComponentStatus("db", v1alpha1.RolloutSucceedState, "", 2),
+	}
+	failComponents(statuses)
+	assert.Equal(t, v1alpha1.RolloutFailedState, statuses[0].RollingState)
+	assert.Equal(t, v1alpha1.RolloutFailingState, statuses[1].RollingState)
+	assert.Equal(t, v1alpha1.RolloutFailedState, statuses[2].RollingState)
+	assert.Equal(t, v1alpha1.RolloutSucceedState, statuses[3].RollingState)
+}
+
+func TestAggregateComponentStatuses(t *testing.T) {
+	tests := map[string]struct {
+		statuses       []oamv1alpha2.ComponentRolloutStatus
+		wantState      v1alpha1.RollingState
+		wantBatch      int32
+		wantBatchState v1alpha1.BatchRollingState
+	}{
+		"no component has started": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", "", "", 0),
+				newComponentStatus("backend", "", "", 0),
+			},
+			wantState: v1alpha1.VerifyingSpecState,
+		},
+		"the slowest component represents the rollout": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", v1alpha1.RollingInBatchesState, v1alpha1.BatchReadyState, 2),
+				newComponentStatus("backend", v1alpha1.RollingInBatchesState, v1alpha1.BatchInRollingState, 1),
+				newComponentStatus("cache", "", "", 0),
+			},
+			wantState:      v1alpha1.RollingInBatchesState,
+			wantBatchState: v1alpha1.BatchInRollingState,
+			wantBatch:      1,
+		},
+		"all components succeeded": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", v1alpha1.RolloutSucceedState, "", 2),
+				newComponentStatus("backend", v1alpha1.RolloutSucceedState, "", 2),
+			},
+			wantState: v1alpha1.RolloutSucceedState,
+		},
+		"a component is still failing": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", v1alpha1.RolloutFailedState, "", 1),
+				newComponentStatus("backend", v1alpha1.RolloutFailingState, "", 1),
+			},
+			wantState: v1alpha1.RolloutFailingState,
+		},
+		"all components stopped": {
+			statuses: []oamv1alpha2.ComponentRolloutStatus{
+				newComponentStatus("frontend", v1alpha1.RolloutFailedState, "", 1),
+				newComponentStatus("backend", v1alpha1.RolloutSucceedState, "", 2),
+			},
+			wantState: v1alpha1.RolloutFailedState,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			status := &oamv1alpha2.AppRolloutStatus{ComponentStatuses: tt.statuses}
+			for i := range status.ComponentStatuses {
+				status.ComponentStatuses[i].RolloutTargetTotalSize = 5
+				status.ComponentStatuses[i].UpgradedReplicas = 2
+			}
+			aggregateComponentStatuses(status)
+			assert.Equal(t, tt.wantState, status.RollingState)
+			if tt.wantState == v1alpha1.RollingInBatchesState {
+				assert.Equal(t, tt.wantBatchState, status.BatchRollingState)
+				assert.Equal(t, tt.wantBatch, status.CurrentBatch)
+			}
+			assert.Equal(t, int32(5*len(tt.statuses)), status.RolloutTargetTotalSize)
+			assert.Equal(t, int32(2*len(tt.statuses)), status.UpgradedReplicas)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package applicationdeployment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	oamv1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+)
+
+// rolloutInFlight checks if the appRollout still holds the application revisions, that is when neither
+// the rollout nor the rollback has handed the application over to the appConfig controller
+func rolloutInFlight(appRollout *oamv1alpha2.AppRollout) bool {
+	return appRollout.Status.RollingState != v1alpha1.RolloutSucceedState &&
+		appRollout.Status.RollbackState != oamv1alpha2.RollbackSucceedState
+}
+
+// finalizeAppRollout hands the application over to the appConfig controller when an in-flight appRollout
+// is deleted. It restores the source if revertOnDelete is set, otherwise it completes the target. The target is
+// released to the appConfig controller as well if there is no source to restore
+func (r *Reconciler) finalizeAppRollout(ctx context.Context, appRollout *oamv1alpha2.AppRollout) error {
+	if !rolloutInFlight(appRollout) {
+		klog.InfoS("the rollout is already done, nothing to clean up", "appRollout", klog.KObj(appRollout))
+		return nil
+	}
+	targetApp, err := r.getAppRevision(ctx, appRollout.Namespace, appRollout.Spec.TargetAppRevisionName)
+	if err != nil {
+		return err
+	}
+	sourceApp, err := r.getAppRevision(ctx, appRollout.Namespace,
+		sourceAppRevision(appRollout, appRollout.Spec.SourceAppRevisionName))
+	if err != nil {
+		return err
+	}
+	revert := appRollout.Spec.RevertOnDelete != nil && *appRollout.Spec.RevertOnDelete
+	if targetApp != nil {
+		r.releaseWorkloads(ctx, appRollout, targetApp, sourceApp, revert && sourceApp != nil)
+	}
+
+	switch {
+	case revert && sourceApp != nil:
+		klog.InfoS("the rollout is deleted, restore the source application revision",
+			"appRollout", klog.KObj(appRollout), "source", sourceApp.Name)
+		if err := ActivateAppRevision(ctx, r, sourceApp, targetApp); err != nil {
+			return err
+		}
+		r.record.Event(appRollout, event.Normal("Rollout Reverted",
+			fmt.Sprintf("The rollout is deleted, restored the application revision %s", sourceApp.Name)))
+
+	case !revert && targetApp != nil:
+		klog.InfoS("the rollout is deleted, complete the target application revision",
+			"appRollout", klog.KObj(appRollout), "target", targetApp.Name)
+		if err := ActivateAppRevision(ctx, r, targetApp, sourceApp); err != nil {
+			return err
+		}
+		r.record.Event(appRollout, event.Normal("Rollout Completed",
+			fmt.Sprintf("The rollout is deleted, completed the application revision %s", targetApp.Name)))
+
+	case revert && targetApp != nil:
+		// there is nothing to restore, the target must not be left frozen by the rollout annotation
+		klog.InfoS("the rollout is deleted without a source to restore, hand the target application revision over",
+			"appRollout", klog.KObj(appRollout), "target", targetApp.Name)
+		if err := ActivateAppRevision(ctx, r, targetApp, nil); err != nil {
+			return err
+		}
+		r.record.Event(appRollout, event.Normal("Rollout Completed",
+			fmt.Sprintf("The rollout is deleted without a source to restore, released the application revision %s",
+				targetApp.Name)))
+
+	default:
+		klog.InfoS("the rollout is deleted, there is no application revision to hand over",
+			"appRollout", klog.KObj(appRollout), "revertOnDelete", revert)
+	}
+	return nil
+}
+
+// getAppRevision returns the application revision with the given name, it returns nil if it does not exist
+func (r *Reconciler) getAppRevision(ctx context.Context, namespace,
+	name string) (*oamv1alpha2.ApplicationConfiguration, error) {
+	if len(name) == 0 {
+		return nil, nil
+	}
+	var appConfig oamv1alpha2.ApplicationConfiguration
+	if err := r.Get(ctx, ktypes.NamespacedName{Namespace: namespace, Name: name}, &appConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			klog.InfoS("the application revision does not exist", "application revision",
+				klog.KRef(namespace, name))
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &appConfig, nil
+}
+
+// releaseWorkloads removes the appRollout from the owners of the workloads it claimed, it's best effort
+// as the workloads are still owned by their appConfig. On revert, the target workload that is a different object
+// than the source is scaled down and the source is scaled back to the size of the rollout
+func (r *Reconciler) releaseWorkloads(ctx context.Context, appRollout *oamv1alpha2.AppRollout, targetApp,
+	sourceApp *oamv1alpha2.ApplicationConfiguration, revert bool) {
+	type componentRollout struct {
+		componentList []string
+		totalSize     int32
+	}
+	rollouts := []componentRollout{{appRollout.Spec.ComponentList, appRollout.Status.RolloutTargetTotalSize}}
+	if len(appRollout.Status.ComponentStatuses) != 0 {
+		rollouts = nil
+		for _, cs := range appRollout.Status.ComponentStatuses {
+			rollouts = append(rollouts, componentRollout{[]string{cs.ComponentName}, cs.RolloutTargetTotalSize})
+		}
+	}
+	for _, cr := range rollouts {
+		targetWorkload, sourceWorkload, err := ExtractWorkloads(ctx, r, cr.componentList, targetApp, sourceApp)
+		if err != nil {
+			klog.ErrorS(err, "cannot fetch the workloads to release", "appRollout", klog.KObj(appRollout),
+				"components", cr.componentList)
+			continue
+		}
+		// a workload that is upgraded in place is restored by the appConfig of the source
+		inPlace := sourceWorkload != nil && sourceWorkload.GetName() == targetWorkload.GetName() &&
+			sourceWorkload.GroupVersionKind() == targetWorkload.GroupVersionKind()
+		var targetScaled, sourceScaled bool
+		if revert && !inPlace {
+			targetScaled = scaleWorkload(targetWorkload, 0)
+			if cr.totalSize > 0 {
+				sourceScaled = scaleWorkload(sourceWorkload, cr.totalSize)
+			}
+		}
+		r.releaseWorkload(ctx, appRollout, targetWorkload, targetScaled)
+		if !inPlace {
+			r.releaseWorkload(ctx, appRollout, sourceWorkload, sourceScaled)
+		}
+	}
+}
+
+// scaleWorkload sets the replicas of a workload that has them, it returns true if the workload is changed
+func scaleWorkload(workload *unstructured.Unstructured, replicas int32) bool {
+	if workload == nil {
+		return false
+	}
+	if _, exist, _ := unstructured.NestedFieldNoCopy(workload.Object, "spec", "replicas"); !exist {
+		klog.InfoS("the workload has no replicas to scale", "workload", klog.KObj(workload))
+		return false
+	}
+	klog.InfoS("scale the workload", "workload", klog.KObj(workload), "replicas", replicas)
+	return unstructured.SetNestedField(workload.Object, int64(replicas), "spec", "replicas") == nil
+}
+
+// releaseWorkload removes the appRollout from the owners of the workload and saves it if it is changed
+func (r *Reconciler) releaseWorkload(ctx context.Context, appRollout *oamv1alpha2.AppRollout,
+	workload *unstructured.Unstructured, modified bool) {
+	if workload == nil {
+		return
+	}
+	var owners []metav1.OwnerReference
+	for _, owner := range workload.GetOwnerReferences() {
+		if owner.UID != appRollout.UID {
+			owners = append(owners, owner)
+		}
+	}
+	if !modified && len(owners) == len(workload.GetOwnerReferences()) {
+		return
+	}
+	workload.SetOwnerReferences(owners)
+	if err := r.Update(ctx, workload); err != nil {
+		klog.ErrorS(err, "cannot release the workload", "workload", klog.KObj(workload))
+	}
+}
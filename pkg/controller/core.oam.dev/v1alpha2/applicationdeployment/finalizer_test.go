@@ -0,0 +1,255 @@
+package applicationdeployment
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	oamv1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestHandleFinalizer(t *testing.T) {
+	ctx := context.TODO()
+	const namespace = "default"
+	newDeletedAppRollout := func(source string, revert bool, state v1alpha1.RollingState,
+		rollbackState oamv1alpha2.RollbackState) *oamv1alpha2.AppRollout {
+		now := metav1.Now()
+		appRollout := &oamv1alpha2.AppRollout{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "rollout",
+				Namespace:         namespace,
+				UID:               "rollout-uid",
+				Finalizers:        []string{appRolloutFinalizer},
+				DeletionTimestamp: &now,
+			},
+			Spec: oamv1alpha2.AppRolloutSpec{
+				TargetAppRevisionName: "app-v2",
+				SourceAppRevisionName: source,
+				RevertOnDelete:        pointer.BoolPtr(revert),
+			},
+		}
+		appRollout.Status.RollingState = state
+		appRollout.Status.RolloutTargetTotalSize = 3
+		appRollout.Status.RollbackState = rollbackState
+		return appRollout
+	}
+	// the expected annotations of an application revision after the rollout is deleted
+	const (
+		untouched = "untouched"
+		active    = "active"
+		inactive  = "inactive"
+	)
+	inFlightStates := []v1alpha1.RollingState{
+		"",
+		v1alpha1.VerifyingSpecState,
+		v1alpha1.InitializingState,
+		v1alpha1.RollingInBatchesState,
+		v1alpha1.FinalisingState,
+		v1alpha1.RolloutFailingState,
+		v1alpha1.RolloutFailedState,
+	}
+	type testCase struct {
+		appRollout         *oamv1alpha2.AppRollout
+		wantTarget         string
+		wantSource         string
+		wantTargetReplicas int32
+		wantSourceReplicas int32
+		wantReleased       bool
+	}
+	tests := map[string]testCase{}
+	for _, state := range inFlightStates {
+		tests["revert in "+string(state)] = testCase{
+			appRollout:         newDeletedAppRollout("app-v1", true, state, ""),
+			wantTarget:         inactive,
+			wantSource:         active,
+			wantTargetReplicas: 0,
+			wantSourceReplicas: 3,
+			wantReleased:       true,
+		}
+		tests["complete in "+string(state)] = testCase{
+			appRollout:         newDeletedAppRollout("app-v1", false, state, ""),
+			wantTarget:         active,
+			wantSource:         inactive,
+			wantTargetReplicas: 2,
+			wantSourceReplicas: 1,
+			wantReleased:       true,
+		}
+	}
+	tests["revert in "+string(v1alpha1.RolloutSucceedState)] = testCase{
+		appRollout:         newDeletedAppRollout("app-v1", true, v1alpha1.RolloutSucceedState, ""),
+		wantTarget:         untouched,
+		wantSource:         untouched,
+		wantTargetReplicas: 2,
+		wantSourceReplicas: 1,
+	}
+	tests["complete in "+string(v1alpha1.RolloutSucceedState)] = testCase{
+		appRollout:         newDeletedAppRollout("app-v1", false, v1alpha1.RolloutSucceedState, ""),
+		wantTarget:         untouched,
+		wantSource:         untouched,
+		wantTargetReplicas: 2,
+		wantSourceReplicas: 1,
+	}
+	tests["revert while rolling back"] = testCase{
+		appRollout: newDeletedAppRollout("app-v1", true, v1alpha1.RolloutFailedState,
+			oamv1alpha2.RollingBackState),
+		wantTarget:         inactive,
+		wantSource:         active,
+		wantTargetReplicas: 0,
+		wantSourceReplicas: 3,
+		wantReleased:       true,
+	}
+	tests["complete after rolled back"] = testCase{
+		appRollout: newDeletedAppRollout("app-v1", false, v1alpha1.RolloutFailedState,
+			oamv1alpha2.RollbackSucceedState),
+		wantTarget:         untouched,
+		wantSource:         untouched,
+		wantTargetReplicas: 2,
+		wantSourceReplicas: 1,
+	}
+	tests["revert without a source"] = testCase{
+		appRollout:         newDeletedAppRollout("", true, v1alpha1.RollingInBatchesState, ""),
+		wantTarget:         active,
+		wantTargetReplicas: 2,
+		wantSourceReplicas: 1,
+		wantReleased:       true,
+	}
+	tests["complete without a source"] = testCase{
+		appRollout:         newDeletedAppRollout("", false, v1alpha1.RollingInBatchesState, ""),
+		wantTarget:         active,
+		wantTargetReplicas: 2,
+		wantSourceReplicas: 1,
+		wantReleased:       true,
+	}
+
+	checkAnnotations := func(t *testing.T, r *Reconciler, name, want string) {
+		var appConfig oamv1alpha2.ApplicationConfiguration
+		assert.NoError(t, r.Get(ctx, ktypes.NamespacedName{Namespace: namespace, Name: name}, &appConfig))
+		annotations := appConfig.GetAnnotations()
+		switch want {
+		case untouched:
+			assert.Contains(t, annotations, oam.AnnotationAppRollout)
+			assert.NotContains(t, annotations, oam.AnnotationAppRevision)
+		case active:
+			assert.NotContains(t, annotations, oam.AnnotationAppRollout)
+			assert.NotContains(t, annotations, oam.AnnotationAppRevision)
+		case inactive:
+			assert.NotContains(t, annotations, oam.AnnotationAppRollout)
+			assert.Equal(t, "true", annotations[oam.AnnotationAppRevision])
+		}
+	}
+
+	checkWorkload := func(t *testing.T, r *Reconciler, name string, wantReplicas int32, wantReleased bool) {
+		var workload appsv1.Deployment
+		assert.NoError(t, r.Get(ctx, ktypes.NamespacedName{Namespace: namespace, Name: name}, &workload))
+		assert.Equal(t, wantReplicas, *workload.Spec.Replicas)
+		if wantReleased {
+			assert.Empty(t, workload.GetOwnerReferences())
+		} else {
+			assert.Len(t, workload.GetOwnerReferences(), 1)
+		}
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{
+				Client: fake.NewFakeClientWithScheme(common.Scheme, tt.appRollout,
+					newTestAppRevision("app-v1", "web-v1"), newTestAppRevision("app-v2", "web-v2"),
+					newTestComponentRevision("web-v1"), newTestComponentRevision("web-v2"),
+					newTestWorkload("web-v1", 1), newTestWorkload("web-v2", 2)),
+				record: event.NewNopRecorder(),
+			}
+			deleting, err := r.handleFinalizer(ctx, tt.appRollout)
+			assert.NoError(t, err)
+			assert.True(t, deleting)
+			assert.False(t, meta.FinalizerExists(&tt.appRollout.ObjectMeta, appRolloutFinalizer))
+			checkAnnotations(t, r, "app-v2", tt.wantTarget)
+			if len(tt.wantSource) != 0 {
+				checkAnnotations(t, r, "app-v1", tt.wantSource)
+			}
+			checkWorkload(t, r, "web-v2", tt.wantTargetReplicas, tt.wantReleased)
+			// the source workload is only released if it is part of the rollout
+			checkWorkload(t, r, "web-v1", tt.wantSourceReplicas, tt.wantReleased && len(tt.wantSource) != 0)
+		})
+	}
+}
+
+func TestHandleFinalizerRegister(t *testing.T) {
+	ctx := context.TODO()
+	appRollout := &oamv1alpha2.AppRollout{
+		ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default"},
+	}
+	r := &Reconciler{
+		Client: fake.NewFakeClientWithScheme(common.Scheme, appRollout),
+		record: event.NewNopRecorder(),
+	}
+	deleting, err := r.handleFinalizer(ctx, appRollout)
+	assert.NoError(t, err)
+	assert.False(t, deleting)
+	var got oamv1alpha2.AppRollout
+	assert.NoError(t, r.Get(ctx, ktypes.NamespacedName{Namespace: "default", Name: "rollout"}, &got))
+	assert.True(t, meta.FinalizerExists(&got.ObjectMeta, appRolloutFinalizer))
+
+	// the finalizer of a deleted appRollout is already removed
+	now := metav1.Now()
+	got.Finalizers = nil
+	got.DeletionTimestamp = &now
+	deleting, err = r.handleFinalizer(ctx, &got)
+	assert.NoError(t, err)
+	assert.True(t, deleting)
+}
+
+// newTestAppRevision returns an application revision in the middle of a rollout with a single component
+func newTestAppRevision(name, componentRevision string) *oamv1alpha2.ApplicationConfiguration {
+	return &oamv1alpha2.ApplicationConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: map[string]string{oam.AnnotationAppRollout: "true"},
+		},
+		Spec: oamv1alpha2.ApplicationConfigurationSpec{
+			Components: []oamv1alpha2.ApplicationConfigurationComponent{{RevisionName: componentRevision}},
+		},
+	}
+}
+
+// newTestComponentRevision returns a component revision whose workload is a Deployment
+func newTestComponentRevision(name string) *appsv1.ControllerRevision {
+	component := oamv1alpha2.Component{
+		Spec: oamv1alpha2.ComponentSpec{
+			Workload: runtime.RawExtension{Object: &appsv1.Deployment{
+				TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			}},
+		},
+	}
+	raw, _ := json.Marshal(component)
+	return &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       runtime.RawExtension{Raw: raw},
+	}
+}
+
+// newTestWorkload returns a Deployment claimed by the rollout
+func newTestWorkload(name string, replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: oamv1alpha2.SchemeGroupVersion.String(),
+				Kind: oamv1alpha2.AppRolloutKind, Name: "rollout", UID: "rollout-uid"}},
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: pointer.Int32Ptr(replicas)},
+	}
+}
@@ -0,0 +1,213 @@
+package applicationdeployment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	oamv1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/controller/common/rollout"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+)
+
+// the max number of entries we keep in the history of an appRollout
+const maxRolloutHistory = 10
+
+// rolloutModified checks if the target or the source of the rollout has changed since we last worked on it
+func rolloutModified(appRollout *oamv1alpha2.AppRollout) bool {
+	if len(appRollout.Status.LastUpgradedTargetAppRevision) == 0 {
+		// we have not started to work on it yet
+		return false
+	}
+	return appRollout.Status.LastUpgradedTargetAppRevision != appRollout.Spec.TargetAppRevisionName ||
+		appRollout.Status.LastSourceAppRevision != appRollout.Spec.SourceAppRevisionName
+}
+
+// sourceAppRevision returns the application revision that the rollout upgrades from given the source it is asked to,
+// they differ when a modified rollout restarted from the revision that serves the application
+func sourceAppRevision(appRollout *oamv1alpha2.AppRollout, source string) string {
+	if len(appRollout.Status.EffectiveSourceAppRevision) != 0 {
+		return appRollout.Status.EffectiveSourceAppRevision
+	}
+	return source
+}
+
+// deployedAppRevision returns the application revision that serves the application when a modified rollout is
+// stopped, that is the previous target once the rollout has upgraded any replica, otherwise the previous source
+func deployedAppRevision(appRollout *oamv1alpha2.AppRollout) string {
+	upgraded := appRollout.Status.UpgradedReplicas > 0
+	for _, cs := range appRollout.Status.ComponentStatuses {
+		upgraded = upgraded || cs.UpgradedReplicas > 0
+	}
+	if upgraded {
+		return appRollout.Status.LastUpgradedTargetAppRevision
+	}
+	return sourceAppRevision(appRollout, appRollout.Status.LastSourceAppRevision)
+}
+
+// restartRollout records the restart in the history and starts the rollout over with the current spec
+func (r *Reconciler) restartRollout(appRollout *oamv1alpha2.AppRollout) {
+	status := &appRollout.Status
+	status.History = append(status.History, oamv1alpha2.AppRolloutHistoryEntry{
+		Event:             v1alpha1.WorkloadModifiedEvent,
+		SourceAppRevision: sourceAppRevision(appRollout, status.LastSourceAppRevision),
+		TargetAppRevision: status.LastUpgradedTargetAppRevision,
+		RollingState:      status.RollingState,
+		CurrentBatch:      status.CurrentBatch,
+		Time:              metav1.Now(),
+	})
+	if len(status.History) > maxRolloutHistory {
+		status.History = status.History[len(status.History)-maxRolloutHistory:]
+	}
+	r.record.Event(appRollout, event.Normal("Rollout Restarted",
+		fmt.Sprintf("The rollout from %s to %s is restarted as a rollout from %s to %s",
+			sourceAppRevision(appRollout, status.LastSourceAppRevision), status.LastUpgradedTargetAppRevision,
+			appRollout.Spec.SourceAppRevisionName, appRollout.Spec.TargetAppRevisionName)))
+	status.StateTransition(v1alpha1.WorkloadModifiedEvent)
+	status.EffectiveSourceAppRevision = ""
+	status.RollbackState = ""
+	status.RollbackStatus = nil
+	status.ComponentStatuses = nil
+}
+
+// finalizeModifiedRollout finishes the batch in progress with the workloads of the previous target and source,
+// releases them and then restarts the rollout with the current target from the currently deployed revision.
+// The spec is left as it is, the deployed revision is recorded as the effective source in the status instead
+func (r *Reconciler) finalizeModifiedRollout(ctx context.Context, appRollout *oamv1alpha2.AppRollout) (ctrl.Result,
+	error) {
+	lastTargetName := appRollout.Status.LastUpgradedTargetAppRevision
+	lastSourceName := sourceAppRevision(appRollout, appRollout.Status.LastSourceAppRevision)
+	klog.InfoS("rollout target changed in the middle of the rollout", "appRollout", klog.KObj(appRollout),
+		"previous source", lastSourceName, "previous target", lastTargetName,
+		"source", appRollout.Spec.SourceAppRevisionName, "target", appRollout.Spec.TargetAppRevisionName)
+
+	lastTarget, err := r.getAppRevision(ctx, appRollout.Namespace, lastTargetName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	lastSource, err := r.getAppRevision(ctx, appRollout.Namespace, lastSourceName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if lastTarget != nil {
+		finalized := true
+		if len(appRollout.Status.ComponentStatuses) == 0 {
+			finalized = r.finalizeModifiedWorkloads(ctx, appRollout, appRollout.Spec.ComponentList, lastTarget,
+				lastSource, &appRollout.Status.RolloutStatus)
+		}
+		for i := range appRollout.Status.ComponentStatuses {
+			cs := &appRollout.Status.ComponentStatuses[i]
+			if len(cs.RollingState) == 0 {
+				// the component never started
+				continue
+			}
+			finalized = r.finalizeModifiedWorkloads(ctx, appRollout, []string{cs.ComponentName}, lastTarget,
+				lastSource, &cs.RolloutStatus) && finalized
+		}
+		if !finalized {
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, r.updateStatus(ctx, appRollout)
+		}
+	}
+
+	// start over from what is serving now rather than from a source that may be long gone
+	deployed := deployedAppRevision(appRollout)
+	effectiveSource := ""
+	if len(deployed) != 0 && deployed != appRollout.Spec.SourceAppRevisionName &&
+		deployed != appRollout.Spec.TargetAppRevisionName {
+		klog.InfoS("restart the rollout from the deployed application revision", "appRollout", klog.KObj(appRollout),
+			"deployed", deployed, "source", appRollout.Spec.SourceAppRevisionName)
+		effectiveSource = deployed
+	}
+	inUse := []string{appRollout.Spec.TargetAppRevisionName, appRollout.Spec.SourceAppRevisionName}
+	if len(effectiveSource) != 0 {
+		inUse[1] = effectiveSource
+	}
+	if err := r.retireAbandonedAppRevision(ctx, appRollout, deployed, lastTarget, lastSource, inUse); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.restartRollout(appRollout)
+	appRollout.Status.EffectiveSourceAppRevision = effectiveSource
+	appRollout.Status.LastUpgradedTargetAppRevision = appRollout.Spec.TargetAppRevisionName
+	appRollout.Status.LastSourceAppRevision = appRollout.Spec.SourceAppRevisionName
+	return ctrl.Result{Requeue: true}, r.updateStatus(ctx, appRollout)
+}
+
+// retireAbandonedAppRevision scales down the workloads of the previous source or target that no longer serves the
+// application once the rollout starts over from the other one, unless the new rollout works on it again, and stops
+// the appConfig controller from reconciling it
+func (r *Reconciler) retireAbandonedAppRevision(ctx context.Context, appRollout *oamv1alpha2.AppRollout,
+	deployed string, lastTarget, lastSource *oamv1alpha2.ApplicationConfiguration, inUse []string) error {
+	if lastTarget == nil || lastSource == nil {
+		return nil
+	}
+	kept, abandoned := lastTarget, lastSource
+	if deployed != lastTarget.Name {
+		kept, abandoned = lastSource, lastTarget
+	}
+	for _, name := range inUse {
+		if abandoned.Name == name {
+			return nil
+		}
+	}
+	componentLists := [][]string{appRollout.Spec.ComponentList}
+	if len(appRollout.Status.ComponentStatuses) != 0 {
+		componentLists = nil
+		for _, cs := range appRollout.Status.ComponentStatuses {
+			componentLists = append(componentLists, []string{cs.ComponentName})
+		}
+	}
+	for _, componentList := range componentLists {
+		keptWorkload, abandonedWorkload, err := ExtractWorkloads(ctx, r, componentList, kept, abandoned)
+		if err != nil {
+			// there is nothing to scale down if the workload is gone
+			klog.ErrorS(err, "cannot fetch the workload of the abandoned application revision",
+				"appRollout", klog.KObj(appRollout), "components", componentList)
+			continue
+		}
+		// a workload that is upgraded in place keeps serving the deployed revision
+		if abandonedWorkload.GetName() == keptWorkload.GetName() &&
+			abandonedWorkload.GroupVersionKind() == keptWorkload.GroupVersionKind() {
+			continue
+		}
+		if scaleWorkload(abandonedWorkload, 0) {
+			if err := r.Update(ctx, abandonedWorkload); err != nil {
+				return err
+			}
+		}
+	}
+	klog.InfoS("retire the abandoned application revision", "appRollout", klog.KObj(appRollout),
+		"application revision", abandoned.Name, "deployed", deployed)
+	r.record.Event(appRollout, event.Normal("Rollout Restarted",
+		fmt.Sprintf("The application revision %s is scaled down as the rollout restarts from %s",
+			abandoned.Name, kept.Name)))
+	oamutil.RemoveAnnotations(abandoned, []string{oam.AnnotationAppRollout})
+	oamutil.AddAnnotations(abandoned, map[string]string{oam.AnnotationAppRevision: strconv.FormatBool(true)})
+	return r.Update(ctx, abandoned)
+}
+
+// finalizeModifiedWorkloads wraps up the rollout of the previous workloads of a component,
+// it returns true once they are released
+func (r *Reconciler) finalizeModifiedWorkloads(ctx context.Context, appRollout *oamv1alpha2.AppRollout,
+	componentList []string, lastTarget, lastSource *oamv1alpha2.ApplicationConfiguration,
+	rolloutStatus *v1alpha1.RolloutStatus) bool {
+	targetWorkload, sourceWorkload, err := ExtractWorkloads(ctx, r, componentList, lastTarget, lastSource)
+	if err != nil {
+		// there is nothing to wrap up if the previous workloads are gone
+		klog.ErrorS(err, "cannot fetch the previous workloads, restart the rollout right away",
+			"appRollout", klog.KObj(appRollout), "components", componentList)
+		return true
+	}
+	rolloutPlanController := rollout.NewRolloutPlanController(r, appRollout, r.record,
+		&appRollout.Spec.RolloutPlan, rolloutStatus, targetWorkload, sourceWorkload)
+	finalized, newStatus := rolloutPlanController.FinalizeModifiedRollout(ctx)
+	*rolloutStatus = *newStatus
+	return finalized
+}
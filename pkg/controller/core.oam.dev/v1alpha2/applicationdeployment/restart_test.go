@@ -0,0 +1,275 @@
+package applicationdeployment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	oamv1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestRolloutModified(t *testing.T) {
+	tests := map[string]struct {
+		target, source         string
+		lastTarget, lastSource string
+		want                   bool
+	}{
+		"not started": {
+			target: "app-v2", source: "app-v1",
+		},
+		"not modified": {
+			target: "app-v2", source: "app-v1",
+			lastTarget: "app-v2", lastSource: "app-v1",
+		},
+		"target modified": {
+			target: "app-v3", source: "app-v1",
+			lastTarget: "app-v2", lastSource: "app-v1",
+			want: true,
+		},
+		"source modified": {
+			target: "app-v2", source: "app-v0",
+			lastTarget: "app-v2", lastSource: "app-v1",
+			want: true,
+		},
+		"source removed": {
+			target:     "app-v2",
+			lastTarget: "app-v2", lastSource: "app-v1",
+			want: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			appRollout := &oamv1alpha2.AppRollout{
+				Spec: oamv1alpha2.AppRolloutSpec{
+					TargetAppRevisionName: tt.target,
+					SourceAppRevisionName: tt.source,
+				},
+				Status: oamv1alpha2.AppRolloutStatus{
+					LastUpgradedTargetAppRevision: tt.lastTarget,
+					LastSourceAppRevision:         tt.lastSource,
+				},
+			}
+			assert.Equal(t, tt.want, rolloutModified(appRollout))
+		})
+	}
+}
+
+func TestRestartRollout(t *testing.T) {
+	r := &Reconciler{record: event.NewNopRecorder()}
+	appRollout := &oamv1alpha2.AppRollout{
+		Spec: oamv1alpha2.AppRolloutSpec{
+			TargetAppRevisionName: "app-v3",
+			SourceAppRevisionName: "app-v1",
+		},
+	}
+	for i := 0; i < maxRolloutHistory+2; i++ {
+		appRollout.Status.LastUpgradedTargetAppRevision = fmt.Sprintf("app-v%d", i)
+		appRollout.Status.LastSourceAppRevision = "app-v1"
+		appRollout.Status.RollingState = v1alpha1.RollingInBatchesState
+		appRollout.Status.CurrentBatch = int32(i)
+		appRollout.Status.RollbackState = oamv1alpha2.RollingBackState
+		appRollout.Status.RollbackStatus = &v1alpha1.RolloutStatus{}
+		r.restartRollout(appRollout)
+
+		assert.Equal(t, v1alpha1.VerifyingSpecState, appRollout.Status.RollingState)
+		assert.Equal(t, int32(0), appRollout.Status.CurrentBatch)
+		assert.Empty(t, appRollout.Status.RollbackState)
+		assert.Nil(t, appRollout.Status.RollbackStatus)
+	}
+
+	history := appRollout.Status.History
+	assert.Len(t, history, maxRolloutHistory)
+	last := history[len(history)-1]
+	assert.Equal(t, v1alpha1.WorkloadModifiedEvent, last.Event)
+	assert.Equal(t, fmt.Sprintf("app-v%d", maxRolloutHistory+1), last.TargetAppRevision)
+	assert.Equal(t, "app-v1", last.SourceAppRevision)
+	assert.Equal(t, v1alpha1.RollingInBatchesState, last.RollingState)
+	assert.Equal(t, int32(maxRolloutHistory+1), last.CurrentBatch)
+	// the oldest entries are dropped
+	assert.Equal(t, "app-v2", history[0].TargetAppRevision)
+}
+
+func TestRestartClearsBatchApproval(t *testing.T) {
+	ctx := context.TODO()
+	appRollout := &oamv1alpha2.AppRollout{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "rollout",
+			Namespace:   "default",
+			Annotations: map[string]string{oam.AnnotationRolloutBatchApproval: "0:approved"},
+		},
+		Spec: oamv1alpha2.AppRolloutSpec{TargetAppRevisionName: "app-v3", SourceAppRevisionName: "app-v2"},
+		Status: oamv1alpha2.AppRolloutStatus{
+			RolloutStatus: v1alpha1.RolloutStatus{
+				RollingState:      v1alpha1.RollingInBatchesState,
+				BatchRollingState: v1alpha1.BatchReadyState,
+			},
+			LastUpgradedTargetAppRevision: "app-v2",
+			LastSourceAppRevision:         "app-v1",
+		},
+	}
+	r := &Reconciler{
+		Client: fake.NewFakeClientWithScheme(common.Scheme, appRollout.DeepCopy()),
+		record: event.NewNopRecorder(),
+	}
+	r.restartRollout(appRollout)
+	assert.NoError(t, r.updateStatus(ctx, appRollout))
+
+	got := &oamv1alpha2.AppRollout{}
+	assert.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: "rollout"}, got))
+	assert.NotContains(t, got.GetAnnotations(), oam.AnnotationRolloutBatchApproval)
+	assert.Equal(t, v1alpha1.VerifyingSpecState, got.Status.RollingState)
+}
+
+func TestDeployedAppRevision(t *testing.T) {
+	tests := map[string]struct {
+		status oamv1alpha2.AppRolloutStatus
+		want   string
+	}{
+		"nothing upgraded": {
+			status: oamv1alpha2.AppRolloutStatus{
+				LastUpgradedTargetAppRevision: "app-v2",
+				LastSourceAppRevision:         "app-v1",
+			},
+			want: "app-v1",
+		},
+		"some replicas upgraded": {
+			status: oamv1alpha2.AppRolloutStatus{
+				RolloutStatus:                 v1alpha1.RolloutStatus{UpgradedReplicas: 2},
+				LastUpgradedTargetAppRevision: "app-v2",
+				LastSourceAppRevision:         "app-v1",
+			},
+			want: "app-v2",
+		},
+		"a component upgraded": {
+			status: oamv1alpha2.AppRolloutStatus{
+				LastUpgradedTargetAppRevision: "app-v2",
+				LastSourceAppRevision:         "app-v1",
+				ComponentStatuses: []oamv1alpha2.ComponentRolloutStatus{
+					{ComponentName: "frontend"},
+					{ComponentName: "backend", RolloutStatus: v1alpha1.RolloutStatus{UpgradedReplicas: 1}},
+				},
+			},
+			want: "app-v2",
+		},
+		"first deployment": {
+			status: oamv1alpha2.AppRolloutStatus{LastUpgradedTargetAppRevision: "app-v1"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, deployedAppRevision(&oamv1alpha2.AppRollout{Status: tt.status}))
+		})
+	}
+}
+
+func TestFinalizeModifiedRollout(t *testing.T) {
+	ctx := context.TODO()
+	// the rollout from app-v1 to app-v2 is pointed to app-v3 after it upgraded some replicas
+	newAppRollout := func(batchState v1alpha1.BatchRollingState, paused bool) *oamv1alpha2.AppRollout {
+		return &oamv1alpha2.AppRollout{
+			ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default", UID: "rollout-uid"},
+			Spec: oamv1alpha2.AppRolloutSpec{
+				TargetAppRevisionName: "app-v3",
+				SourceAppRevisionName: "app-v1",
+				RolloutPlan: v1alpha1.RolloutPlan{
+					Paused: paused,
+					RolloutBatches: []v1alpha1.RolloutBatch{
+						{Replicas: intstr.FromInt(1)}, {Replicas: intstr.FromInt(1)}, {Replicas: intstr.FromInt(1)},
+					},
+				},
+			},
+			Status: oamv1alpha2.AppRolloutStatus{
+				RolloutStatus: v1alpha1.RolloutStatus{
+					RollingState:           v1alpha1.RollingInBatchesState,
+					BatchRollingState:      batchState,
+					CurrentBatch:           1,
+					RolloutTargetTotalSize: 3,
+					UpgradedReplicas:       2,
+				},
+				LastUpgradedTargetAppRevision: "app-v2",
+				LastSourceAppRevision:         "app-v1",
+			},
+		}
+	}
+	newReconciler := func(appRollout *oamv1alpha2.AppRollout) *Reconciler {
+		return &Reconciler{
+			Client: fake.NewFakeClientWithScheme(common.Scheme, appRollout.DeepCopy(),
+				newTestAppRevision("app-v1", "web-v1"), newTestAppRevision("app-v2", "web-v2"),
+				newTestAppRevision("app-v3", "web-v3"), newTestComponentRevision("web-v1"),
+				newTestComponentRevision("web-v2"), newTestComponentRevision("web-v3"),
+				newTestWorkload("web-v1", 1), newTestWorkload("web-v2", 2)),
+			record: event.NewNopRecorder(),
+		}
+	}
+	getReplicas := func(t *testing.T, r *Reconciler, name string) int32 {
+		var workload appsv1.Deployment
+		assert.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &workload))
+		return *workload.Spec.Replicas
+	}
+
+	t.Run("finish the batch in progress first", func(t *testing.T) {
+		appRollout := newAppRollout(v1alpha1.BatchFinalizingState, false)
+		r := newReconciler(appRollout)
+		res, err := r.finalizeModifiedRollout(ctx, appRollout)
+		assert.NoError(t, err)
+		assert.NotZero(t, res.RequeueAfter)
+		assert.Equal(t, v1alpha1.BatchReadyState, appRollout.Status.BatchRollingState)
+		assert.Equal(t, "app-v2", appRollout.Status.LastUpgradedTargetAppRevision)
+		assert.Equal(t, int32(1), getReplicas(t, r, "web-v1"))
+	})
+
+	for name, batchState := range map[string]v1alpha1.BatchRollingState{
+		"restart between two batches":             v1alpha1.BatchReadyState,
+		"restart in the middle of a paused batch": v1alpha1.BatchVerifyingState,
+	} {
+		t.Run(name, func(t *testing.T) {
+			appRollout := newAppRollout(batchState, batchState == v1alpha1.BatchVerifyingState)
+			r := newReconciler(appRollout)
+			res, err := r.finalizeModifiedRollout(ctx, appRollout)
+			assert.NoError(t, err)
+			assert.True(t, res.Requeue)
+
+			var got oamv1alpha2.AppRollout
+			assert.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: "rollout"}, &got))
+			// the spec is never rewritten, the deployed revision is the effective source
+			assert.Equal(t, "app-v1", got.Spec.SourceAppRevisionName)
+			assert.Equal(t, "app-v2", got.Status.EffectiveSourceAppRevision)
+			assert.Equal(t, "app-v1", got.Status.LastSourceAppRevision)
+			assert.Equal(t, "app-v3", got.Status.LastUpgradedTargetAppRevision)
+			assert.Equal(t, v1alpha1.VerifyingSpecState, got.Status.RollingState)
+			assert.False(t, rolloutModified(&got))
+			assert.Equal(t, "app-v2", sourceAppRevision(&got, got.Spec.SourceAppRevisionName))
+
+			// the previous source is abandoned
+			assert.Equal(t, int32(0), getReplicas(t, r, "web-v1"))
+			assert.Equal(t, int32(2), getReplicas(t, r, "web-v2"))
+			var source oamv1alpha2.ApplicationConfiguration
+			assert.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: "app-v1"}, &source))
+			assert.Equal(t, "true", source.GetAnnotations()[oam.AnnotationAppRevision])
+			assert.NotContains(t, source.GetAnnotations(), oam.AnnotationAppRollout)
+		})
+	}
+
+	t.Run("keep the previous source the new rollout starts from", func(t *testing.T) {
+		appRollout := newAppRollout(v1alpha1.BatchReadyState, false)
+		appRollout.Status.UpgradedReplicas = 0
+		r := newReconciler(appRollout)
+		_, err := r.finalizeModifiedRollout(ctx, appRollout)
+		assert.NoError(t, err)
+		assert.Empty(t, appRollout.Status.EffectiveSourceAppRevision)
+		assert.Equal(t, int32(1), getReplicas(t, r, "web-v1"))
+		// the previous target never served the application
+		assert.Equal(t, int32(0), getReplicas(t, r, "web-v2"))
+	})
+}
@@ -0,0 +1,129 @@
+package applicationdeployment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	oamv1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/controller/common/rollout"
+)
+
+// needRollback checks if we need to roll back a failed rollout automatically
+func needRollback(appRollout *oamv1alpha2.AppRollout) bool {
+	if appRollout.Spec.RollbackOnFailure == nil || !*appRollout.Spec.RollbackOnFailure {
+		return false
+	}
+	if appRollout.Status.RollingState != v1alpha1.RolloutFailedState ||
+		len(sourceAppRevision(appRollout, appRollout.Spec.SourceAppRevisionName)) == 0 {
+		return false
+	}
+	return appRollout.Status.RollbackState != oamv1alpha2.RollbackSucceedState &&
+		appRollout.Status.RollbackState != oamv1alpha2.RollbackFailedState
+}
+
+// skipMultiComponentRollback gives up rolling back a failed rollout of more than one component, we only roll back
+// a single component automatically. The rollout is marked as failed to roll back so that it doesn't look pending
+func (r *Reconciler) skipMultiComponentRollback(ctx context.Context, appRollout *oamv1alpha2.AppRollout) error {
+	err := fmt.Errorf("rollbackOnFailure only supports a rollout of a single component but %d components are "+
+		"rolled out, point the rollout back to the source to roll them back", len(appRollout.Status.ComponentStatuses))
+	klog.ErrorS(err, "cannot roll back the failed rollout", "appRollout", klog.KObj(appRollout))
+	r.record.Event(appRollout, event.Warning("Rollback Failed", err))
+	appRollout.Status.RollbackState = oamv1alpha2.RollbackFailedState
+	return r.updateStatus(ctx, appRollout)
+}
+
+// newRollbackPlan creates a single batch rollout plan that moves all the replicas back to the source
+func newRollbackPlan(appRollout *oamv1alpha2.AppRollout) *v1alpha1.RolloutPlan {
+	// always bring up the source before shrinking the target to avoid any capacity drop
+	return &v1alpha1.RolloutPlan{
+		RolloutStrategy: v1alpha1.IncreaseFirstRolloutStrategyType,
+		TargetSize:      pointer.Int32Ptr(appRollout.Status.RolloutTargetTotalSize),
+		RolloutBatches: []v1alpha1.RolloutBatch{
+			{
+				Replicas: intstr.FromString("100%"),
+			},
+		},
+	}
+}
+
+// newRollbackStatus creates the initial rollback status. We skip the spec verification since the workloads are
+// already claimed and modified by the failed rollout, so we start from the initializing state with the same size
+func newRollbackStatus(appRollout *oamv1alpha2.AppRollout) *v1alpha1.RolloutStatus {
+	status := &v1alpha1.RolloutStatus{}
+	status.ResetStatus()
+	status.RollingState = v1alpha1.InitializingState
+	status.RolloutTargetTotalSize = appRollout.Status.RolloutTargetTotalSize
+	return status
+}
+
+// rollbackOnFailure moves all the replicas from the target workload back to the source workload
+// through the same rollout plan controller with the source and target swapped
+func (r *Reconciler) rollbackOnFailure(ctx context.Context, appRollout *oamv1alpha2.AppRollout,
+	targetApp, sourceApp *oamv1alpha2.ApplicationConfiguration,
+	targetWorkload, sourceWorkload *unstructured.Unstructured) (ctrl.Result, error) {
+	// the failed rollout never got the size of the workloads, so it has not touched them
+	if appRollout.Status.RolloutTargetTotalSize <= 0 {
+		klog.InfoS("the rollout failed before it modified any workload, nothing to roll back",
+			"appRollout", klog.KObj(appRollout))
+		return r.finishRollback(ctx, appRollout, targetApp, sourceApp)
+	}
+
+	if appRollout.Status.RollbackStatus == nil {
+		klog.InfoS("start to roll back the failed rollout", "appRollout", klog.KObj(appRollout))
+		r.record.Event(appRollout, event.Normal("Rollback Started",
+			fmt.Sprintf("Start to roll back from %s to %s", targetApp.Name, sourceApp.Name)))
+		appRollout.Status.RollbackState = oamv1alpha2.RollingBackState
+		appRollout.Status.RollbackStatus = newRollbackStatus(appRollout)
+	}
+
+	// in-place upgraded workloads can't be rolled back by moving the replicas between them
+	if sourceWorkload == nil || (sourceWorkload.GetName() == targetWorkload.GetName() &&
+		sourceWorkload.GroupVersionKind() == targetWorkload.GroupVersionKind()) {
+		err := fmt.Errorf("the workload %s is upgraded in place, it cannot be rolled back automatically",
+			targetWorkload.GetName())
+		klog.ErrorS(err, "cannot roll back the failed rollout", "appRollout", klog.KObj(appRollout))
+		r.record.Event(appRollout, event.Warning("Rollback Failed", err))
+		appRollout.Status.RollbackState = oamv1alpha2.RollbackFailedState
+		appRollout.Status.RollbackStatus.RolloutFailed(err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, appRollout)
+	}
+
+	// the source workload is the target of the rollback
+	rollbackController := rollout.NewRolloutPlanController(r, appRollout, r.record, newRollbackPlan(appRollout),
+		appRollout.Status.RollbackStatus, sourceWorkload, targetWorkload)
+	result, rollbackStatus := rollbackController.Reconcile(ctx)
+	appRollout.Status.RollbackStatus = rollbackStatus
+
+	switch rollbackStatus.RollingState {
+	case v1alpha1.RolloutSucceedState:
+		return r.finishRollback(ctx, appRollout, targetApp, sourceApp)
+
+	case v1alpha1.RolloutFailedState:
+		appRollout.Status.RollbackState = oamv1alpha2.RollbackFailedState
+		r.record.Event(appRollout, event.Warning("Rollback Failed",
+			fmt.Errorf("failed to roll back from %s to %s", targetApp.Name, sourceApp.Name)))
+		klog.InfoS("rollback failed", "source", sourceApp.Name, "target", targetApp.Name)
+	}
+	return result, r.updateStatus(ctx, appRollout)
+}
+
+// finishRollback lets the source appConfig controller take over again and marks the target as a revision only
+func (r *Reconciler) finishRollback(ctx context.Context, appRollout *oamv1alpha2.AppRollout,
+	targetApp, sourceApp *oamv1alpha2.ApplicationConfiguration) (ctrl.Result, error) {
+	if err := ActivateAppRevision(ctx, r, sourceApp, targetApp); err != nil {
+		return ctrl.Result{}, err
+	}
+	appRollout.Status.RollbackState = oamv1alpha2.RollbackSucceedState
+	r.record.Event(appRollout, event.Normal("Rollback Succeeded",
+		fmt.Sprintf("Rolled back from %s to %s", targetApp.Name, sourceApp.Name)))
+	klog.InfoS("rollback succeeded", "source", sourceApp.Name, "target", targetApp.Name)
+	return ctrl.Result{}, r.updateStatus(ctx, appRollout)
+}
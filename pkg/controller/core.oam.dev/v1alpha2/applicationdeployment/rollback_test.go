@@ -0,0 +1,141 @@
+package applicationdeployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	oamv1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestNeedRollback(t *testing.T) {
+	newAppRollout := func(rollback *bool, source string, state v1alpha1.RollingState,
+		rollbackState oamv1alpha2.RollbackState) *oamv1alpha2.AppRollout {
+		appRollout := &oamv1alpha2.AppRollout{
+			Spec: oamv1alpha2.AppRolloutSpec{
+				TargetAppRevisionName: "app-v2",
+				SourceAppRevisionName: source,
+				RollbackOnFailure:     rollback,
+			},
+		}
+		appRollout.Status.RollingState = state
+		appRollout.Status.RollbackState = rollbackState
+		return appRollout
+	}
+	tests := map[string]struct {
+		appRollout *oamv1alpha2.AppRollout
+		want       bool
+	}{
+		"not set": {
+			appRollout: newAppRollout(nil, "app-v1", v1alpha1.RolloutFailedState, ""),
+			want:       false,
+		},
+		"disabled": {
+			appRollout: newAppRollout(pointer.BoolPtr(false), "app-v1", v1alpha1.RolloutFailedState, ""),
+			want:       false,
+		},
+		"rollout not failed": {
+			appRollout: newAppRollout(pointer.BoolPtr(true), "app-v1", v1alpha1.RolloutFailingState, ""),
+			want:       false,
+		},
+		"no source": {
+			appRollout: newAppRollout(pointer.BoolPtr(true), "", v1alpha1.RolloutFailedState, ""),
+			want:       false,
+		},
+		"failed rollout": {
+			appRollout: newAppRollout(pointer.BoolPtr(true), "app-v1", v1alpha1.RolloutFailedState, ""),
+			want:       true,
+		},
+		"rolling back": {
+			appRollout: newAppRollout(pointer.BoolPtr(true), "app-v1", v1alpha1.RolloutFailedState,
+				oamv1alpha2.RollingBackState),
+			want: true,
+		},
+		"rolled back": {
+			appRollout: newAppRollout(pointer.BoolPtr(true), "app-v1", v1alpha1.RolloutFailedState,
+				oamv1alpha2.RollbackSucceedState),
+			want: false,
+		},
+		"failed rollout of multiple components": {
+			appRollout: func() *oamv1alpha2.AppRollout {
+				appRollout := newAppRollout(pointer.BoolPtr(true), "app-v1", v1alpha1.RolloutFailedState, "")
+				appRollout.Status.ComponentStatuses = []oamv1alpha2.ComponentRolloutStatus{
+					{ComponentName: "frontend"}, {ComponentName: "backend"}}
+				return appRollout
+			}(),
+			want: true,
+		},
+		"rollback failed": {
+			appRollout: newAppRollout(pointer.BoolPtr(true), "app-v1", v1alpha1.RolloutFailedState,
+				oamv1alpha2.RollbackFailedState),
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, needRollback(tt.appRollout))
+		})
+	}
+}
+
+func TestNewRollbackPlan(t *testing.T) {
+	appRollout := &oamv1alpha2.AppRollout{
+		Spec: oamv1alpha2.AppRolloutSpec{
+			RolloutPlan: v1alpha1.RolloutPlan{
+				RolloutStrategy: v1alpha1.DecreaseFirstRolloutStrategyType,
+				RolloutBatches: []v1alpha1.RolloutBatch{
+					{Replicas: intstr.FromInt(2)}, {Replicas: intstr.FromInt(3)},
+				},
+			},
+		},
+	}
+	appRollout.Status.RollingState = v1alpha1.RolloutFailedState
+	appRollout.Status.RolloutTargetTotalSize = 5
+	appRollout.Status.CurrentBatch = 1
+	appRollout.Status.UpgradedReplicas = 5
+
+	plan := newRollbackPlan(appRollout)
+	assert.Equal(t, v1alpha1.IncreaseFirstRolloutStrategyType, plan.RolloutStrategy)
+	assert.Equal(t, int32(5), *plan.TargetSize)
+	assert.Len(t, plan.RolloutBatches, 1)
+
+	status := newRollbackStatus(appRollout)
+	assert.Equal(t, v1alpha1.InitializingState, status.RollingState)
+	assert.Equal(t, v1alpha1.BatchInitializingState, status.BatchRollingState)
+	assert.Equal(t, int32(5), status.RolloutTargetTotalSize)
+	assert.Equal(t, int32(0), status.CurrentBatch)
+	assert.Equal(t, int32(0), status.UpgradedReplicas)
+}
+
+func TestSkipMultiComponentRollback(t *testing.T) {
+	ctx := context.TODO()
+	appRollout := &oamv1alpha2.AppRollout{
+		ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default"},
+		Spec: oamv1alpha2.AppRolloutSpec{
+			TargetAppRevisionName: "app-v2",
+			SourceAppRevisionName: "app-v1",
+			RollbackOnFailure:     pointer.BoolPtr(true),
+		},
+	}
+	appRollout.Status.RollingState = v1alpha1.RolloutFailedState
+	appRollout.Status.ComponentStatuses = []oamv1alpha2.ComponentRolloutStatus{
+		{ComponentName: "frontend"}, {ComponentName: "backend"}}
+	r := &Reconciler{
+		Client: fake.NewFakeClientWithScheme(common.Scheme, appRollout),
+		record: event.NewNopRecorder(),
+	}
+	assert.NoError(t, r.skipMultiComponentRollback(ctx, appRollout))
+	var got oamv1alpha2.AppRollout
+	assert.NoError(t, r.Get(ctx, ktypes.NamespacedName{Namespace: "default", Name: "rollout"}, &got))
+	assert.Equal(t, oamv1alpha2.RollbackFailedState, got.Status.RollbackState)
+	assert.False(t, needRollback(&got))
+}
@@ -76,6 +76,9 @@ func (def *CapabilityComponentDefinition) GetCapabilityObject(ctx context.Contex
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert ComponentDefinition to Capability Object")
 	}
+	if err = util.ResolveCapabilityTemplate(ctx, &capability); err != nil {
+		return nil, fmt.Errorf("failed to load the template of ComponentDefinition %s: %w", def.Name, err)
+	}
 	return &capability, err
 }
 
@@ -136,6 +139,9 @@ func (def *CapabilityTraitDefinition) GetCapabilityObject(ctx context.Context, k
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert WorkloadDefinition to Capability Object")
 	}
+	if err = util.ResolveCapabilityTemplate(ctx, &capability); err != nil {
+		return nil, fmt.Errorf("failed to load the template of TraitDefinition %s: %w", def.Name, err)
+	}
 	return &capability, err
 }
 
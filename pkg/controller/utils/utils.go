@@ -168,7 +168,7 @@ func GetAppNextRevision(app *v1alpha2.Application) (string, int64) {
 	var nextRevision int64 = 1
 	if app.Status.LatestRevision != nil {
 		// we only bump the version when we are rolling
-		if _, exist := app.GetAnnotations()[oam.AnnotationAppRollout]; exist {
+		if _, exist := app.GetAnnotations()[oam.AnnotationAppRollout]; exist || app.Spec.RolloutPlan != nil {
 			nextRevision = app.Status.LatestRevision.Revision + 1
 		} else {
 			nextRevision = app.Status.LatestRevision.Revision
@@ -19,6 +19,7 @@ import (
 	controllerruntime "sigs.k8s.io/controller-runtime"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
 	"github.com/oam-dev/kubevela/pkg/oam"
 	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
 )
@@ -229,4 +230,9 @@ func TestGetAppRevison(t *testing.T) {
 	revisionName, latestRevision = GetAppNextRevision(app)
 	assert.Equal(t, revisionName, "myapp-v3")
 	assert.Equal(t, latestRevision, int64(3))
+	// the app with a rollout plan generates new revisions too
+	app.Spec.RolloutPlan = &v1alpha1.RolloutPlan{}
+	revisionName, latestRevision = GetAppNextRevision(app)
+	assert.Equal(t, revisionName, "myapp-v4")
+	assert.Equal(t, latestRevision, int64(4))
 }
@@ -4,7 +4,37 @@ package cue
 const BaseTemplate = `
 
 context: {
+  // the name of the component
   name: string
+  // the name of the application
+  appName?: string
+  // the name of the revision of the application, e.g. myapp-v1
+  appRevision?: string
+  // the number of the revision of the application, e.g. 1
+  appRevisionNum?: int
+  // the namespace of the application
+  namespace?: string
+  // the labels of the application
+  appLabels?: [string]: string
+  // the annotations of the application
+  appAnnotations?: [string]: string
+  // all the components in the application keyed by the component name,
+  // the components referred to are rendered before this one
+  components?: [string]: {
+    name: string
+    // the main workload of the component
+    output?: {...}
+    // the auxiliary resources of the component, keyed by the name in outputs
+    outputs?: [string]: {...}
+  }
+  // the main workloads of the components rendered before this one, keyed by the component name,
+  // the same as the output in components
+  componentOutputs?: [string]: {...}
+  // the main workload rendered by the workload template, available in trait templates
+  output?: {...}
+  // the auxiliary resources rendered by the workload and traits, keyed by the name in outputs
+  outputs?: [string]: {...}
+  // the configs of the component set by vela config
   config?: [...{
     name: string
     value: string
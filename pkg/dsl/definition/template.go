@@ -11,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/pkg/dsl/model"
 	"github.com/oam-dev/kubevela/pkg/dsl/process"
 	"github.com/oam-dev/kubevela/pkg/dsl/task"
@@ -25,10 +26,14 @@ const (
 	OutputsFieldName = process.OutputsFieldName
 	// PatchFieldName is the name of the struct contains the patch of CR data
 	PatchFieldName = "patch"
+	// PatchOutputsFieldName is the name of the struct contains the patches of the auxiliary resources in context.outputs
+	PatchOutputsFieldName = "patchOutputs"
 	// CustomMessage defines the custom message in definition template
 	CustomMessage = "message"
 	// HealthCheckPolicy defines the health check policy in definition template
 	HealthCheckPolicy = "isHealth"
+	// HealthCheckResult defines the structured health check result in definition template
+	HealthCheckResult = "health"
 )
 
 const (
@@ -41,10 +46,23 @@ const (
 type AbstractEngine interface {
 	Params(params interface{}) AbstractEngine
 	Complete(ctx process.Context, abstractTemplate string) error
-	HealthCheck(ctx process.Context, cli client.Client, ns string, healthPolicyTemplate string) (bool, error)
+	HealthCheck(ctx process.Context, cli client.Client, ns string, healthPolicyTemplate string) (*HealthResult, error)
 	Status(ctx process.Context, cli client.Client, ns string, customStatusTemplate string) (string, error)
 }
 
+// HealthResult is the result of evaluating the health policy of a definition
+type HealthResult struct {
+	Healthy bool                 `json:"healthy"`
+	State   v1alpha2.HealthState `json:"state,omitempty"`
+	Reason  string               `json:"reason,omitempty"`
+	Message string               `json:"message,omitempty"`
+}
+
+// healthyResult is returned when a definition has no health policy
+func healthyResult() *HealthResult {
+	return &HealthResult{Healthy: true, State: v1alpha2.HealthStateHealthy}
+}
+
 type def struct {
 	name   string
 	params interface{}
@@ -94,6 +112,12 @@ func (wd *workloadDef) Complete(ctx process.Context, abstractTemplate string) er
 		if err := inst.Value().Err(); err != nil {
 			return errors.WithMessagef(err, "invalid cue template of workload %s after merge parameter and context", wd.name)
 		}
+		if processing := inst.Lookup("processing"); processing.Exists() {
+			var err error
+			if inst, err = task.Process(inst, ctx.Client(), ctx.Namespace()); err != nil {
+				return errors.WithMessagef(err, "invalid process of workload %s", wd.name)
+			}
+		}
 		output := inst.Lookup(OutputFieldName)
 		base, err := model.NewBase(output)
 		if err != nil {
@@ -171,34 +195,79 @@ func (wd *workloadDef) getTemplateContext(ctx process.Context, cli client.Reader
 }
 
 // HealthCheck address health check for workload
-func (wd *workloadDef) HealthCheck(ctx process.Context, cli client.Client, ns string, healthPolicyTemplate string) (bool, error) {
+func (wd *workloadDef) HealthCheck(ctx process.Context, cli client.Client, ns string, healthPolicyTemplate string) (*HealthResult, error) {
 	if healthPolicyTemplate == "" {
-		return true, nil
+		return healthyResult(), nil
 	}
 	templateContext, err := wd.getTemplateContext(ctx, cli, ns)
 	if err != nil {
-		return false, errors.WithMessage(err, "get template context")
+		return nil, errors.WithMessage(err, "get template context")
 	}
 	return checkHealth(templateContext, healthPolicyTemplate)
 }
 
-func checkHealth(templateContext map[string]interface{}, healthPolicyTemplate string) (bool, error) {
+// checkHealth evaluates the health policy, which either sets `isHealth` to a bool
+// or fills the `health` struct with healthy, state, reason and message.
+func checkHealth(templateContext map[string]interface{}, healthPolicyTemplate string) (*HealthResult, error) {
 	bt, err := json.Marshal(templateContext)
 	if err != nil {
-		return false, errors.WithMessage(err, "json marshal template context")
+		return nil, errors.WithMessage(err, "json marshal template context")
 	}
 
 	var buff = "context: " + string(bt) + "\n" + healthPolicyTemplate
 	var r cue.Runtime
 	inst, err := r.Compile("-", buff)
 	if err != nil {
-		return false, errors.WithMessage(err, "compile health template")
+		return nil, errors.WithMessage(err, "compile health template")
+	}
+	if health := inst.Lookup(HealthCheckResult); health.Exists() {
+		return decodeHealthResult(health)
 	}
 	healthy, err := inst.Lookup(HealthCheckPolicy).Bool()
 	if err != nil {
-		return false, errors.WithMessage(err, "evaluate health status")
+		return nil, errors.WithMessage(err, "evaluate health status")
+	}
+	result := &HealthResult{Healthy: healthy, State: v1alpha2.HealthStateHealthy}
+	if !healthy {
+		result.State = v1alpha2.HealthStateUnhealthy
+	}
+	return result, nil
+}
+
+func decodeHealthResult(health cue.Value) (*HealthResult, error) {
+	if err := health.Validate(cue.Concrete(true)); err != nil {
+		return nil, errors.WithMessage(err, "evaluate health result")
+	}
+	var raw struct {
+		Healthy *bool                `json:"healthy"`
+		State   v1alpha2.HealthState `json:"state"`
+		Reason  string               `json:"reason"`
+		Message string               `json:"message"`
+	}
+	if err := health.Decode(&raw); err != nil {
+		return nil, errors.WithMessage(err, "decode health result")
+	}
+	result := &HealthResult{State: raw.State, Reason: raw.Reason, Message: raw.Message}
+	switch raw.State {
+	case "":
+		if raw.Healthy == nil {
+			return nil, errors.Errorf("%s must set at least one of healthy and state", HealthCheckResult)
+		}
+		result.Healthy = *raw.Healthy
+		result.State = v1alpha2.HealthStateHealthy
+		if !result.Healthy {
+			result.State = v1alpha2.HealthStateUnhealthy
+		}
+		return result, nil
+	case v1alpha2.HealthStateHealthy, v1alpha2.HealthStateProgressing, v1alpha2.HealthStateDegraded, v1alpha2.HealthStateUnhealthy:
+		result.Healthy = raw.State == v1alpha2.HealthStateHealthy
+	default:
+		return nil, errors.Errorf("invalid %s.state %q, must be one of healthy, progressing, degraded and unhealthy", HealthCheckResult, raw.State)
+	}
+	if raw.Healthy != nil && *raw.Healthy != result.Healthy {
+		return nil, errors.Errorf("%s.healthy is %t which conflicts with %s.state %q", HealthCheckResult, *raw.Healthy, HealthCheckResult, raw.State)
 	}
-	return healthy, nil
+	return result, nil
 }
 
 // Status get workload status by customStatusTemplate
@@ -277,7 +346,7 @@ func (td *traitDef) Complete(ctx process.Context, abstractTemplate string) error
 		processing := inst.Lookup("processing")
 		var err error
 		if processing.Exists() {
-			if inst, err = task.Process(inst); err != nil {
+			if inst, err = task.Process(inst, ctx.Client(), ctx.Namespace()); err != nil {
 				return errors.WithMessagef(err, "invalid process of trait %s", td.name)
 			}
 		}
@@ -312,6 +381,54 @@ func (td *traitDef) Complete(ctx process.Context, abstractTemplate string) error
 				return errors.WithMessagef(err, "invalid patch trait %s into workload", td.name)
 			}
 		}
+
+		if err := td.patchOutputs(ctx, inst.Lookup(PatchOutputsFieldName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchOutputs unifies the patches in `patchOutputs.<name>` into the auxiliary resources `context.outputs.<name>`.
+// Traits are rendered in the order they are declared, so a trait can only patch the outputs of the workload,
+// the traits before it and itself.
+func (td *traitDef) patchOutputs(ctx process.Context, patches cue.Value) error {
+	if !patches.Exists() {
+		return nil
+	}
+	st, err := patches.Struct()
+	if err != nil {
+		return errors.WithMessagef(err, "invalid patchOutputs of trait %s", td.name)
+	}
+	_, auxiliaries := ctx.Output()
+	for i := 0; i < st.Len(); i++ {
+		fieldInfo := st.Field(i)
+		if fieldInfo.IsDefinition || fieldInfo.IsHidden || fieldInfo.IsOptional {
+			continue
+		}
+		target := findAuxiliary(auxiliaries, fieldInfo.Name)
+		if target == nil {
+			return errors.Errorf("trait %s patches outputs.%s which does not exist, "+
+				"only the outputs of the workload and the traits before it can be patched", td.name, fieldInfo.Name)
+		}
+		p, err := model.NewOther(fieldInfo.Value)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid patchOutputs(resource=%s) of trait %s", fieldInfo.Name, td.name)
+		}
+		if err := target.Ins.Unify(p); err != nil {
+			return errors.WithMessagef(err, "invalid patch trait %s into outputs.%s", td.name, fieldInfo.Name)
+		}
+	}
+	return nil
+}
+
+// findAuxiliary returns the auxiliary with the name, the latter one wins if more than one have the same name
+// as it covers the former one in context.outputs
+func findAuxiliary(auxiliaries []process.Auxiliary, name string) *process.Auxiliary {
+	for i := len(auxiliaries) - 1; i >= 0; i-- {
+		if auxiliaries[i].Name == name {
+			return &auxiliaries[i]
+		}
 	}
 	return nil
 }
@@ -380,17 +497,80 @@ func (td *traitDef) Status(ctx process.Context, cli client.Client, ns string, cu
 }
 
 // HealthCheck address health check for trait
-func (td *traitDef) HealthCheck(ctx process.Context, cli client.Client, ns string, healthPolicyTemplate string) (bool, error) {
+func (td *traitDef) HealthCheck(ctx process.Context, cli client.Client, ns string, healthPolicyTemplate string) (*HealthResult, error) {
 	if healthPolicyTemplate == "" {
-		return true, nil
+		return healthyResult(), nil
 	}
 	templateContext, err := td.getTemplateContext(ctx, cli, ns)
 	if err != nil {
-		return false, errors.WithMessage(err, "get template context")
+		return nil, errors.WithMessage(err, "get template context")
 	}
 	return checkHealth(templateContext, healthPolicyTemplate)
 }
 
+type scopeDef struct {
+	def
+}
+
+// NewScopeAbstractEngine create Scope Definition AbstractEngine
+func NewScopeAbstractEngine(name string) AbstractEngine {
+	return &scopeDef{
+		def: def{
+			name: name,
+		},
+	}
+}
+
+// Params set definition's params
+func (sd *scopeDef) Params(params interface{}) AbstractEngine {
+	sd.params = params
+	return sd
+}
+
+// Complete do scope definition's rendering, the output of the template is the scope object
+func (sd *scopeDef) Complete(ctx process.Context, abstractTemplate string) error {
+	bi := build.NewContext().NewInstance("", nil)
+	if err := bi.AddFile("-", abstractTemplate); err != nil {
+		return errors.WithMessagef(err, "invalid cue template of scope %s", sd.name)
+	}
+	if sd.params != nil {
+		bt, err := json.Marshal(sd.params)
+		if err != nil {
+			return errors.WithMessagef(err, "marshal parameter of scope %s", sd.name)
+		}
+		if err := bi.AddFile("parameter", fmt.Sprintf("parameter: %s", string(bt))); err != nil {
+			return errors.WithMessagef(err, "invalid parameter of scope %s", sd.name)
+		}
+	}
+
+	if err := bi.AddFile("context", ctx.BaseContextFile()); err != nil {
+		return errors.WithMessagef(err, "invalid context of scope %s", sd.name)
+	}
+	instances := cue.Build([]*build.Instance{bi})
+	for _, inst := range instances {
+		if err := inst.Value().Err(); err != nil {
+			return errors.WithMessagef(err, "invalid cue template of scope %s after merge parameter and context", sd.name)
+		}
+		output := inst.Lookup(OutputFieldName)
+		base, err := model.NewBase(output)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid output of scope %s", sd.name)
+		}
+		ctx.SetBase(base)
+	}
+	return nil
+}
+
+// HealthCheck address health check for scope, scopes have no health policy for now
+func (sd *scopeDef) HealthCheck(ctx process.Context, cli client.Client, ns string, healthPolicyTemplate string) (*HealthResult, error) {
+	return healthyResult(), nil
+}
+
+// Status get scope status, scopes have no custom status for now
+func (sd *scopeDef) Status(ctx process.Context, cli client.Client, ns string, customStatusTemplate string) (string, error) {
+	return "", nil
+}
+
 func getResourceFromObj(obj *unstructured.Unstructured, client client.Reader, namespace string, labels map[string]string, outputsResource string) (map[string]interface{}, error) {
 	if outputsResource != "" {
 		labels[oam.TraitResource] = outputsResource
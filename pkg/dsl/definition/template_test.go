@@ -7,6 +7,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/pkg/dsl/process"
 )
 
@@ -182,6 +183,97 @@ parameter: {
 				},
 			},
 		},
+		"patch trait with strategy": {
+			traitTemplate: `
+patch: {
+      // +patchKey=name
+      spec: template: spec: containers: [{
+      	name: "main"
+      	// +patchStrategy=replace
+      	image: parameter.image
+      	// +patchStrategy=delete
+      	envFrom: []
+      }]
+}
+
+parameter: {
+	image: string
+}`,
+			params: map[string]interface{}{
+				"image": "website:0.2",
+			},
+			expWorkload: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "Deployment",
+					"spec": map[string]interface{}{
+						"replicas": int64(2),
+						"selector": map[string]interface{}{
+							"matchLabels": map[string]interface{}{
+								"app.oam.dev/component": "test"}},
+						"template": map[string]interface{}{
+							"metadata": map[string]interface{}{
+								"labels": map[string]interface{}{"app.oam.dev/component": "test"},
+							},
+							"spec": map[string]interface{}{
+								"containers": []interface{}{map[string]interface{}{
+									"image": "website:0.2",
+									"name":  "main",
+									"ports": []interface{}{map[string]interface{}{"containerPort": int64(443)}}}}}}}},
+			},
+			expAssObjs: map[string]runtime.Object{
+				"AuxiliaryWorkloadgameconfig": &unstructured.Unstructured{
+					Object: map[string]interface{}{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata":   map[string]interface{}{"name": "testgame-config"}, "data": map[string]interface{}{"enemies": "enemies-data", "lives": "lives-data"}},
+				},
+			},
+		},
+		"patch trait with json patch": {
+			traitTemplate: `
+patch: {
+      // +patchStrategy=jsonPatch
+      operations: [
+      	{op: "replace", path: "/spec/replicas", value: parameter.replicas},
+      	{op: "remove", path: "/spec/template/spec/containers/0/envFrom"},
+      ]
+}
+
+parameter: {
+	replicas: int
+}`,
+			params: map[string]interface{}{
+				"replicas": 3,
+			},
+			expWorkload: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "Deployment",
+					"spec": map[string]interface{}{
+						"replicas": int64(3),
+						"selector": map[string]interface{}{
+							"matchLabels": map[string]interface{}{
+								"app.oam.dev/component": "test"}},
+						"template": map[string]interface{}{
+							"metadata": map[string]interface{}{
+								"labels": map[string]interface{}{"app.oam.dev/component": "test"},
+							},
+							"spec": map[string]interface{}{
+								"containers": []interface{}{map[string]interface{}{
+									"image": "website:0.1",
+									"name":  "main",
+									"ports": []interface{}{map[string]interface{}{"containerPort": int64(443)}}}}}}}},
+			},
+			expAssObjs: map[string]runtime.Object{
+				"AuxiliaryWorkloadgameconfig": &unstructured.Unstructured{
+					Object: map[string]interface{}{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata":   map[string]interface{}{"name": "testgame-config"}, "data": map[string]interface{}{"enemies": "enemies-data", "lives": "lives-data"}},
+				},
+			},
+		},
 		"output trait": {
 			traitTemplate: `
 outputs: service: {
@@ -517,11 +609,139 @@ parameter: {
 	}
 }
 
+func TestTraitPatchOutputs(t *testing.T) {
+	baseTemplate := `
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	metadata: name: context.name
+}
+outputs: gameconfig: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: context.name + "game-config"
+}
+`
+	ingressTemplate := `
+outputs: service: {
+	apiVersion: "v1"
+	kind:       "Service"
+	metadata: name: context.name
+	spec: type: "ClusterIP"
+}
+`
+	testCases := map[string]struct {
+		traitTemplate string
+		expAssObjs    map[string]*unstructured.Unstructured
+		expErr        string
+	}{
+		"patch the outputs of a trait before it": {
+			traitTemplate: `
+patchOutputs: service: metadata: annotations: "prometheus.io/scrape": "true"
+`,
+			expAssObjs: map[string]*unstructured.Unstructured{
+				"service": {Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Service",
+					"metadata": map[string]interface{}{
+						"name":        "test",
+						"annotations": map[string]interface{}{"prometheus.io/scrape": "true"},
+					},
+					"spec": map[string]interface{}{"type": "ClusterIP"},
+				}},
+				"gameconfig": {Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]interface{}{"name": "testgame-config"},
+				}},
+			},
+		},
+		"patch the outputs of the workload": {
+			traitTemplate: `
+patchOutputs: gameconfig: data: lives: context.outputs.service.metadata.name
+`,
+			expAssObjs: map[string]*unstructured.Unstructured{
+				"service": {Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Service",
+					"metadata":   map[string]interface{}{"name": "test"},
+					"spec":       map[string]interface{}{"type": "ClusterIP"},
+				}},
+				"gameconfig": {Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]interface{}{"name": "testgame-config"},
+					"data":       map[string]interface{}{"lives": "test"},
+				}},
+			},
+		},
+		"patch outputs that do not exist": {
+			traitTemplate: `
+patchOutputs: ingress: metadata: annotations: "prometheus.io/scrape": "true"
+`,
+			expErr: "trait sidecar patches outputs.ingress which does not exist",
+		},
+		"conflict with the outputs": {
+			traitTemplate: `
+patchOutputs: service: spec: type: "NodePort"
+`,
+			expErr: "invalid patch trait sidecar into outputs.service",
+		},
+	}
+	for name, tc := range testCases {
+		ctx := process.NewContext("test", "myapp", "myapp-v1")
+		assert.NoError(t, NewWorkloadAbstractEngine("worker").Complete(ctx, baseTemplate), name)
+		assert.NoError(t, NewTraitAbstractEngine("ingress").Complete(ctx, ingressTemplate), name)
+		err := NewTraitAbstractEngine("sidecar").Complete(ctx, tc.traitTemplate)
+		if len(tc.expErr) != 0 {
+			assert.Error(t, err, name)
+			assert.Contains(t, err.Error(), tc.expErr, name)
+			continue
+		}
+		assert.NoError(t, err, name)
+		_, assists := ctx.Output()
+		assert.Equal(t, len(tc.expAssObjs), len(assists), name)
+		for _, ss := range assists {
+			got, err := ss.Ins.Unstructured()
+			assert.NoError(t, err, name)
+			assert.Equal(t, tc.expAssObjs[ss.Name], got, "case %s, name: %s", name, ss.Name)
+		}
+	}
+}
+
+func TestScopeTemplateComplete(t *testing.T) {
+	scopeTemplate := `
+output: {
+	apiVersion: "core.oam.dev/v1alpha2"
+	kind:       "HealthScope"
+	metadata: name: context.name
+	spec: "probe-timeout": parameter.timeout
+}
+parameter: {
+	timeout: *10 | int
+}
+`
+	ctx := process.NewContext("myscope", "myapp", "myapp-v1")
+	st := NewScopeAbstractEngine("myscope")
+	assert.NoError(t, st.Params(map[string]interface{}{"timeout": 5}).Complete(ctx, scopeTemplate))
+	base, assists := ctx.Output()
+	assert.Empty(t, assists)
+	baseObj, err := base.Unstructured()
+	assert.NoError(t, err)
+	assert.Equal(t, &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "core.oam.dev/v1alpha2",
+		"kind": "HealthScope", "metadata": map[string]interface{}{"name": "myscope"},
+		"spec": map[string]interface{}{"probe-timeout": int64(5)}}}, baseObj)
+
+	assert.Error(t, NewScopeAbstractEngine("myscope").Complete(process.NewContext("myscope", "myapp",
+		"myapp-v1"), `output: {`))
+}
+
 func TestCheckHealth(t *testing.T) {
 	cases := map[string]struct {
 		tpContext  map[string]interface{}
 		healthTemp string
-		exp        bool
+		exp        *HealthResult
+		expErr     string
 	}{
 		"normal-equal": {
 			tpContext: map[string]interface{}{
@@ -533,7 +753,7 @@ func TestCheckHealth(t *testing.T) {
 				},
 			},
 			healthTemp: "isHealth:  context.output.status.readyReplicas == context.output.status.replicas",
-			exp:        true,
+			exp:        &HealthResult{Healthy: true, State: v1alpha2.HealthStateHealthy},
 		},
 		"normal-false": {
 			tpContext: map[string]interface{}{
@@ -545,7 +765,7 @@ func TestCheckHealth(t *testing.T) {
 				},
 			},
 			healthTemp: "isHealth: context.output.status.readyReplicas == context.output.status.replicas",
-			exp:        false,
+			exp:        &HealthResult{Healthy: false, State: v1alpha2.HealthStateUnhealthy},
 		},
 		"array-case-equal": {
 			tpContext: map[string]interface{}{
@@ -558,13 +778,89 @@ func TestCheckHealth(t *testing.T) {
 				},
 			},
 			healthTemp: `isHealth: context.output.status.conditions[0].status == "True"`,
-			exp:        true,
+			exp:        &HealthResult{Healthy: true, State: v1alpha2.HealthStateHealthy},
+		},
+		"structured-healthy-only": {
+			tpContext: map[string]interface{}{
+				"output": map[string]interface{}{
+					"status": map[string]interface{}{
+						"readyReplicas": 4,
+						"replicas":      4,
+					},
+				},
+			},
+			healthTemp: `health: healthy: context.output.status.readyReplicas == context.output.status.replicas`,
+			exp:        &HealthResult{Healthy: true, State: v1alpha2.HealthStateHealthy},
+		},
+		"structured-with-reason": {
+			tpContext: map[string]interface{}{
+				"output": map[string]interface{}{
+					"status": map[string]interface{}{
+						"readyReplicas": 2,
+						"replicas":      4,
+					},
+				},
+			},
+			healthTemp: `
+ready: context.output.status.readyReplicas
+desired: context.output.status.replicas
+health: {
+	if ready == desired {
+		state: "healthy"
+	}
+	if ready < desired && ready > 0 {
+		state:   "progressing"
+		reason:  "ReplicasNotReady"
+		message: "\(ready)/\(desired) replicas are ready"
+	}
+	if ready == 0 {
+		state:  "unhealthy"
+		reason: "NoReplicaReady"
+	}
+}`,
+			exp: &HealthResult{
+				Healthy: false,
+				State:   v1alpha2.HealthStateProgressing,
+				Reason:  "ReplicasNotReady",
+				Message: "2/4 replicas are ready",
+			},
+		},
+		"structured-prefer-health-over-isHealth": {
+			tpContext: map[string]interface{}{},
+			healthTemp: `
+isHealth: true
+health: {
+	healthy: false
+	reason:  "Degraded"
+	state:   "degraded"
+}`,
+			exp: &HealthResult{Healthy: false, State: v1alpha2.HealthStateDegraded, Reason: "Degraded"},
+		},
+		"structured-invalid-state": {
+			tpContext:  map[string]interface{}{},
+			healthTemp: `health: state: "broken"`,
+			expErr:     `invalid health.state "broken"`,
+		},
+		"structured-conflict": {
+			tpContext:  map[string]interface{}{},
+			healthTemp: `health: {healthy: true, state: "progressing"}`,
+			expErr:     `health.healthy is true which conflicts with health.state "progressing"`,
+		},
+		"structured-empty": {
+			tpContext:  map[string]interface{}{},
+			healthTemp: `health: reason: "Unknown"`,
+			expErr:     "health must set at least one of healthy and state",
 		},
 	}
 	for message, ca := range cases {
-		healthy, err := checkHealth(ca.tpContext, ca.healthTemp)
+		result, err := checkHealth(ca.tpContext, ca.healthTemp)
+		if ca.expErr != "" {
+			assert.Error(t, err, message)
+			assert.Contains(t, err.Error(), ca.expErr, message)
+			continue
+		}
 		assert.NoError(t, err, message)
-		assert.Equal(t, ca.exp, healthy, message)
+		assert.Equal(t, ca.exp, result, message)
 	}
 }
 
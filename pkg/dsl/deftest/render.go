@@ -0,0 +1,220 @@
+package deftest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/pkg/dsl/definition"
+	"github.com/oam-dev/kubevela/pkg/dsl/model"
+	"github.com/oam-dev/kubevela/pkg/dsl/process"
+	"github.com/oam-dev/kubevela/pkg/oam/util"
+	"github.com/oam-dev/kubevela/pkg/utils/remote"
+)
+
+// Template is a definition template loaded from disk
+type Template struct {
+	// Name is the name of the definition, or the CUE file name without extension
+	Name string
+	// Kind is workload or trait
+	Kind     string
+	Template string
+}
+
+// LoadTemplate reads the template of the suite
+func (s *Suite) LoadTemplate() (*Template, error) {
+	path := filepath.Join(filepath.Dir(s.path), s.Template)
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".cue" {
+		if s.Kind != KindWorkload && s.Kind != KindTrait {
+			return nil, fmt.Errorf("test suite %s must set kind to %s or %s for the CUE template %s", s.path, KindWorkload, KindTrait, s.Template)
+		}
+		return &Template{Name: strings.TrimSuffix(filepath.Base(path), ".cue"), Kind: s.Kind, Template: string(data)}, nil
+	}
+	return loadDefinition(path, data)
+}
+
+func loadDefinition(path string, data []byte) (*Template, error) {
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+		return nil, errors.WithMessagef(err, "parse definition %s", path)
+	}
+	var (
+		tmpl *util.Template
+		name string
+		kind string
+		err  error
+	)
+	switch typeMeta.Kind {
+	case v1alpha2.WorkloadDefinitionKind:
+		wd := new(v1alpha2.WorkloadDefinition)
+		if err = yaml.Unmarshal(data, wd); err != nil {
+			return nil, errors.WithMessagef(err, "parse definition %s", path)
+		}
+		name, kind = wd.Name, KindWorkload
+		tmpl, err = util.NewTemplate(wd.Spec.Schematic, wd.Spec.Status, wd.Spec.Extension)
+	case v1alpha2.ComponentDefinitionKind:
+		cd := new(v1alpha2.ComponentDefinition)
+		if err = yaml.Unmarshal(data, cd); err != nil {
+			return nil, errors.WithMessagef(err, "parse definition %s", path)
+		}
+		name, kind = cd.Name, KindWorkload
+		tmpl, err = util.NewTemplate(cd.Spec.Schematic, cd.Spec.Status, cd.Spec.Extension)
+	case v1alpha2.TraitDefinitionKind:
+		td := new(v1alpha2.TraitDefinition)
+		if err = yaml.Unmarshal(data, td); err != nil {
+			return nil, errors.WithMessagef(err, "parse definition %s", path)
+		}
+		name, kind = td.Name, KindTrait
+		tmpl, err = util.NewTemplate(td.Spec.Schematic, td.Spec.Status, td.Spec.Extension)
+	default:
+		return nil, fmt.Errorf("%s is not a CUE file or a WorkloadDefinition, ComponentDefinition or TraitDefinition", path)
+	}
+	if err != nil {
+		return nil, errors.WithMessagef(err, "load template of %s", path)
+	}
+	if tmpl.TemplateURI != "" {
+		if tmpl.TemplateStr, err = remote.Load(context.Background(), tmpl.TemplateURI, tmpl.TemplateDigest); err != nil {
+			return nil, errors.WithMessagef(err, "load template of %s", path)
+		}
+	}
+	if tmpl.TemplateStr == "" {
+		return nil, fmt.Errorf("definition %s has no CUE template", path)
+	}
+	return &Template{Name: name, Kind: kind, Template: tmpl.TemplateStr}, nil
+}
+
+// Render renders the template with the case like the application controller does, but without a cluster
+func Render(t *Template, c Case) (*Rendered, error) {
+	ctxName, appName := c.Context.Name, c.Context.AppName
+	if ctxName == "" {
+		ctxName = t.Name
+	}
+	if appName == "" {
+		appName = ctxName
+	}
+	pCtx := process.NewContext(ctxName, appName, c.Context.AppRevision)
+	pCtx.SetAppInfo(process.AppInfo{
+		RevisionNum: c.Context.AppRevisionNum,
+		Labels:      c.Context.AppLabels,
+		Annotations: c.Context.AppAnnotations,
+	})
+	var objs []runtime.Object
+	for _, o := range c.Objects {
+		objs = append(objs, &unstructured.Unstructured{Object: o})
+	}
+	pCtx.SetClient(fake.NewFakeClientWithScheme(clientgoscheme.Scheme, objs...), c.Context.Namespace)
+
+	var engine definition.AbstractEngine
+	switch t.Kind {
+	case KindWorkload:
+		engine = definition.NewWorkloadAbstractEngine(t.Name)
+	case KindTrait:
+		engine = definition.NewTraitAbstractEngine(t.Name)
+		if c.Workload != nil {
+			if err := setWorkload(pCtx, c.Workload); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %s of template %s", t.Kind, t.Name)
+	}
+	if c.Parameter != nil {
+		engine = engine.Params(c.Parameter)
+	}
+	if err := engine.Complete(pCtx, t.Template); err != nil {
+		return nil, err
+	}
+	return collectRendered(pCtx)
+}
+
+// setWorkload sets the rendered workload into the context as if the workload template ran before the trait
+func setWorkload(pCtx process.Context, workload *Rendered) error {
+	if workload.Output != nil {
+		v, err := toValue(workload.Output)
+		if err != nil {
+			return errors.WithMessage(err, "invalid workload output")
+		}
+		base, err := model.NewBase(v)
+		if err != nil {
+			return errors.WithMessage(err, "invalid workload output")
+		}
+		pCtx.SetBase(base)
+	}
+	for _, name := range sortedKeys(workload.Outputs) {
+		v, err := toValue(workload.Outputs[name])
+		if err != nil {
+			return errors.WithMessagef(err, "invalid workload outputs.%s", name)
+		}
+		other, err := model.NewOther(v)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid workload outputs.%s", name)
+		}
+		pCtx.AppendAuxiliaries(process.Auxiliary{Ins: other, Type: definition.AuxiliaryWorkload, Name: name})
+	}
+	return nil
+}
+
+func collectRendered(pCtx process.Context) (*Rendered, error) {
+	base, auxiliaries := pCtx.Output()
+	rendered := &Rendered{}
+	if base != nil {
+		obj, err := toMap(base)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid output")
+		}
+		rendered.Output = obj
+	}
+	for _, aux := range auxiliaries {
+		obj, err := toMap(aux.Ins)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid outputs.%s", aux.Name)
+		}
+		if rendered.Outputs == nil {
+			rendered.Outputs = map[string]map[string]interface{}{}
+		}
+		rendered.Outputs[aux.Name] = obj
+	}
+	return rendered, nil
+}
+
+func toValue(obj map[string]interface{}) (cue.Value, error) {
+	bt, err := json.Marshal(obj)
+	if err != nil {
+		return cue.Value{}, err
+	}
+	var r cue.Runtime
+	inst, err := r.Compile("-", bt)
+	if err != nil {
+		return cue.Value{}, err
+	}
+	return inst.Value(), nil
+}
+
+func toMap(ins model.Instance) (map[string]interface{}, error) {
+	bt, err := ins.Compile()
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(bt, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
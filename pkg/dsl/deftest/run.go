@@ -0,0 +1,111 @@
+package deftest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Result is the result of a test case
+type Result struct {
+	Suite  string
+	Case   string
+	Golden string
+	// Diff is the difference between the golden file (-) and the rendered objects (+), it's empty if they are the same
+	Diff string
+	// Updated means the golden file is written with the rendered objects
+	Updated bool
+	Err     error
+}
+
+// Passed returns whether the case renders the objects in its golden file
+func (r Result) Passed() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+// Run runs all the cases of the suite. If update is true, the golden files are written with the rendered objects.
+func (s *Suite) Run(update bool) []Result {
+	tmpl, err := s.LoadTemplate()
+	if err != nil {
+		return []Result{{Suite: s.Name(), Err: err}}
+	}
+	var results []Result
+	for _, c := range s.Cases {
+		results = append(results, s.runCase(tmpl, c, update))
+	}
+	return results
+}
+
+func (s *Suite) runCase(tmpl *Template, c Case, update bool) Result {
+	result := Result{Suite: s.Name(), Case: c.Name, Golden: s.GoldenFile(c)}
+	rendered, err := Render(tmpl, c)
+	if err != nil {
+		result.Err = errors.WithMessage(err, "render")
+		return result
+	}
+	actual, err := normalize(rendered)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if update {
+		result.Err = writeGolden(result.Golden, actual)
+		result.Updated = result.Err == nil
+		return result
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(result.Golden))
+	if os.IsNotExist(err) {
+		result.Err = fmt.Errorf("golden file %s doesn't exist, run with update to create it", result.Golden)
+		return result
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	expected := &Rendered{}
+	if err := yaml.Unmarshal(data, expected); err != nil {
+		result.Err = errors.WithMessagef(err, "parse golden file %s", result.Golden)
+		return result
+	}
+	result.Diff = cmp.Diff(expected, actual)
+	return result
+}
+
+// normalize makes the rendered objects comparable with the ones parsed from the golden file
+func normalize(rendered *Rendered) (*Rendered, error) {
+	data, err := yaml.Marshal(rendered)
+	if err != nil {
+		return nil, err
+	}
+	normalized := &Rendered{}
+	if err := yaml.Unmarshal(data, normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func writeGolden(path string, rendered *Rendered) error {
+	data, err := yaml.Marshal(rendered)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func sortedKeys(m map[string]map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
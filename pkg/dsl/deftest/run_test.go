@@ -0,0 +1,103 @@
+package deftest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSuites(t *testing.T) {
+	files, err := FindSuites("testdata")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testdata/scaler_test.yaml", "testdata/worker_test.yaml"}, files)
+	for _, f := range files {
+		suite, err := LoadSuite(f)
+		assert.NoError(t, err)
+		for _, r := range suite.Run(false) {
+			assert.True(t, r.Passed(), "%s/%s: %v %s", r.Suite, r.Case, r.Err, r.Diff)
+		}
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	suite, err := LoadSuite("testdata/worker_test.yaml")
+	assert.NoError(t, err)
+	suite.Cases[0].Parameter["image"] = "nginx"
+	suite.Cases[1].Name = "not-exist"
+	results := suite.Run(false)
+
+	assert.False(t, results[0].Passed())
+	assert.NoError(t, results[0].Err)
+	assert.Regexp(t, `-[^\n]*"image": string\("busybox"\)`, results[0].Diff)
+	assert.Regexp(t, `\+[^\n]*"image": string\("nginx"\)`, results[0].Diff)
+
+	assert.EqualError(t, results[1].Err, "golden file testdata/worker/not-exist.golden.yaml doesn't exist, run with update to create it")
+}
+
+func TestRunUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"scaler.yaml", "scaler_test.yaml"} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", f))
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, f), data, 0600))
+	}
+	suite, err := LoadSuite(filepath.Join(dir, "scaler_test.yaml"))
+	assert.NoError(t, err)
+
+	results := suite.Run(true)
+	assert.Len(t, results, 1)
+	assert.NoError(t, results[0].Err)
+	assert.True(t, results[0].Updated)
+	actual, err := ioutil.ReadFile(filepath.Join(dir, "scaler", "scale-deployment.golden.yaml"))
+	assert.NoError(t, err)
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "scaler", "scale-deployment.golden.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+
+	for _, r := range suite.Run(false) {
+		assert.True(t, r.Passed())
+	}
+}
+
+func TestLoadSuiteErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cases := map[string]struct {
+		suite  string
+		expErr string
+	}{
+		"no template": {
+			suite:  "cases: []",
+			expErr: "must set template",
+		},
+		"invalid case name": {
+			suite:  "template: a.cue\ncases:\n- name: a/b",
+			expErr: `has a case with invalid name "a/b"`,
+		},
+		"duplicated case": {
+			suite:  "template: a.cue\ncases:\n- name: a\n- name: a",
+			expErr: "has duplicated case a",
+		},
+	}
+	for name, c := range cases {
+		path := filepath.Join(dir, "a_test.yaml")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(c.suite), 0600), name)
+		_, err := LoadSuite(path)
+		assert.Error(t, err, name)
+		assert.Contains(t, err.Error(), c.expErr, name)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.cue"), []byte("output: {}"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a_test.yaml"), []byte("template: a.cue\ncases:\n- name: a"), 0600))
+	suite, err := LoadSuite(filepath.Join(dir, "a_test.yaml"))
+	assert.NoError(t, err)
+	results := suite.Run(false)
+	assert.Len(t, results, 1)
+	assert.Contains(t, results[0].Err.Error(), "must set kind to workload or trait for the CUE template a.cue")
+}
@@ -0,0 +1,130 @@
+package deftest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// SuiteFileSuffix is the suffix of the test suite files
+const SuiteFileSuffix = "_test.yaml"
+
+const (
+	// KindWorkload is the kind of the suite testing a workload template
+	KindWorkload = "workload"
+	// KindTrait is the kind of the suite testing a trait template
+	KindTrait = "trait"
+)
+
+// Suite is a set of golden-file test cases of one definition template
+type Suite struct {
+	// Template is the path of the template, relative to the suite file.
+	// It's either a CUE file or a WorkloadDefinition/ComponentDefinition/TraitDefinition yaml.
+	Template string `json:"template"`
+	// Kind is workload or trait, it's required if the template is a CUE file
+	Kind  string `json:"kind,omitempty"`
+	Cases []Case `json:"cases"`
+
+	// path is the path of the suite file
+	path string
+}
+
+// Case renders the template with the parameter and the context, and compares the result with its golden file
+type Case struct {
+	// Name is the name of the case, the golden file is <suite>/<name>.golden.yaml next to the suite file
+	Name      string                 `json:"name"`
+	Parameter map[string]interface{} `json:"parameter,omitempty"`
+	Context   Context                `json:"context,omitempty"`
+	// Workload is the rendered workload that a trait is applied to
+	Workload *Rendered `json:"workload,omitempty"`
+	// Objects are the objects in the cluster that the processing steps of the template read
+	Objects []map[string]interface{} `json:"objects,omitempty"`
+}
+
+// Context is the context of the application that the template refers to
+type Context struct {
+	Name           string            `json:"name,omitempty"`
+	AppName        string            `json:"appName,omitempty"`
+	AppRevision    string            `json:"appRevision,omitempty"`
+	AppRevisionNum int64             `json:"appRevisionNum,omitempty"`
+	Namespace      string            `json:"namespace,omitempty"`
+	AppLabels      map[string]string `json:"appLabels,omitempty"`
+	AppAnnotations map[string]string `json:"appAnnotations,omitempty"`
+}
+
+// Rendered are the objects rendered by a template, it's the content of the golden files
+type Rendered struct {
+	// Output is the workload, for a trait it's the workload after patched
+	Output map[string]interface{} `json:"output,omitempty"`
+	// Outputs are the auxiliary resources keyed by their names
+	Outputs map[string]map[string]interface{} `json:"outputs,omitempty"`
+}
+
+// LoadSuite reads the test suite file
+func LoadSuite(path string) (*Suite, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	suite := &Suite{path: path}
+	if err := yaml.Unmarshal(data, suite); err != nil {
+		return nil, errors.WithMessagef(err, "parse test suite %s", path)
+	}
+	if suite.Template == "" {
+		return nil, fmt.Errorf("test suite %s must set template", path)
+	}
+	names := map[string]bool{}
+	for _, c := range suite.Cases {
+		if c.Name == "" || strings.ContainsAny(c.Name, `/\`) {
+			return nil, fmt.Errorf("test suite %s has a case with invalid name %q, it must be a non-empty file name", path, c.Name)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("test suite %s has duplicated case %s", path, c.Name)
+		}
+		names[c.Name] = true
+	}
+	return suite, nil
+}
+
+// Name is the name of the suite file without the suffix
+func (s *Suite) Name() string {
+	return strings.TrimSuffix(filepath.Base(s.path), SuiteFileSuffix)
+}
+
+// GoldenFile returns the path of the golden file of the case
+func (s *Suite) GoldenFile(c Case) string {
+	return filepath.Join(filepath.Dir(s.path), s.Name(), c.Name+".golden.yaml")
+}
+
+// FindSuites returns the suite files in the paths, directories are walked recursively
+func FindSuites(paths ...string) ([]string, error) {
+	var suites []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			suites = append(suites, p)
+			continue
+		}
+		err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(path, SuiteFileSuffix) {
+				suites = append(suites, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return suites, nil
+}
@@ -0,0 +1,35 @@
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	metadata: labels: context.appLabels
+	spec: {
+		selector: matchLabels: "app.oam.dev/component": context.name
+		template: {
+			metadata: labels: "app.oam.dev/component": context.name
+			spec: containers: [{
+				name:  context.name
+				image: parameter.image
+				if parameter["cmd"] != _|_ {
+					command: parameter.cmd
+				}
+			}]
+		}
+	}
+}
+outputs: config: {
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: name: "\(context.appName)-\(context.name)"
+	data: {
+		revision: "\(context.appRevisionNum)"
+		region:   processing.steps.cluster.output.region
+	}
+}
+processing: steps: cluster: {
+	configMap: name: "cluster-info"
+	output: region?: string
+}
+parameter: {
+	image: string
+	cmd?: [...string]
+}
@@ -1,17 +1,38 @@
 package sets
 
 import (
+	"strconv"
 	"strings"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
 	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/encoding/json"
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/pkg/errors"
 )
 
 const (
 	// TagPatchKey specify the primary key of the list items
 	TagPatchKey = "patchKey"
+	// TagPatchStrategy specify how the field of the patch is applied to the base
+	TagPatchStrategy = "patchStrategy"
+	// DirectivePatch is the field of a list item that specify how the item is applied to the base list merged by key
+	DirectivePatch = "$patch"
+	// FieldOperations is the field of a patch with the `jsonPatch` strategy that contains the operations
+	FieldOperations = "operations"
+)
+
+const (
+	// StrategyReplace means the value of the field in the patch replaces the one in the base
+	StrategyReplace = "replace"
+	// StrategyDelete means the field is removed from the base, the value of the field in the patch is ignored
+	StrategyDelete = "delete"
+	// StrategyJSONPatch means the whole patch is a list of RFC 6902 JSON patch operations in the `operations` field
+	StrategyJSONPatch = "jsonPatch"
+	// StrategyJSONMergePatch means the whole patch is a RFC 7386 JSON merge patch
+	StrategyJSONMergePatch = "jsonMergePatch"
 )
 
 var (
@@ -22,17 +43,18 @@ type interceptor func(node ast.Node) (ast.Node, error)
 
 func listMergeByKey(baseNode ast.Node) interceptor {
 	return func(lnode ast.Node) (ast.Node, error) {
+		var err error
 		walker := newWalker(func(node ast.Node, ctx walkCtx) {
 			clist, ok := node.(*ast.ListLit)
-			if !ok {
+			if !ok || err != nil {
 				return
 			}
 			key, ok := ctx.Tags()[TagPatchKey]
 			if !ok {
 				return
 			}
-			baseNode, err := lookUp(baseNode, ctx.Pos()...)
-			if err != nil {
+			baseNode, lerr := lookUp(baseNode, ctx.Pos()...)
+			if lerr != nil {
 				return
 			}
 			baselist, ok := baseNode.(*ast.ListLit)
@@ -41,29 +63,44 @@ func listMergeByKey(baseNode ast.Node) interceptor {
 			}
 
 			kmaps := map[string]ast.Expr{}
+			deleted := map[string]bool{}
+			replaced := map[string]bool{}
 			nElts := []ast.Expr{}
 
 			for i, elt := range clist.Elts {
 				if _, ok := elt.(*ast.Ellipsis); ok {
 					continue
 				}
-				nodev, err := lookUp(elt, key)
-				if err != nil {
+				nodev, lerr := lookUp(elt, key)
+				if lerr != nil {
 					return
 				}
 				blit, ok := nodev.(*ast.BasicLit)
 				if !ok {
 					return
 				}
+				switch directive := patchDirective(elt); directive {
+				case "":
+				case StrategyDelete:
+					deleted[blit.Value] = true
+					continue
+				case StrategyReplace:
+					replaced[blit.Value] = true
+				default:
+					err = errors.Errorf("unknown %s %s of the item %s=%s in %s", DirectivePatch, directive, key, blit.Value, strings.Join(ctx.Pos(), "."))
+					return
+				}
 				kmaps[blit.Value] = clist.Elts[i]
 			}
+			bElts := []ast.Expr{}
 			for _, elt := range baselist.Elts {
 				if _, ok := elt.(*ast.Ellipsis); ok {
+					bElts = append(bElts, elt)
 					continue
 				}
 
-				nodev, err := lookUp(elt, key)
-				if err != nil {
+				nodev, lerr := lookUp(elt, key)
+				if lerr != nil {
 					return
 				}
 				blit, ok := nodev.(*ast.BasicLit)
@@ -71,6 +108,15 @@ func listMergeByKey(baseNode ast.Node) interceptor {
 					return
 				}
 
+				if deleted[blit.Value] {
+					continue
+				}
+				if replaced[blit.Value] {
+					// keep the position of the item, but only the one in the patch is left
+					bElts = append(bElts, ast.NewStruct())
+				} else {
+					bElts = append(bElts, elt)
+				}
 				if v, ok := kmaps[blit.Value]; ok {
 					nElts = append(nElts, v)
 					delete(kmaps, blit.Value)
@@ -91,12 +137,99 @@ func listMergeByKey(baseNode ast.Node) interceptor {
 
 			nElts = append(nElts, &ast.Ellipsis{})
 			clist.Elts = nElts
+			baselist.Elts = bElts
 		})
 		walker.walk(lnode)
+		if err != nil {
+			return nil, err
+		}
 		return lnode, nil
 	}
 }
 
+// patchDirective returns the value of the `$patch` field of the list item
+func patchDirective(elt ast.Node) string {
+	st, ok := elt.(*ast.StructLit)
+	if !ok {
+		return ""
+	}
+	for i, decl := range st.Elts {
+		field, ok := decl.(*ast.Field)
+		if !ok || unquoteLabel(field.Label) != DirectivePatch {
+			continue
+		}
+		if blit, ok := field.Value.(*ast.BasicLit); ok {
+			if v, err := strconv.Unquote(blit.Value); err == nil {
+				// the directive is not a part of the item
+				st.Elts = append(st.Elts[:i], st.Elts[i+1:]...)
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// fieldStrategy applies the patch strategy of the fields, a field with the `replace` strategy is removed from the base
+// so that it's replaced by the one in the patch, a field with the `delete` strategy is removed from both of them.
+func fieldStrategy(baseNode ast.Node) interceptor {
+	return func(lnode ast.Node) (ast.Node, error) {
+		var deleted [][]string
+		var err error
+		walker := newWalker(func(node ast.Node, ctx walkCtx) {
+			field, ok := node.(*ast.Field)
+			if !ok || err != nil {
+				return
+			}
+			strategy, ok := findCommentTag(field.Comments())[TagPatchStrategy]
+			if !ok {
+				return
+			}
+			label := unquoteLabel(field.Label)
+			switch strategy {
+			case StrategyReplace:
+				removeField(baseNode, ctx.Pos(), label)
+			case StrategyDelete:
+				removeField(baseNode, ctx.Pos(), label)
+				deleted = append(deleted, append(append([]string{}, ctx.Pos()...), label))
+			default:
+				err = errors.Errorf("unknown patchStrategy %s of field %s", strategy, strings.Join(append(ctx.Pos(), label), "."))
+			}
+		})
+		walker.walk(lnode)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range deleted {
+			removeField(lnode, path[:len(path)-1], path[len(path)-1])
+		}
+		return lnode, nil
+	}
+}
+
+// removeField removes the field with the label from the struct in the paths, it does nothing if the struct doesn't exist
+func removeField(node ast.Node, paths []string, label string) {
+	parent, err := lookUp(node, paths...)
+	if err != nil {
+		return
+	}
+	filter := func(decls []ast.Decl) []ast.Decl {
+		var kept []ast.Decl
+		for _, decl := range decls {
+			if field, ok := decl.(*ast.Field); ok && unquoteLabel(field.Label) == label {
+				continue
+			}
+			kept = append(kept, decl)
+		}
+		return kept
+	}
+	switch x := parent.(type) {
+	case *ast.File:
+		x.Decls = filter(x.Decls)
+	case *ast.StructLit:
+		x.Elts = filter(x.Elts)
+	}
+}
+
 // StrategyUnify unify the objects by the strategy
 func StrategyUnify(base, patch string) (string, error) {
 	baseFile, err := parser.ParseFile("-", base, parser.ParseComments)
@@ -108,7 +241,102 @@ func StrategyUnify(base, patch string) (string, error) {
 		return "", errors.WithMessage(err, "invalid patch cue file")
 	}
 
-	return strategyUnify(baseFile, patchFile, listMergeByKey(baseFile))
+	switch strategy := patchStrategy(patchFile); strategy {
+	case StrategyJSONPatch, StrategyJSONMergePatch:
+		return jsonPatchUnify(baseFile, patchFile, strategy)
+	}
+	return strategyUnify(baseFile, patchFile, listMergeByKey(baseFile), fieldStrategy(baseFile))
+}
+
+// patchStrategy returns the `jsonPatch` or `jsonMergePatch` strategy that is tagged on the file or its first field,
+// such a strategy applies to the whole patch instead of a field.
+func patchStrategy(patchFile *ast.File) string {
+	groups := append([]*ast.CommentGroup{}, patchFile.Comments()...)
+	for _, decl := range patchFile.Decls {
+		if field, ok := decl.(*ast.Field); ok {
+			groups = append(groups, field.Comments()...)
+			break
+		}
+	}
+	switch strategy := findCommentTag(groups)[TagPatchStrategy]; strategy {
+	case StrategyJSONPatch, StrategyJSONMergePatch:
+		return strategy
+	}
+	return ""
+}
+
+// jsonPatchUnify applies the patch to the base as JSON, the base must be concrete.
+// The lists of the result are left open just like the base, so that it can be patched by other traits.
+func jsonPatchUnify(baseFile *ast.File, patchFile *ast.File, strategy string) (string, error) {
+	var r cue.Runtime
+	baseInst, err := r.CompileFile(baseFile)
+	if err != nil {
+		return "", errors.WithMessage(err, "compile base file")
+	}
+	patchInst, err := r.CompileFile(patchFile)
+	if err != nil {
+		return "", errors.WithMessage(err, "compile patch file")
+	}
+	base, err := baseInst.Value().MarshalJSON()
+	if err != nil {
+		return "", errors.WithMessagef(err, "base of the %s is not concrete", strategy)
+	}
+
+	var result []byte
+	switch strategy {
+	case StrategyJSONPatch:
+		operations, err := patchInst.Lookup(FieldOperations).MarshalJSON()
+		if err != nil {
+			return "", errors.WithMessagef(err, "invalid %s of the %s", FieldOperations, strategy)
+		}
+		patch, err := jsonpatch.DecodePatch(operations)
+		if err != nil {
+			return "", errors.WithMessagef(err, "decode the %s", strategy)
+		}
+		if result, err = patch.Apply(base); err != nil {
+			return "", errors.WithMessagef(err, "apply the %s", strategy)
+		}
+	case StrategyJSONMergePatch:
+		patch, err := patchInst.Value().MarshalJSON()
+		if err != nil {
+			return "", errors.WithMessagef(err, "the %s is not concrete", strategy)
+		}
+		if result, err = jsonpatch.MergePatch(base, patch); err != nil {
+			return "", errors.WithMessagef(err, "apply the %s", strategy)
+		}
+	}
+
+	expr, err := json.Extract("-", result)
+	if err != nil {
+		return "", errors.WithMessagef(err, "extract the result of the %s", strategy)
+	}
+	openList(expr)
+	f, err := toFile(expr)
+	if err != nil {
+		return "", err
+	}
+	b, err := format.Node(f)
+	if err != nil {
+		return "", errors.WithMessage(err, "format the result")
+	}
+	return string(b), nil
+}
+
+// openList appends `...` to the lists in the node
+func openList(node ast.Node) {
+	switch x := node.(type) {
+	case *ast.Field:
+		openList(x.Value)
+	case *ast.StructLit:
+		for _, elt := range x.Elts {
+			openList(elt)
+		}
+	case *ast.ListLit:
+		for _, elt := range x.Elts {
+			openList(elt)
+		}
+		x.Elts = append(x.Elts, &ast.Ellipsis{})
+	}
 }
 
 func strategyUnify(baseFile *ast.File, patchFile *ast.File, patchOpts ...interceptor) (string, error) {
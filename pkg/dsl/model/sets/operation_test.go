@@ -148,6 +148,292 @@ containers: [{
 	}
 }
 
+func TestPatchStrategy(t *testing.T) {
+	testCases := map[string]struct {
+		base   string
+		patch  string
+		result string
+		err    string
+	}{
+		"replace the value of the item merged by key": {
+			base: `containers: [{name: "x1", image: "nginx:1.0"},{name: "x2"},...]`,
+			patch: `
+// +patchKey=name
+containers: [{
+	name: "x1"
+	// +patchStrategy=replace
+	image: "nginx:2.0"
+}]`,
+			result: `// +patchKey=name
+containers: [{
+	name: "x1"
+	// +patchStrategy=replace
+	image: "nginx:2.0"
+}, {
+	name: "x2"
+}, ...]
+`,
+		},
+		"override the env merged by key": {
+			base: `containers: [{name: "x1", env: [{name: "LOG", value: "info"}, {name: "USER", value: "dev"}, ...]},...]`,
+			patch: `
+// +patchKey=name
+containers: [{
+	name: "x1"
+	env: [{
+		name: "LOG"
+		// +patchStrategy=replace
+		value: "debug"
+	}]
+}]`,
+			result: `// +patchKey=name
+containers: [{
+	name: "x1"
+	env: [{
+		name: "LOG"
+		// +patchStrategy=replace
+		value: "debug"
+	}, {
+		name:  "USER"
+		value: "dev"
+	}, ...]
+}, ...]
+`,
+		},
+		"replace a list": {
+			base: `containers: [{name: "x1", args: ["a", "b"]},...]`,
+			patch: `
+// +patchKey=name
+containers: [{
+	name: "x1"
+	// +patchStrategy=replace
+	args: ["c"]
+}]`,
+			result: `// +patchKey=name
+containers: [{
+	name: "x1"
+	// +patchStrategy=replace
+	args: ["c"]
+}, ...]
+`,
+		},
+		"replace a struct": {
+			base: `metadata: labels: {app: "x", version: "v1"}`,
+			patch: `
+metadata: {
+	// +patchStrategy=replace
+	labels: {app: "y"}
+}`,
+			result: `metadata: {
+	// +patchStrategy=replace
+	labels: {
+		app: "y"
+	}
+}
+`,
+		},
+		"replace a field that does not exist in the base": {
+			base: `a: {b: 1, c: 2}`,
+			patch: `
+a: {
+	// +patchStrategy=replace
+	b: 3
+	c: 2
+}
+// +patchStrategy=replace
+d: 1`,
+			result: `a: {
+	c: 2
+	// +patchStrategy=replace
+	b: 3
+}
+// +patchStrategy=replace
+d: 1
+`,
+		},
+		"delete the item merged by key": {
+			base: `containers: [{name: "x1"},{name: "x2"},{name: "x3"},...]`,
+			patch: `
+// +patchKey=name
+containers: [{name: "x2", "$patch": "delete"}, {name: "x4"}]`,
+			result: `// +patchKey=name
+containers: [{
+	name: "x1"
+}, {
+	name: "x3"
+}, {
+	name: "x4"
+}, ...]
+`,
+		},
+		"delete the item that does not exist": {
+			base: `containers: [{name: "x1"},{name: "x2"},...]`,
+			patch: `
+// +patchKey=name
+containers: [{name: "x5", $patch: "delete"}]`,
+			result: `// +patchKey=name
+containers: [{
+	name: "x1"
+}, {
+	name: "x2"
+}, ...]
+`,
+		},
+		"replace the item merged by key": {
+			base: `containers: [{name: "x1", image: "nginx:1.0", args: ["-v"]},{name: "x2"},...]`,
+			patch: `
+// +patchKey=name
+containers: [{name: "x1", "$patch": "replace", image: "nginx:2.0"}]`,
+			result: `// +patchKey=name
+containers: [{
+	name:  "x1"
+	image: "nginx:2.0"
+}, {
+	name: "x2"
+}, ...]
+`,
+		},
+		"replace the item that does not exist": {
+			base: `containers: [{name: "x1"},...]`,
+			patch: `
+// +patchKey=name
+containers: [{name: "x2", "$patch": "replace"}]`,
+			result: `// +patchKey=name
+containers: [{
+	name: "x1"
+}, {
+	name: "x2"
+}, ...]
+`,
+		},
+		"unknown directive of the item": {
+			base: `containers: [{name: "x1"},...]`,
+			patch: `
+// +patchKey=name
+containers: [{name: "x1", "$patch": "merge"}]`,
+			err: `process patchOption: unknown $patch merge of the item name="x1" in containers`,
+		},
+		"delete and replace fields": {
+			base: `spec: {replicas: 1, strategy: {type: "Recreate"}, paused: false}`,
+			patch: `
+spec: {
+	// +patchStrategy=delete
+	strategy: {}
+	// +patchStrategy=replace
+	replicas: 3
+}`,
+			result: `spec: {
+	paused: false
+	// +patchStrategy=replace
+	replicas: 3
+}
+`,
+		},
+		"delete the field that does not exist": {
+			base: `spec: {replicas: 1}`,
+			patch: `
+spec: {
+	// +patchStrategy=delete
+	paused: {}
+}`,
+			result: `spec: {
+	replicas: 1
+}
+`,
+		},
+		"the strategy is not inherited by the siblings": {
+			base: `spec: {replicas: 1, paused: false}`,
+			patch: `
+spec: {
+	// +patchStrategy=replace
+	replicas: 2
+	paused: true
+}`,
+			result: "_|_\n",
+			err:    "result check err: spec.paused: conflicting values false and true",
+		},
+		"conflict without strategy": {
+			base:   `spec: {replicas: 1}`,
+			patch:  `spec: replicas: 2`,
+			result: "_|_\n",
+			err:    "result check err: spec.replicas: conflicting values 1 and 2",
+		},
+		"unknown strategy": {
+			base: `spec: {replicas: 1}`,
+			patch: `
+spec: {
+	// +patchStrategy=unknown
+	replicas: 2
+}`,
+			err: "process patchOption: unknown patchStrategy unknown of field spec.replicas",
+		},
+		"json patch": {
+			base: `spec: {replicas: 1, containers: [{name: "x1", env: [{name: "LOG", value: "info"}]}, {name: "x2"}, ...]}`,
+			patch: `
+// +patchStrategy=jsonPatch
+operations: [
+	{op: "replace", path: "/spec/replicas", value: 3},
+	{op: "remove", path: "/spec/containers/1"},
+	{op: "add", path: "/spec/containers/0/env/-", value: {name: "USER", value: "dev"}},
+	{op: "add", path: "/spec/paused", value: true},
+]`,
+			result: `spec: {
+	containers: [{
+		env: [{
+			name:  "LOG"
+			value: "info"
+		}, {
+			name:  "USER"
+			value: "dev"
+		}, ...]
+		name: "x1"
+	}, ...]
+	paused:   true
+	replicas: 3
+}
+`,
+		},
+		"json patch of the path that does not exist": {
+			base: `spec: {replicas: 1}`,
+			patch: `
+// +patchStrategy=jsonPatch
+operations: [{op: "remove", path: "/spec/paused"}]`,
+			err: "apply the jsonPatch: error in remove for path: '/spec/paused': Unable to remove nonexistent key: paused: missing value",
+		},
+		"json merge patch": {
+			base: `spec: {replicas: 1, strategy: {type: "Recreate"}, selector: {app: "x"}, containers: [{name: "x1"}, {name: "x2"}, ...]}`,
+			patch: `
+// +patchStrategy=jsonMergePatch
+spec: {
+	replicas: 3
+	strategy: null
+	containers: [{name: "x3"}]
+}`,
+			result: `spec: {
+	containers: [{
+		name: "x3"
+	}, ...]
+	replicas: 3
+	selector: {
+		app: "x"
+	}
+}
+`,
+		},
+	}
+
+	for name, tc := range testCases {
+		v, err := StrategyUnify(tc.base, tc.patch)
+		if len(tc.err) != 0 {
+			assert.NotEqual(t, nil, err, name)
+			assert.Equal(t, tc.err, err.Error(), name)
+		} else {
+			assert.Equal(t, nil, err, name)
+		}
+		assert.Equal(t, tc.result, v, name)
+	}
+}
+
 func TestParseCommentTags(t *testing.T) {
 	temp := `
 // +patchKey=name
@@ -73,6 +73,15 @@ func labelStr(label ast.Label) string {
 	return ""
 }
 
+// unquoteLabel returns the name of the label, the quoted label is unquoted
+func unquoteLabel(label ast.Label) string {
+	name := labelStr(label)
+	if v, err := strconv.Unquote(name); err == nil {
+		return v
+	}
+	return name
+}
+
 func toString(v cue.Value) (string, error) {
 	v = v.Eval()
 	syopts := []cue.Option{cue.All(), cue.DisallowCycles(true), cue.ResolveReferences(true), cue.Docs(true)}
@@ -3,9 +3,12 @@ package process
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"unicode"
 
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
 	"github.com/oam-dev/kubevela/pkg/dsl/model"
 )
 
@@ -22,6 +25,18 @@ const (
 	ContextAppName = "appName"
 	// ContextAppRevision is the revision name of app of context
 	ContextAppRevision = "appRevision"
+	// ContextAppRevisionNum is the revision number of app of context
+	ContextAppRevisionNum = "appRevisionNum"
+	// ContextNamespace is the namespace of the app of context
+	ContextNamespace = "namespace"
+	// ContextAppLabels is the labels of the app of context
+	ContextAppLabels = "appLabels"
+	// ContextAppAnnotations is the annotations of the app of context
+	ContextAppAnnotations = "appAnnotations"
+	// ContextComponents is the reference of all the components of the app of context, keyed by the component name
+	ContextComponents = "components"
+	// ContextComponentOutputs is the reference of the main workloads of the other components of the app
+	ContextComponentOutputs = "componentOutputs"
 )
 
 // Context defines Rendering Context Interface
@@ -29,6 +44,10 @@ type Context interface {
 	SetBase(base model.Instance)
 	AppendAuxiliaries(auxiliaries ...Auxiliary)
 	SetConfigs(configs []map[string]string)
+	SetClient(cli client.Reader, namespace string)
+	SetAppInfo(info AppInfo)
+	Client() client.Reader
+	Namespace() string
 	Output() (model.Instance, []Auxiliary)
 	BaseContextFile() string
 	BaseContextLabels() map[string]string
@@ -46,6 +65,26 @@ type Auxiliary struct {
 	Name string
 }
 
+// AppInfo is the information of the application that the templates of its components can refer to
+type AppInfo struct {
+	// RevisionNum is the revision number of the application
+	RevisionNum int64
+	Labels      map[string]string
+	Annotations map[string]string
+	// Components are the names of all the components in the application
+	Components []string
+	// RenderedComponents are the components rendered before, keyed by the component name
+	RenderedComponents map[string]RenderedComponent
+}
+
+// RenderedComponent is a component rendered by its templates
+type RenderedComponent struct {
+	// Output is the main workload of the component
+	Output model.Instance
+	// Outputs are the auxiliary resources of the component, keyed by the name in outputs
+	Outputs map[string]model.Instance
+}
+
 type templateContext struct {
 	// name is the component name of Application
 	name string
@@ -56,6 +95,10 @@ type templateContext struct {
 	configs     []map[string]string
 	base        model.Instance
 	auxiliaries []Auxiliary
+	// cli and namespace are used by the processing steps of the template to read the cluster
+	cli       client.Reader
+	namespace string
+	appInfo   AppInfo
 }
 
 // NewContext create render templateContext
@@ -74,6 +117,27 @@ func (ctx *templateContext) SetConfigs(configs []map[string]string) {
 	ctx.configs = configs
 }
 
+// SetAppInfo set the information of the application
+func (ctx *templateContext) SetAppInfo(info AppInfo) {
+	ctx.appInfo = info
+}
+
+// SetClient set the client and the namespace that the processing steps of the template read the cluster with
+func (ctx *templateContext) SetClient(cli client.Reader, namespace string) {
+	ctx.cli = cli
+	ctx.namespace = namespace
+}
+
+// Client return the client of templateContext, it's nil if the template can't read the cluster
+func (ctx *templateContext) Client() client.Reader {
+	return ctx.cli
+}
+
+// Namespace return the namespace of templateContext
+func (ctx *templateContext) Namespace() string {
+	return ctx.namespace
+}
+
 // SetBase set templateContext base model
 func (ctx *templateContext) SetBase(base model.Instance) {
 	ctx.base = base
@@ -90,6 +154,34 @@ func (ctx *templateContext) BaseContextFile() string {
 	buff += fmt.Sprintf(ContextName+": \"%s\"\n", ctx.name)
 	buff += fmt.Sprintf(ContextAppName+": \"%s\"\n", ctx.appName)
 	buff += fmt.Sprintf(ContextAppRevision+": \"%s\"\n", ctx.appRevision)
+	buff += fmt.Sprintf(ContextAppRevisionNum+": %d\n", ctx.appInfo.RevisionNum)
+	buff += fmt.Sprintf(ContextNamespace+": \"%s\"\n", ctx.namespace)
+	buff += ContextAppLabels + ": " + marshalStringMap(ctx.appInfo.Labels) + "\n"
+	buff += ContextAppAnnotations + ": " + marshalStringMap(ctx.appInfo.Annotations) + "\n"
+	var compLines, outputLines []string
+	for _, name := range ctx.appInfo.Components {
+		compLine := fmt.Sprintf("%s: %q\n", ContextName, name)
+		if rendered, ok := ctx.appInfo.RenderedComponents[name]; ok {
+			if rendered.Output != nil {
+				output := structMarshal(rendered.Output.String())
+				compLine += fmt.Sprintf(OutputFieldName+": %s\n", output)
+				outputLines = append(outputLines, fmt.Sprintf("%q: %s", name, output))
+			}
+			var auxLines []string
+			for auxName, aux := range rendered.Outputs {
+				auxLines = append(auxLines, fmt.Sprintf("%q: %s", auxName, structMarshal(aux.String())))
+			}
+			if len(auxLines) > 0 {
+				sort.Strings(auxLines)
+				compLine += fmt.Sprintf(OutputsFieldName+": {%s}\n", strings.Join(auxLines, "\n"))
+			}
+		}
+		compLines = append(compLines, fmt.Sprintf("%q: {%s}", name, compLine))
+	}
+	buff += fmt.Sprintf(ContextComponents+": {%s}\n", strings.Join(compLines, "\n"))
+	if len(outputLines) > 0 {
+		buff += fmt.Sprintf(ContextComponentOutputs+": {%s}\n", strings.Join(outputLines, "\n"))
+	}
 
 	if ctx.base != nil {
 		buff += fmt.Sprintf(OutputFieldName+": %s\n", structMarshal(ctx.base.String()))
@@ -130,6 +222,14 @@ func (ctx *templateContext) Output() (model.Instance, []Auxiliary) {
 	return ctx.base, ctx.auxiliaries
 }
 
+func marshalStringMap(m map[string]string) string {
+	if m == nil {
+		m = map[string]string{}
+	}
+	bt, _ := json.Marshal(m)
+	return string(bt)
+}
+
 func structMarshal(v string) string {
 	skip := false
 	v = strings.TrimFunc(v, func(r rune) bool {
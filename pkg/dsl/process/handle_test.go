@@ -51,6 +51,18 @@ image: "myserver"
 	ctx := NewContext("mycomp", "myapp", "myapp-v1")
 	ctx.SetBase(base)
 	ctx.AppendAuxiliaries(svcAux)
+	ctx.SetClient(nil, "default")
+	ctx.SetAppInfo(AppInfo{
+		RevisionNum: 2,
+		Labels:      map[string]string{"team": "web"},
+		Components:  []string{"backend", "mycomp"},
+		RenderedComponents: map[string]RenderedComponent{
+			"backend": {
+				Output:  base,
+				Outputs: map[string]model.Instance{"service": svcIns},
+			},
+		},
+	})
 
 	ctxInst, err := r.Compile("-", ctx.BaseContextFile())
 	if err != nil {
@@ -77,4 +89,28 @@ image: "myserver"
 	outputsJs, err := ctxInst.Lookup("context", OutputsFieldName, "service").MarshalJSON()
 	assert.Equal(t, nil, err)
 	assert.Equal(t, "{\"apiVersion\":\"v1\",\"kind\":\"ConfigMap\"}", string(outputsJs))
+
+	revisionNum, err := ctxInst.Lookup("context", ContextAppRevisionNum).Int64()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), revisionNum)
+
+	namespace, err := ctxInst.Lookup("context", ContextNamespace).String()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "default", namespace)
+
+	labelsJs, err := ctxInst.Lookup("context", ContextAppLabels).MarshalJSON()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"team":"web"}`, string(labelsJs))
+
+	annotationsJs, err := ctxInst.Lookup("context", ContextAppAnnotations).MarshalJSON()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{}`, string(annotationsJs))
+
+	componentsJs, err := ctxInst.Lookup("context", ContextComponents).MarshalJSON()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"backend":{"name":"backend","output":{"image":"myserver"},"outputs":{"service":{"apiVersion":"v1","kind":"ConfigMap"}}},"mycomp":{"name":"mycomp"}}`, string(componentsJs))
+
+	componentOutputJs, err := ctxInst.Lookup("context", ContextComponentOutputs).MarshalJSON()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"backend":{"image":"myserver"}}`, string(componentOutputJs))
 }
@@ -0,0 +1,104 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cuelang.org/go/cue"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// objectRef refers to a Kubernetes object that a step reads
+type objectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+func decodeObjectRef(ctx *stepContext, spec cue.Value) (*objectRef, error) {
+	if ctx.cli == nil {
+		return nil, errors.New("the template can not read the cluster in this context")
+	}
+	var ref objectRef
+	// the reference has to be concrete when the step runs
+	b, err := spec.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("invalid object reference, %w", err)
+	}
+	if err := json.Unmarshal(b, &ref); err != nil {
+		return nil, fmt.Errorf("invalid object reference, %w", err)
+	}
+	if len(ref.Name) == 0 {
+		return nil, errors.New("the name of the object is required")
+	}
+	// the steps run with the client of the controller, they can only read the namespace of the application
+	if len(ref.Namespace) == 0 {
+		ref.Namespace = ctx.namespace
+	}
+	if ref.Namespace != ctx.namespace {
+		return nil, fmt.Errorf("the object %s can only be read in the namespace %q of the application, not %q",
+			ref.Name, ctx.namespace, ref.Namespace)
+	}
+	return &ref, nil
+}
+
+// runKube reads a Kubernetes object, the output is the object
+func runKube(ctx *stepContext, spec cue.Value) (interface{}, error) {
+	ref, err := decodeObjectRef(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gv.WithKind(ref.Kind))
+	if err := ctx.cli.Get(context.Background(), types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name},
+		obj); err != nil {
+		return nil, err
+	}
+	return obj.Object, nil
+}
+
+// runConfigMap reads a ConfigMap, the output is its data
+func runConfigMap(ctx *stepContext, spec cue.Value) (interface{}, error) {
+	ref, err := decodeObjectRef(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	var cm corev1.ConfigMap
+	if err := ctx.cli.Get(context.Background(), types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name},
+		&cm); err != nil {
+		return nil, err
+	}
+	data := make(map[string]string, len(cm.Data))
+	for k, v := range cm.Data {
+		data[k] = v
+	}
+	return data, nil
+}
+
+// runSecret reads a Secret, the output is its decoded data
+func runSecret(ctx *stepContext, spec cue.Value) (interface{}, error) {
+	ref, err := decodeObjectRef(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	var secret corev1.Secret
+	if err := ctx.cli.Get(context.Background(), types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name},
+		&secret); err != nil {
+		return nil, err
+	}
+	data := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		data[k] = string(v)
+	}
+	return data, nil
+}
@@ -6,27 +6,47 @@ import (
 	"fmt"
 
 	"cuelang.org/go/cue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/oam-dev/kubevela/pkg/builtin"
 	"github.com/oam-dev/kubevela/pkg/builtin/registry"
 )
 
-// Process processing the http task
-func Process(inst *cue.Instance) (*cue.Instance, error) {
-	taskVal := inst.Lookup("processing", "http")
-	if !taskVal.Exists() {
-		return inst, errors.New("there is no http in processing")
+const (
+	// ProcessingFieldName is the name of the struct contains the processing of a template
+	ProcessingFieldName = "processing"
+	// StepsFieldName is the name of the struct contains the named steps of the processing
+	StepsFieldName = "steps"
+	// OutputFieldName is the name of the field that a task fills with its result
+	OutputFieldName = "output"
+)
+
+// Process runs the processing of a template and fills the results back to the template.
+// It runs the legacy `processing.http` task and then the steps in `processing.steps` in the order of their
+// dependencies. The steps read the cluster with the client in the namespace if they don't specify one
+func Process(inst *cue.Instance, cli client.Reader, namespace string) (*cue.Instance, error) {
+	httpVal := inst.Lookup(ProcessingFieldName, "http")
+	stepsVal := inst.Lookup(ProcessingFieldName, StepsFieldName)
+	if !httpVal.Exists() && !stepsVal.Exists() {
+		return inst, errors.New("there is no http or steps in processing")
 	}
-	resp, err := exec(taskVal)
-	if err != nil {
-		return nil, fmt.Errorf("fail to exec http task, %w", err)
+	var err error
+	if httpVal.Exists() {
+		resp, err := exec(httpVal)
+		if err != nil {
+			return nil, fmt.Errorf("fail to exec http task, %w", err)
+		}
+		inst, err = inst.Fill(resp, ProcessingFieldName, OutputFieldName)
+		if err != nil {
+			return nil, fmt.Errorf("fail to fill output from http, %w", err)
+		}
 	}
-
-	appInst, err := inst.Fill(resp, "processing", "output")
-	if err != nil {
-		return nil, fmt.Errorf("fail to fill output from http, %w", err)
+	if stepsVal.Exists() {
+		if inst, err = runSteps(inst, &stepContext{cli: cli, namespace: namespace}); err != nil {
+			return nil, err
+		}
 	}
-	return appInst, nil
+	return inst, nil
 }
 
 func exec(v cue.Value) (map[string]interface{}, error) {
@@ -11,6 +11,11 @@ import (
 	"cuelang.org/go/cue"
 	cueJson "cuelang.org/go/pkg/encoding/json"
 	"github.com/bmizerany/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/pkg/utils/common"
 )
 
 const TaskTemplate = `
@@ -55,7 +60,7 @@ func TestProcess(t *testing.T) {
 		"serviceURL": "http://127.0.0.1:8090/api/v1/token?val=test-token",
 	}, "parameter")
 
-	inst, err := Process(taskTemplate)
+	inst, err := Process(taskTemplate, nil, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -64,6 +69,226 @@ func TestProcess(t *testing.T) {
 	assert.Equal(t, "{\"data\":\"test-token\"}", data)
 }
 
+const StepsTemplate = `
+parameter: {
+  serviceURL: string
+}
+
+processing: steps: {
+  config: {
+    dependsOn: ["token"]
+    configMap: name: "app-config"
+    output: {
+      endpoint ?: string
+    }
+  }
+  token: {
+    http: {
+      method: *"GET" | string
+      url: parameter.serviceURL
+      request: {
+        header: {}
+        trailer: {}
+      }
+    }
+    output: {
+      token ?: string
+    }
+  }
+  password: {
+    secret: {
+      name: "db"
+      namespace: "default"
+    }
+    output: {
+      password ?: string
+    }
+  }
+  encoded: {
+    dependsOn: ["token"]
+    transform: base64Encode: processing.steps.token.output.token
+    output: string
+  }
+  settings: {
+    dependsOn: ["config"]
+    transform: jsonDecode: processing.steps.config.output.settings
+    output: {
+      replicas ?: int
+    }
+  }
+}
+
+output: {
+  token: processing.steps.encoded.output
+  endpoint: processing.steps.config.output.endpoint
+  password: processing.steps.password.output.password
+  replicas: processing.steps.settings.output.replicas
+}
+`
+
+func TestProcessSteps(t *testing.T) {
+	s := NewMock()
+	defer s.Close()
+
+	cli := fake.NewFakeClientWithScheme(common.Scheme,
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"},
+			Data:       map[string]string{"endpoint": "db.prod:3306", "settings": `{"replicas":3}`},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		})
+
+	r := cue.Runtime{}
+	taskTemplate, err := r.Compile("", StepsTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskTemplate, _ = taskTemplate.Fill(map[string]interface{}{
+		"serviceURL": "http://127.0.0.1:8090/api/v1/token?val=test-token",
+	}, "parameter")
+
+	inst, err := Process(taskTemplate, cli, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := inst.Lookup("output")
+	data, _ := cueJson.Marshal(output)
+	assert.Equal(t, `{"endpoint":"db.prod:3306","token":"dGVzdC10b2tlbg==","password":"secret","replicas":3}`, data)
+
+	// the steps can't read the cluster without a client
+	_, err = Process(taskTemplate, nil, "default")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestProcessKubeStep(t *testing.T) {
+	cli := fake.NewFakeClientWithScheme(common.Scheme, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.1"},
+	})
+	r := cue.Runtime{}
+	taskTemplate, err := r.Compile("", `
+processing: steps: service: {
+  kube: {
+    apiVersion: "v1"
+    kind: "Service"
+    name: "db"
+  }
+  output: {...}
+}
+output: clusterIP: processing.steps.service.output.spec.clusterIP
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := Process(taskTemplate, cli, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := cueJson.Marshal(inst.Lookup("output"))
+	assert.Equal(t, `{"clusterIP":"10.0.0.1"}`, data)
+}
+
+func TestProcessStepsInOtherNamespace(t *testing.T) {
+	cli := fake.NewFakeClientWithScheme(common.Scheme, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "kube-system"},
+		Data:       map[string][]byte{"token": []byte("secret")},
+	})
+	r := cue.Runtime{}
+	taskTemplate, err := r.Compile("", `
+processing: steps: admin: {
+  secret: {
+    name: "admin"
+    namespace: "kube-system"
+  }
+  output: {...}
+}
+output: token: processing.steps.admin.output.token
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Process(taskTemplate, cli, "default")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestProcessTransformSteps(t *testing.T) {
+	r := cue.Runtime{}
+	taskTemplate, err := r.Compile("", `
+processing: steps: {
+  decoded: {
+    transform: base64Decode: "aGVsbG8="
+    output: string
+  }
+  json: {
+    transform: jsonEncode: {a: 1}
+    output: string
+  }
+  yaml: {
+    transform: yamlEncode: {a: 1}
+    output: string
+  }
+  fromYAML: {
+    dependsOn: ["yaml"]
+    transform: yamlDecode: processing.steps.yaml.output
+    output: {...}
+  }
+}
+output: {
+  decoded: processing.steps.decoded.output
+  json: processing.steps.json.output
+  a: processing.steps.fromYAML.output.a
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := Process(taskTemplate, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := cueJson.Marshal(inst.Lookup("output"))
+	assert.Equal(t, `{"decoded":"hello","json":"{\"a\":1}","a":1}`, data)
+}
+
+func TestSortSteps(t *testing.T) {
+	testCases := map[string]struct {
+		steps   string
+		want    []string
+		wantErr bool
+	}{
+		"keep the order without dependencies": {
+			steps: `b: {}, a: {}, c: {}`,
+			want:  []string{"b", "a", "c"},
+		},
+		"dependencies come first": {
+			steps: `a: dependsOn: ["c"], b: dependsOn: ["a"], c: {}`,
+			want:  []string{"c", "a", "b"},
+		},
+		"cycle": {
+			steps:   `a: dependsOn: ["b"], b: dependsOn: ["a"]`,
+			wantErr: true,
+		},
+		"unknown dependency": {
+			steps:   `a: dependsOn: ["x"]`,
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		r := cue.Runtime{}
+		inst, err := r.Compile("", tc.steps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := sortSteps(inst.Value())
+		assert.Equal(t, tc.wantErr, err != nil, name)
+		if !tc.wantErr {
+			assert.Equal(t, tc.want, got, name)
+		}
+	}
+}
+
 func NewMock() *httptest.Server {
 	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
@@ -0,0 +1,132 @@
+package task
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DependsOnFieldName is the name of the field that lists the steps a step depends on
+const DependsOnFieldName = "dependsOn"
+
+// stepContext provides what the steps need to run
+type stepContext struct {
+	cli       client.Reader
+	namespace string
+}
+
+// stepRunner runs one type of step with its spec and returns the result as the output of the step
+type stepRunner func(ctx *stepContext, spec cue.Value) (interface{}, error)
+
+// stepRunners are all the types of the step, a step has exactly one of them
+var stepRunners = map[string]stepRunner{
+	"http":      runHTTP,
+	"kube":      runKube,
+	"configMap": runConfigMap,
+	"secret":    runSecret,
+	"transform": runTransform,
+}
+
+// runSteps runs the steps in the order of their dependencies, each step can refer to the output
+// of the steps it depends on
+func runSteps(inst *cue.Instance, ctx *stepContext) (*cue.Instance, error) {
+	order, err := sortSteps(inst.Lookup(ProcessingFieldName, StepsFieldName))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range order {
+		// look up the step again so that it sees the outputs of the steps before it
+		step := inst.Lookup(ProcessingFieldName, StepsFieldName, name)
+		output, err := runStep(ctx, step)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "run step %s", name)
+		}
+		if inst, err = inst.Fill(output, ProcessingFieldName, StepsFieldName, name, OutputFieldName); err != nil {
+			return nil, errors.WithMessagef(err, "fill the output of step %s", name)
+		}
+	}
+	return inst, nil
+}
+
+func runStep(ctx *stepContext, step cue.Value) (interface{}, error) {
+	var kind string
+	for k := range stepRunners {
+		if step.Lookup(k).Exists() {
+			if len(kind) != 0 {
+				return nil, fmt.Errorf("a step can only have one of %s and %s", kind, k)
+			}
+			kind = k
+		}
+	}
+	if len(kind) == 0 {
+		return nil, errors.New("the step has no task to run")
+	}
+	return stepRunners[kind](ctx, step.Lookup(kind))
+}
+
+// sortSteps returns the names of the steps in an order that every step comes after the steps it depends on,
+// the steps keep the order in the template otherwise
+func sortSteps(steps cue.Value) ([]string, error) {
+	st, err := steps.Struct()
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid steps in processing")
+	}
+	var names []string
+	dependencies := make(map[string][]string, st.Len())
+	for i := 0; i < st.Len(); i++ {
+		fieldInfo := st.Field(i)
+		if fieldInfo.IsDefinition || fieldInfo.IsHidden || fieldInfo.IsOptional {
+			continue
+		}
+		names = append(names, fieldInfo.Name)
+		var dependsOn []string
+		if dv := fieldInfo.Value.Lookup(DependsOnFieldName); dv.Exists() {
+			if err := dv.Decode(&dependsOn); err != nil {
+				return nil, errors.WithMessagef(err, "invalid dependsOn of step %s", fieldInfo.Name)
+			}
+		}
+		dependencies[fieldInfo.Name] = dependsOn
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("the step %s depends on itself", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range dependencies[name] {
+			if _, exist := dependencies[dep]; !exist {
+				return fmt.Errorf("the step %s depends on the step %s that does not exist", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// runHTTP calls an http endpoint, the output is the JSON body of the response
+func runHTTP(_ *stepContext, spec cue.Value) (interface{}, error) {
+	return exec(spec)
+}
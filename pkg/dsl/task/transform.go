@@ -0,0 +1,124 @@
+package task
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"sigs.k8s.io/yaml"
+)
+
+// transformer converts the value of a transform step into its output
+type transformer func(v cue.Value) (interface{}, error)
+
+// transformers are all the types of the transform step, a transform step has exactly one of them
+var transformers = map[string]transformer{
+	"base64Encode": func(v cue.Value) (interface{}, error) {
+		s, err := v.String()
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString([]byte(s)), nil
+	},
+	"base64Decode": func(v cue.Value) (interface{}, error) {
+		s, err := v.String()
+		if err != nil {
+			return nil, err
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	},
+	"jsonEncode": func(v cue.Value) (interface{}, error) {
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	},
+	"jsonDecode": func(v cue.Value) (interface{}, error) {
+		s, err := v.String()
+		if err != nil {
+			return nil, err
+		}
+		return decodeJSON([]byte(s))
+	},
+	"yamlEncode": func(v cue.Value) (interface{}, error) {
+		b, err := v.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		y, err := yaml.JSONToYAML(b)
+		if err != nil {
+			return nil, err
+		}
+		return string(y), nil
+	},
+	"yamlDecode": func(v cue.Value) (interface{}, error) {
+		s, err := v.String()
+		if err != nil {
+			return nil, err
+		}
+		b, err := yaml.YAMLToJSON([]byte(s))
+		if err != nil {
+			return nil, err
+		}
+		return decodeJSON(b)
+	},
+}
+
+// decodeJSON decodes the data and keeps the integers as int64, so that they still unify with int in the template
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var out interface{}
+	if err := decoder.Decode(&out); err != nil {
+		return nil, err
+	}
+	return convertNumbers(out), nil
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = convertNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = convertNumbers(item)
+		}
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	}
+	return v
+}
+
+// runTransform converts a value from or to base64, JSON or YAML
+func runTransform(_ *stepContext, spec cue.Value) (interface{}, error) {
+	var kind string
+	for k := range transformers {
+		if spec.Lookup(k).Exists() {
+			if len(kind) != 0 {
+				return nil, fmt.Errorf("a transform can only have one of %s and %s", kind, k)
+			}
+			kind = k
+		}
+	}
+	if len(kind) == 0 {
+		return nil, fmt.Errorf("unknown transform")
+	}
+	out, err := transformers[kind](spec.Lookup(kind))
+	if err != nil {
+		return nil, fmt.Errorf("fail to %s, %w", kind, err)
+	}
+	return out, nil
+}
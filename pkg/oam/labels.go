@@ -38,6 +38,9 @@ const (
 	TraitTypeLabel = "trait.oam.dev/type"
 	// TraitResource indicates which resource it is when a trait is composed by multiple resources in KubeVela
 	TraitResource = "trait.oam.dev/resource"
+
+	// LabelEnvName records the name of the environment that a ConfigMap stores
+	LabelEnvName = "env.oam.dev/name"
 )
 
 const (
@@ -67,4 +70,8 @@ const (
 	// AnnotationAppRevision indicates that the object is an application revision
 	//	its controller should not try to reconcile it
 	AnnotationAppRevision = "app.oam.dev/app-revision"
+
+	// AnnotationRolloutBatchApproval records the manual decision on the current batch of a rollout
+	// the value is in the form of "<batch>:<approved|rejected>"
+	AnnotationRolloutBatchApproval = "app.oam.dev/rollout-batch-approval"
 )
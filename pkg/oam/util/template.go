@@ -13,11 +13,14 @@ import (
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/apis/types"
 	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
+	"github.com/oam-dev/kubevela/pkg/utils/remote"
 )
 
 // Template includes its string, health and its category
 type Template struct {
 	TemplateStr        string
+	TemplateURI        string
+	TemplateDigest     string
 	Health             string
 	CustomStatus       string
 	CapabilityCategory types.CapabilityCategory
@@ -54,6 +57,9 @@ func LoadTemplate(ctx context.Context, cli client.Reader, key string, kd types.C
 		if tmpl == nil {
 			return nil, errors.New("no template found in definition")
 		}
+		if err = tmpl.resolveURI(ctx); err != nil {
+			return nil, errors.WithMessagef(err, "LoadTemplate [%s] ", key)
+		}
 		tmpl.Reference = wd.Spec.Reference
 		if wd.Annotations["type"] == string(types.TerraformCategory) {
 			tmpl.CapabilityCategory = types.TerraformCategory
@@ -77,11 +83,30 @@ func LoadTemplate(ctx context.Context, cli client.Reader, key string, kd types.C
 		if tmpl == nil {
 			return nil, errors.New("no template found in definition")
 		}
+		if err = tmpl.resolveURI(ctx); err != nil {
+			return nil, errors.WithMessagef(err, "LoadTemplate [%s] ", key)
+		}
 		tmpl.Reference = td.Spec.Reference
 		tmpl.CapabilityCategory = capabilityCategory
 		return tmpl, nil
 	case types.TypeScope:
-		// TODO: add scope template support
+		sd := new(v1alpha2.ScopeDefinition)
+		err := GetDefinition(ctx, cli, sd, key)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "LoadTemplate [%s] ", key)
+		}
+		tmpl, err := NewTemplate(sd.Spec.Schematic, nil, sd.Spec.Extension)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "LoadTemplate [%s] ", key)
+		}
+		if tmpl == nil {
+			return nil, errors.New("no template found in definition")
+		}
+		if err = tmpl.resolveURI(ctx); err != nil {
+			return nil, errors.WithMessagef(err, "LoadTemplate [%s] ", key)
+		}
+		tmpl.Reference = sd.Spec.Reference
+		return tmpl, nil
 	}
 	return nil, fmt.Errorf("kind(%s) of %s not supported", kd, key)
 }
@@ -97,6 +122,8 @@ func NewTemplate(schematic *v1alpha2.Schematic, status *v1alpha2.Status, raw *ru
 	if schematic != nil {
 		if schematic.CUE != nil {
 			tmp.TemplateStr = schematic.CUE.Template
+			tmp.TemplateURI = schematic.CUE.TemplateURI
+			tmp.TemplateDigest = schematic.CUE.TemplateDigest
 			// CUE module has highest priority
 			// no need to check other schematic types
 			return tmp, nil
@@ -118,10 +145,29 @@ func NewTemplate(schematic *v1alpha2.Schematic, status *v1alpha2.Status, raw *ru
 				tmp.TemplateStr = tmpStr
 			}
 		}
+		if uri, ok := extension["templateURI"].(string); ok {
+			tmp.TemplateURI = uri
+		}
+		if digest, ok := extension["templateDigest"].(string); ok {
+			tmp.TemplateDigest = digest
+		}
 	}
 	return tmp, nil
 }
 
+// resolveURI fetches the template from TemplateURI, which takes precedence over the inlined one
+func (t *Template) resolveURI(ctx context.Context) error {
+	if t.TemplateURI == "" {
+		return nil
+	}
+	tmpStr, err := remote.Load(ctx, t.TemplateURI, t.TemplateDigest)
+	if err != nil {
+		return err
+	}
+	t.TemplateStr = tmpStr
+	return nil
+}
+
 // ConvertTemplateJSON2Object convert spec.extension to object
 func ConvertTemplateJSON2Object(capabilityName string, in *runtime.RawExtension, schematic *v1alpha2.Schematic) (types.Capability, error) {
 	var t types.Capability
@@ -140,5 +186,22 @@ func ConvertTemplateJSON2Object(capabilityName string, in *runtime.RawExtension,
 	if capTemplate.TemplateStr != "" {
 		t.CueTemplate = capTemplate.TemplateStr
 	}
+	if schematic != nil && schematic.CUE != nil {
+		t.CueTemplateURI = capTemplate.TemplateURI
+		t.CueTemplateDigest = capTemplate.TemplateDigest
+	}
 	return t, err
 }
+
+// ResolveCapabilityTemplate fetches the template of the capability from its CueTemplateURI
+func ResolveCapabilityTemplate(ctx context.Context, c *types.Capability) error {
+	if c.CueTemplateURI == "" {
+		return nil
+	}
+	tmpStr, err := remote.Load(ctx, c.CueTemplateURI, c.CueTemplateDigest)
+	if err != nil {
+		return err
+	}
+	c.CueTemplate = tmpStr
+	return nil
+}
@@ -2,17 +2,22 @@ package util
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"cuelang.org/go/cue"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/ghodss/yaml"
 	"k8s.io/apimachinery/pkg/runtime"
 	ktypes "k8s.io/apimachinery/pkg/types"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/utils/remote"
 )
 
 func TestLoadWorkloadTemplate(t *testing.T) {
@@ -232,6 +237,61 @@ spec:
 	}
 }
 
+func TestLoadScopeTemplate(t *testing.T) {
+	cueTemplate := `
+        output: {
+        	apiVersion: "core.oam.dev/v1alpha2"
+        	kind:       "HealthScope"
+        	spec: "probe-timeout": parameter.timeout
+        }
+        parameter: {
+        	timeout: *10 | int
+        }
+`
+	var scopeDefinition = `
+apiVersion: core.oam.dev/v1alpha2
+kind: ScopeDefinition
+metadata:
+  name: healthscope
+  namespace: default
+spec:
+  definitionRef:
+    name: healthscopes.core.oam.dev
+  allowComponentOverlap: true
+  schematic:
+    cue:
+      template: |
+` + cueTemplate
+
+	// Create mock client
+	tclient := test.MockClient{
+		MockGet: func(ctx context.Context, key ktypes.NamespacedName, obj runtime.Object) error {
+			switch o := obj.(type) {
+			case *v1alpha2.ScopeDefinition:
+				sd := &v1alpha2.ScopeDefinition{}
+				if err := yaml.Unmarshal([]byte(scopeDefinition), sd); err != nil {
+					return err
+				}
+				*o = *sd
+			}
+			return nil
+		},
+	}
+
+	temp, err := LoadTemplate(context.TODO(), &tclient, "healthscope", types.TypeScope)
+	assert.NoError(t, err)
+	assert.Equal(t, "healthscopes.core.oam.dev", temp.Reference.Name)
+
+	var r cue.Runtime
+	inst, err := r.Compile("-", temp.TemplateStr)
+	assert.NoError(t, err)
+	instDest, err := r.Compile("-", cueTemplate)
+	assert.NoError(t, err)
+	s1, _ := inst.Value().String()
+	s2, _ := instDest.Value().String()
+	assert.Equal(t, s2, s1)
+}
+
 func TestNewTemplate(t *testing.T) {
 	testCases := map[string]struct {
 		tmp    *v1alpha2.Schematic
@@ -269,6 +329,20 @@ func TestNewTemplate(t *testing.T) {
 				Health:       "h1",
 			},
 		},
+		"tmp with uri": {
+			tmp: &v1alpha2.Schematic{CUE: &v1alpha2.CUE{TemplateURI: "https://example.com/t1.cue", TemplateDigest: "sha256:abc"}},
+			exp: &Template{
+				TemplateURI:    "https://example.com/t1.cue",
+				TemplateDigest: "sha256:abc",
+			},
+		},
+		"no tmp,but has extension with uri": {
+			ext: &runtime.RawExtension{Raw: []byte(`{"template":"t1","templateURI":"https://example.com/t2.cue"}`)},
+			exp: &Template{
+				TemplateStr: "t1",
+				TemplateURI: "https://example.com/t2.cue",
+			},
+		},
 		"no tmp only status": {
 			status: &v1alpha2.Status{
 				CustomStatus: "s1",
@@ -286,3 +360,72 @@ func TestNewTemplate(t *testing.T) {
 		assert.Equal(t, gtmp, casei.exp, reason)
 	}
 }
+
+func TestLoadTemplateFromURI(t *testing.T) {
+	cueTemplate := `output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, cueTemplate)
+	}))
+	defer server.Close()
+
+	testCases := map[string]struct {
+		cue    *v1alpha2.CUE
+		exp    string
+		expErr string
+	}{
+		"uri takes precedence over template": {
+			cue: &v1alpha2.CUE{Template: "output: {}", TemplateURI: server.URL + "/worker.cue"},
+			exp: cueTemplate,
+		},
+		"pinned by digest": {
+			cue: &v1alpha2.CUE{TemplateURI: server.URL + "/worker.cue", TemplateDigest: remote.Digest([]byte(cueTemplate))},
+			exp: cueTemplate,
+		},
+		"digest mismatch": {
+			cue:    &v1alpha2.CUE{TemplateURI: server.URL + "/other.cue", TemplateDigest: remote.Digest([]byte("output: {}"))},
+			expErr: "doesn't match the pinned digest",
+		},
+	}
+	for name, tc := range testCases {
+		tclient := test.MockClient{
+			MockGet: func(ctx context.Context, key ktypes.NamespacedName, obj runtime.Object) error {
+				o := obj.(*v1alpha2.WorkloadDefinition)
+				o.Spec.Schematic = &v1alpha2.Schematic{CUE: tc.cue}
+				return nil
+			},
+		}
+		temp, err := LoadTemplate(context.TODO(), &tclient, "worker", types.TypeWorkload)
+		if tc.expErr != "" {
+			assert.Error(t, err, name)
+			assert.Contains(t, err.Error(), tc.expErr, name)
+			continue
+		}
+		assert.NoError(t, err, name)
+		assert.Equal(t, tc.exp, temp.TemplateStr, name)
+	}
+}
+
+func TestLoadScopeTemplateFromURI(t *testing.T) {
+	cueTemplate := `output: {
+	apiVersion: "core.oam.dev/v1alpha2"
+	kind:       "HealthScope"
+}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, cueTemplate)
+	}))
+	defer server.Close()
+
+	tclient := test.MockClient{
+		MockGet: func(ctx context.Context, key ktypes.NamespacedName, obj runtime.Object) error {
+			o := obj.(*v1alpha2.ScopeDefinition)
+			o.Spec.Schematic = &v1alpha2.Schematic{CUE: &v1alpha2.CUE{TemplateURI: server.URL + "/healthscope.cue"}}
+			return nil
+		},
+	}
+	temp, err := LoadTemplate(context.TODO(), &tclient, "healthscope", types.TypeScope)
+	assert.NoError(t, err)
+	assert.Equal(t, cueTemplate, temp.TemplateStr)
+}
@@ -0,0 +1,90 @@
+package terraform
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// State is the state of applying a Terraform configuration
+type State string
+
+const (
+	// Applying means terraform is still applying the configuration
+	Applying State = "Applying"
+	// Available means the configuration is applied and the outputs are written to the connection secret
+	Available State = "Available"
+	// Failed means terraform failed to apply the configuration
+	Failed State = "Failed"
+)
+
+// Configuration is the Terraform configuration rendered from a component
+type Configuration struct {
+	// Name is the name of the component, the connection secret has the same name
+	Name      string
+	Namespace string
+	AppName   string
+	// JSON is the configuration in the Terraform JSON syntax, like the main.tf.json file
+	JSON []byte
+	// Owner owns the objects the executor creates for the configuration except the Terraform state
+	Owner metav1.OwnerReference
+}
+
+// Status is the status of applying a Terraform configuration
+type Status struct {
+	State   State
+	Message string
+	// Outputs are the Terraform outputs, they are only set when the configuration is Available
+	Outputs map[string]string
+}
+
+// Executor applies Terraform configurations. Apply must not block until terraform finishes, it's called in every
+// reconciliation until the configuration is Available or Failed.
+// Destroy destroys the resources of a configuration applied before when the application is deleted, only the name,
+// the namespace, the application name and the owner of the configuration are set. It mustn't block either, it's
+// called until it returns true, which means the resources are destroyed or the configuration was never applied.
+type Executor interface {
+	Apply(ctx context.Context, conf *Configuration) (*Status, error)
+	Destroy(ctx context.Context, conf *Configuration) (bool, error)
+}
+
+// FakeExecutor is an Executor for tests, it records the configurations and returns the preset statuses
+type FakeExecutor struct {
+	// Statuses are the statuses to return by the configuration name, the configurations without one are Available
+	Statuses map[string]*Status
+	// Err is returned by Apply if it's not nil
+	Err error
+	// Applied are the configurations passed to Apply by their name
+	Applied map[string]*Configuration
+	// Destroying are the names of the configurations that are still being destroyed
+	Destroying map[string]bool
+	// Destroyed are the configurations passed to Destroy by their name
+	Destroyed map[string]*Configuration
+}
+
+// Apply records the configuration and returns its preset status
+func (e *FakeExecutor) Apply(_ context.Context, conf *Configuration) (*Status, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	if e.Applied == nil {
+		e.Applied = map[string]*Configuration{}
+	}
+	e.Applied[conf.Name] = conf
+	if status, ok := e.Statuses[conf.Name]; ok {
+		return status, nil
+	}
+	return &Status{State: Available}, nil
+}
+
+// Destroy records the configuration and returns whether it's not in Destroying
+func (e *FakeExecutor) Destroy(_ context.Context, conf *Configuration) (bool, error) {
+	if e.Err != nil {
+		return false, e.Err
+	}
+	if e.Destroyed == nil {
+		e.Destroyed = map[string]*Configuration{}
+	}
+	e.Destroyed[conf.Name] = conf
+	return !e.Destroying[conf.Name], nil
+}
@@ -0,0 +1,383 @@
+package terraform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+const (
+	// DefaultImage is the default image of the Jobs that run terraform
+	DefaultImage = "hashicorp/terraform:0.14.10"
+
+	// AnnotationConfigurationHash is the hash of the configuration a Job applies
+	AnnotationConfigurationHash = "terraform.core.oam.dev/configuration-hash"
+
+	// ConfigurationFile is the key of the configuration in the ConfigMap, and its file name in the Job
+	ConfigurationFile = "main.tf.json"
+
+	// DefaultRetryInterval is how long a failed Job is kept before it's recreated to run terraform again
+	DefaultRetryInterval = time.Minute
+
+	// stateSecretKey is the key of the gzipped state in the Secret written by the kubernetes backend
+	stateSecretKey        = "tfstate"
+	configDir             = "/etc/terraform"
+	workingDir            = "/data"
+	jobBackoffLimit int32 = 2
+)
+
+// JobExecutor applies the Terraform configurations with Jobs that run `terraform apply` in the cluster. The
+// configuration is stored in a ConfigMap, and the state is stored in a Secret by the kubernetes backend of terraform.
+// The state Secret isn't owned by the application, it's only deleted by Destroy once the cloud resources are destroyed.
+type JobExecutor struct {
+	Client client.Client
+	// Image is the image of the Jobs, it must have both terraform and sh
+	Image string
+	// ServiceAccountName is the service account of the Jobs, it must be able to manage the Secrets and Leases in the
+	// namespace of the application to store and lock the state
+	ServiceAccountName string
+	// CredentialSecret is the name of the Secret in the namespace of the application whose data are exported as
+	// environment variables to terraform, like the ALICLOUD_ACCESS_KEY of the cloud provider
+	CredentialSecret string
+	// RetryInterval is how long a failed Job is kept before it's recreated, the failure is reported in the meantime
+	RetryInterval time.Duration
+}
+
+// NewJobExecutor creates a JobExecutor with the default image and retry interval
+func NewJobExecutor(c client.Client) *JobExecutor {
+	return &JobExecutor{Client: c, Image: DefaultImage, RetryInterval: DefaultRetryInterval}
+}
+
+// ConfigMapName returns the name of the ConfigMap that stores the configuration
+func ConfigMapName(name string) string {
+	return name + "-tf-config"
+}
+
+// JobName returns the name of the Job that applies the configuration
+func JobName(name string) string {
+	return name + "-tf-apply"
+}
+
+// DestroyJobName returns the name of the Job that destroys the resources of the configuration
+func DestroyJobName(name string) string {
+	return name + "-tf-destroy"
+}
+
+// StateSecretName returns the name of the Secret that the kubernetes backend stores the state in
+func StateSecretName(name string) string {
+	return "tfstate-default-" + name
+}
+
+// Apply creates or updates the ConfigMap and the Job of the configuration, and writes the outputs to the connection
+// secret once the Job succeeds. A Job applying an outdated configuration is deleted and then recreated in the next call,
+// so is a failed Job once it's kept for the RetryInterval.
+func (e *JobExecutor) Apply(ctx context.Context, conf *Configuration) (*Status, error) {
+	data, err := withKubernetesBackend(conf)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: e.objectMeta(conf, ConfigMapName(conf.Name)),
+		Data:       map[string]string{ConfigurationFile: string(data)},
+	}
+	if err := e.createOrUpdate(ctx, cm, &v1.ConfigMap{}); err != nil {
+		return nil, errors.WithMessagef(err, "cannot apply the ConfigMap of terraform configuration %s", conf.Name)
+	}
+
+	job := &batchv1.Job{}
+	err = e.Client.Get(ctx, client.ObjectKey{Namespace: conf.Namespace, Name: JobName(conf.Name)}, job)
+	if apierrors.IsNotFound(err) {
+		job = e.newJob(conf, JobName(conf.Name), "terraform apply -input=false -auto-approve")
+		job.Annotations = map[string]string{AnnotationConfigurationHash: hash}
+		if err := e.Client.Create(ctx, job); err != nil {
+			return nil, errors.WithMessagef(err, "cannot create the Job of terraform configuration %s", conf.Name)
+		}
+		klog.InfoS("Created a Job to apply terraform configuration", "namespace", conf.Namespace, "name", conf.Name)
+		return applying(conf), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if job.GetAnnotations()[AnnotationConfigurationHash] != hash {
+		if err := e.deleteJob(ctx, job); err != nil {
+			return nil, errors.WithMessagef(err, "cannot delete the outdated Job of terraform configuration %s", conf.Name)
+		}
+		klog.InfoS("Deleted the outdated Job of terraform configuration", "namespace", conf.Namespace, "name", conf.Name)
+		return applying(conf), nil
+	}
+	switch cond := finishedCondition(job); {
+	case cond == nil:
+		return applying(conf), nil
+	case cond.Type == batchv1.JobFailed:
+		message := fmt.Sprintf("terraform apply failed in Job %s: %s", job.Name, cond.Message)
+		retried, err := e.retryFailedJob(ctx, job, cond)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "cannot retry the failed Job of terraform configuration %s", conf.Name)
+		}
+		if retried {
+			message += ", retrying"
+		}
+		return &Status{State: Failed, Message: message}, nil
+	default:
+		outputs, err := e.readOutputs(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.writeConnectionSecret(ctx, conf, outputs); err != nil {
+			return nil, err
+		}
+		return &Status{State: Available, Outputs: outputs}, nil
+	}
+}
+
+// Destroy runs `terraform destroy` in a Job with the configuration stored by Apply, and deletes the state Secret once
+// the Job succeeds. The apply Job is deleted first so that it releases the lock of the state. A failed Job is retried
+// like the one of Apply. It returns true if the configuration is destroyed or was never applied.
+func (e *JobExecutor) Destroy(ctx context.Context, conf *Configuration) (bool, error) {
+	err := e.Client.Get(ctx, client.ObjectKey{Namespace: conf.Namespace, Name: ConfigMapName(conf.Name)}, &v1.ConfigMap{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	applyJob := &batchv1.Job{}
+	err = e.Client.Get(ctx, client.ObjectKey{Namespace: conf.Namespace, Name: JobName(conf.Name)}, applyJob)
+	if err == nil {
+		if err := e.deleteJob(ctx, applyJob); err != nil {
+			return false, errors.WithMessagef(err, "cannot delete the apply Job of terraform configuration %s", conf.Name)
+		}
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	job := &batchv1.Job{}
+	err = e.Client.Get(ctx, client.ObjectKey{Namespace: conf.Namespace, Name: DestroyJobName(conf.Name)}, job)
+	if apierrors.IsNotFound(err) {
+		job = e.newJob(conf, DestroyJobName(conf.Name), "terraform destroy -input=false -auto-approve")
+		if err := e.Client.Create(ctx, job); err != nil {
+			return false, errors.WithMessagef(err, "cannot create the destroy Job of terraform configuration %s", conf.Name)
+		}
+		klog.InfoS("Created a Job to destroy terraform configuration", "namespace", conf.Namespace, "name", conf.Name)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch cond := finishedCondition(job); {
+	case cond == nil:
+		return false, nil
+	case cond.Type == batchv1.JobFailed:
+		klog.InfoS("Failed to destroy terraform configuration", "namespace", conf.Namespace, "name", conf.Name,
+			"message", cond.Message)
+		if _, err := e.retryFailedJob(ctx, job, cond); err != nil {
+			return false, errors.WithMessagef(err, "cannot retry the failed destroy Job of terraform configuration %s", conf.Name)
+		}
+		return false, nil
+	default:
+		state := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: conf.Namespace, Name: StateSecretName(conf.Name)}}
+		if err := e.Client.Delete(ctx, state); err != nil && !apierrors.IsNotFound(err) {
+			return false, errors.WithMessagef(err, "cannot delete the state of terraform configuration %s", conf.Name)
+		}
+		klog.InfoS("Destroyed terraform configuration", "namespace", conf.Namespace, "name", conf.Name)
+		return true, nil
+	}
+}
+
+// finishedCondition returns the Complete or Failed condition of the Job, or nil if the Job is still running
+func finishedCondition(job *batchv1.Job) *batchv1.JobCondition {
+	for i, cond := range job.Status.Conditions {
+		if cond.Status == v1.ConditionTrue && (cond.Type == batchv1.JobComplete || cond.Type == batchv1.JobFailed) {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// retryFailedJob deletes the failed Job once it's kept for the RetryInterval, so that it's recreated in the next call.
+// It returns true if the Job is deleted.
+func (e *JobExecutor) retryFailedJob(ctx context.Context, job *batchv1.Job, failed *batchv1.JobCondition) (bool, error) {
+	if time.Since(failed.LastTransitionTime.Time) < e.RetryInterval {
+		return false, nil
+	}
+	if err := e.deleteJob(ctx, job); err != nil {
+		return false, err
+	}
+	klog.InfoS("Deleted the failed Job of terraform configuration to retry", "namespace", job.Namespace, "name", job.Name)
+	return true, nil
+}
+
+// deleteJob deletes the Job with its pods, terraform holds the lock of the state until its pod is killed
+func (e *JobExecutor) deleteJob(ctx context.Context, job *batchv1.Job) error {
+	if job.DeletionTimestamp != nil {
+		return nil
+	}
+	if err := e.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func applying(conf *Configuration) *Status {
+	return &Status{State: Applying, Message: fmt.Sprintf("terraform apply is running in Job %s", JobName(conf.Name))}
+}
+
+// withKubernetesBackend makes terraform store the state in a Secret, it replaces the backend of the configuration
+func withKubernetesBackend(conf *Configuration) ([]byte, error) {
+	tf := map[string]interface{}{}
+	if err := json.Unmarshal(conf.JSON, &tf); err != nil {
+		return nil, errors.WithMessagef(err, "terraform configuration %s isn't a JSON object", conf.Name)
+	}
+	settings, ok := tf["terraform"].(map[string]interface{})
+	if !ok {
+		if _, exist := tf["terraform"]; exist {
+			return nil, fmt.Errorf("the terraform block of terraform configuration %s must be an object", conf.Name)
+		}
+		settings = map[string]interface{}{}
+	}
+	settings["backend"] = map[string]interface{}{
+		"kubernetes": map[string]interface{}{
+			"secret_suffix":     conf.Name,
+			"namespace":         conf.Namespace,
+			"in_cluster_config": true,
+		},
+	}
+	tf["terraform"] = settings
+	return json.Marshal(tf)
+}
+
+func (e *JobExecutor) objectMeta(conf *Configuration, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:            name,
+		Namespace:       conf.Namespace,
+		Labels:          map[string]string{oam.LabelAppName: conf.AppName, oam.LabelAppComponent: conf.Name},
+		OwnerReferences: []metav1.OwnerReference{conf.Owner},
+	}
+}
+
+// newJob returns a Job that runs the terraform command with the configuration in the ConfigMap after `terraform init`
+func (e *JobExecutor) newJob(conf *Configuration, name, command string) *batchv1.Job {
+	job := &batchv1.Job{ObjectMeta: e.objectMeta(conf, name)}
+	container := v1.Container{
+		Name:       "terraform",
+		Image:      e.Image,
+		WorkingDir: workingDir,
+		Command: []string{"sh", "-c", fmt.Sprintf("cp %s/%s . && terraform init -input=false && %s",
+			configDir, ConfigurationFile, command)},
+		VolumeMounts: []v1.VolumeMount{
+			{Name: "configuration", MountPath: configDir},
+			{Name: "data", MountPath: workingDir},
+		},
+	}
+	if e.CredentialSecret != "" {
+		container.EnvFrom = []v1.EnvFromSource{{
+			SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: e.CredentialSecret}},
+		}}
+	}
+	job.Spec = batchv1.JobSpec{
+		BackoffLimit: pointer.Int32Ptr(jobBackoffLimit),
+		Template: v1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: job.Labels},
+			Spec: v1.PodSpec{
+				ServiceAccountName: e.ServiceAccountName,
+				RestartPolicy:      v1.RestartPolicyNever,
+				Containers:         []v1.Container{container},
+				Volumes: []v1.Volume{
+					{Name: "configuration", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{
+						LocalObjectReference: v1.LocalObjectReference{Name: ConfigMapName(conf.Name)},
+					}}},
+					{Name: "data", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+				},
+			},
+		},
+	}
+	return job
+}
+
+// readOutputs reads the outputs from the state Secret, the values that aren't strings are encoded in JSON
+func (e *JobExecutor) readOutputs(ctx context.Context, conf *Configuration) (map[string]string, error) {
+	secret := &v1.Secret{}
+	if err := e.Client.Get(ctx, client.ObjectKey{Namespace: conf.Namespace, Name: StateSecretName(conf.Name)}, secret); err != nil {
+		return nil, errors.WithMessagef(err, "cannot get the state of terraform configuration %s", conf.Name)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(secret.Data[stateSecretKey]))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid state of terraform configuration %s", conf.Name)
+	}
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid state of terraform configuration %s", conf.Name)
+	}
+	var state struct {
+		Outputs map[string]struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, errors.WithMessagef(err, "invalid state of terraform configuration %s", conf.Name)
+	}
+	outputs := make(map[string]string, len(state.Outputs))
+	for name, output := range state.Outputs {
+		var s string
+		if err := json.Unmarshal(output.Value, &s); err == nil {
+			outputs[name] = s
+			continue
+		}
+		outputs[name] = string(output.Value)
+	}
+	return outputs, nil
+}
+
+// writeConnectionSecret writes the outputs to the Secret with the same name as the component
+func (e *JobExecutor) writeConnectionSecret(ctx context.Context, conf *Configuration, outputs map[string]string) error {
+	secret := &v1.Secret{ObjectMeta: e.objectMeta(conf, conf.Name), Data: map[string][]byte{}}
+	for k, v := range outputs {
+		secret.Data[k] = []byte(v)
+	}
+	if err := e.createOrUpdate(ctx, secret, &v1.Secret{}); err != nil {
+		return errors.WithMessagef(err, "cannot write the outputs of terraform configuration %s", conf.Name)
+	}
+	return nil
+}
+
+// object is a Kubernetes object with metadata
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+func (e *JobExecutor) createOrUpdate(ctx context.Context, obj, existing object) error {
+	err := e.Client.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}, existing)
+	if apierrors.IsNotFound(err) {
+		return e.Client.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return e.Client.Update(ctx, obj)
+}
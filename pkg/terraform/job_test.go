@@ -0,0 +1,228 @@
+package terraform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newState(t *testing.T, outputs string) *v1.Secret {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(`{"version":4,"outputs":` + outputs + `}`))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: StateSecretName("sample-oss"), Namespace: "default"},
+		Data:       map[string][]byte{stateSecretKey: buf.Bytes()},
+	}
+}
+
+func TestJobExecutor(t *testing.T) {
+	ctx := context.TODO()
+	conf := &Configuration{
+		Name:      "sample-oss",
+		Namespace: "default",
+		AppName:   "app",
+		JSON:      []byte(`{"resource":{"alicloud_oss_bucket":{"bucket-acl":{"bucket":"vela-website","acl":"private"}}},"output":{"BUCKET_NAME":{"value":"vela-website"}}}`),
+		Owner:     metav1.OwnerReference{APIVersion: "core.oam.dev/v1alpha2", Kind: "Application", Name: "app"},
+	}
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+	e := NewJobExecutor(c)
+	e.CredentialSecret = "alibaba-account-creds"
+
+	status, err := e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, Applying, status.State)
+	assert.Equal(t, "terraform apply is running in Job sample-oss-tf-apply", status.Message)
+
+	cm := &v1.ConfigMap{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-config"}, cm))
+	assert.Equal(t, []metav1.OwnerReference{conf.Owner}, cm.OwnerReferences)
+	tf := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(cm.Data[ConfigurationFile]), &tf))
+	assert.Equal(t, map[string]interface{}{"backend": map[string]interface{}{"kubernetes": map[string]interface{}{
+		"secret_suffix": "sample-oss", "namespace": "default", "in_cluster_config": true,
+	}}}, tf["terraform"])
+	assert.Contains(t, tf, "resource")
+
+	job := &batchv1.Job{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-apply"}, job))
+	hash := job.Annotations[AnnotationConfigurationHash]
+	assert.NotEmpty(t, hash)
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, DefaultImage, container.Image)
+	assert.Equal(t, "alibaba-account-creds", container.EnvFrom[0].SecretRef.Name)
+	assert.Equal(t, "sample-oss-tf-config", job.Spec.Template.Spec.Volumes[0].ConfigMap.Name)
+
+	// the Job is still running
+	status, err = e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, Applying, status.State)
+
+	// the Job fails, it's kept for the retry interval
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue,
+		LastTransitionTime: metav1.Now(), Message: "Job has reached the specified backoff limit"}}
+	assert.NoError(t, c.Status().Update(ctx, job))
+	status, err = e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, &Status{State: Failed, Message: "terraform apply failed in Job sample-oss-tf-apply: Job has reached the specified backoff limit"}, status)
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-apply"}, &batchv1.Job{}))
+
+	// the failed Job is deleted after the retry interval even though the configuration is the same, and then recreated
+	job.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-DefaultRetryInterval))
+	assert.NoError(t, c.Status().Update(ctx, job))
+	status, err = e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, &Status{State: Failed, Message: "terraform apply failed in Job sample-oss-tf-apply: Job has reached the specified backoff limit, retrying"}, status)
+	err = c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-apply"}, &batchv1.Job{})
+	assert.True(t, apierrors.IsNotFound(err))
+	status, err = e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, Applying, status.State)
+	job = &batchv1.Job{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-apply"}, job))
+	assert.Equal(t, hash, job.Annotations[AnnotationConfigurationHash])
+
+	// the Job succeeds
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}
+	assert.NoError(t, c.Status().Update(ctx, job))
+	_, err = e.Apply(ctx, conf)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot get the state of terraform configuration sample-oss")
+	assert.NoError(t, c.Create(ctx, newState(t, `{"BUCKET_NAME":{"value":"vela-website","type":"string"},"PORTS":{"value":[80,443]}}`)))
+	status, err = e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, &Status{State: Available, Outputs: map[string]string{"BUCKET_NAME": "vela-website", "PORTS": "[80,443]"}}, status)
+	secret := &v1.Secret{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss"}, secret))
+	assert.Equal(t, map[string][]byte{"BUCKET_NAME": []byte("vela-website"), "PORTS": []byte("[80,443]")}, secret.Data)
+	assert.Equal(t, []metav1.OwnerReference{conf.Owner}, secret.OwnerReferences)
+
+	// the configuration changes, the outdated Job is deleted and then recreated
+	conf.JSON = []byte(`{"resource":{"alicloud_oss_bucket":{"bucket-acl":{"bucket":"vela-website","acl":"public-read"}}}}`)
+	status, err = e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, Applying, status.State)
+	err = c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-apply"}, &batchv1.Job{})
+	assert.True(t, apierrors.IsNotFound(err))
+	status, err = e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, Applying, status.State)
+	job = &batchv1.Job{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-apply"}, job))
+	assert.NotEqual(t, hash, job.Annotations[AnnotationConfigurationHash])
+}
+
+func TestJobExecutorDestroy(t *testing.T) {
+	ctx := context.TODO()
+	conf := &Configuration{
+		Name:      "sample-oss",
+		Namespace: "default",
+		AppName:   "app",
+		Owner:     metav1.OwnerReference{APIVersion: "core.oam.dev/v1alpha2", Kind: "Application", Name: "app"},
+	}
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+	e := NewJobExecutor(c)
+	destroyJobKey := client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-destroy"}
+
+	// the configuration was never applied
+	done, err := e.Destroy(ctx, conf)
+	assert.NoError(t, err)
+	assert.True(t, done)
+
+	conf.JSON = []byte(`{"resource":{"alicloud_oss_bucket":{"bucket-acl":{"bucket":"vela-website","acl":"private"}}}}`)
+	_, err = e.Apply(ctx, conf)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Create(ctx, newState(t, `{}`)))
+
+	// the apply Job is deleted first
+	done, err = e.Destroy(ctx, conf)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	err = c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "sample-oss-tf-apply"}, &batchv1.Job{})
+	assert.True(t, apierrors.IsNotFound(err))
+	err = c.Get(ctx, destroyJobKey, &batchv1.Job{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	done, err = e.Destroy(ctx, conf)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	job := &batchv1.Job{}
+	assert.NoError(t, c.Get(ctx, destroyJobKey, job))
+	assert.Contains(t, job.Spec.Template.Spec.Containers[0].Command[2], "terraform destroy -input=false -auto-approve")
+	assert.Equal(t, []metav1.OwnerReference{conf.Owner}, job.OwnerReferences)
+
+	// the failed Job is retried after the retry interval
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-DefaultRetryInterval))}}
+	assert.NoError(t, c.Status().Update(ctx, job))
+	done, err = e.Destroy(ctx, conf)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	err = c.Get(ctx, destroyJobKey, &batchv1.Job{})
+	assert.True(t, apierrors.IsNotFound(err))
+	done, err = e.Destroy(ctx, conf)
+	assert.NoError(t, err)
+	assert.False(t, done)
+
+	// the state is deleted once the Job succeeds
+	job = &batchv1.Job{}
+	assert.NoError(t, c.Get(ctx, destroyJobKey, job))
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}
+	assert.NoError(t, c.Status().Update(ctx, job))
+	done, err = e.Destroy(ctx, conf)
+	assert.NoError(t, err)
+	assert.True(t, done)
+	err = c.Get(ctx, client.ObjectKey{Namespace: "default", Name: StateSecretName("sample-oss")}, &v1.Secret{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestWithKubernetesBackend(t *testing.T) {
+	cases := map[string]struct {
+		json   string
+		exp    string
+		expErr string
+	}{
+		"add backend": {
+			json: `{"terraform":{"required_version":">= 0.13"}}`,
+			exp:  `{"terraform":{"backend":{"kubernetes":{"in_cluster_config":true,"namespace":"default","secret_suffix":"db"}},"required_version":">= 0.13"}}`,
+		},
+		"replace backend": {
+			json: `{"terraform":{"backend":{"s3":{"bucket":"state"}}}}`,
+			exp:  `{"terraform":{"backend":{"kubernetes":{"in_cluster_config":true,"namespace":"default","secret_suffix":"db"}}}}`,
+		},
+		"not an object": {
+			json:   `[]`,
+			expErr: "terraform configuration db isn't a JSON object",
+		},
+		"invalid terraform block": {
+			json:   `{"terraform":[]}`,
+			expErr: "the terraform block of terraform configuration db must be an object",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			data, err := withKubernetesBackend(&Configuration{Name: "db", Namespace: "default", JSON: []byte(c.json)})
+			if c.expErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, c.exp, string(data))
+		})
+	}
+}
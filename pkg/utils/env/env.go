@@ -5,9 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path/filepath"
+	"sort"
 
 	acmev1 "github.com/wonderflow/cert-manager-api/pkg/apis/acme/v1"
 	certmanager "github.com/wonderflow/cert-manager-api/pkg/apis/certmanager/v1"
@@ -20,32 +19,146 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/oam-dev/kubevela/apis/types"
-	"github.com/oam-dev/kubevela/pkg/utils/system"
+	"github.com/oam-dev/kubevela/pkg/oam"
 )
 
 // ProductionACMEServer is the production ACME Server from let's encrypt
 const ProductionACMEServer = "https://acme-v02.api.letsencrypt.org/directory"
 
-// GetEnvDirByName will get env dir from name
-func GetEnvDirByName(name string) string {
-	envdir, _ := system.GetEnvDir()
-	return filepath.Join(envdir, name)
+// Namespace is the namespace of the ConfigMaps that store the envs
+var Namespace = types.DefaultKubeVelaNS
+
+const (
+	envConfigMapPrefix = "vela-env-"
+
+	keyNamespace = "namespace"
+	keyEmail     = "email"
+	keyDomain    = "domain"
+	keyIssuer    = "issuer"
+	keyPolicy    = "policy"
+)
+
+// ConfigMapName returns the name of the ConfigMap that stores the env
+func ConfigMapName(envName string) string {
+	return envConfigMapPrefix + envName
 }
 
-// GetEnvByName will get env info by name
-func GetEnvByName(name string) (*types.EnvMeta, error) {
-	data, err := ioutil.ReadFile(filepath.Join(GetEnvDirByName(name), system.EnvConfigName))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("env %s not exist", name)
+func builtinDefaultEnv() *types.EnvMeta {
+	return &types.EnvMeta{Name: types.DefaultEnvName, Namespace: types.DefaultAppNamespace, Source: types.EnvSourceBuiltin}
+}
+
+func toConfigMap(envMeta *types.EnvMeta) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName(envMeta.Name),
+			Namespace: Namespace,
+			Labels:    map[string]string{oam.LabelEnvName: envMeta.Name},
+		},
+		Data: map[string]string{
+			keyNamespace: envMeta.Namespace,
+			keyEmail:     envMeta.Email,
+			keyDomain:    envMeta.Domain,
+			keyIssuer:    envMeta.Issuer,
+		},
+	}
+	if envMeta.Policy != nil {
+		data, err := json.Marshal(envMeta.Policy)
+		if err != nil {
+			return nil, err
+		}
+		cm.Data[keyPolicy] = string(data)
+	}
+	return cm, nil
+}
+
+func fromConfigMap(cm *corev1.ConfigMap) (*types.EnvMeta, error) {
+	envMeta := &types.EnvMeta{
+		Name:      cm.Labels[oam.LabelEnvName],
+		Namespace: cm.Data[keyNamespace],
+		Email:     cm.Data[keyEmail],
+		Domain:    cm.Data[keyDomain],
+		Issuer:    cm.Data[keyIssuer],
+		Source:    types.EnvSourceCluster,
+	}
+	if data, ok := cm.Data[keyPolicy]; ok {
+		envMeta.Policy = &types.EnvPolicy{}
+		if err := json.Unmarshal([]byte(data), envMeta.Policy); err != nil {
+			return nil, fmt.Errorf("invalid policy of env %s: %w", envMeta.Name, err)
 		}
+	}
+	return envMeta, nil
+}
+
+// getClusterEnv gets the env stored in the cluster, it returns nil if the env doesn't exist
+func getClusterEnv(ctx context.Context, c client.Reader, name string) (*types.EnvMeta, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, k8stypes.NamespacedName{Namespace: Namespace, Name: ConfigMapName(name)}, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return fromConfigMap(cm)
+}
+
+// GetEnvByName will get env info by name. The envs stored in the cluster come first, then the ones stored locally by
+// older versions of vela, the default env is built in if it's stored nowhere.
+func GetEnvByName(ctx context.Context, c client.Reader, name string) (*types.EnvMeta, error) {
+	envMeta, err := getClusterEnv(ctx, c, name)
+	if err != nil {
 		return nil, err
 	}
-	var meta types.EnvMeta
-	if err = json.Unmarshal(data, &meta); err != nil {
+	if envMeta != nil {
+		return envMeta, nil
+	}
+	envMeta, err = getLocalEnv(name)
+	if err == nil {
+		return envMeta, nil
+	}
+	if !os.IsNotExist(err) {
 		return nil, err
 	}
-	return &meta, nil
+	if name == types.DefaultEnvName {
+		return builtinDefaultEnv(), nil
+	}
+	return nil, fmt.Errorf("env %s not exist", name)
+}
+
+// ensureNamespace creates the namespace if it doesn't exist
+func ensureNamespace(ctx context.Context, c client.Client, namespace string) error {
+	if err := c.Get(ctx, k8stypes.NamespacedName{Name: namespace}, &corev1.Namespace{}); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveEnv stores the env in the cluster, and removes the local one since it's migrated
+func saveEnv(ctx context.Context, c client.Client, envMeta *types.EnvMeta) error {
+	if err := ensureNamespace(ctx, c, Namespace); err != nil {
+		return err
+	}
+	cm, err := toConfigMap(envMeta)
+	if err != nil {
+		return err
+	}
+	existing := &corev1.ConfigMap{}
+	err = c.Get(ctx, k8stypes.NamespacedName{Namespace: cm.Namespace, Name: cm.Name}, existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		err = c.Create(ctx, cm)
+	case err == nil:
+		cm.ResourceVersion = existing.ResourceVersion
+		err = c.Update(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+	return removeLocalEnv(envMeta.Name)
 }
 
 // CreateOrUpdateEnv will create or update env.
@@ -54,8 +167,8 @@ func GetEnvByName(name string) (*types.EnvMeta, error) {
 func CreateOrUpdateEnv(ctx context.Context, c client.Client, envName string, envArgs *types.EnvMeta) (string, error) {
 
 	createOrUpdated := "created"
-	old, err := GetEnvByName(envName)
-	if err == nil {
+	old, err := GetEnvByName(ctx, c, envName)
+	if err == nil && old.Source != types.EnvSourceBuiltin {
 		createOrUpdated = "updated"
 		if envArgs.Domain == "" {
 			envArgs.Domain = old.Domain
@@ -69,22 +182,23 @@ func CreateOrUpdateEnv(ctx context.Context, c client.Client, envName string, env
 		if envArgs.Namespace == "" {
 			envArgs.Namespace = old.Namespace
 		}
+		if envArgs.Policy == nil {
+			envArgs.Policy = old.Policy
+		}
 	}
 
 	if envArgs.Namespace == "" {
 		envArgs.Namespace = "default"
 	}
+	envArgs.Name = envName
 
 	var message = ""
-	// Check If Namespace Exists
-	if err := c.Get(ctx, k8stypes.NamespacedName{Name: envArgs.Namespace}, &corev1.Namespace{}); err != nil {
-		if !apierrors.IsNotFound(err) {
-			return message, err
-		}
-		// Create Namespace if not found
-		if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: envArgs.Namespace}}); err != nil {
-			return message, err
-		}
+	// Check If Namespace Exists, Create Namespace if not found
+	if err := ensureNamespace(ctx, c, envArgs.Namespace); err != nil {
+		return message, err
+	}
+	if err := applyPolicy(ctx, c, envArgs); err != nil {
+		return message, err
 	}
 
 	// Create Issuer For SSL if both email and domain are all set.
@@ -114,28 +228,12 @@ func CreateOrUpdateEnv(ctx context.Context, c client.Client, envName string, env
 		envArgs.Issuer = issuerName
 	}
 
-	data, err := json.Marshal(envArgs)
-	if err != nil {
+	envArgs.Current = ""
+	envArgs.Source = types.EnvSourceCluster
+	if err := saveEnv(ctx, c, envArgs); err != nil {
 		return message, err
 	}
-	envdir, err := system.GetEnvDir()
-	if err != nil {
-		return message, err
-	}
-	subEnvDir := filepath.Join(envdir, envName)
-	if _, err = system.CreateIfNotExist(subEnvDir); err != nil {
-		return message, err
-	}
-	// nolint:gosec
-	if err = ioutil.WriteFile(filepath.Join(subEnvDir, system.EnvConfigName), data, 0644); err != nil {
-		return message, err
-	}
-	curEnvPath, err := system.GetCurrentEnvPath()
-	if err != nil {
-		return message, err
-	}
-	// nolint:gosec
-	if err = ioutil.WriteFile(curEnvPath, []byte(envName), 0644); err != nil {
+	if err := setCurrentEnv(envArgs); err != nil {
 		return message, err
 	}
 
@@ -148,7 +246,7 @@ func CreateOrUpdateEnv(ctx context.Context, c client.Client, envName string, env
 
 // CreateEnv will only create. If env already exists, return error
 func CreateEnv(ctx context.Context, c client.Client, envName string, envArgs *types.EnvMeta) (string, error) {
-	_, err := GetEnvByName(envName)
+	_, err := GetEnvByName(ctx, c, envName)
 	if err == nil {
 		message := fmt.Sprintf("Env %s already exist", envName)
 		return message, errors.New(message)
@@ -159,92 +257,111 @@ func CreateEnv(ctx context.Context, c client.Client, envName string, envArgs *ty
 // UpdateEnv will update Env, if env does not exist, return error
 func UpdateEnv(ctx context.Context, c client.Client, envName string, namespace string) (string, error) {
 	var message = ""
-	envMeta, err := GetEnvByName(envName)
+	envMeta, err := GetEnvByName(ctx, c, envName)
 	if err != nil {
 		return err.Error(), err
 	}
-	if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: envMeta.Namespace}}); err != nil && !apierrors.IsAlreadyExists(err) {
+	if err := ensureNamespace(ctx, c, namespace); err != nil {
 		return message, err
 	}
 	envMeta.Namespace = namespace
-	data, err := json.Marshal(envMeta)
-	if err != nil {
+	if err := applyPolicy(ctx, c, envMeta); err != nil {
 		return message, err
 	}
-	envdir, err := system.GetEnvDir()
-	if err != nil {
+	envMeta.Source = types.EnvSourceCluster
+	if err := saveEnv(ctx, c, envMeta); err != nil {
 		return message, err
 	}
-	subEnvDir := filepath.Join(envdir, envName)
-	// nolint:gosec
-	if err = ioutil.WriteFile(filepath.Join(subEnvDir, system.EnvConfigName), data, 0644); err != nil {
-		return message, err
+	if curEnv, err := GetCurrentEnvName(); err == nil && curEnv == envName {
+		if err := setCurrentEnv(envMeta); err != nil {
+			return message, err
+		}
 	}
 	message = "Update env succeed"
-	return message, err
+	return message, nil
 }
 
-// ListEnvs will list all envs
-func ListEnvs(envName string) ([]*types.EnvMeta, error) {
+// ListEnvs will list all envs, including the ones stored in the cluster and the ones stored locally by older versions
+// of vela. An env stored in both places is listed once as the one in the cluster.
+func ListEnvs(ctx context.Context, c client.Reader, envName string) ([]*types.EnvMeta, error) {
 	var envList []*types.EnvMeta
+	curEnv, err := GetCurrentEnvName()
+	if err != nil {
+		curEnv = types.DefaultEnvName
+	}
 	if envName != "" {
-		env, err := GetEnvByName(envName)
+		env, err := GetEnvByName(ctx, c, envName)
 		if err != nil {
-			if os.IsNotExist(err) {
-				err = fmt.Errorf("env %s not exist", envName)
-			}
 			return envList, err
 		}
 		envList = append(envList, env)
 		return envList, err
 	}
-	envDir, err := system.GetEnvDir()
-	if err != nil {
+
+	cms := &corev1.ConfigMapList{}
+	if err := c.List(ctx, cms, client.InNamespace(Namespace), client.HasLabels{oam.LabelEnvName}); err != nil {
 		return envList, err
 	}
-	files, err := ioutil.ReadDir(envDir)
-	if err != nil {
-		return envList, err
+	listed := map[string]bool{}
+	for i := range cms.Items {
+		envMeta, err := fromConfigMap(&cms.Items[i])
+		if err != nil {
+			return envList, err
+		}
+		listed[envMeta.Name] = true
+		envList = append(envList, envMeta)
 	}
-	curEnv, err := GetCurrentEnvName()
+	localEnvs, err := listLocalEnvs()
 	if err != nil {
-		curEnv = types.DefaultEnvName
+		return envList, err
 	}
-	for _, f := range files {
-		if !f.IsDir() {
-			continue
+	for _, envMeta := range localEnvs {
+		if !listed[envMeta.Name] {
+			listed[envMeta.Name] = true
+			envList = append(envList, envMeta)
 		}
-		data, err := ioutil.ReadFile(filepath.Clean(filepath.Join(envDir, f.Name(), system.EnvConfigName)))
-		if err != nil {
-			continue
-		}
-		var envMeta types.EnvMeta
-		if err = json.Unmarshal(data, &envMeta); err != nil {
-			continue
-		}
-		if curEnv == f.Name() {
+	}
+	if !listed[types.DefaultEnvName] {
+		envList = append(envList, builtinDefaultEnv())
+	}
+	sort.Slice(envList, func(i, j int) bool {
+		return envList[i].Name < envList[j].Name
+	})
+	for _, envMeta := range envList {
+		if envMeta.Name == curEnv {
 			envMeta.Current = "*"
 		}
-		envList = append(envList, &envMeta)
 	}
 	return envList, nil
 }
 
-// GetCurrentEnvName will get current env name
-func GetCurrentEnvName() (string, error) {
-	currentEnvPath, err := system.GetCurrentEnvPath()
+// MigrateEnvs stores the envs stored locally by older versions of vela in the cluster. It returns the migrated envs
+// and the envs that already exist in the cluster, the latter are kept locally and hidden by the ones in the cluster.
+func MigrateEnvs(ctx context.Context, c client.Client) ([]string, []string, error) {
+	localEnvs, err := listLocalEnvs()
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
-	data, err := ioutil.ReadFile(filepath.Clean(currentEnvPath))
-	if err != nil {
-		return "", err
+	var migrated, conflicted []string
+	for _, envMeta := range localEnvs {
+		existing, err := getClusterEnv(ctx, c, envMeta.Name)
+		if err != nil {
+			return migrated, conflicted, err
+		}
+		if existing != nil {
+			conflicted = append(conflicted, envMeta.Name)
+			continue
+		}
+		if err := saveEnv(ctx, c, envMeta); err != nil {
+			return migrated, conflicted, err
+		}
+		migrated = append(migrated, envMeta.Name)
 	}
-	return string(data), nil
+	return migrated, conflicted, nil
 }
 
-// DeleteEnv will delete env locally
-func DeleteEnv(envName string) (string, error) {
+// DeleteEnv will delete env from the cluster and locally, the applications and configs of the env are deleted too
+func DeleteEnv(ctx context.Context, c client.Client, envName string) (string, error) {
 	var message string
 	var err error
 	curEnv, err := GetCurrentEnvName()
@@ -255,37 +372,35 @@ func DeleteEnv(envName string) (string, error) {
 		err = fmt.Errorf("you can't delete current using environment %s", curEnv)
 		return message, err
 	}
-	envdir, err := system.GetEnvDir()
-	if err != nil {
+	var deleted bool
+	err = c.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: Namespace, Name: ConfigMapName(envName)}})
+	if err != nil && !apierrors.IsNotFound(err) {
 		return message, err
 	}
-	envPath := filepath.Join(envdir, envName)
-	if _, err := os.Stat(envPath); err != nil {
-		if os.IsNotExist(err) {
-			err = fmt.Errorf("%s does not exist", envName)
+	deleted = err == nil
+	envPath := GetEnvDirByName(envName)
+	if _, err := os.Stat(envPath); err == nil {
+		deleted = true
+		if err = os.RemoveAll(envPath); err != nil {
 			return message, err
 		}
 	}
-	if err = os.RemoveAll(envPath); err != nil {
+	if !deleted {
+		err = fmt.Errorf("%s does not exist", envName)
 		return message, err
 	}
 	message = envName + " deleted"
-	return message, err
+	return message, nil
 }
 
-// SetEnv will set the current env to the specified one
-func SetEnv(envName string) (string, error) {
+// SetEnv will set the current env to the specified one, the env is cached locally as the current one
+func SetEnv(ctx context.Context, c client.Reader, envName string) (string, error) {
 	var msg string
-	currentEnvPath, err := system.GetCurrentEnvPath()
-	if err != nil {
-		return msg, err
-	}
-	envMeta, err := GetEnvByName(envName)
+	envMeta, err := GetEnvByName(ctx, c, envName)
 	if err != nil {
 		return msg, err
 	}
-	//nolint:gosec
-	if err = ioutil.WriteFile(currentEnvPath, []byte(envName), 0644); err != nil {
+	if err = setCurrentEnv(envMeta); err != nil {
 		return msg, err
 	}
 	msg = fmt.Sprintf("Set environment succeed, current environment is " + envName + ", namespace is " + envMeta.Namespace)
@@ -0,0 +1,86 @@
+package env
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/utils/system"
+)
+
+func writeLocalEnv(t *testing.T, name, data string) {
+	dir := GetEnvDirByName(name)
+	assert.NoError(t, os.MkdirAll(dir, 0750))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, system.EnvConfigName), []byte(data), 0600))
+}
+
+func TestGetEnvByName(t *testing.T) {
+	ctx := context.Background()
+	home, err := ioutil.TempDir("", "vela-home")
+	assert.NoError(t, err)
+	defer os.RemoveAll(home)
+	assert.NoError(t, os.Setenv(system.VelaHomeEnv, home))
+	defer os.Unsetenv(system.VelaHomeEnv)
+
+	prod, err := toConfigMap(&types.EnvMeta{Name: "prod", Namespace: "prod"})
+	assert.NoError(t, err)
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, prod)
+	writeLocalEnv(t, "prod", `{"name":"prod","namespace":"old-prod"}`)
+	writeLocalEnv(t, "dev", `{"name":"dev","namespace":"dev"}`)
+
+	envMeta, err := GetEnvByName(ctx, c, "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.EnvMeta{Name: "prod", Namespace: "prod", Source: types.EnvSourceCluster}, envMeta)
+	envMeta, err = GetEnvByName(ctx, c, "dev")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.EnvMeta{Name: "dev", Namespace: "dev", Source: types.EnvSourceLocal}, envMeta)
+	envMeta, err = GetEnvByName(ctx, c, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, builtinDefaultEnv(), envMeta)
+	_, err = GetEnvByName(ctx, c, "test")
+	assert.EqualError(t, err, "env test not exist")
+
+	// older versions of vela only cache the name of the current env
+	curEnvPath, err := system.GetCurrentEnvPath()
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(curEnvPath, []byte("dev"), 0600))
+	envMeta, err = GetCurrentEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "dev", envMeta.Namespace)
+
+	_, err = SetEnv(ctx, c, "prod")
+	assert.NoError(t, err)
+	name, err := GetCurrentEnvName()
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", name)
+	envMeta, err = GetEnvByNameLocally("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", envMeta.Namespace)
+	_, err = GetEnvByNameLocally("test")
+	assert.EqualError(t, err, "env test not exist locally, run `vela env set test` to cache it")
+
+	migrated, conflicted, err := MigrateEnvs(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev"}, migrated)
+	assert.Equal(t, []string{"prod"}, conflicted)
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: Namespace, Name: ConfigMapName("dev")}, cm))
+	assert.Equal(t, "dev", cm.Data[keyNamespace])
+
+	envList, err := ListEnvs(ctx, c, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.EnvMeta{
+		{Name: "default", Namespace: "default", Source: types.EnvSourceBuiltin},
+		{Name: "dev", Namespace: "dev", Source: types.EnvSourceCluster},
+		{Name: "prod", Namespace: "prod", Source: types.EnvSourceCluster, Current: "*"},
+	}, envList)
+}
@@ -0,0 +1,155 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/utils/system"
+)
+
+// GetEnvDirByName will get env dir from name, the applications and configs of the env are stored in it
+func GetEnvDirByName(name string) string {
+	envdir, _ := system.GetEnvDir()
+	return filepath.Join(envdir, name)
+}
+
+// getLocalEnv gets the env stored locally by older versions of vela
+func getLocalEnv(name string) (*types.EnvMeta, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(filepath.Join(GetEnvDirByName(name), system.EnvConfigName)))
+	if err != nil {
+		return nil, err
+	}
+	var meta types.EnvMeta
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	meta.Current = ""
+	meta.Source = types.EnvSourceLocal
+	return &meta, nil
+}
+
+// listLocalEnvs lists the envs stored locally by older versions of vela
+func listLocalEnvs() ([]*types.EnvMeta, error) {
+	envDir, err := system.GetEnvDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(envDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var envList []*types.EnvMeta
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+		envMeta, err := getLocalEnv(f.Name())
+		if err != nil {
+			continue
+		}
+		envList = append(envList, envMeta)
+	}
+	return envList, nil
+}
+
+// removeLocalEnv removes the env stored locally, the applications and configs of the env are kept
+func removeLocalEnv(name string) error {
+	err := os.Remove(filepath.Join(GetEnvDirByName(name), system.EnvConfigName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GetCurrentEnvName will get current env name
+func GetCurrentEnvName() (string, error) {
+	envMeta, name, err := readCurrentEnv()
+	if err != nil {
+		return "", err
+	}
+	if envMeta != nil {
+		return envMeta.Name, nil
+	}
+	return name, nil
+}
+
+// GetCurrentEnv gets the current env from the local cache without accessing the cluster, run `vela env set` to
+// refresh the cache if the env is changed by others
+func GetCurrentEnv() (*types.EnvMeta, error) {
+	envMeta, name, err := readCurrentEnv()
+	if err != nil {
+		return nil, err
+	}
+	if envMeta != nil {
+		return envMeta, nil
+	}
+	// older versions of vela only cache the name of the current env which is stored locally
+	envMeta, err = getLocalEnv(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("env %s not exist", name)
+		}
+		return nil, err
+	}
+	return envMeta, nil
+}
+
+// readCurrentEnv reads the cached current env, only the name is returned if the cache is written by older versions of vela
+func readCurrentEnv() (*types.EnvMeta, string, error) {
+	currentEnvPath, err := system.GetCurrentEnvPath()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(currentEnvPath))
+	if err != nil {
+		return nil, "", err
+	}
+	var envMeta types.EnvMeta
+	if err := json.Unmarshal(data, &envMeta); err != nil {
+		return nil, strings.TrimSpace(string(data)), nil
+	}
+	return &envMeta, envMeta.Name, nil
+}
+
+// setCurrentEnv caches the env as the current one
+func setCurrentEnv(envMeta *types.EnvMeta) error {
+	currentEnvPath, err := system.GetCurrentEnvPath()
+	if err != nil {
+		return err
+	}
+	cached := *envMeta
+	cached.Current = ""
+	data, err := json.Marshal(&cached)
+	if err != nil {
+		return err
+	}
+	//nolint:gosec
+	return ioutil.WriteFile(currentEnvPath, data, 0644)
+}
+
+// GetEnvByNameLocally gets the env without accessing the cluster, it's the cached current env, the env stored locally
+// by older versions of vela or the built-in default env
+func GetEnvByNameLocally(name string) (*types.EnvMeta, error) {
+	if cur, _, err := readCurrentEnv(); err == nil && cur != nil && cur.Name == name {
+		return cur, nil
+	}
+	envMeta, err := getLocalEnv(name)
+	if err == nil {
+		return envMeta, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if name == types.DefaultEnvName {
+		return builtinDefaultEnv(), nil
+	}
+	return nil, fmt.Errorf("env %s not exist locally, run `vela env set %s` to cache it", name, name)
+}
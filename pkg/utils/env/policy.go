@@ -0,0 +1,195 @@
+package env
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+// applyPolicy applies the policy of the env to its namespace, the namespace labels and annotations are added, the
+// ResourceQuota and LimitRange of the env are created, updated or deleted as the policy declares
+func applyPolicy(ctx context.Context, c client.Client, envMeta *types.EnvMeta) error {
+	policy := envMeta.Policy
+	if policy == nil {
+		policy = &types.EnvPolicy{}
+	}
+	if err := applyNamespaceMeta(ctx, c, envMeta.Namespace, policy); err != nil {
+		return fmt.Errorf("cannot apply the labels and annotations of env %s to namespace %s: %w", envMeta.Name, envMeta.Namespace, err)
+	}
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: policyObjectMeta(envMeta),
+		Spec:       corev1.ResourceQuotaSpec{Hard: policy.ResourceQuota},
+	}
+	if err := applyOrDelete(ctx, c, quota, len(policy.ResourceQuota) > 0); err != nil {
+		return fmt.Errorf("cannot apply the ResourceQuota of env %s: %w", envMeta.Name, err)
+	}
+	limitRange := &corev1.LimitRange{
+		ObjectMeta: policyObjectMeta(envMeta),
+		Spec:       corev1.LimitRangeSpec{Limits: policy.LimitRange},
+	}
+	if err := applyOrDelete(ctx, c, limitRange, len(policy.LimitRange) > 0); err != nil {
+		return fmt.Errorf("cannot apply the LimitRange of env %s: %w", envMeta.Name, err)
+	}
+	return nil
+}
+
+func policyObjectMeta(envMeta *types.EnvMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      ConfigMapName(envMeta.Name),
+		Namespace: envMeta.Namespace,
+		Labels:    map[string]string{oam.LabelEnvName: envMeta.Name},
+	}
+}
+
+// applyNamespaceMeta adds the required labels and annotations to the namespace, the existing ones are kept
+func applyNamespaceMeta(ctx context.Context, c client.Client, namespace string, policy *types.EnvPolicy) error {
+	if len(policy.NamespaceLabels) == 0 && len(policy.NamespaceAnnotations) == 0 {
+		return nil
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, k8stypes.NamespacedName{Name: namespace}, ns); err != nil {
+		return err
+	}
+	labels, changed := mergeMap(ns.Labels, policy.NamespaceLabels)
+	annotations, annotationsChanged := mergeMap(ns.Annotations, policy.NamespaceAnnotations)
+	if !changed && !annotationsChanged {
+		return nil
+	}
+	ns.Labels = labels
+	ns.Annotations = annotations
+	return c.Update(ctx, ns)
+}
+
+func mergeMap(dst, src map[string]string) (map[string]string, bool) {
+	changed := false
+	for k, v := range src {
+		if old, ok := dst[k]; ok && old == v {
+			continue
+		}
+		if dst == nil {
+			dst = map[string]string{}
+		}
+		dst[k] = v
+		changed = true
+	}
+	return dst, changed
+}
+
+// applyOrDelete creates or updates the object if it's wanted, otherwise deletes it
+func applyOrDelete(ctx context.Context, c client.Client, obj object, wanted bool) error {
+	existing, _ := obj.DeepCopyObject().(object)
+	err := c.Get(ctx, k8stypes.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		if !wanted {
+			return nil
+		}
+		return c.Create(ctx, obj)
+	case err != nil:
+		return err
+	case !wanted:
+		return client.IgnoreNotFound(c.Delete(ctx, existing))
+	default:
+		obj.SetResourceVersion(existing.GetResourceVersion())
+		return c.Update(ctx, obj)
+	}
+}
+
+// GetPoliciesByNamespace gets the policies of the envs stored in the cluster whose namespace is the given one
+func GetPoliciesByNamespace(ctx context.Context, c client.Reader, namespace string) ([]*types.EnvPolicy, error) {
+	cms := &corev1.ConfigMapList{}
+	if err := c.List(ctx, cms, client.InNamespace(Namespace), client.HasLabels{oam.LabelEnvName}); err != nil {
+		return nil, err
+	}
+	var policies []*types.EnvPolicy
+	for i := range cms.Items {
+		if cms.Items[i].Data[keyNamespace] != namespace {
+			continue
+		}
+		envMeta, err := fromConfigMap(&cms.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		if envMeta.Policy != nil {
+			policies = append(policies, envMeta.Policy)
+		}
+	}
+	return policies, nil
+}
+
+// InjectDefaultTraits attaches the default traits of the policies to every component of the application, a component
+// keeps its own trait if it already has one of the same type
+func InjectDefaultTraits(app *v1alpha2.Application, policies ...*types.EnvPolicy) error {
+	for i := range app.Spec.Components {
+		comp := &app.Spec.Components[i]
+		attached := map[string]bool{}
+		for _, tr := range comp.Traits {
+			attached[tr.Name] = true
+		}
+		for _, policy := range policies {
+			for _, tr := range policy.DefaultTraits {
+				if attached[tr.Name] {
+					continue
+				}
+				properties, err := json.Marshal(tr.Properties)
+				if err != nil {
+					return fmt.Errorf("invalid properties of default trait %s: %w", tr.Name, err)
+				}
+				comp.Traits = append(comp.Traits, v1alpha2.ApplicationTrait{
+					Name:       tr.Name,
+					Properties: runtime.RawExtension{Raw: properties},
+				})
+				attached[tr.Name] = true
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateApplication checks the workload and trait types of the application against the allowlists of the policies
+func ValidateApplication(app *v1alpha2.Application, policies ...*types.EnvPolicy) field.ErrorList {
+	var errs field.ErrorList
+	compsPath := field.NewPath("spec", "components")
+	for i, comp := range app.Spec.Components {
+		for _, policy := range policies {
+			if !allowed(policy.AllowedWorkloads, comp.WorkloadType) {
+				errs = append(errs, field.NotSupported(compsPath.Index(i).Child("type"), comp.WorkloadType, policy.AllowedWorkloads))
+			}
+			for j, tr := range comp.Traits {
+				if !allowed(policy.AllowedTraits, tr.Name) {
+					errs = append(errs, field.NotSupported(compsPath.Index(i).Child("traits").Index(j).Child("name"), tr.Name, policy.AllowedTraits))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func allowed(allowlist []string, t string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	for _, a := range allowlist {
+		if a == t {
+			return true
+		}
+	}
+	return false
+}
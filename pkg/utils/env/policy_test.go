@@ -0,0 +1,169 @@
+package env
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/utils/system"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	ctx := context.Background()
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"team": "web"}}}
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, ns)
+	envMeta := &types.EnvMeta{Name: "prod", Namespace: "prod", Policy: &types.EnvPolicy{
+		ResourceQuota: corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("8")},
+		LimitRange: []corev1.LimitRangeItem{{
+			Type:           corev1.LimitTypeContainer,
+			DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+		}},
+		NamespaceLabels:      map[string]string{"stage": "production"},
+		NamespaceAnnotations: map[string]string{"owner": "sre"},
+	}}
+	assert.NoError(t, applyPolicy(ctx, c, envMeta))
+
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Name: "prod"}, ns))
+	assert.Equal(t, map[string]string{"team": "web", "stage": "production"}, ns.Labels)
+	assert.Equal(t, map[string]string{"owner": "sre"}, ns.Annotations)
+	quota := &corev1.ResourceQuota{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "prod", Name: "vela-env-prod"}, quota))
+	assert.Equal(t, corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("8")}, quota.Spec.Hard)
+	limitRange := &corev1.LimitRange{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "prod", Name: "vela-env-prod"}, limitRange))
+	assert.Equal(t, envMeta.Policy.LimitRange, limitRange.Spec.Limits)
+
+	// the ResourceQuota is updated and the LimitRange is deleted once they are changed in the policy
+	envMeta.Policy.ResourceQuota = corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("16")}
+	envMeta.Policy.LimitRange = nil
+	assert.NoError(t, applyPolicy(ctx, c, envMeta))
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "prod", Name: "vela-env-prod"}, quota))
+	assert.Equal(t, corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("16")}, quota.Spec.Hard)
+	err := c.Get(ctx, client.ObjectKey{Namespace: "prod", Name: "vela-env-prod"}, limitRange)
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestGetPoliciesByNamespace(t *testing.T) {
+	ctx := context.Background()
+	var objs []runtime.Object
+	for _, envMeta := range []*types.EnvMeta{
+		{Name: "prod", Namespace: "prod", Policy: &types.EnvPolicy{AllowedWorkloads: []string{"webservice"}}},
+		{Name: "prod-eu", Namespace: "prod", Policy: &types.EnvPolicy{AllowedTraits: []string{"scaler"}}},
+		{Name: "staging", Namespace: "staging", Policy: &types.EnvPolicy{AllowedWorkloads: []string{"worker"}}},
+		{Name: "test", Namespace: "prod"},
+	} {
+		cm, err := toConfigMap(envMeta)
+		assert.NoError(t, err)
+		objs = append(objs, cm)
+	}
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, objs...)
+	policies, err := GetPoliciesByNamespace(ctx, c, "prod")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []*types.EnvPolicy{
+		{AllowedWorkloads: []string{"webservice"}},
+		{AllowedTraits: []string{"scaler"}},
+	}, policies)
+	policies, err = GetPoliciesByNamespace(ctx, c, "default")
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+}
+
+func TestInjectDefaultTraits(t *testing.T) {
+	app := &v1alpha2.Application{Spec: v1alpha2.ApplicationSpec{Components: []v1alpha2.ApplicationComponent{
+		{Name: "frontend", WorkloadType: "webservice"},
+		{Name: "backend", WorkloadType: "worker", Traits: []v1alpha2.ApplicationTrait{
+			{Name: "scaler", Properties: runtime.RawExtension{Raw: []byte(`{"replicas":3}`)}},
+		}},
+	}}}
+	policy := &types.EnvPolicy{DefaultTraits: []types.EnvTrait{
+		{Name: "scaler", Properties: map[string]interface{}{"replicas": 2}},
+		{Name: "metrics"},
+	}}
+	assert.NoError(t, InjectDefaultTraits(app, policy))
+	assert.Equal(t, []v1alpha2.ApplicationTrait{
+		{Name: "scaler", Properties: runtime.RawExtension{Raw: []byte(`{"replicas":2}`)}},
+		{Name: "metrics", Properties: runtime.RawExtension{Raw: []byte(`null`)}},
+	}, app.Spec.Components[0].Traits)
+	assert.Equal(t, []v1alpha2.ApplicationTrait{
+		{Name: "scaler", Properties: runtime.RawExtension{Raw: []byte(`{"replicas":3}`)}},
+		{Name: "metrics", Properties: runtime.RawExtension{Raw: []byte(`null`)}},
+	}, app.Spec.Components[1].Traits)
+}
+
+func TestValidateApplication(t *testing.T) {
+	app := &v1alpha2.Application{Spec: v1alpha2.ApplicationSpec{Components: []v1alpha2.ApplicationComponent{
+		{Name: "frontend", WorkloadType: "webservice", Traits: []v1alpha2.ApplicationTrait{{Name: "route"}}},
+		{Name: "backend", WorkloadType: "worker", Traits: []v1alpha2.ApplicationTrait{{Name: "scaler"}}},
+	}}}
+	testCases := map[string]struct {
+		policies []*types.EnvPolicy
+		want     []string
+	}{
+		"no policy": {},
+		"allow all": {
+			policies: []*types.EnvPolicy{{DefaultTraits: []types.EnvTrait{{Name: "metrics"}}}},
+		},
+		"workload not allowed": {
+			policies: []*types.EnvPolicy{{AllowedWorkloads: []string{"webservice"}}},
+			want:     []string{`spec.components[1].type: Unsupported value: "worker": supported values: "webservice"`},
+		},
+		"trait not allowed": {
+			policies: []*types.EnvPolicy{{AllowedWorkloads: []string{"webservice", "worker"}}, {AllowedTraits: []string{"scaler"}}},
+			want:     []string{`spec.components[0].traits[0].name: Unsupported value: "route": supported values: "scaler"`},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, err := range ValidateApplication(app, tc.policies...) {
+				got = append(got, err.Error())
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCreateOrUpdateEnvWithPolicy(t *testing.T) {
+	ctx := context.Background()
+	home, err := ioutil.TempDir("", "vela-home")
+	assert.NoError(t, err)
+	defer os.RemoveAll(home)
+	assert.NoError(t, os.Setenv(system.VelaHomeEnv, home))
+	defer os.Unsetenv(system.VelaHomeEnv)
+
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme)
+	policy := &types.EnvPolicy{
+		ResourceQuota:   corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+		NamespaceLabels: map[string]string{"stage": "production"},
+	}
+	_, err = CreateOrUpdateEnv(ctx, c, "prod", &types.EnvMeta{Namespace: "prod", Policy: policy})
+	assert.NoError(t, err)
+	ns := &corev1.Namespace{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Name: "prod"}, ns))
+	assert.Equal(t, "production", ns.Labels["stage"])
+
+	// the policy is kept if it's not specified on update
+	_, err = CreateOrUpdateEnv(ctx, c, "prod", &types.EnvMeta{Email: "sre@example.com"})
+	assert.NoError(t, err)
+	envMeta, err := GetEnvByName(ctx, c, "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "sre@example.com", envMeta.Email)
+	assert.Equal(t, policy.NamespaceLabels, envMeta.Policy.NamespaceLabels)
+	cur, err := GetCurrentEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, envMeta.Policy.NamespaceLabels, cur.Policy.NamespaceLabels)
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "prod", Name: "vela-env-prod"}, &corev1.ResourceQuota{}))
+}
@@ -0,0 +1,130 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// DefaultTTL is how long the content of an unpinned URI is reused before it's fetched again
+const DefaultTTL = 5 * time.Minute
+
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// DefaultCache is the cache used by Load
+var DefaultCache = NewCache(DefaultTTL)
+
+// Load returns the content of the URI with the DefaultCache
+func Load(ctx context.Context, uri, digest string) (string, error) {
+	return DefaultCache.Load(ctx, uri, digest)
+}
+
+// Digest returns the sha256 digest of the content, in the form of sha256:<hex>
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+type uriEntry struct {
+	digest  string
+	fetched time.Time
+}
+
+// Cache caches the fetched contents by their digest.
+// A pinned URI is fetched only once, an unpinned one is fetched again after TTL.
+type Cache struct {
+	// TTL is how long the content of an unpinned URI is reused
+	TTL     time.Duration
+	Fetcher Fetcher
+
+	mu       sync.Mutex
+	contents map[string][]byte
+	uris     map[string]uriEntry
+	now      func() time.Time
+}
+
+// NewCache creates a cache which fetches http(s), git and OCI URIs
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		TTL:      ttl,
+		Fetcher:  FetcherFunc(defaultFetcher),
+		contents: map[string][]byte{},
+		uris:     map[string]uriEntry{},
+		now:      time.Now,
+	}
+}
+
+// Load returns the content of the URI. If the digest is set, the content must match it.
+func (c *Cache) Load(ctx context.Context, uri, digest string) (string, error) {
+	if digest != "" {
+		if !digestRegexp.MatchString(digest) {
+			return "", fmt.Errorf("invalid digest %s of %s, must be sha256:<64 hex characters>", digest, uri)
+		}
+		if data, ok := c.getContent(digest); ok {
+			return string(data), nil
+		}
+		data, err := c.Fetcher.Fetch(ctx, uri)
+		if err != nil {
+			return "", errors.WithMessagef(err, "fetch template %s", uri)
+		}
+		if actual := Digest(data); actual != digest {
+			return "", fmt.Errorf("the template fetched from %s has digest %s, which doesn't match the pinned digest %s", uri, actual, digest)
+		}
+		c.put(uri, digest, data)
+		return string(data), nil
+	}
+
+	c.mu.Lock()
+	entry, cached := c.uris[uri]
+	c.mu.Unlock()
+	if cached && c.now().Sub(entry.fetched) < c.TTL {
+		if data, ok := c.getContent(entry.digest); ok {
+			return string(data), nil
+		}
+	}
+	data, err := c.Fetcher.Fetch(ctx, uri)
+	if err != nil {
+		// keep serving the last fetched content rather than breaking all the applications using it
+		if stale, ok := c.getContent(entry.digest); cached && ok {
+			klog.ErrorS(err, "Failed to refresh template, use the cached one", "uri", uri, "digest", entry.digest)
+			return string(stale), nil
+		}
+		return "", errors.WithMessagef(err, "fetch template %s", uri)
+	}
+	c.put(uri, Digest(data), data)
+	return string(data), nil
+}
+
+func (c *Cache) getContent(digest string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.contents[digest]
+	return data, ok
+}
+
+func (c *Cache) put(uri, digest string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.uris[uri]; ok && old.digest != digest {
+		c.release(uri, old.digest)
+	}
+	c.contents[digest] = data
+	c.uris[uri] = uriEntry{digest: digest, fetched: c.now()}
+}
+
+// release drops the content if no other URI refers to it
+func (c *Cache) release(uri, digest string) {
+	for u, e := range c.uris {
+		if u != uri && e.digest == digest {
+			return
+		}
+	}
+	delete(c.contents, digest)
+}
@@ -0,0 +1,94 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSource struct {
+	contents map[string]string
+	err      error
+	fetched  int
+}
+
+func (f *fakeSource) Fetch(_ context.Context, uri string) ([]byte, error) {
+	f.fetched++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte(f.contents[uri]), nil
+}
+
+func TestCacheLoad(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{contents: map[string]string{"https://example.com/a.cue": "a: 1"}}
+	now := time.Now()
+	c := NewCache(time.Minute)
+	c.Fetcher = src
+	c.now = func() time.Time { return now }
+
+	data, err := c.Load(ctx, "https://example.com/a.cue", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1", data)
+	assert.Equal(t, 1, src.fetched)
+
+	// reused within TTL
+	src.contents["https://example.com/a.cue"] = "a: 2"
+	data, err = c.Load(ctx, "https://example.com/a.cue", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1", data)
+	assert.Equal(t, 1, src.fetched)
+
+	// fetched again after TTL, the old content is released
+	now = now.Add(2 * time.Minute)
+	data, err = c.Load(ctx, "https://example.com/a.cue", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 2", data)
+	assert.Equal(t, 2, src.fetched)
+	_, ok := c.getContent(Digest([]byte("a: 1")))
+	assert.False(t, ok)
+
+	// the stale content is served if the source is down
+	now = now.Add(2 * time.Minute)
+	src.err = errors.New("connection refused")
+	data, err = c.Load(ctx, "https://example.com/a.cue", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 2", data)
+	assert.Equal(t, 3, src.fetched)
+
+	// pinned content is never fetched again
+	data, err = c.Load(ctx, "https://example.com/b.cue", Digest([]byte("a: 2")))
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 2", data)
+	assert.Equal(t, 3, src.fetched)
+
+	_, err = c.Load(ctx, "https://example.com/c.cue", "")
+	assert.EqualError(t, err, "fetch template https://example.com/c.cue: connection refused")
+}
+
+func TestCacheLoadPinned(t *testing.T) {
+	ctx := context.Background()
+	src := &fakeSource{contents: map[string]string{"https://example.com/a.cue": "a: 1"}}
+	c := NewCache(time.Minute)
+	c.Fetcher = src
+
+	_, err := c.Load(ctx, "https://example.com/a.cue", "sha256:abc")
+	assert.EqualError(t, err, "invalid digest sha256:abc of https://example.com/a.cue, must be sha256:<64 hex characters>")
+
+	pinned := Digest([]byte("a: 0"))
+	_, err = c.Load(ctx, "https://example.com/a.cue", pinned)
+	assert.EqualError(t, err, "the template fetched from https://example.com/a.cue has digest "+Digest([]byte("a: 1"))+
+		", which doesn't match the pinned digest "+pinned)
+
+	data, err := c.Load(ctx, "https://example.com/a.cue", Digest([]byte("a: 1")))
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1", data)
+	data, err = c.Load(ctx, "https://example.com/a.cue", Digest([]byte("a: 1")))
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1", data)
+	assert.Equal(t, 2, src.fetched)
+}
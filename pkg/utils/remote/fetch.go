@@ -0,0 +1,198 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	orasdocker "github.com/deislabs/oras/pkg/auth/docker"
+	"github.com/deislabs/oras/pkg/content"
+	"github.com/deislabs/oras/pkg/oras"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GitPrefix is the prefix of a template in a git repository, e.g. git::https://github.com/org/repo.git//path/to/template.cue?ref=v1.0.0
+	GitPrefix = "git::"
+	// OCIPrefix is the prefix of a template stored as an OCI artifact, e.g. oci://ghcr.io/org/templates/webservice:v1.0.0
+	OCIPrefix = "oci://"
+)
+
+// Fetcher fetches the content referred by a URI
+type Fetcher interface {
+	Fetch(ctx context.Context, uri string) ([]byte, error)
+}
+
+// FetcherFunc is a func which implements Fetcher
+type FetcherFunc func(ctx context.Context, uri string) ([]byte, error)
+
+// Fetch calls f(ctx, uri)
+func (f FetcherFunc) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	return f(ctx, uri)
+}
+
+// defaultFetcher dispatches the URI to the fetcher of its scheme
+func defaultFetcher(ctx context.Context, uri string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		return fetchHTTP(ctx, uri)
+	case strings.HasPrefix(uri, GitPrefix):
+		return fetchGit(ctx, uri)
+	case strings.HasPrefix(uri, OCIPrefix):
+		return fetchOCI(ctx, uri)
+	}
+	return nil, fmt.Errorf("unsupported template URI %s, only http(s)://, %s and %s are supported", uri, GitPrefix, OCIPrefix)
+}
+
+func fetchHTTP(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:errcheck
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", uri, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// gitSource is a file in a git repository at a ref
+type gitSource struct {
+	repo string
+	path string
+	ref  string
+}
+
+// parseGitURI parses git::<repo>//<path>[?ref=<ref>]
+func parseGitURI(uri string) (*gitSource, error) {
+	src := &gitSource{}
+	s := strings.TrimPrefix(uri, GitPrefix)
+	if i := strings.LastIndex(s, "?"); i >= 0 {
+		query := s[i+1:]
+		s = s[:i]
+		if !strings.HasPrefix(query, "ref=") {
+			return nil, fmt.Errorf("invalid git template URI %s, only the ref query is supported", uri)
+		}
+		src.ref = strings.TrimPrefix(query, "ref=")
+	}
+	start := 0
+	if i := strings.Index(s, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	i := strings.Index(s[start:], "//")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid git template URI %s, the file must be given as <repo>//<path>", uri)
+	}
+	src.repo, src.path = s[:start+i], strings.Trim(path.Clean(s[start+i+2:]), "/")
+	if src.repo == "" || src.path == "" || src.path == "." {
+		return nil, fmt.Errorf("invalid git template URI %s, the file must be given as <repo>//<path>", uri)
+	}
+	// the repo and the ref are passed to git, only the remote transports are allowed and neither of them
+	// can be taken as an option
+	if !strings.HasPrefix(src.repo, "https://") && !strings.HasPrefix(src.repo, "ssh://") {
+		return nil, fmt.Errorf("invalid git template URI %s, the repository must be an https:// or ssh:// URL", uri)
+	}
+	if strings.HasPrefix(src.ref, "-") {
+		return nil, fmt.Errorf("invalid git template URI %s, the ref must not start with -", uri)
+	}
+	return src, nil
+}
+
+// fetchGit reads one file from a shallow fetch of the ref, it needs the git binary
+func fetchGit(ctx context.Context, uri string) ([]byte, error) {
+	src, err := parseGitURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return fetchGitSource(ctx, src)
+}
+
+func fetchGitSource(ctx context.Context, src *gitSource) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "vela-template-")
+	if err != nil {
+		return nil, err
+	}
+	//nolint:errcheck
+	defer os.RemoveAll(dir)
+
+	git := func(args ...string) ([]byte, error) {
+		// #nosec G204
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			var stderr string
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				stderr = strings.TrimSpace(string(exitErr.Stderr))
+			}
+			return nil, errors.Errorf("git %s: %v %s", args[0], err, stderr)
+		}
+		return out, nil
+	}
+	ref := src.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := git("init", "-q"); err != nil {
+		return nil, err
+	}
+	if _, err := git("fetch", "-q", "--depth", "1", "--", src.repo, ref); err != nil {
+		return nil, errors.WithMessagef(err, "fetch %s of %s", ref, src.repo)
+	}
+	return git("show", "FETCH_HEAD:"+src.path)
+}
+
+// fetchOCI pulls an OCI artifact with the docker credentials of the controller and returns its template layer
+func fetchOCI(ctx context.Context, uri string) ([]byte, error) {
+	ref := strings.TrimPrefix(uri, OCIPrefix)
+	cli, err := orasdocker.NewClient()
+	if err != nil {
+		return nil, errors.WithMessage(err, "load docker credentials")
+	}
+	resolver, err := cli.Resolver(ctx, http.DefaultClient, false)
+	if err != nil {
+		return nil, err
+	}
+	store := content.NewMemoryStore()
+	_, layers, err := oras.Pull(ctx, resolver, ref, store)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "pull %s", ref)
+	}
+	layer, err := pickTemplateLayer(layers)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "pull %s", ref)
+	}
+	_, data, ok := store.Get(layer)
+	if !ok {
+		return nil, errors.Errorf("pull %s: layer %s is not downloaded", ref, layer.Digest)
+	}
+	return data, nil
+}
+
+// pickTemplateLayer returns the only layer of the artifact, or the only one whose file name ends with .cue
+func pickTemplateLayer(layers []ocispec.Descriptor) (ocispec.Descriptor, error) {
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+	var picked []ocispec.Descriptor
+	for _, l := range layers {
+		if strings.HasSuffix(l.Annotations[ocispec.AnnotationTitle], ".cue") {
+			picked = append(picked, l)
+		}
+	}
+	if len(picked) != 1 {
+		return ocispec.Descriptor{}, errors.Errorf("the artifact must have exactly one layer or one .cue file, got %d layers", len(layers))
+	}
+	return picked[0], nil
+}
@@ -0,0 +1,141 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/a.cue" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "a: 1")
+	}))
+	defer server.Close()
+
+	data, err := defaultFetcher(context.Background(), server.URL+"/a.cue")
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1", string(data))
+
+	_, err = defaultFetcher(context.Background(), server.URL+"/b.cue")
+	assert.EqualError(t, err, fmt.Sprintf("get %s/b.cue: 404 Not Found", server.URL))
+
+	_, err = defaultFetcher(context.Background(), "s3://bucket/a.cue")
+	assert.EqualError(t, err, "unsupported template URI s3://bucket/a.cue, only http(s)://, git:: and oci:// are supported")
+}
+
+func TestParseGitURI(t *testing.T) {
+	cases := map[string]struct {
+		uri    string
+		exp    *gitSource
+		expErr string
+	}{
+		"https with ref": {
+			uri: "git::https://github.com/org/repo.git//templates/webservice.cue?ref=v1.0.0",
+			exp: &gitSource{repo: "https://github.com/org/repo.git", path: "templates/webservice.cue", ref: "v1.0.0"},
+		},
+		"ssh without ref": {
+			uri: "git::ssh://git@github.com/org/repo.git//webservice.cue",
+			exp: &gitSource{repo: "ssh://git@github.com/org/repo.git", path: "webservice.cue"},
+		},
+		"local path": {
+			uri:    "git::/tmp/repo//webservice.cue",
+			expErr: "invalid git template URI git::/tmp/repo//webservice.cue, the repository must be an https:// or ssh:// URL",
+		},
+		"ext transport": {
+			uri:    "git::ext::sh -c touch% /tmp/pwned//a.cue",
+			expErr: "invalid git template URI git::ext::sh -c touch% /tmp/pwned//a.cue, the repository must be an https:// or ssh:// URL",
+		},
+		"option as the repository": {
+			uri:    "git::--upload-pack=touch /tmp/pwned//a.cue",
+			expErr: "invalid git template URI git::--upload-pack=touch /tmp/pwned//a.cue, the repository must be an https:// or ssh:// URL",
+		},
+		"option as the ref": {
+			uri:    "git::https://github.com/org/repo.git//a.cue?ref=--upload-pack=touch",
+			expErr: "invalid git template URI git::https://github.com/org/repo.git//a.cue?ref=--upload-pack=touch, the ref must not start with -",
+		},
+		"no file": {
+			uri:    "git::https://github.com/org/repo.git?ref=main",
+			expErr: "invalid git template URI git::https://github.com/org/repo.git?ref=main, the file must be given as <repo>//<path>",
+		},
+		"unknown query": {
+			uri:    "git::https://github.com/org/repo.git//a.cue?depth=1",
+			expErr: "invalid git template URI git::https://github.com/org/repo.git//a.cue?depth=1, only the ref query is supported",
+		},
+	}
+	for name, c := range cases {
+		src, err := parseGitURI(c.uri)
+		if c.expErr != "" {
+			assert.EqualError(t, err, c.expErr, name)
+			continue
+		}
+		assert.NoError(t, err, name)
+		assert.Equal(t, c.exp, src, name)
+	}
+}
+
+func TestFetchGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo, err := ioutil.TempDir("", "vela-template-repo")
+	assert.NoError(t, err)
+	defer os.RemoveAll(repo)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	git("init", "-q")
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, "templates"), 0750))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(repo, "templates", "a.cue"), []byte("a: 1"), 0600))
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(repo, "templates", "a.cue"), []byte("a: 2"), 0600))
+	git("commit", "-q", "-am", "v2")
+
+	// the URIs of local repositories are rejected, so the sources are given directly
+	data, err := fetchGitSource(context.Background(), &gitSource{repo: "file://" + repo, path: "templates/a.cue", ref: "v1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1", string(data))
+
+	data, err = fetchGitSource(context.Background(), &gitSource{repo: "file://" + repo, path: "templates/a.cue"})
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 2", string(data))
+
+	_, err = fetchGitSource(context.Background(), &gitSource{repo: "file://" + repo, path: "templates/b.cue"})
+	assert.Error(t, err)
+
+	_, err = defaultFetcher(context.Background(), "git::file://"+repo+"//templates/a.cue")
+	assert.Error(t, err)
+}
+
+func TestPickTemplateLayer(t *testing.T) {
+	layer := func(name string) ocispec.Descriptor {
+		return ocispec.Descriptor{Annotations: map[string]string{ocispec.AnnotationTitle: name}}
+	}
+	l, err := pickTemplateLayer([]ocispec.Descriptor{layer("webservice")})
+	assert.NoError(t, err)
+	assert.Equal(t, layer("webservice"), l)
+
+	l, err = pickTemplateLayer([]ocispec.Descriptor{layer("README.md"), layer("webservice.cue")})
+	assert.NoError(t, err)
+	assert.Equal(t, layer("webservice.cue"), l)
+
+	_, err = pickTemplateLayer([]ocispec.Descriptor{layer("a.cue"), layer("b.cue")})
+	assert.EqualError(t, err, "the artifact must have exactly one layer or one .cue file, got 2 layers")
+}
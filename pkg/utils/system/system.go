@@ -121,34 +121,26 @@ func InitCapabilityDir() error {
 // EnvConfigName defines config
 const EnvConfigName = "config.json"
 
-// InitDefaultEnv create dir if not exits
+// InitDefaultEnv create dir if not exits, and set the default env as the current one if there is no current env
 func InitDefaultEnv() error {
 	envDir, err := GetEnvDir()
 	if err != nil {
 		return err
 	}
 	defaultEnvDir := filepath.Join(envDir, types.DefaultEnvName)
-	exist, err := CreateIfNotExist(defaultEnvDir)
-	if err != nil {
-		return err
-	}
-	if exist {
-		return nil
-	}
-	data, _ := json.Marshal(&types.EnvMeta{Namespace: types.DefaultAppNamespace, Name: types.DefaultEnvName})
-	//nolint:gosec
-	if err = ioutil.WriteFile(filepath.Join(defaultEnvDir, EnvConfigName), data, 0644); err != nil {
+	if _, err = CreateIfNotExist(defaultEnvDir); err != nil {
 		return err
 	}
 	curEnvPath, err := GetCurrentEnvPath()
 	if err != nil {
 		return err
 	}
-	//nolint:gosec
-	if err = ioutil.WriteFile(curEnvPath, []byte(types.DefaultEnvName), 0644); err != nil {
+	if _, err = os.Stat(curEnvPath); !os.IsNotExist(err) {
 		return err
 	}
-	return nil
+	data, _ := json.Marshal(&types.EnvMeta{Namespace: types.DefaultAppNamespace, Name: types.DefaultEnvName})
+	//nolint:gosec
+	return ioutil.WriteFile(curEnvPath, data, 0644)
 }
 
 // CreateIfNotExist create dir if not exist
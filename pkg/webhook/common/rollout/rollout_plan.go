@@ -7,6 +7,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	controller "github.com/oam-dev/kubevela/pkg/controller/common/rollout"
 )
 
 // DefaultRolloutPlan set the default values for a rollout plan
@@ -30,8 +31,8 @@ func DefaultRolloutPlan(rollout *v1alpha1.RolloutPlan) {
 	}
 }
 
-// ValidateCreate validate the rollout plan
-func ValidateCreate(rollout *v1alpha1.RolloutPlan, rootPath *field.Path) field.ErrorList {
+// ValidateCreate validate the rollout plan of an object in the given namespace
+func ValidateCreate(rollout *v1alpha1.RolloutPlan, namespace string, rootPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 	// TODO: The total number of num in the batches match the current target resource pod size
 
@@ -54,12 +55,78 @@ func ValidateCreate(rollout *v1alpha1.RolloutPlan, rootPath *field.Path) field.E
 	}
 
 	// validate the webhooks
-	allErrs = append(allErrs, validateWebhook(rollout, rootPath)...)
+	allErrs = append(allErrs, validateWebhook(rollout, namespace, rootPath)...)
 
+	// validate the traffic routing
+	allErrs = append(allErrs, validateTrafficRouting(rollout, rootPath)...)
+
+	// validate the canary metrics
+	allErrs = append(allErrs, validateCanaryMetrics(rollout, rootPath)...)
+
+	return allErrs
+}
+
+func validateCanaryMetrics(rollout *v1alpha1.RolloutPlan, rootPath *field.Path) (allErrs field.ErrorList) {
+	metricPath := rootPath.Child("canaryMetric")
+	for i, metric := range rollout.CanaryMetric {
+		if err := controller.ValidateCanaryMetric(metric); err != nil {
+			allErrs = append(allErrs, field.Invalid(metricPath.Index(i), metric.Name, err.Error()))
+		}
+	}
+	batchesPath := rootPath.Child("rolloutBatches")
+	for i, rb := range rollout.RolloutBatches {
+		for j, metric := range rb.CanaryMetric {
+			if err := controller.ValidateCanaryMetric(metric); err != nil {
+				allErrs = append(allErrs, field.Invalid(batchesPath.Index(i).Child("canaryMetric").Index(j),
+					metric.Name, err.Error()))
+			}
+		}
+	}
+	return allErrs
+}
+
+func validateTrafficRouting(rollout *v1alpha1.RolloutPlan, rootPath *field.Path) (allErrs field.ErrorList) {
+	batchesPath := rootPath.Child("rolloutBatches")
+	for i, rb := range rollout.RolloutBatches {
+		if rb.TrafficWeight == nil {
+			continue
+		}
+		if rollout.TrafficRouting == nil {
+			allErrs = append(allErrs, field.Forbidden(batchesPath.Index(i).Child("trafficWeight"),
+				"the traffic weight only applies to a rollout plan with traffic routing"))
+		} else if *rb.TrafficWeight < 0 || *rb.TrafficWeight > 100 {
+			allErrs = append(allErrs, field.Invalid(batchesPath.Index(i).Child("trafficWeight"),
+				*rb.TrafficWeight, "the traffic weight has to be between 0 and 100"))
+		}
+	}
+	routing := rollout.TrafficRouting
+	if routing == nil {
+		return allErrs
+	}
+	routingPath := rootPath.Child("trafficRouting")
+	if routing.Provider != v1alpha1.IstioTrafficRouting && routing.Provider != v1alpha1.SMITrafficRouting {
+		allErrs = append(allErrs, field.NotSupported(routingPath.Child("provider"), routing.Provider,
+			[]string{string(v1alpha1.IstioTrafficRouting), string(v1alpha1.SMITrafficRouting)}))
+	}
+	if len(routing.Name) == 0 {
+		allErrs = append(allErrs, field.Required(routingPath.Child("name"),
+			"the traffic routing needs the name of the resource that routes the traffic"))
+	}
+	if len(routing.SourceService) == 0 {
+		allErrs = append(allErrs, field.Required(routingPath.Child("sourceService"),
+			"the traffic routing needs the service of the source workload"))
+	}
+	if len(routing.TargetService) == 0 {
+		allErrs = append(allErrs, field.Required(routingPath.Child("targetService"),
+			"the traffic routing needs the service of the target workload"))
+	} else if routing.TargetService == routing.SourceService {
+		allErrs = append(allErrs, field.Invalid(routingPath.Child("targetService"), routing.TargetService,
+			"the source and the target service have to be different"))
+	}
 	return allErrs
 }
 
-func validateWebhook(rollout *v1alpha1.RolloutPlan, rootPath *field.Path) (allErrs field.ErrorList) {
+func validateWebhook(rollout *v1alpha1.RolloutPlan, namespace string, rootPath *field.Path) (allErrs field.ErrorList) {
 	// The webhooks in the rollout plan can only be initialize or finalize webhooks
 	if rollout.RolloutWebhooks != nil {
 		webhookPath := rootPath.Child("rolloutWebhooks")
@@ -68,6 +135,7 @@ func validateWebhook(rollout *v1alpha1.RolloutPlan, rootPath *field.Path) (allEr
 				allErrs = append(allErrs, field.Invalid(webhookPath.Index(i),
 					rw.Type, "the rollout webhook type can only be initialize or finalize webhook"))
 			}
+			allErrs = append(allErrs, validateWebhookOptions(rw, namespace, webhookPath.Index(i))...)
 			// TODO: check the URL/name uniqueness?
 			if rw.Method != http.MethodPost && rw.Method != http.MethodGet && rw.Method != http.MethodPut {
 				allErrs = append(allErrs, field.Invalid(webhookPath.Index(i),
@@ -86,6 +154,8 @@ func validateWebhook(rollout *v1alpha1.RolloutPlan, rootPath *field.Path) (allEr
 					allErrs = append(allErrs, field.Invalid(rolloutBatchPath.Child("batchRolloutWebhooks").Index(j),
 						brw.Type, "the batch webhook type can only be pre or post batch webhook"))
 				}
+				allErrs = append(allErrs, validateWebhookOptions(brw, namespace,
+					rolloutBatchPath.Child("batchRolloutWebhooks").Index(j))...)
 				// TODO: check the URL/name uniqueness?
 			}
 		}
@@ -100,3 +170,25 @@ func ValidateUpdate(new *v1alpha1.RolloutPlan, prev *v1alpha1.RolloutPlan, rootP
 
 	return allErrs
 }
+
+// validateWebhookOptions validates the call options shared by all types of webhooks
+func validateWebhookOptions(rw v1alpha1.RolloutWebhook, namespace string, webhookPath *field.Path) (allErrs field.ErrorList) {
+	if rw.TimeoutSeconds != nil && *rw.TimeoutSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(webhookPath.Child("timeoutSeconds"), *rw.TimeoutSeconds,
+			"the webhook timeout has to be positive"))
+	}
+	if rw.RetryLimit != nil && *rw.RetryLimit < 0 {
+		allErrs = append(allErrs, field.Invalid(webhookPath.Child("retryLimit"), *rw.RetryLimit,
+			"the webhook retry limit cannot be negative"))
+	}
+	if rw.SigningSecretRef != nil && (len(rw.SigningSecretRef.Name) == 0 || len(rw.SigningSecretRef.Key) == 0) {
+		allErrs = append(allErrs, field.Required(webhookPath.Child("signingSecretRef"),
+			"the webhook signing secret needs both the name and the key"))
+	}
+	// the controller signs with its own client, so the secret is confined to the namespace of the rollout
+	if rw.SigningSecretRef != nil && len(rw.SigningSecretRef.Namespace) != 0 && rw.SigningSecretRef.Namespace != namespace {
+		allErrs = append(allErrs, field.Invalid(webhookPath.Child("signingSecretRef", "namespace"),
+			rw.SigningSecretRef.Namespace, "the webhook signing secret has to be in the namespace of the rollout"))
+	}
+	return allErrs
+}
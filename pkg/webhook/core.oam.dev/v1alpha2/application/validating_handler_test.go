@@ -6,9 +6,12 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/oam-dev/kubevela/pkg/oam"
 )
 
 var _ = Describe("Test Application Validator", func() {
@@ -70,6 +73,41 @@ var _ = Describe("Test Application Validator", func() {
 		Expect(resp.Allowed).Should(BeFalse())
 	})
 
+	It("Test Application Validator [Env policy]", func() {
+		envCM := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "vela-env-staging",
+				Namespace: "vela-system",
+				Labels:    map[string]string{oam.LabelEnvName: "staging"},
+			},
+			Data: map[string]string{
+				"namespace": "staging",
+				"policy":    `{"allowedWorkloads":["webservice"]}`,
+			},
+		}
+		Expect(k8sClient.Create(ctx, envCM)).Should(BeNil())
+		defer func() {
+			Expect(k8sClient.Delete(ctx, envCM)).Should(BeNil())
+		}()
+		req := admission.Request{
+			AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: admissionv1beta1.Create,
+				Resource:  metav1.GroupVersionResource{Group: "core.oam.dev", Version: "v1alpha2", Resource: "applications"},
+				Object: runtime.RawExtension{
+					Raw: []byte(`
+{"apiVersion":"core.oam.dev/v1alpha2",
+"kind":"Application",
+"metadata":{"name":"application-sample","namespace":"staging"},
+"spec":{"components":[{"name":"myweb","settings":{"cmd":["sleep","1000"],"image":"busybox"},"type":"worker"}]}}
+`),
+				},
+			},
+		}
+		resp := handler.Handle(ctx, req)
+		Expect(resp.Allowed).Should(BeFalse())
+		Expect(resp.Result.Message).Should(ContainSubstring(`spec.components[0].type: Unsupported value: "worker"`))
+	})
+
 	It("Test Application Validator Forbid rollout annotation", func() {
 		req := admission.Request{
 			AdmissionRequest: admissionv1beta1.AdmissionRequest{
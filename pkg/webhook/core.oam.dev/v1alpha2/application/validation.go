@@ -1,12 +1,19 @@
 package application
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/pkg/appfile"
+	"github.com/oam-dev/kubevela/pkg/utils/env"
 )
 
 // ValidateCreate validates the Application on creation
@@ -17,6 +24,13 @@ func (h *ValidatingHandler) ValidateCreate(ctx context.Context, app *v1alpha2.Ap
 	if _, err := appParser.GenerateAppFile(ctx, app.Name, app); err != nil {
 		componentErrs = append(componentErrs, field.Invalid(field.NewPath("spec"), app, err.Error()))
 	}
+	// the workload and trait types must be allowed by the envs the app is deployed to
+	policies, err := env.GetPoliciesByNamespace(ctx, h.Client, app.Namespace)
+	if err != nil {
+		componentErrs = append(componentErrs, field.InternalError(field.NewPath("metadata", "namespace"), err))
+		return componentErrs
+	}
+	componentErrs = append(componentErrs, env.ValidateApplication(app, policies...)...)
 	return componentErrs
 }
 
@@ -24,6 +38,44 @@ func (h *ValidatingHandler) ValidateCreate(ctx context.Context, app *v1alpha2.Ap
 func (h *ValidatingHandler) ValidateUpdate(ctx context.Context, newApp, oldApp *v1alpha2.Application) field.ErrorList {
 	// check if the newApp is valid
 	componentErrs := h.ValidateCreate(ctx, newApp)
+	componentErrs = append(componentErrs, validateRollingComponents(newApp, oldApp)...)
 	// TODO: add more validating
 	return componentErrs
 }
+
+// validateRollingComponents makes sure that an application with a rollout plan changes at most one component's
+// workload in an update, the rollout plan only upgrades one component at a time
+func validateRollingComponents(newApp, oldApp *v1alpha2.Application) field.ErrorList {
+	if newApp.Spec.RolloutPlan == nil {
+		return nil
+	}
+	oldComps := make(map[string]v1alpha2.ApplicationComponent, len(oldApp.Spec.Components))
+	for _, comp := range oldApp.Spec.Components {
+		oldComps[comp.Name] = comp
+	}
+	var changed []string
+	for _, comp := range newApp.Spec.Components {
+		oldComp, exist := oldComps[comp.Name]
+		if exist && (oldComp.WorkloadType != comp.WorkloadType || !equalSettings(oldComp.Settings, comp.Settings)) {
+			changed = append(changed, comp.Name)
+		}
+	}
+	if len(changed) <= 1 {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(field.NewPath("spec", "components"),
+		fmt.Sprintf("the rollout plan can only upgrade one component at a time but the components %s changed",
+			strings.Join(changed, ", ")))}
+}
+
+// equalSettings compares the settings of two components regardless of how they are formatted
+func equalSettings(a, b runtime.RawExtension) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal(a.Raw, &va); err != nil {
+		return bytes.Equal(a.Raw, b.Raw)
+	}
+	if err := json.Unmarshal(b.Raw, &vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
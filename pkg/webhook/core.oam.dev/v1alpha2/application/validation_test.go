@@ -0,0 +1,65 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+)
+
+func TestValidateRollingComponents(t *testing.T) {
+	newApp := func(plan *v1alpha1.RolloutPlan, images ...string) *v1alpha2.Application {
+		app := &v1alpha2.Application{}
+		app.Spec.RolloutPlan = plan
+		for i, image := range images {
+			app.Spec.Components = append(app.Spec.Components, v1alpha2.ApplicationComponent{
+				Name:         []string{"frontend", "backend"}[i],
+				WorkloadType: "webservice",
+				Settings:     runtime.RawExtension{Raw: []byte(`{"image": "` + image + `"}`)},
+			})
+		}
+		return app
+	}
+	plan := &v1alpha1.RolloutPlan{RolloutBatches: []v1alpha1.RolloutBatch{{}}}
+	tests := map[string]struct {
+		newApp  *v1alpha2.Application
+		oldApp  *v1alpha2.Application
+		wantErr bool
+	}{
+		"one component changed": {
+			newApp: newApp(plan, "nginx:v2", "redis:v1"),
+			oldApp: newApp(plan, "nginx:v1", "redis:v1"),
+		},
+		"more than one component changed": {
+			newApp:  newApp(plan, "nginx:v2", "redis:v2"),
+			oldApp:  newApp(plan, "nginx:v1", "redis:v1"),
+			wantErr: true,
+		},
+		"more than one component changed without a rollout plan": {
+			newApp: newApp(nil, "nginx:v2", "redis:v2"),
+			oldApp: newApp(nil, "nginx:v1", "redis:v1"),
+		},
+		"a new component": {
+			newApp: newApp(plan, "nginx:v2", "redis:v1"),
+			oldApp: newApp(plan, "nginx:v1"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			errs := validateRollingComponents(tt.newApp, tt.oldApp)
+			assert.Equal(t, tt.wantErr, len(errs) != 0, errs)
+		})
+	}
+
+	t.Run("the settings are only formatted differently", func(t *testing.T) {
+		oldApp := newApp(plan, "nginx:v1", "redis:v1")
+		app := oldApp.DeepCopy()
+		for i := range app.Spec.Components {
+			app.Spec.Components[i].Settings.Raw = append([]byte(" "), app.Spec.Components[i].Settings.Raw...)
+		}
+		assert.Empty(t, validateRollingComponents(app, oldApp))
+	})
+}
@@ -58,6 +58,10 @@ func DefaultAppRollout(obj *v1alpha2.AppRollout) {
 		klog.V(common.LogDebug).Info("default RevertOnDelete as false")
 		obj.Spec.RevertOnDelete = pointer.BoolPtr(false)
 	}
+	if obj.Spec.RollbackOnFailure == nil {
+		klog.V(common.LogDebug).Info("default RollbackOnFailure as false")
+		obj.Spec.RollbackOnFailure = pointer.BoolPtr(false)
+	}
 
 	// default rollout plan
 	rollout.DefaultRolloutPlan(&obj.Spec.RolloutPlan)
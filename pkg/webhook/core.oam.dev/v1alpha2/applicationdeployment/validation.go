@@ -2,6 +2,7 @@ package applicationdeployment
 
 import (
 	"context"
+	"fmt"
 
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
@@ -51,59 +52,64 @@ func (h *ValidatingHandler) ValidateCreate(appRollout *v1alpha2.AppRollout) fiel
 		}
 	} else {
 		sourceApp = nil
+		// there is nothing to roll back to without a source
+		if appRollout.Spec.RollbackOnFailure != nil && *appRollout.Spec.RollbackOnFailure {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("rollbackOnFailure"),
+				appRollout.Spec.RollbackOnFailure, "cannot roll back without a source application"))
+		}
 	}
 
 	// validate the component spec
+	rollbackOnFailure := appRollout.Spec.RollbackOnFailure != nil && *appRollout.Spec.RollbackOnFailure
+	trafficRouting := appRollout.Spec.RolloutPlan.TrafficRouting != nil
 	allErrs = append(allErrs, validateComponent(appRollout.Spec.ComponentList, &targetApp, sourceApp,
-		fldPath.Child("componentList"))...)
+		rollbackOnFailure, trafficRouting, fldPath.Child("componentList"))...)
 
 	// validate the rollout plan spec
-	allErrs = append(allErrs, rollout.ValidateCreate(&appRollout.Spec.RolloutPlan, fldPath.Child("rolloutPlan"))...)
+	allErrs = append(allErrs, rollout.ValidateCreate(&appRollout.Spec.RolloutPlan, appRollout.Namespace, fldPath.Child("rolloutPlan"))...)
 	return allErrs
 }
 
 // validateComponent validate the ComponentList
-// 1. there can only be one component or less
-// 2. if there are no components, make sure the applications has only one common component so that's the default
-// 3. it is contained in both source and target application
-// 4. the common component has the same type
+// 1. if there are no components, make sure the applications have at least one common component so that we roll out
+// all the common components by default
+// 2. every component is contained in both source and target application
+// 3. there is only one component to roll out if we need to roll it back on failure
+// 4. there is only one component to roll out if the rollout shifts the traffic, the traffic routing names the
+// services of a single component
 func validateComponent(componentList []string, targetApp, sourceApp *v1alpha2.ApplicationConfiguration,
-	fldPath *field.Path) field.ErrorList {
+	rollbackOnFailure, trafficRouting bool, fldPath *field.Path) field.ErrorList {
 	var componentErrs field.ErrorList
-	var commonComponentName string
-	if len(componentList) > 1 {
-		componentErrs = append(componentErrs, field.TooLong(fldPath, componentList, 1))
-		return componentErrs
-	}
 	commons := FindCommonComponent(targetApp, sourceApp)
 	if len(componentList) == 0 {
-		// we need to find the default
-		if len(commons) != 1 {
-			// we cannot find a default component if there are multiple
-			klog.Error("there are more than one common component", "common component", commons)
-			componentErrs = append(componentErrs, field.TooMany(fldPath, len(commons), 1))
+		// we roll out all the common components by default
+		if len(commons) == 0 {
+			klog.Error("there is no common component in the application")
+			componentErrs = append(componentErrs, field.Required(fldPath,
+				"there is no common component in the application"))
 			return componentErrs
 		}
-		commonComponentName = commons[0]
-	} else {
+		componentList = commons
+	}
+	for i, componentName := range componentList {
 		// the component need to be one of the common components
-		if !slice.ContainsString(commons, componentList[0], nil) {
+		if !slice.ContainsString(commons, componentName, nil) {
 			klog.Error("The component does not belong to the application",
-				"common components", commons, "component to upgrade", componentList[0])
-			componentErrs = append(componentErrs, field.Invalid(fldPath, componentList[0],
+				"common components", commons, "component to upgrade", componentName)
+			componentErrs = append(componentErrs, field.Invalid(fldPath.Index(i), componentName,
 				"it is not a common component in the application"))
-			return componentErrs
 		}
-		commonComponentName = componentList[0]
 	}
-	// check if the workload type are the same in the source and target application
-	if len(commonComponentName) == 0 {
-		klog.Error("the common component have different types in the application",
-			"common component", commonComponentName)
-		componentErrs = append(componentErrs, field.Invalid(fldPath, componentList[0],
-			"the common component have different types in the application"))
+	if rollbackOnFailure && len(componentList) > 1 {
+		componentErrs = append(componentErrs, field.Invalid(fldPath, componentList,
+			fmt.Sprintf("rollbackOnFailure only supports a rollout of a single component but %d components are "+
+				"rolled out, disable rollbackOnFailure or roll out one component at a time", len(componentList))))
+	}
+	if trafficRouting && len(componentList) > 1 {
+		componentErrs = append(componentErrs, field.Invalid(fldPath, componentList,
+			fmt.Sprintf("trafficRouting only supports a rollout of a single component but %d components are "+
+				"rolled out, remove trafficRouting or roll out one component at a time", len(componentList))))
 	}
-
 	return componentErrs
 }
 
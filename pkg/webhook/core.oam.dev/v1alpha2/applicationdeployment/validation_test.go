@@ -0,0 +1,57 @@
+package applicationdeployment
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+)
+
+var _ = Describe("Application Deployment Validation Test", func() {
+	var targetApp, sourceApp *v1alpha2.ApplicationConfiguration
+	fldPath := field.NewPath("spec").Child("componentList")
+
+	BeforeEach(func() {
+		targetApp = &v1alpha2.ApplicationConfiguration{}
+		sourceApp = &v1alpha2.ApplicationConfiguration{}
+		fillApplication(&targetApp.Spec, []string{"frontend", "backend", "cache"})
+		fillApplication(&sourceApp.Spec, []string{"frontend", "backend"})
+	})
+
+	It("Test roll out all the common components by default", func() {
+		Expect(validateComponent(nil, targetApp, sourceApp, false, false, fldPath)).Should(BeEmpty())
+	})
+
+	It("Test roll out multiple components", func() {
+		Expect(validateComponent([]string{"backend", "frontend"}, targetApp, sourceApp, false, false,
+			fldPath)).Should(BeEmpty())
+	})
+
+	It("Test the component is not a common component", func() {
+		errs := validateComponent([]string{"frontend", "cache"}, targetApp, sourceApp, false, false, fldPath)
+		Expect(errs).Should(HaveLen(1))
+		Expect(errs[0].Field).Should(Equal("spec.componentList[1]"))
+	})
+
+	It("Test there is no common component", func() {
+		sourceApp = &v1alpha2.ApplicationConfiguration{}
+		fillApplication(&sourceApp.Spec, []string{"database"})
+		Expect(validateComponent(nil, targetApp, sourceApp, false, false, fldPath)).Should(HaveLen(1))
+	})
+
+	It("Test roll back multiple components on failure", func() {
+		errs := validateComponent(nil, targetApp, sourceApp, true, false, fldPath)
+		Expect(errs).Should(HaveLen(1))
+		Expect(errs[0].Detail).Should(ContainSubstring("rollbackOnFailure only supports a rollout of a single component"))
+		Expect(validateComponent([]string{"frontend"}, targetApp, sourceApp, true, false, fldPath)).Should(BeEmpty())
+	})
+
+	It("Test shift the traffic of multiple components", func() {
+		errs := validateComponent(nil, targetApp, sourceApp, false, true, fldPath)
+		Expect(errs).Should(HaveLen(1))
+		Expect(errs[0].Detail).Should(ContainSubstring("trafficRouting only supports a rollout of a single component"))
+		Expect(validateComponent([]string{"frontend"}, targetApp, sourceApp, false, true, fldPath)).Should(BeEmpty())
+	})
+})
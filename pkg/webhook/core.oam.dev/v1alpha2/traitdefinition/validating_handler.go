@@ -128,7 +128,7 @@ func ValidateDefinitionReference(_ context.Context, td v1alpha2.TraitDefinition)
 	if err != nil {
 		return errors.Wrap(err, errValidateDefRef)
 	}
-	if len(tmp.TemplateStr) == 0 {
+	if len(tmp.TemplateStr) == 0 && len(tmp.TemplateURI) == 0 {
 		return errors.New(failInfoDefRefOmitted)
 	}
 	return nil
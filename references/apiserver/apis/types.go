@@ -15,6 +15,8 @@ type Environment struct {
 	Email     string `json:"email"`
 	Domain    string `json:"domain"`
 	Current   string `json:"current,omitempty"`
+	// Source is where the environment is stored, cluster, local or built-in
+	Source string `json:"source,omitempty"`
 }
 
 // EnvironmentBody used for restful API in dashboard server
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
 	"github.com/oam-dev/kubevela/pkg/utils/env"
 	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
 	"github.com/oam-dev/kubevela/references/apiserver/util"
@@ -20,7 +21,7 @@ func (s *APIServer) UpdateApps(c *gin.Context) {
 // GetApp requests an application by the namespaced name in the gin.Context
 func (s *APIServer) GetApp(c *gin.Context) {
 	envName := c.Param("envName")
-	envMeta, err := env.GetEnvByName(envName)
+	envMeta, err := env.GetEnvByName(util.GetContext(c), s.KubeClient, envName)
 	if err != nil {
 		util.HandleError(c, util.StatusInternalServerError, err)
 		return
@@ -46,7 +47,7 @@ func (s *APIServer) GetApp(c *gin.Context) {
 // @Router /envs/{envName}/apps [get]
 func (s *APIServer) ListApps(c *gin.Context) {
 	envName := c.Param("envName")
-	envMeta, err := env.GetEnvByName(envName)
+	envMeta, err := env.GetEnvByName(util.GetContext(c), s.KubeClient, envName)
 	if err != nil {
 		util.HandleError(c, util.StatusInternalServerError, err)
 		return
@@ -65,7 +66,7 @@ func (s *APIServer) ListApps(c *gin.Context) {
 // DeleteApps deletes an application by the namespaced name in the gin.Context
 func (s *APIServer) DeleteApps(c *gin.Context) {
 	envName := c.Param("envName")
-	envMeta, err := env.GetEnvByName(envName)
+	envMeta, err := env.GetEnvByName(util.GetContext(c), s.KubeClient, envName)
 	if err != nil {
 		util.HandleError(c, util.StatusInternalServerError, err)
 		return
@@ -96,7 +97,7 @@ func (s *APIServer) CreateApplication(c *gin.Context) {
 		util.HandleError(c, util.InvalidArgument, "the application creation request body is invalid")
 		return
 	}
-	env, err := env.GetEnvByName(c.Param("envName"))
+	env, err := env.GetEnvByName(util.GetContext(c), s.KubeClient, c.Param("envName"))
 	if err != nil {
 		util.HandleError(c, util.StatusInternalServerError, err.Error())
 		return
@@ -120,3 +121,44 @@ func (s *APIServer) CreateApplication(c *gin.Context) {
 	msg := fmt.Sprintf("application %s is successfully created", body.Name)
 	util.AssembleResponse(c, msg, nil)
 }
+
+// ApproveRolloutBatch approves the current rollout batch of an application
+// @tags applications
+// @ID ApproveRolloutBatch
+// @Summary approve the current rollout batch of an application
+// @Param envName path string true "environment name"
+// @Param appName path string true "application name"
+// @Success 200 {object} apis.Response{code=int,data=string}
+// @Failure 500 {object} apis.Response{code=int,data=string}
+// @Router /envs/{envName}/apps/{appName}/rollout/approve [post]
+func (s *APIServer) ApproveRolloutBatch(c *gin.Context) {
+	s.decideRolloutBatch(c, v1alpha1.BatchApproved)
+}
+
+// RejectRolloutBatch rejects the current rollout batch of an application, the rollout will fail
+// @tags applications
+// @ID RejectRolloutBatch
+// @Summary reject the current rollout batch of an application
+// @Param envName path string true "environment name"
+// @Param appName path string true "application name"
+// @Success 200 {object} apis.Response{code=int,data=string}
+// @Failure 500 {object} apis.Response{code=int,data=string}
+// @Router /envs/{envName}/apps/{appName}/rollout/reject [post]
+func (s *APIServer) RejectRolloutBatch(c *gin.Context) {
+	s.decideRolloutBatch(c, v1alpha1.BatchRejected)
+}
+
+func (s *APIServer) decideRolloutBatch(c *gin.Context, decision v1alpha1.BatchApprovalDecision) {
+	envMeta, err := env.GetEnvByName(util.GetContext(c), s.KubeClient, c.Param("envName"))
+	if err != nil {
+		util.HandleError(c, util.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx := util.GetContext(c)
+	msg, err := common.DecideRolloutBatch(ctx, s.KubeClient, envMeta.Namespace, c.Param("appName"), decision)
+	if err != nil {
+		util.HandleError(c, util.StatusInternalServerError, err.Error())
+		return
+	}
+	util.AssembleResponse(c, msg, nil)
+}
@@ -71,7 +71,7 @@ func (s *APIServer) UpdateEnv(c *gin.Context) {
 func (s *APIServer) GetEnv(c *gin.Context) {
 	envName := c.Param("envName")
 	ctrl.Log.Info("Get a get environment request", "envName", envName)
-	envList, err := env.ListEnvs(envName)
+	envList, err := env.ListEnvs(util.GetContext(c), s.KubeClient, envName)
 
 	environmentList := make([]apis.Environment, 0)
 	for _, envMeta := range envList {
@@ -79,6 +79,7 @@ func (s *APIServer) GetEnv(c *gin.Context) {
 			EnvName:   envMeta.Name,
 			Namespace: envMeta.Namespace,
 			Current:   envMeta.Current,
+			Source:    envMeta.Source,
 		})
 	}
 	util.AssembleResponse(c, environmentList, err)
@@ -106,7 +107,7 @@ func (s *APIServer) ListEnv(c *gin.Context) {
 func (s *APIServer) DeleteEnv(c *gin.Context) {
 	envName := c.Param("envName")
 	ctrl.Log.Info("Delete a delete environment request", "envName", envName)
-	msg, err := env.DeleteEnv(envName)
+	msg, err := env.DeleteEnv(util.GetContext(c), s.KubeClient, envName)
 	util.AssembleResponse(c, msg, err)
 }
 
@@ -120,6 +121,6 @@ func (s *APIServer) DeleteEnv(c *gin.Context) {
 func (s *APIServer) SetEnv(c *gin.Context) {
 	envName := c.Param("envName")
 	ctrl.Log.Info("Patch a set environment request", "envName", envName)
-	msg, err := env.SetEnv(envName)
+	msg, err := env.SetEnv(util.GetContext(c), s.KubeClient, envName)
 	util.AssembleResponse(c, msg, err)
 }
@@ -81,6 +81,8 @@ func (s *APIServer) setupRoute(staticPath string) http.Handler {
 			apps.GET("", s.ListApps)
 			apps.DELETE("/:appName", s.DeleteApps)
 			apps.POST("/", s.CreateApplication)
+			apps.POST("/:appName/rollout/approve", s.ApproveRolloutBatch)
+			apps.POST("/:appName/rollout/reject", s.RejectRolloutBatch)
 
 			// component related operation
 			components := apps.Group("/:appName/components")
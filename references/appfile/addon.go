@@ -59,7 +59,7 @@ func ApplyTerraform(app *v1alpha2.Application, k8sClient client.Client, ioStream
 			name := wl.Name
 			ioStream.Infof("\nApplying cloud resources %s\n", name)
 
-			tf, err := getTerraformJSONFiles(k8sClient, wl, appFile.Name, revisionName, namespace)
+			tf, err := getTerraformJSONFiles(k8sClient, wl, appFile, revisionName, namespace)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get Terraform JSON files from workload %s: %w", name, err)
 			}
@@ -173,8 +173,8 @@ func generateSecretFromTerraformOutput(k8sClient client.Client, outputList []str
 }
 
 // getTerraformJSONFiles gets Terraform JSON files or modules from workload
-func getTerraformJSONFiles(k8sClient client.Client, wl *appfile.Workload, applicationName, revisionName string, namespace string) ([]byte, error) {
-	pCtx, err := appfile.PrepareProcessContext(k8sClient, wl, applicationName, namespace, revisionName)
+func getTerraformJSONFiles(k8sClient client.Client, wl *appfile.Workload, appFile *appfile.Appfile, revisionName string, namespace string) ([]byte, error) {
+	pCtx, err := appfile.PrepareProcessContext(k8sClient, wl, appFile.Name, revisionName, namespace, appFile.AppInfo(nil))
 	if err != nil {
 		return nil, err
 	}
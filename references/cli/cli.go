@@ -58,6 +58,7 @@ func NewCommand() *cobra.Command {
 		NewInitCommand(commandArgs, ioStream),
 		NewUpCommand(commandArgs, ioStream),
 		NewExportCommand(commandArgs, ioStream),
+		NewDiffCommand(commandArgs, ioStream),
 		NewCapabilityShowCommand(commandArgs, ioStream),
 
 		// Apps
@@ -67,12 +68,14 @@ func NewCommand() *cobra.Command {
 		NewExecCommand(commandArgs, ioStream),
 		NewPortForwardCommand(commandArgs, ioStream),
 		NewLogsCommand(commandArgs, ioStream),
+		NewRolloutCommand(commandArgs, ioStream),
 		NewEnvCommand(commandArgs, ioStream),
 		NewConfigCommand(ioStream),
 
 		// Capabilities
 		CapabilityCommandGroup(commandArgs, ioStream),
 		NewTemplateCommand(ioStream),
+		DefinitionCommandGroup(ioStream),
 		NewTraitsCommand(commandArgs, ioStream),
 		NewWorkloadsCommand(commandArgs, ioStream),
 
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/dsl/deftest"
+	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
+)
+
+// DefinitionCommandGroup creates `def` command and its nested children command
+func DefinitionCommandGroup(ioStream cmdutil.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "def",
+		DisableFlagsInUseLine: true,
+		Short:                 "Manage definitions",
+		Long:                  "Manage the WorkloadDefinitions, ComponentDefinitions and TraitDefinitions",
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeCap,
+		},
+	}
+	cmd.SetOut(ioStream.Out)
+	cmd.AddCommand(NewDefinitionTestCommand(ioStream))
+	return cmd
+}
+
+// NewDefinitionTestCommand creates `def test` command
+func NewDefinitionTestCommand(ioStream cmdutil.IOStreams) *cobra.Command {
+	var update bool
+	cmd := &cobra.Command{
+		Use:   "test [PATH]...",
+		Short: "Test the CUE templates of definitions with golden files",
+		Long: "Render the CUE templates of definitions with the test cases in the *" + deftest.SuiteFileSuffix + " files, " +
+			"and compare the rendered objects with the golden files, no cluster is needed.\n" +
+			"Directories are searched recursively, the current directory is tested if no path is given.",
+		Example: `vela def test ./definitions
+vela def test ./definitions/webservice_test.yaml --update`,
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeCap,
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"."}
+			}
+			return testDefinitions(ioStream, args, update)
+		},
+	}
+	cmd.Flags().BoolVar(&update, "update", false, "write the golden files with the rendered objects instead of comparing them")
+	cmd.SetOut(ioStream.Out)
+	return cmd
+}
+
+func testDefinitions(ioStream cmdutil.IOStreams, paths []string, update bool) error {
+	suiteFiles, err := deftest.FindSuites(paths...)
+	if err != nil {
+		return err
+	}
+	if len(suiteFiles) == 0 {
+		return fmt.Errorf("no test suite (*%s) found in %s", deftest.SuiteFileSuffix, strings.Join(paths, ", "))
+	}
+	var passed, failed int
+	for _, file := range suiteFiles {
+		suite, err := deftest.LoadSuite(file)
+		if err != nil {
+			ioStream.Info(red.Sprintf("FAIL %s", file))
+			ioStream.Infof("    %v\n", err)
+			failed++
+			continue
+		}
+		for _, r := range suite.Run(update) {
+			name := r.Suite + "/" + r.Case
+			switch {
+			case r.Updated:
+				ioStream.Infof("%s %s\n", yellow.Sprint("UPDATE"), r.Golden)
+				passed++
+			case r.Passed():
+				ioStream.Infof("%s %s\n", green.Sprint("PASS"), name)
+				passed++
+			case r.Err != nil:
+				ioStream.Infof("%s %s\n", red.Sprint("FAIL"), name)
+				ioStream.Infof("    %v\n", r.Err)
+				failed++
+			default:
+				ioStream.Infof("%s %s\n", red.Sprint("FAIL"), name)
+				ioStream.Infof("    rendered objects differ from %s (-golden +rendered):\n", r.Golden)
+				ioStream.Infof("    %s\n", strings.ReplaceAll(strings.TrimSpace(r.Diff), "\n", "\n    "))
+				failed++
+			}
+		}
+	}
+	ioStream.Infof("\n%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d definition test cases failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
+)
+
+func TestDefinitionTest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "def-test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "worker.cue"), []byte(`
+output: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	spec: template: spec: containers: [{name: context.name, image: parameter.image}]
+}
+parameter: image: string
+`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "worker_test.yaml"), []byte(`
+template: worker.cue
+kind: workload
+cases:
+- name: basic
+  parameter:
+    image: busybox
+  context:
+    name: backend
+`), 0600))
+	golden := filepath.Join(dir, "worker", "basic.golden.yaml")
+
+	buffer := &bytes.Buffer{}
+	ioStreams := cmdutil.IOStreams{In: os.Stdin, Out: buffer, ErrOut: buffer}
+	err = testDefinitions(ioStreams, []string{dir}, false)
+	assert.EqualError(t, err, "1 definition test cases failed")
+	assert.Contains(t, buffer.String(), "golden file "+golden+" doesn't exist")
+
+	buffer.Reset()
+	assert.NoError(t, testDefinitions(ioStreams, []string{dir}, true))
+	assert.Contains(t, buffer.String(), "UPDATE "+golden)
+
+	buffer.Reset()
+	assert.NoError(t, testDefinitions(ioStreams, []string{dir}, false))
+	assert.Contains(t, buffer.String(), "PASS worker/basic")
+	assert.Contains(t, buffer.String(), "1 passed, 0 failed")
+
+	data, err := ioutil.ReadFile(golden)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(golden, bytes.Replace(data, []byte("busybox"), []byte("nginx"), 1), 0600))
+	buffer.Reset()
+	err = testDefinitions(ioStreams, []string{dir}, false)
+	assert.EqualError(t, err, "1 definition test cases failed")
+	assert.Contains(t, buffer.String(), "FAIL worker/basic")
+	assert.Contains(t, buffer.String(), "rendered objects differ from "+golden)
+
+	err = testDefinitions(ioStreams, []string{filepath.Join(dir, "worker")}, false)
+	assert.EqualError(t, err, "no test suite (*_test.yaml) found in "+filepath.Join(dir, "worker"))
+}
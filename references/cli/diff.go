@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	corev1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
+	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
+	"github.com/oam-dev/kubevela/references/common"
+)
+
+type diffOptions struct {
+	cmdutil.IOStreams
+	file     string
+	output   string
+	exitCode bool
+}
+
+// NewDiffCommand creates `diff` command
+func NewDiffCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	o := &diffOptions{IOStreams: ioStreams}
+	cmd := &cobra.Command{
+		Use:                   "diff",
+		DisableFlagsInUseLine: true,
+		Short:                 "Diff the local application against the one running in the cluster",
+		Long: "Render the local Appfile or Application the same way as the application controller does, and diff the " +
+			"Application, ApplicationConfiguration, Components, workloads and traits against the ones running in the cluster",
+		Example: `vela diff
+vela diff -f app.yaml -o json --exit-code`,
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeStart,
+		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.SetConfig()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if o.output != "" && o.output != "json" {
+				return fmt.Errorf("unsupported output format %s, only json is supported", o.output)
+			}
+			newClient, err := c.GetClient()
+			if err != nil {
+				return err
+			}
+			dm, err := discoverymapper.New(c.Config)
+			if err != nil {
+				return err
+			}
+			velaEnv, err := GetEnv(cmd)
+			if err != nil {
+				return err
+			}
+			app, err := o.loadApplication(newClient, velaEnv, c)
+			if err != nil {
+				return err
+			}
+			if app.Namespace == "" {
+				app.Namespace = velaEnv.Namespace
+			}
+			diffs, err := common.DiffApplication(context.Background(), newClient, dm, app)
+			if err != nil {
+				return err
+			}
+			if err := o.print(diffs); err != nil {
+				return err
+			}
+			if o.exitCode && len(diffs) > 0 {
+				return fmt.Errorf("application %s differs from the one running in the cluster", app.Name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&o.file, "file", "f", "", "specify the Appfile or Application file, the Appfile in the current directory is used by default")
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "output format of the differences, support: [json]")
+	cmd.Flags().BoolVar(&o.exitCode, "exit-code", false, "exit with an error if there are differences")
+	cmd.SetOut(ioStreams.Out)
+	return cmd
+}
+
+// loadApplication loads the Application file directly, or builds the Application from the Appfile like `vela up`
+func (o *diffOptions) loadApplication(c client.Client, velaEnv *types.EnvMeta, args types.Args) (*corev1alpha2.Application, error) {
+	isApp, err := isApplicationFile(o.file)
+	if err != nil {
+		return nil, err
+	}
+	if isApp {
+		app, err := readApplicationFromFile(o.file)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "read application file: %s", o.file)
+		}
+		return app, nil
+	}
+	appfileOpts := &common.AppfileOptions{Kubecli: c, IO: o.IOStreams, Env: velaEnv}
+	result, _, err := appfileOpts.Export(o.file, velaEnv.Namespace, true, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Application(), nil
+}
+
+// isApplicationFile checks whether the file is an Application rather than an Appfile
+func isApplicationFile(filename string) (bool, error) {
+	if filename == "" {
+		return false, nil
+	}
+	switch filepath.Ext(filename) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return false, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return false, err
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+		return false, nil
+	}
+	return typeMeta.Kind == corev1alpha2.ApplicationKind && typeMeta.APIVersion == corev1alpha2.SchemeGroupVersion.String(), nil
+}
+
+func (o *diffOptions) print(diffs []common.ObjectDiff) error {
+	if o.output == "json" {
+		if diffs == nil {
+			diffs = []common.ObjectDiff{}
+		}
+		data, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return err
+		}
+		o.Info(string(data))
+		return nil
+	}
+	if len(diffs) == 0 {
+		o.Info("No differences, the application is up to date")
+		return nil
+	}
+	count := map[common.DiffType]int{}
+	for _, d := range diffs {
+		count[d.Type]++
+		o.Info(diffColor(d.Type).Sprintf("%s %s %s/%s (%s)", diffSymbol(d.Type), d.Kind, d.Namespace, d.Name, d.APIVersion))
+		for _, f := range d.Fields {
+			o.Info(diffColor(f.Type).Sprintf("    %s %s: %s", diffSymbol(f.Type), f.Path, fieldChange(f)))
+		}
+	}
+	o.Infof("\n%d to add, %d to change, %d to remove\n", count[common.DiffAdded], count[common.DiffChanged], count[common.DiffRemoved])
+	return nil
+}
+
+func fieldChange(f common.FieldDiff) string {
+	switch f.Type {
+	case common.DiffAdded:
+		return compactJSON(f.Local)
+	case common.DiffRemoved:
+		return compactJSON(f.Live)
+	default:
+		return compactJSON(f.Live) + " => " + compactJSON(f.Local)
+	}
+}
+
+func compactJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func diffSymbol(t common.DiffType) string {
+	switch t {
+	case common.DiffAdded:
+		return "+"
+	case common.DiffRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+func diffColor(t common.DiffType) *color.Color {
+	switch t {
+	case common.DiffAdded:
+		return green
+	case common.DiffRemoved:
+		return red
+	default:
+		return yellow
+	}
+}
@@ -3,12 +3,16 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 
 	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
 	"github.com/oam-dev/kubevela/pkg/utils/env"
 	"github.com/oam-dev/kubevela/pkg/utils/system"
 	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
@@ -26,21 +30,31 @@ func NewEnvCommand(c types.Args, ioStream cmdutil.IOStreams) *cobra.Command {
 		},
 	}
 	cmd.SetOut(ioStream.Out)
-	cmd.AddCommand(NewEnvListCommand(ioStream), NewEnvInitCommand(c, ioStream), NewEnvSetCommand(ioStream), NewEnvDeleteCommand(ioStream))
+	cmd.AddCommand(NewEnvListCommand(c, ioStream), NewEnvInitCommand(c, ioStream), NewEnvSetCommand(c, ioStream),
+		NewEnvDeleteCommand(c, ioStream), NewEnvMigrateCommand(c, ioStream))
 	return cmd
 }
 
 // NewEnvListCommand creates `env list` command for listing all environments
-func NewEnvListCommand(ioStream cmdutil.IOStreams) *cobra.Command {
+func NewEnvListCommand(c types.Args, ioStream cmdutil.IOStreams) *cobra.Command {
+	ctx := context.Background()
 	cmd := &cobra.Command{
 		Use:                   "ls",
 		Aliases:               []string{"list"},
 		DisableFlagsInUseLine: true,
 		Short:                 "List environments",
-		Long:                  "List all environments",
-		Example:               `vela env ls [env-name]`,
+		Long: "List all environments, including the ones stored in the cluster and the ones stored locally by older " +
+			"versions of vela, run `vela env migrate` to store the local ones in the cluster",
+		Example: `vela env ls [env-name]`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.SetConfig()
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return ListEnvs(args, ioStream)
+			newClient, err := c.GetClient()
+			if err != nil {
+				return err
+			}
+			return ListEnvs(ctx, newClient, args, ioStream)
 		},
 		Annotations: map[string]string{
 			types.TagCommandType: types.TypeStart,
@@ -53,13 +67,14 @@ func NewEnvListCommand(ioStream cmdutil.IOStreams) *cobra.Command {
 // NewEnvInitCommand creates `env init` command for initializing environments
 func NewEnvInitCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
 	var envArgs types.EnvMeta
+	var policyFile string
 	ctx := context.Background()
 	cmd := &cobra.Command{
 		Use:                   "init <envName>",
 		DisableFlagsInUseLine: true,
 		Short:                 "Create environments",
 		Long:                  "Create environment and set the currently using environment",
-		Example:               `vela env init test --namespace test --email my@email.com`,
+		Example:               `vela env init test --namespace test --email my@email.com --policy policy.yaml`,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			return c.SetConfig()
 		},
@@ -68,6 +83,11 @@ func NewEnvInitCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command
 			if err != nil {
 				return err
 			}
+			if policyFile != "" {
+				if envArgs.Policy, err = LoadEnvPolicy(policyFile); err != nil {
+					return err
+				}
+			}
 
 			return CreateOrUpdateEnv(ctx, newClient, &envArgs, args, ioStreams)
 		},
@@ -79,11 +99,12 @@ func NewEnvInitCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command
 	cmd.Flags().StringVar(&envArgs.Namespace, "namespace", "", "specify K8s namespace for env")
 	cmd.Flags().StringVar(&envArgs.Email, "email", "", "specify email for production TLS Certificate notification")
 	cmd.Flags().StringVar(&envArgs.Domain, "domain", "", "specify domain your applications")
+	cmd.Flags().StringVar(&policyFile, "policy", "", "specify the policy file of env, including the default traits, resource quota, limit range, namespace labels and annotations, and the allowed workload and trait types")
 	return cmd
 }
 
 // NewEnvDeleteCommand creates `env delete` command for deleting environments
-func NewEnvDeleteCommand(ioStreams cmdutil.IOStreams) *cobra.Command {
+func NewEnvDeleteCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
 	ctx := context.Background()
 	cmd := &cobra.Command{
 		Use:                   "delete",
@@ -91,8 +112,15 @@ func NewEnvDeleteCommand(ioStreams cmdutil.IOStreams) *cobra.Command {
 		Short:                 "Delete environment",
 		Long:                  "Delete environment",
 		Example:               `vela env delete test`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.SetConfig()
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return DeleteEnv(ctx, args, ioStreams)
+			newClient, err := c.GetClient()
+			if err != nil {
+				return err
+			}
+			return DeleteEnv(ctx, newClient, args, ioStreams)
 		},
 		Annotations: map[string]string{
 			types.TagCommandType: types.TypeStart,
@@ -103,16 +131,52 @@ func NewEnvDeleteCommand(ioStreams cmdutil.IOStreams) *cobra.Command {
 }
 
 // NewEnvSetCommand creates `env set` command for setting current environment
-func NewEnvSetCommand(ioStreams cmdutil.IOStreams) *cobra.Command {
+func NewEnvSetCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	ctx := context.Background()
 	cmd := &cobra.Command{
 		Use:                   "set",
 		Aliases:               []string{"sw"},
 		DisableFlagsInUseLine: true,
 		Short:                 "Set an environment",
-		Long:                  "Set an environment as the current using one",
+		Long:                  "Set an environment as the current using one, it also refreshes the locally cached current environment",
 		Example:               `vela env set test`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.SetConfig()
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return SetEnv(args, ioStreams)
+			newClient, err := c.GetClient()
+			if err != nil {
+				return err
+			}
+			return SetEnv(ctx, newClient, args, ioStreams)
+		},
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeStart,
+		},
+	}
+	cmd.SetOut(ioStreams.Out)
+	return cmd
+}
+
+// NewEnvMigrateCommand creates `env migrate` command for storing the local environments in the cluster
+func NewEnvMigrateCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	ctx := context.Background()
+	cmd := &cobra.Command{
+		Use:                   "migrate",
+		DisableFlagsInUseLine: true,
+		Short:                 "Migrate local environments to the cluster",
+		Long: "Store the environments created by older versions of vela in the local vela home directory in the " +
+			"cluster, so that they are shared by everyone using the cluster",
+		Example: `vela env migrate`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.SetConfig()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			newClient, err := c.GetClient()
+			if err != nil {
+				return err
+			}
+			return MigrateEnvs(ctx, newClient, ioStreams)
 		},
 		Annotations: map[string]string{
 			types.TagCommandType: types.TypeStart,
@@ -123,31 +187,74 @@ func NewEnvSetCommand(ioStreams cmdutil.IOStreams) *cobra.Command {
 }
 
 // ListEnvs shows info of all environments
-func ListEnvs(args []string, ioStreams cmdutil.IOStreams) error {
+func ListEnvs(ctx context.Context, c client.Reader, args []string, ioStreams cmdutil.IOStreams) error {
 	table := newUITable()
-	table.AddRow("NAME", "CURRENT", "NAMESPACE", "EMAIL", "DOMAIN")
+	table.AddRow("NAME", "CURRENT", "NAMESPACE", "EMAIL", "DOMAIN", "SOURCE")
 	var envName = ""
 	if len(args) > 0 {
 		envName = args[0]
 	}
-	envList, err := env.ListEnvs(envName)
+	envList, err := env.ListEnvs(ctx, c, envName)
 	if err != nil {
 		return err
 	}
+	var local bool
 	for _, env := range envList {
-		table.AddRow(env.Name, env.Current, env.Namespace, env.Email, env.Domain)
+		table.AddRow(env.Name, env.Current, env.Namespace, env.Email, env.Domain, env.Source)
+		local = local || env.Source == types.EnvSourceLocal
 	}
 	ioStreams.Info(table.String())
+	if envName != "" && len(envList) == 1 && envList[0].Policy != nil {
+		policy, err := yaml.Marshal(envList[0].Policy)
+		if err != nil {
+			return err
+		}
+		ioStreams.Infof("\nPolicy:\n%s", policy)
+	}
+	if local {
+		ioStreams.Info("\nThe local environments are only visible to you, run `vela env migrate` to share them with everyone using the cluster")
+	}
 	return nil
 }
 
+// MigrateEnvs stores the local environments in the cluster
+func MigrateEnvs(ctx context.Context, c client.Client, ioStreams cmdutil.IOStreams) error {
+	migrated, conflicted, err := env.MigrateEnvs(ctx, c)
+	for _, name := range migrated {
+		ioStreams.Infof("environment %s migrated\n", name)
+	}
+	for _, name := range conflicted {
+		ioStreams.Infof("environment %s already exists in the cluster, the local one in %s is kept but not used\n", name, env.GetEnvDirByName(name))
+	}
+	if err != nil {
+		return err
+	}
+	if len(migrated) == 0 && len(conflicted) == 0 {
+		ioStreams.Info("no local environment to migrate")
+	}
+	return nil
+}
+
+// LoadEnvPolicy loads the policy of an environment from a YAML or JSON file
+func LoadEnvPolicy(path string) (*types.EnvPolicy, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	policy := &types.EnvPolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return policy, nil
+}
+
 // DeleteEnv deletes an environment
-func DeleteEnv(ctx context.Context, args []string, ioStreams cmdutil.IOStreams) error {
+func DeleteEnv(ctx context.Context, c client.Client, args []string, ioStreams cmdutil.IOStreams) error {
 	if len(args) < 1 {
 		return fmt.Errorf("you must specify environment name for 'vela env delete' command")
 	}
 	for _, envName := range args {
-		msg, err := env.DeleteEnv(envName)
+		msg, err := env.DeleteEnv(ctx, c, envName)
 		if err != nil {
 			return err
 		}
@@ -172,12 +279,12 @@ func CreateOrUpdateEnv(ctx context.Context, c client.Client, envArgs *types.EnvM
 }
 
 // SetEnv sets current environment
-func SetEnv(args []string, ioStreams cmdutil.IOStreams) error {
+func SetEnv(ctx context.Context, c client.Reader, args []string, ioStreams cmdutil.IOStreams) error {
 	if len(args) < 1 {
 		return fmt.Errorf("you must specify environment name for vela env command")
 	}
 	envName := args[0]
-	msg, err := env.SetEnv(envName)
+	msg, err := env.SetEnv(ctx, c, envName)
 	if err != nil {
 		return err
 	}
@@ -189,14 +296,21 @@ func SetEnv(args []string, ioStreams cmdutil.IOStreams) error {
 // if no env exists, then init default environment
 func GetEnv(cmd *cobra.Command) (*types.EnvMeta, error) {
 	var envName string
-	var err error
 	if cmd != nil {
 		envName = cmd.Flag("env").Value.String()
 	}
 	if envName != "" {
-		return env.GetEnvByName(envName)
+		// the current env is cached locally, the others are loaded from the cluster
+		if cur, err := env.GetCurrentEnv(); err == nil && cur.Name == envName {
+			return cur, nil
+		}
+		c, err := (&types.Args{Schema: common.Scheme}).GetClient()
+		if err != nil {
+			return nil, err
+		}
+		return env.GetEnvByName(context.Background(), c, envName)
 	}
-	envName, err = env.GetCurrentEnvName()
+	envMeta, err := env.GetCurrentEnv()
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return nil, err
@@ -204,7 +318,7 @@ func GetEnv(cmd *cobra.Command) (*types.EnvMeta, error) {
 		if err = system.InitDefaultEnv(); err != nil {
 			return nil, err
 		}
-		envName = types.DefaultEnvName
+		return env.GetCurrentEnv()
 	}
-	return env.GetEnvByName(envName)
+	return envMeta, nil
 }
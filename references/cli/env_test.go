@@ -3,15 +3,18 @@ package cli
 import (
 	"bytes"
 	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
-	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
 	"github.com/oam-dev/kubevela/pkg/utils/env"
 	"github.com/oam-dev/kubevela/pkg/utils/system"
 	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
@@ -45,7 +48,7 @@ func TestENV(t *testing.T) {
 		Namespace: "test1",
 		Name:      "env1",
 	}
-	client := test.NewMockClient()
+	client := fake.NewFakeClientWithScheme(common.Scheme)
 	// Create env1
 	err = CreateOrUpdateEnv(ctx, client, exp, []string{"env1"}, ioStream)
 	assert.NoError(t, err)
@@ -56,26 +59,47 @@ func TestENV(t *testing.T) {
 	assert.Equal(t, "env1", curEnvName)
 	gotEnv, err = GetEnv(nil)
 	assert.NoError(t, err)
-	assert.Equal(t, exp, gotEnv)
+	assert.Equal(t, &types.EnvMeta{Namespace: "test1", Name: "env1", Source: types.EnvSourceCluster}, gotEnv)
+
+	// an env created by older versions of vela
+	legacyDir := env.GetEnvDirByName("legacy")
+	assert.NoError(t, os.MkdirAll(legacyDir, 0750))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(legacyDir, system.EnvConfigName), []byte(`{"name":"legacy","namespace":"test2"}`), 0600))
 
 	// List all env
 	var b bytes.Buffer
 	ioStream.Out = &b
-	err = ListEnvs([]string{}, ioStream)
+	err = ListEnvs(ctx, client, []string{}, ioStream)
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME   \tCURRENT\tNAMESPACE\tEMAIL\tDOMAIN\tSOURCE  \n"+
+		"default\t       \tdefault  \t     \t      \tbuilt-in\n"+
+		"env1   \t*      \ttest1    \t     \t      \tcluster \n"+
+		"legacy \t       \ttest2    \t     \t      \tlocal   \n"+
+		"\nThe local environments are only visible to you, run `vela env migrate` to share them with everyone using the cluster\n", b.String())
+	b.Reset()
+	err = ListEnvs(ctx, client, []string{"env1"}, ioStream)
 	assert.NoError(t, err)
-	assert.Equal(t, "NAME   \tCURRENT\tNAMESPACE\tEMAIL\tDOMAIN\ndefault\t       \tdefault  \t     \t      \nenv1   \t*      \ttest1    \t     \t      \n", b.String())
+	assert.Equal(t, "NAME\tCURRENT\tNAMESPACE\tEMAIL\tDOMAIN\tSOURCE \nenv1\t       \ttest1    \t     \t      \tcluster\n", b.String())
+
+	// migrate the local env
+	b.Reset()
+	assert.NoError(t, MigrateEnvs(ctx, client, ioStream))
+	assert.Equal(t, "environment legacy migrated\n", b.String())
+	b.Reset()
+	assert.NoError(t, MigrateEnvs(ctx, client, ioStream))
+	assert.Equal(t, "no local environment to migrate\n", b.String())
 	b.Reset()
-	err = ListEnvs([]string{"env1"}, ioStream)
+	err = ListEnvs(ctx, client, []string{"legacy"}, ioStream)
 	assert.NoError(t, err)
-	assert.Equal(t, "NAME\tCURRENT\tNAMESPACE\tEMAIL\tDOMAIN\nenv1\t       \ttest1    \t     \t      \n", b.String())
+	assert.Contains(t, b.String(), "cluster")
 	ioStream.Out = os.Stdout
 
 	// can not delete current env
-	err = DeleteEnv(ctx, []string{"env1"}, ioStream)
+	err = DeleteEnv(ctx, client, []string{"env1"}, ioStream)
 	assert.Error(t, err)
 
 	// set as default env
-	err = SetEnv([]string{"default"}, ioStream)
+	err = SetEnv(ctx, client, []string{"default"}, ioStream)
 	assert.NoError(t, err)
 
 	// check env set success
@@ -84,18 +108,21 @@ func TestENV(t *testing.T) {
 	assert.Equal(t, &types.EnvMeta{
 		Namespace: "default",
 		Name:      "default",
+		Source:    types.EnvSourceBuiltin,
 	}, gotEnv)
 
 	// delete env
-	err = DeleteEnv(ctx, []string{"env1"}, ioStream)
+	err = DeleteEnv(ctx, client, []string{"env1"}, ioStream)
 	assert.NoError(t, err)
+	err = DeleteEnv(ctx, client, []string{"env1"}, ioStream)
+	assert.EqualError(t, err, "env1 does not exist")
 
 	// can not set as a non-exist env
-	err = SetEnv([]string{"env1"}, ioStream)
+	err = SetEnv(ctx, client, []string{"env1"}, ioStream)
 	assert.Error(t, err)
 
 	// set success
-	err = SetEnv([]string{"default"}, ioStream)
+	err = SetEnv(ctx, client, []string{"legacy"}, ioStream)
 	assert.NoError(t, err)
 }
 
@@ -105,3 +132,32 @@ func TestEnvInitCommandPersistentPreRunE(t *testing.T) {
 	cmd := NewEnvInitCommand(fakeC, io)
 	assert.Nil(t, cmd.PersistentPreRunE(new(cobra.Command), []string{}))
 }
+
+func TestLoadEnvPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vela-env-policy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	policyFile := filepath.Join(dir, "policy.yaml")
+	assert.NoError(t, ioutil.WriteFile(policyFile, []byte(`
+defaultTraits:
+  - name: scaler
+    properties:
+      replicas: 2
+namespaceLabels:
+  stage: production
+allowedWorkloads:
+  - webservice
+`), 0600))
+	policy, err := LoadEnvPolicy(policyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.EnvPolicy{
+		DefaultTraits:    []types.EnvTrait{{Name: "scaler", Properties: map[string]interface{}{"replicas": float64(2)}}},
+		NamespaceLabels:  map[string]string{"stage": "production"},
+		AllowedWorkloads: []string{"webservice"},
+	}, policy)
+
+	assert.NoError(t, ioutil.WriteFile(policyFile, []byte(`allowedWorkload: [webservice]`), 0600))
+	_, err = LoadEnvPolicy(policyFile)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid policy file")
+}
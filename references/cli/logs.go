@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"sync"
 	"text/template"
 	"time"
 
@@ -12,11 +13,19 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/wercker/stern/stern"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
 	"github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
 	"github.com/oam-dev/kubevela/pkg/utils/common"
 	"github.com/oam-dev/kubevela/pkg/utils/util"
 	"github.com/oam-dev/kubevela/references/appfile"
@@ -28,7 +37,11 @@ func NewLogsCommand(c types.Args, ioStreams util.IOStreams) *cobra.Command {
 	cmd := &cobra.Command{}
 	cmd.Use = "logs"
 	cmd.Short = "Tail logs for application"
-	cmd.Long = "Tail logs for application"
+	cmd.Long = "Tail logs for application, the pods are selected by the labels of the application and its components, and by the pod selectors of the workloads"
+	cmd.Example = `vela logs myapp
+vela logs myapp --component frontend --component backend --since 10m --tail 100
+vela logs myapp --all -c sidecar
+vela logs myapp --revision myapp-v2`
 	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		if err := c.SetConfig(); err != nil {
 			return err
@@ -61,6 +74,12 @@ func NewLogsCommand(c types.Args, ioStreams util.IOStreams) *cobra.Command {
 		types.TagCommandType: types.TypeApp,
 	}
 	cmd.Flags().StringVarP(&largs.Output, "output", "o", "default", "output format for logs, support: [default, raw, json]")
+	cmd.Flags().StringSliceVar(&largs.Components, "component", nil, "specify the components to tail, you will be asked to choose one if not specified")
+	cmd.Flags().BoolVarP(&largs.All, "all", "a", false, "tail the logs of all the components of the application")
+	cmd.Flags().StringVarP(&largs.Container, "container", "c", ".*", "regular expression of the container names to tail")
+	cmd.Flags().DurationVar(&largs.Since, "since", 48*time.Hour, "only return logs newer than a relative duration like 5s, 2m or 3h")
+	cmd.Flags().Int64Var(&largs.Tail, "tail", -1, "the number of recent lines of each container to show, all lines are shown by default")
+	cmd.Flags().StringVar(&largs.Revision, "revision", "", "only tail the pods of the given revision of the application, e.g. myapp-v2")
 	return cmd
 }
 
@@ -70,6 +89,14 @@ type Args struct {
 	Env    *types.EnvMeta
 	C      types.Args
 	App    *v1alpha2.Application
+
+	// Components are the components to tail, the user is asked to choose one if it's empty and All is false
+	Components []string
+	All        bool
+	Container  string
+	Since      time.Duration
+	Tail       int64
+	Revision   string
 }
 
 // Run refer to the implementation at https://github.com/oam-dev/stern/blob/master/stern/main.go
@@ -79,23 +106,24 @@ func (l *Args) Run(ctx context.Context, ioStreams util.IOStreams) error {
 	if err != nil {
 		return err
 	}
-	compName, err := common.AskToChooseOneService(appfile.GetComponents(l.App))
+	newClient, err := l.C.GetClient()
 	if err != nil {
 		return err
 	}
-	// TODO(wonderflow): we could get labels from service to narrow the pods scope selected
-	labelSelector := labels.Everything()
-	pod, err := regexp.Compile(compName + "-.*")
+	compNames, err := l.components()
 	if err != nil {
-		return fmt.Errorf("fail to compile '%s' for logs query", compName+".*")
+		return err
 	}
-	container := regexp.MustCompile(".*")
-	namespace := l.Env.Namespace
-	added, removed, err := stern.Watch(ctx, clientSet.CoreV1().Pods(namespace), pod, container, nil, stern.RUNNING, labelSelector)
+	selectors, err := l.podSelectors(ctx, newClient, compNames)
 	if err != nil {
 		return err
 	}
-	tails := make(map[string]*stern.Tail)
+	pod := regexp.MustCompile(".*")
+	container, err := regexp.Compile(l.Container)
+	if err != nil {
+		return fmt.Errorf("fail to compile '%s' for logs query", l.Container)
+	}
+	namespace := l.Env.Namespace
 	logC := make(chan string, 1024)
 
 	go func() {
@@ -138,41 +166,249 @@ func (l *Args) Run(ctx context.Context, ioStreams util.IOStreams) error {
 	if err != nil {
 		return errors.Wrap(err, "unable to parse template")
 	}
+	tailOpts := &stern.TailOptions{
+		Timestamps:   true,
+		SinceSeconds: int64(l.Since.Seconds()),
+		Exclude:      nil,
+		Include:      nil,
+		Namespace:    false,
+		TailLines:    nil, // default for all logs
+	}
+	if l.Tail >= 0 {
+		tailOpts.TailLines = &l.Tail
+	}
 
-	go func() {
-		for p := range added {
-			id := p.GetID()
-			if tails[id] != nil {
-				continue
+	var mu sync.Mutex
+	tails := make(map[string]*stern.Tail)
+	for _, selector := range selectors {
+		added, removed, err := stern.Watch(ctx, clientSet.CoreV1().Pods(namespace), pod, container, nil, stern.RUNNING, selector)
+		if err != nil {
+			return err
+		}
+		go func() {
+			for p := range added {
+				id := p.GetID()
+				mu.Lock()
+				if tails[id] != nil {
+					mu.Unlock()
+					continue
+				}
+				tail := stern.NewTail(p.Namespace, p.Pod, p.Container, template, tailOpts)
+				tails[id] = tail
+				mu.Unlock()
+
+				tail.Start(ctx, clientSet.CoreV1().Pods(p.Namespace), logC)
 			}
-			// 48h
-			dur, _ := time.ParseDuration("48h")
-			tail := stern.NewTail(p.Namespace, p.Pod, p.Container, template, &stern.TailOptions{
-				Timestamps:   true,
-				SinceSeconds: int64(dur.Seconds()),
-				Exclude:      nil,
-				Include:      nil,
-				Namespace:    false,
-				TailLines:    nil, // default for all logs
-			})
-			tails[id] = tail
-
-			tail.Start(ctx, clientSet.CoreV1().Pods(p.Namespace), logC)
+		}()
+
+		go func() {
+			for p := range removed {
+				id := p.GetID()
+				mu.Lock()
+				if tails[id] != nil {
+					tails[id].Close()
+					delete(tails, id)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	<-ctx.Done()
+
+	return nil
+}
+
+// components returns the names of the components to tail
+func (l *Args) components() ([]string, error) {
+	all := appfile.GetComponents(l.App)
+	if l.All {
+		return all, nil
+	}
+	if len(l.Components) == 0 {
+		compName, err := common.AskToChooseOneService(all)
+		if err != nil {
+			return nil, err
 		}
-	}()
+		return []string{compName}, nil
+	}
+	for _, name := range l.Components {
+		if !stringInSlice(name, all) {
+			return nil, fmt.Errorf("component %s not found in application %s", name, l.App.Name)
+		}
+	}
+	return l.Components, nil
+}
 
-	go func() {
-		for p := range removed {
-			id := p.GetID()
-			if tails[id] == nil {
+// podSelectors builds the label selectors of the pods to tail. The pods are selected by the application and component
+// labels unless a revision is specified, in which case they're selected by the workloads of that revision.
+func (l *Args) podSelectors(ctx context.Context, c client.Reader, compNames []string) ([]labels.Selector, error) {
+	if l.Revision == "" {
+		return l.latestPodSelectors(ctx, c, compNames)
+	}
+
+	ac := &v1alpha2.ApplicationConfiguration{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: l.Env.Namespace, Name: l.Revision}, ac); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("revision %s of application %s not found", l.Revision, l.App.Name)
+		}
+		return nil, err
+	}
+	if ac.Labels[oam.LabelAppName] != l.App.Name {
+		return nil, fmt.Errorf("revision %s doesn't belong to application %s", l.Revision, l.App.Name)
+	}
+	var selectors []labels.Selector
+	err := forEachWorkload(ctx, c, ac, compNames, func(workload *unstructured.Unstructured, compName string) error {
+		selector, err := workloadPodSelector(ctx, c, workload, l.App.Name, compName)
+		if err != nil {
+			return err
+		}
+		if selector != nil {
+			selectors = append(selectors, selector)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(selectors) == 0 {
+		return nil, fmt.Errorf("no running workload of the components %v found in revision %s", compNames, l.Revision)
+	}
+	return selectors, nil
+}
+
+// latestPodSelectors selects the pods by the application and component labels. Not every workload labels its pods,
+// e.g. tasks, Helm charts and custom workloads don't, so the pods are also selected by the pod selectors of the
+// workloads in the latest revision. A pod matched by several selectors is only tailed once.
+func (l *Args) latestPodSelectors(ctx context.Context, c client.Reader, compNames []string) ([]labels.Selector, error) {
+	selector, err := appPodSelector(l.App.Name, compNames...)
+	if err != nil {
+		return nil, err
+	}
+	selectors := []labels.Selector{selector}
+	if l.App.Status.LatestRevision == nil {
+		return selectors, nil
+	}
+	ac := &v1alpha2.ApplicationConfiguration{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: l.Env.Namespace, Name: l.App.Status.LatestRevision.Name}, ac); err != nil {
+		if apierrors.IsNotFound(err) {
+			return selectors, nil
+		}
+		return nil, err
+	}
+	err = forEachWorkload(ctx, c, ac, compNames, func(workload *unstructured.Unstructured, compName string) error {
+		selector, found, err := specPodSelector(workload)
+		if err != nil {
+			return err
+		}
+		if found {
+			selectors = append(selectors, selector)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return selectors, nil
+}
+
+// forEachWorkload calls fn with the existing workloads of the given components recorded in the AppConfig
+func forEachWorkload(ctx context.Context, c client.Reader, ac *v1alpha2.ApplicationConfiguration, compNames []string,
+	fn func(workload *unstructured.Unstructured, compName string) error) error {
+	for _, w := range ac.Status.Workloads {
+		if !stringInSlice(w.ComponentName, compNames) {
+			continue
+		}
+		workload := &unstructured.Unstructured{}
+		workload.SetAPIVersion(w.Reference.APIVersion)
+		workload.SetKind(w.Reference.Kind)
+		if err := c.Get(ctx, client.ObjectKey{Namespace: ac.Namespace, Name: w.Reference.Name}, workload); err != nil {
+			if apierrors.IsNotFound(err) {
 				continue
 			}
-			tails[id].Close()
-			delete(tails, id)
+			return errors.Wrapf(err, "get workload %s of component %s", w.Reference.Name, w.ComponentName)
 		}
-	}()
+		if err := fn(workload, w.ComponentName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	<-ctx.Done()
+// appPodSelector selects the pods of the given components of the application
+func appPodSelector(appName string, compNames ...string) (labels.Selector, error) {
+	appReq, err := labels.NewRequirement(oam.LabelAppName, selection.Equals, []string{appName})
+	if err != nil {
+		return nil, err
+	}
+	compReq, err := labels.NewRequirement(oam.LabelAppComponent, selection.In, compNames)
+	if err != nil {
+		return nil, err
+	}
+	return labels.NewSelector().Add(*appReq, *compReq), nil
+}
 
-	return nil
+// workloadPodSelector selects the pods of the workload by its pod selector. The pods of a Deployment are further
+// narrowed down to the ReplicaSets it owns, so the Deployments of different revisions sharing the same pod selector
+// can be told apart. It returns nil if the Deployment has no ReplicaSet yet.
+func workloadPodSelector(ctx context.Context, c client.Reader, workload *unstructured.Unstructured, appName, compName string) (labels.Selector, error) {
+	selector, found, err := specPodSelector(workload)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return appPodSelector(appName, compName)
+	}
+	if workload.GetAPIVersion() != appsv1.SchemeGroupVersion.String() || workload.GetKind() != "Deployment" {
+		return selector, nil
+	}
+
+	rsList := &appsv1.ReplicaSetList{}
+	if err := c.List(ctx, rsList, client.InNamespace(workload.GetNamespace()), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	var hashes []string
+	for i := range rsList.Items {
+		rs := &rsList.Items[i]
+		if !metav1.IsControlledBy(rs, workload) {
+			continue
+		}
+		if hash, ok := rs.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	hashReq, err := labels.NewRequirement(appsv1.DefaultDeploymentUniqueLabelKey, selection.In, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return selector.Add(*hashReq), nil
+}
+
+// specPodSelector returns the pod selector in the spec of the workload, found is false if the workload has none
+func specPodSelector(workload *unstructured.Unstructured) (selector labels.Selector, found bool, err error) {
+	podSelector, found, err := unstructured.NestedMap(workload.Object, "spec", "selector")
+	if err != nil || !found {
+		return nil, false, err
+	}
+	labelSelector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(podSelector, labelSelector); err != nil {
+		return nil, false, errors.Wrapf(err, "invalid pod selector of workload %s", workload.GetName())
+	}
+	selector, err = metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "invalid pod selector of workload %s", workload.GetName())
+	}
+	return selector, true, nil
+}
+
+func stringInSlice(s string, list []string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
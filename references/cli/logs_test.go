@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	velatypes "github.com/oam-dev/kubevela/apis/types"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestLogsComponents(t *testing.T) {
+	app := &v1alpha2.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "myapp"},
+		Spec: v1alpha2.ApplicationSpec{Components: []v1alpha2.ApplicationComponent{
+			{Name: "frontend"}, {Name: "backend"},
+		}},
+	}
+	testCases := map[string]struct {
+		args    Args
+		want    []string
+		wantErr bool
+	}{
+		"all": {
+			args: Args{All: true},
+			want: []string{"backend", "frontend"},
+		},
+		"specified": {
+			args: Args{Components: []string{"frontend"}},
+			want: []string{"frontend"},
+		},
+		"not found": {
+			args:    Args{Components: []string{"frontend", "db"}},
+			wantErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.args.App = app
+			got, err := tc.args.components()
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestLogsPodSelectors(t *testing.T) {
+	ctx := context.Background()
+	app := &v1alpha2.Application{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"}}
+	app.Status.LatestRevision = &v1alpha2.Revision{Name: "myapp-v2"}
+	env := &velatypes.EnvMeta{Name: "default", Namespace: "default"}
+	podLabels := map[string]string{oam.LabelAppComponent: "frontend"}
+	deploy := func(name, uid string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(uid)},
+			Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: podLabels}},
+		}
+	}
+	replicaSet := func(name, hash string, owner *appsv1.Deployment) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{oam.LabelAppComponent: "frontend", appsv1.DefaultDeploymentUniqueLabelKey: hash},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1", Kind: "Deployment", Name: owner.Name, UID: owner.UID, Controller: pointer.BoolPtr(true),
+			}},
+		}}
+	}
+	v1, v2 := deploy("frontend-v1", "uid-1"), deploy("frontend-v2", "uid-2")
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "pi-v2", Namespace: "default"},
+		Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "uid-3"}}},
+	}
+	ac := &v1alpha2.ApplicationConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "myapp-v2", Namespace: "default", Labels: map[string]string{oam.LabelAppName: "myapp"}},
+		Status: v1alpha2.ApplicationConfigurationStatus{Workloads: []v1alpha2.WorkloadStatus{
+			{ComponentName: "frontend", Reference: runtimev1alpha1.TypedReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "frontend-v2"}},
+			{ComponentName: "backend", Reference: runtimev1alpha1.TypedReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "backend-v2"}},
+			{ComponentName: "pi", Reference: runtimev1alpha1.TypedReference{APIVersion: "batch/v1", Kind: "Job", Name: "pi-v2"}},
+		}},
+	}
+	otherAC := &v1alpha2.ApplicationConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-v1", Namespace: "default", Labels: map[string]string{oam.LabelAppName: "other"}},
+	}
+	c := fake.NewFakeClientWithScheme(common.Scheme, v1, v2, replicaSet("frontend-v1-a", "a", v1), replicaSet("frontend-v2-b", "b", v2), job, ac, otherAC)
+
+	testCases := map[string]struct {
+		compNames []string
+		revision  string
+		want      []string
+		wantErr   bool
+	}{
+		"by labels": {
+			compNames: []string{"frontend"},
+			want: []string{
+				"app.oam.dev/component in (frontend),app.oam.dev/name=myapp",
+				"app.oam.dev/component=frontend",
+			},
+		},
+		"by labels and the pod selector of the job": {
+			compNames: []string{"frontend", "pi"},
+			want: []string{
+				"app.oam.dev/component in (frontend,pi),app.oam.dev/name=myapp",
+				"app.oam.dev/component=frontend",
+				"controller-uid=uid-3",
+			},
+		},
+		"by revision": {
+			compNames: []string{"frontend"},
+			revision:  "myapp-v2",
+			want:      []string{"app.oam.dev/component=frontend,pod-template-hash in (b)"},
+		},
+		"revision not found": {
+			compNames: []string{"frontend"},
+			revision:  "myapp-v3",
+			wantErr:   true,
+		},
+		"revision of another app": {
+			compNames: []string{"frontend"},
+			revision:  "other-v1",
+			wantErr:   true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			l := &Args{App: app, Env: env, Revision: tc.revision}
+			selectors, err := l.podSelectors(ctx, c, tc.compNames)
+			assert.Equal(t, tc.wantErr, err != nil)
+			var got []string
+			for _, s := range selectors {
+				got = append(got, s.String())
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	selector, err := appPodSelector("myapp", "frontend")
+	assert.NoError(t, err)
+	assert.True(t, selector.Matches(labels.Set{oam.LabelAppName: "myapp", oam.LabelAppComponent: "frontend", "app": "web"}))
+	assert.False(t, selector.Matches(labels.Set{oam.LabelAppName: "myapp", oam.LabelAppComponent: "backend"}))
+}
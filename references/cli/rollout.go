@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/apis/types"
+	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
+	"github.com/oam-dev/kubevela/references/common"
+)
+
+// NewRolloutCommand creates `rollout` command and its nested children
+func NewRolloutCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "rollout",
+		DisableFlagsInUseLine: true,
+		Short:                 "Manage application rollouts",
+		Long:                  "Manage application rollouts",
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeApp,
+		},
+	}
+	cmd.SetOut(ioStreams.Out)
+	cmd.AddCommand(
+		newRolloutDecisionCommand(c, ioStreams, "approve", v1alpha1.BatchApproved),
+		newRolloutDecisionCommand(c, ioStreams, "reject", v1alpha1.BatchRejected),
+	)
+	return cmd
+}
+
+// newRolloutDecisionCommand creates the command that approves or rejects the current batch of a rollout
+func newRolloutDecisionCommand(c types.Args, ioStreams cmdutil.IOStreams, verb string,
+	decision v1alpha1.BatchApprovalDecision) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   verb + " APP_NAME",
+		DisableFlagsInUseLine: true,
+		Short:                 "Mark the current rollout batch of an application as " + string(decision),
+		Long:                  "Mark the current rollout batch of an application as " + string(decision),
+		Example:               "vela rollout " + verb + " frontend",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.SetConfig()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("must specify name for the app")
+			}
+			newClient, err := c.GetClient()
+			if err != nil {
+				return err
+			}
+			env, err := GetEnv(cmd)
+			if err != nil {
+				return err
+			}
+			msg, err := common.DecideRolloutBatch(context.Background(), newClient, env.Namespace, args[0], decision)
+			if err != nil {
+				return err
+			}
+			ioStreams.Info(msg)
+			return nil
+		},
+		Annotations: map[string]string{
+			types.TagCommandType: types.TypeApp,
+		},
+	}
+	cmd.SetOut(ioStreams.Out)
+	return cmd
+}
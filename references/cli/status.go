@@ -10,6 +10,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
@@ -72,11 +73,15 @@ const (
 // NewAppStatusCommand creates `status` command for showing status
 func NewAppStatusCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Command {
 	ctx := context.Background()
+	var output string
+	var watch bool
 	cmd := &cobra.Command{
-		Use:     "status APP_NAME",
-		Short:   "Show status of an application",
-		Long:    "Show status of an application, including workloads and traits of each service.",
-		Example: `vela status APP_NAME`,
+		Use:   "status APP_NAME",
+		Short: "Show status of an application",
+		Long:  "Show status of an application, including workloads and traits of each service, and the recent events of them.",
+		Example: `vela status APP_NAME
+vela status APP_NAME -o json
+vela status APP_NAME --watch -o yaml`,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			return c.SetConfig()
 		},
@@ -86,16 +91,35 @@ func NewAppStatusCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Comma
 				ioStreams.Errorf("Hint: please specify an application")
 				os.Exit(1)
 			}
+			if output != "" && output != "json" && output != "yaml" {
+				return fmt.Errorf("unsupported output format %s, support: [json, yaml]", output)
+			}
 			appName := args[0]
 			env, err := GetEnv(cmd)
 			if err != nil {
 				ioStreams.Errorf("Error: failed to get Env: %s", err)
 				return err
 			}
+			if watch {
+				informerCache, err := cache.New(c.Config, cache.Options{Scheme: c.Schema, Namespace: env.Namespace})
+				if err != nil {
+					return err
+				}
+				return watchAppStatus(ctx, informerCache, informerCache, env.Namespace, appName, func(report *AppStatusReport) error {
+					return printStatusReport(ioStreams, report, output, true)
+				})
+			}
 			newClient, err := c.GetClient()
 			if err != nil {
 				return err
 			}
+			if output != "" {
+				report, err := buildAppStatusReport(ctx, newClient, env.Namespace, appName)
+				if err != nil {
+					return err
+				}
+				return printStatusReport(ioStreams, report, output, false)
+			}
 			return printAppStatus(ctx, newClient, ioStreams, appName, env, cmd, c)
 		},
 		Annotations: map[string]string{
@@ -103,6 +127,8 @@ func NewAppStatusCommand(c types.Args, ioStreams cmdutil.IOStreams) *cobra.Comma
 		},
 	}
 	cmd.Flags().StringP("svc", "s", "", "service name")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output format of the status, support: [json, yaml]")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "watch the application and print its status every time it changes")
 	cmd.SetOut(ioStreams.Out)
 	return cmd
 }
@@ -122,7 +148,20 @@ func printAppStatus(ctx context.Context, c client.Client, ioStreams cmdutil.IOSt
 	cmd.Printf("%s\n\n", table.String())
 
 	cmd.Printf("Services:\n\n")
-	return loopCheckStatus(ctx, c, ioStreams, appName, env)
+	if err := loopCheckStatus(ctx, c, ioStreams, appName, env); err != nil {
+		return err
+	}
+	remoteApp, err := loadRemoteApplication(c, env.Namespace, appName)
+	if err != nil {
+		return err
+	}
+	events, err := getAppEvents(ctx, c, remoteApp)
+	if err != nil {
+		return err
+	}
+	ioStreams.Info("")
+	printEvents(ioStreams, events)
+	return nil
 }
 
 func loadRemoteApplication(c client.Client, ns string, name string) (*v1alpha2.Application, error) {
@@ -160,14 +199,22 @@ func loopCheckStatus(ctx context.Context, c client.Client, ioStreams cmdutil.IOS
 			return err
 		}
 		// workload Must found
-		ioStreams.Infof("    Traits:\n")
 		workloadStatus, _ := getWorkloadStatusFromApp(remoteApp, compName)
+		if workloadStatus.State != "" {
+			stateColor := getHealthStateColor(workloadStatus.State)
+			ioStreams.Infof("    Health: %s\n", stateColor.Sprint(formatHealthDetail(workloadStatus.State, workloadStatus.Reason, workloadStatus.Message)))
+		}
+		ioStreams.Infof("    Traits:\n")
 		for _, tr := range workloadStatus.Traits {
-			if tr.Message != "" {
+			if tr.Message != "" || tr.Reason != "" {
+				message := tr.Message
+				if !tr.Healthy {
+					message = formatHealthDetail(tr.State, tr.Reason, tr.Message)
+				}
 				if tr.Healthy {
-					ioStreams.Infof("      - %s%s: %s", emojiSucceed, white.Sprint(tr.Type), tr.Message)
+					ioStreams.Infof("      - %s%s: %s", emojiSucceed, white.Sprint(tr.Type), message)
 				} else {
-					ioStreams.Infof("      - %s%s: %s", emojiFail, white.Sprint(tr.Type), tr.Message)
+					ioStreams.Infof("      - %s%s: %s", emojiFail, white.Sprint(tr.Type), message)
 				}
 				continue
 			}
@@ -346,3 +393,26 @@ func getHealthStatusColor(s HealthStatus) *color.Color {
 	}
 	return c
 }
+
+func getHealthStateColor(s v1alpha2.HealthState) *color.Color {
+	switch s {
+	case v1alpha2.HealthStateHealthy:
+		return green
+	case v1alpha2.HealthStateProgressing, v1alpha2.HealthStateDegraded:
+		return yellow
+	default:
+		return red
+	}
+}
+
+// formatHealthDetail renders the health state reported by a health policy, e.g. "progressing (ReplicasNotReady) 2/4 replicas are ready"
+func formatHealthDetail(state v1alpha2.HealthState, reason, message string) string {
+	detail := string(state)
+	if reason != "" {
+		detail += fmt.Sprintf(" (%s)", reason)
+	}
+	if message != "" {
+		detail += " " + message
+	}
+	return strings.TrimSpace(detail)
+}
@@ -0,0 +1,311 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
+)
+
+// maxStatusEvents is the max number of recent events shown in the status of an application
+const maxStatusEvents = 10
+
+// AppStatusReport is the status of an application printed by `vela status`
+type AppStatusReport struct {
+	Name       string                      `json:"name"`
+	Namespace  string                      `json:"namespace"`
+	CreatedAt  metav1.Time                 `json:"createdAt"`
+	Phase      v1alpha2.ApplicationPhase   `json:"phase,omitempty"`
+	Revision   string                      `json:"revision,omitempty"`
+	Conditions []runtimev1alpha1.Condition `json:"conditions,omitempty"`
+	Services   []ServiceStatusReport       `json:"services,omitempty"`
+	Events     []EventReport               `json:"events,omitempty"`
+}
+
+// ServiceStatusReport is the health of a component and its traits
+type ServiceStatusReport struct {
+	Name    string                            `json:"name"`
+	Type    string                            `json:"type"`
+	Healthy bool                              `json:"healthy"`
+	State   v1alpha2.HealthState              `json:"state,omitempty"`
+	Reason  string                            `json:"reason,omitempty"`
+	Message string                            `json:"message,omitempty"`
+	Traits  []v1alpha2.ApplicationTraitStatus `json:"traits,omitempty"`
+}
+
+// EventReport is a Kubernetes event of the application or the resources it manages
+type EventReport struct {
+	Kind     string      `json:"kind"`
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Reason   string      `json:"reason"`
+	Message  string      `json:"message"`
+	Count    int32       `json:"count,omitempty"`
+	LastSeen metav1.Time `json:"lastSeen"`
+}
+
+// buildAppStatusReport collects the status of the application along with the recent events of the Application, its
+// AppConfig, workloads and traits
+func buildAppStatusReport(ctx context.Context, c client.Reader, namespace, appName string) (*AppStatusReport, error) {
+	app := &v1alpha2.Application{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: appName}, app); err != nil {
+		return nil, err
+	}
+	report := &AppStatusReport{
+		Name:       app.Name,
+		Namespace:  app.Namespace,
+		CreatedAt:  app.CreationTimestamp,
+		Phase:      app.Status.Phase,
+		Conditions: app.Status.Conditions,
+	}
+	if app.Status.LatestRevision != nil {
+		report.Revision = app.Status.LatestRevision.Name
+	}
+	for _, comp := range app.Spec.Components {
+		svc := ServiceStatusReport{Name: comp.Name, Type: comp.WorkloadType}
+		if status, ok := getWorkloadStatusFromApp(app, comp.Name); ok {
+			svc.Healthy = status.Healthy
+			svc.State = status.State
+			svc.Reason = status.Reason
+			svc.Message = status.Message
+			svc.Traits = status.Traits
+		}
+		report.Services = append(report.Services, svc)
+	}
+	events, err := getAppEvents(ctx, c, app)
+	if err != nil {
+		return nil, err
+	}
+	report.Events = events
+	return report, nil
+}
+
+// getAppEvents returns the recent events of the Application, the AppConfig of its latest revision and the workloads and
+// traits recorded in the AppConfig, ordered from the oldest to the newest
+func getAppEvents(ctx context.Context, c client.Reader, app *v1alpha2.Application) ([]EventReport, error) {
+	involved := map[string]bool{eventObjectKey(v1alpha2.ApplicationKind, app.Name): true}
+	if app.Status.LatestRevision != nil {
+		ac := &v1alpha2.ApplicationConfiguration{}
+		err := c.Get(ctx, client.ObjectKey{Namespace: app.Namespace, Name: app.Status.LatestRevision.Name}, ac)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			involved[eventObjectKey(v1alpha2.ApplicationConfigurationKind, ac.Name)] = true
+			for _, w := range ac.Status.Workloads {
+				involved[eventObjectKey(w.Reference.Kind, w.Reference.Name)] = true
+				for _, tr := range w.Traits {
+					involved[eventObjectKey(tr.Reference.Kind, tr.Reference.Name)] = true
+				}
+			}
+		}
+	}
+
+	eventList := &corev1.EventList{}
+	if err := c.List(ctx, eventList, client.InNamespace(app.Namespace)); err != nil {
+		return nil, errors.Wrap(err, "list events")
+	}
+	var events []EventReport
+	for _, e := range eventList.Items {
+		if !involved[eventObjectKey(e.InvolvedObject.Kind, e.InvolvedObject.Name)] {
+			continue
+		}
+		events = append(events, EventReport{
+			Kind:     e.InvolvedObject.Kind,
+			Name:     e.InvolvedObject.Name,
+			Type:     e.Type,
+			Reason:   e.Reason,
+			Message:  strings.TrimSpace(e.Message),
+			Count:    e.Count,
+			LastSeen: eventLastSeen(e),
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastSeen.Before(&events[j].LastSeen)
+	})
+	if len(events) > maxStatusEvents {
+		events = events[len(events)-maxStatusEvents:]
+	}
+	return events, nil
+}
+
+func eventObjectKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+func eventLastSeen(e corev1.Event) metav1.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp
+	case !e.EventTime.IsZero():
+		return metav1.NewTime(e.EventTime.Time)
+	default:
+		return e.CreationTimestamp
+	}
+}
+
+// printStatusReport prints the status in the given format, a report is printed as a single line of JSON in watch mode
+// so that every line can be consumed on its own
+func printStatusReport(ioStreams cmdutil.IOStreams, report *AppStatusReport, output string, watch bool) error {
+	switch output {
+	case "json":
+		var data []byte
+		var err error
+		if watch {
+			data, err = json.Marshal(report)
+		} else {
+			data, err = json.MarshalIndent(report, "", "  ")
+		}
+		if err != nil {
+			return err
+		}
+		ioStreams.Info(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		if watch {
+			ioStreams.Info("---")
+		}
+		ioStreams.Infonln(string(data))
+	default:
+		printStatusReportText(ioStreams, report)
+	}
+	return nil
+}
+
+func printStatusReportText(ioStreams cmdutil.IOStreams, report *AppStatusReport) {
+	ioStreams.Infof("About:\n\n")
+	table := newUITable()
+	table.AddRow("  Name:", report.Name)
+	table.AddRow("  Namespace:", report.Namespace)
+	table.AddRow("  Created at:", report.CreatedAt.String())
+	table.AddRow("  Status:", string(report.Phase))
+	if report.Revision != "" {
+		table.AddRow("  Revision:", report.Revision)
+	}
+	ioStreams.Infof("%s\n\n", table.String())
+
+	ioStreams.Infof("Services:\n\n")
+	for _, svc := range report.Services {
+		ioStreams.Infof(white.Sprintf("  - Name: %s\n", svc.Name))
+		ioStreams.Infof("    Type: %s\n", svc.Type)
+		if svc.State != "" {
+			stateColor := getHealthStateColor(svc.State)
+			ioStreams.Infof("    Health: %s\n", stateColor.Sprint(formatHealthDetail(svc.State, svc.Reason, svc.Message)))
+		}
+		ioStreams.Infof("    Traits:\n")
+		for _, tr := range svc.Traits {
+			emoji, message := emojiSucceed, tr.Message
+			if !tr.Healthy {
+				emoji, message = emojiFail, formatHealthDetail(tr.State, tr.Reason, tr.Message)
+			}
+			ioStreams.Infof("      - %s%s: %s\n", emoji, white.Sprint(tr.Type), message)
+		}
+		ioStreams.Info("")
+	}
+	printEvents(ioStreams, report.Events)
+}
+
+func printEvents(ioStreams cmdutil.IOStreams, events []EventReport) {
+	ioStreams.Infof("Events:\n\n")
+	if len(events) == 0 {
+		ioStreams.Info("  <none>")
+		return
+	}
+	table := newUITable()
+	table.AddRow("  LAST SEEN", "TYPE", "REASON", "OBJECT", "MESSAGE")
+	for _, e := range events {
+		lastSeen := duration.HumanDuration(time.Since(e.LastSeen.Time)) + " ago"
+		if e.Count > 1 {
+			lastSeen = fmt.Sprintf("%s (x%d)", lastSeen, e.Count)
+		}
+		table.AddRow("  "+lastSeen, e.Type, e.Reason, eventObjectKey(e.Kind, e.Name), e.Message)
+	}
+	ioStreams.Info(table.String())
+}
+
+// watchAppStatus prints the status of the application every time it changes until the context is done. The changes
+// are observed by the informers of Applications, AppConfigs and events rather than polling, and the status is read
+// from the given reader, which is expected to be backed by the same informers.
+func watchAppStatus(ctx context.Context, informers cache.Informers, reader client.Reader, namespace, appName string,
+	print func(*AppStatusReport) error) error {
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	handler := toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify() },
+		UpdateFunc: func(interface{}, interface{}) { notify() },
+		DeleteFunc: func(interface{}) { notify() },
+	}
+	for _, obj := range []runtime.Object{&v1alpha2.Application{}, &v1alpha2.ApplicationConfiguration{}, &corev1.Event{}} {
+		informer, err := informers.GetInformer(ctx, obj)
+		if err != nil {
+			return err
+		}
+		informer.AddEventHandler(handler)
+	}
+	errC := make(chan error, 1)
+	go func() {
+		errC <- informers.Start(ctx.Done())
+	}()
+	if !informers.WaitForCacheSync(ctx.Done()) {
+		return errors.Errorf("cannot sync the status of application %s", appName)
+	}
+	notify()
+
+	var last []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errC:
+			if err != nil {
+				return err
+			}
+			// the informers have stopped without error, no more changes would be observed
+			errC = nil
+		case <-changed:
+			report, err := buildAppStatusReport(ctx, reader, namespace, appName)
+			if apierrors.IsNotFound(err) {
+				return errors.Errorf("application %s is deleted", appName)
+			}
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(report)
+			if err != nil {
+				return err
+			}
+			if string(data) == string(last) {
+				continue
+			}
+			last = data
+			if err := print(report); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func newStatusTestApp() *v1alpha2.Application {
+	app := &v1alpha2.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"},
+		Spec: v1alpha2.ApplicationSpec{Components: []v1alpha2.ApplicationComponent{
+			{Name: "web", WorkloadType: "webservice"},
+			{Name: "worker", WorkloadType: "worker"},
+		}},
+	}
+	app.Status.Phase = v1alpha2.ApplicationRunning
+	app.Status.LatestRevision = &v1alpha2.Revision{Name: "myapp-v2", Revision: 2}
+	app.Status.Services = []v1alpha2.ApplicationComponentStatus{{
+		Name:    "web",
+		Healthy: false,
+		State:   v1alpha2.HealthStateProgressing,
+		Traits: []v1alpha2.ApplicationTraitStatus{
+			{Type: "scaler", Healthy: true, State: v1alpha2.HealthStateHealthy},
+		},
+	}}
+	return app
+}
+
+func newStatusTestEvent(name, kind, objName string, lastSeen time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Name: objName},
+		Type:           corev1.EventTypeNormal,
+		Reason:         "Test",
+		Message:        name,
+		LastTimestamp:  metav1.NewTime(lastSeen),
+	}
+}
+
+func TestBuildAppStatusReport(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	app := newStatusTestApp()
+	ac := &v1alpha2.ApplicationConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "myapp-v2", Namespace: "default"}}
+	ac.Status.Workloads = []v1alpha2.WorkloadStatus{{
+		ComponentName: "web",
+		Reference:     runtimev1alpha1.TypedReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		Traits: []v1alpha2.WorkloadTrait{
+			{Reference: runtimev1alpha1.TypedReference{APIVersion: "core.oam.dev/v1alpha2", Kind: "ManualScalerTrait", Name: "web-scaler"}},
+		},
+	}}
+	objs := []runtime.Object{
+		app, ac,
+		newStatusTestEvent("deploy", "Deployment", "web", now.Add(-time.Minute)),
+		newStatusTestEvent("app", v1alpha2.ApplicationKind, "myapp", now.Add(-3*time.Minute)),
+		newStatusTestEvent("trait", "ManualScalerTrait", "web-scaler", now.Add(-2*time.Minute)),
+		newStatusTestEvent("other-app", v1alpha2.ApplicationKind, "other", now),
+		newStatusTestEvent("old-revision", v1alpha2.ApplicationConfigurationKind, "myapp-v1", now),
+	}
+	c := fake.NewFakeClientWithScheme(common.Scheme, objs...)
+
+	report, err := buildAppStatusReport(ctx, c, "default", "myapp")
+	assert.NoError(t, err)
+	assert.Equal(t, "myapp-v2", report.Revision)
+	assert.Equal(t, v1alpha2.ApplicationRunning, report.Phase)
+	assert.Equal(t, []ServiceStatusReport{
+		{Name: "web", Type: "webservice", State: v1alpha2.HealthStateProgressing, Traits: app.Status.Services[0].Traits},
+		{Name: "worker", Type: "worker"},
+	}, report.Services)
+	var events []string
+	for _, e := range report.Events {
+		events = append(events, e.Message)
+	}
+	assert.Equal(t, []string{"app", "trait", "deploy"}, events)
+
+	// only the most recent events are kept
+	for i := 0; i < maxStatusEvents; i++ {
+		assert.NoError(t, c.Create(ctx, newStatusTestEvent(fmt.Sprintf("app-%d", i), v1alpha2.ApplicationKind, "myapp", now.Add(time.Duration(i)*time.Second))))
+	}
+	report, err = buildAppStatusReport(ctx, c, "default", "myapp")
+	assert.NoError(t, err)
+	assert.Len(t, report.Events, maxStatusEvents)
+	assert.Equal(t, "app-0", report.Events[0].Message)
+}
+
+func TestWatchAppStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app := newStatusTestApp()
+	c := fake.NewFakeClientWithScheme(common.Scheme, app)
+	informers := &informertest.FakeInformers{Scheme: common.Scheme}
+
+	reports := make(chan *AppStatusReport)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- watchAppStatus(ctx, informers, c, "default", "myapp", func(report *AppStatusReport) error {
+			reports <- report
+			return nil
+		})
+	}()
+	receive := func() *AppStatusReport {
+		select {
+		case report := <-reports:
+			return report
+		case err := <-errC:
+			t.Fatalf("watch stopped unexpectedly: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the status")
+		}
+		return nil
+	}
+
+	// the status is printed once the watch starts
+	report := receive()
+	assert.Equal(t, v1alpha2.HealthStateProgressing, report.Services[0].State)
+	appInformer, err := informers.FakeInformerFor(&v1alpha2.Application{})
+	assert.NoError(t, err)
+	eventInformer, err := informers.FakeInformerFor(&corev1.Event{})
+	assert.NoError(t, err)
+
+	// the status is printed again after it changes
+	assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "myapp"}, app))
+	app.Status.Services[0].State = v1alpha2.HealthStateHealthy
+	app.Status.Services[0].Healthy = true
+	assert.NoError(t, c.Status().Update(ctx, app))
+	appInformer.Update(app, app)
+	report = receive()
+	assert.Equal(t, v1alpha2.HealthStateHealthy, report.Services[0].State)
+
+	// an unrelated event doesn't change the status, so nothing is printed, while a related one does
+	unrelated := newStatusTestEvent("other", v1alpha2.ApplicationKind, "other", time.Now())
+	assert.NoError(t, c.Create(ctx, unrelated))
+	eventInformer.Add(unrelated)
+	related := newStatusTestEvent("mine", v1alpha2.ApplicationKind, "myapp", time.Now())
+	assert.NoError(t, c.Create(ctx, related))
+	eventInformer.Add(related)
+	report = receive()
+	assert.Len(t, report.Events, 1)
+	assert.Equal(t, "mine", report.Events[0].Message)
+
+	// the watch stops once the application is deleted
+	assert.NoError(t, c.Delete(ctx, app))
+	appInformer.Delete(app)
+	select {
+	case err := <-errC:
+		assert.EqualError(t, err, "application myapp is deleted")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the watch to stop")
+	}
+}
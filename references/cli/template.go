@@ -30,7 +30,7 @@ func NewTemplateContextCommand(ioStream cmdutil.IOStreams) *cobra.Command {
 		Use:                   "context",
 		DisableFlagsInUseLine: true,
 		Short:                 "Show context parameters",
-		Long:                  "Show context parameter",
+		Long:                  "Show the schema and the description of every field of the context that CUE templates can refer to",
 		Example:               `vela template context`,
 		Annotations: map[string]string{
 			types.TagCommandType: types.TypeSystem,
@@ -28,6 +28,7 @@ import (
 	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
 	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
 	"github.com/oam-dev/kubevela/pkg/utils/common"
+	"github.com/oam-dev/kubevela/pkg/utils/env"
 	cmdutil "github.com/oam-dev/kubevela/pkg/utils/util"
 	"github.com/oam-dev/kubevela/references/apiserver/apis"
 	"github.com/oam-dev/kubevela/references/appfile"
@@ -60,6 +61,11 @@ type BuildResult struct {
 	scopes      []oam.Object
 }
 
+// Application returns the Application built from the AppFile
+func (r *BuildResult) Application() *corev1alpha2.Application {
+	return r.application
+}
+
 func (comps componentMetaList) Len() int {
 	return len(comps)
 }
@@ -375,6 +381,12 @@ func (o *AppfileOptions) ExportFromAppFile(app *api.AppFile, namespace string, q
 	if err != nil {
 		return nil, nil, err
 	}
+	// check the app against the policy of the env early, the admission webhook checks it again with the latest one
+	if o.Env != nil && o.Env.Policy != nil {
+		if errs := env.ValidateApplication(retApplication, o.Env.Policy); len(errs) > 0 {
+			return nil, nil, errors.Wrapf(errs.ToAggregate(), "the app isn't allowed in env %s", o.Env.Name)
+		}
+	}
 
 	var w bytes.Buffer
 
@@ -0,0 +1,394 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/pkg/appfile"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
+	oamutil "github.com/oam-dev/kubevela/pkg/oam/util"
+	"github.com/oam-dev/kubevela/pkg/utils/env"
+)
+
+// DiffType is how an object or a field of the local application differs from the one running in the cluster
+type DiffType string
+
+const (
+	// DiffAdded means it only exists locally, it will be created
+	DiffAdded DiffType = "added"
+	// DiffChanged means it exists in both places with different values, it will be updated
+	DiffChanged DiffType = "changed"
+	// DiffRemoved means it only exists in the cluster, it will be deleted
+	DiffRemoved DiffType = "removed"
+)
+
+// FieldDiff is a field that differs between the local object and the live one
+type FieldDiff struct {
+	Path  string      `json:"path"`
+	Type  DiffType    `json:"type"`
+	Live  interface{} `json:"live,omitempty"`
+	Local interface{} `json:"local,omitempty"`
+}
+
+// ObjectDiff is an object that differs between the local application and the cluster, the fields are only listed
+// for the changed objects
+type ObjectDiff struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	Type       DiffType    `json:"type"`
+	Fields     []FieldDiff `json:"fields,omitempty"`
+}
+
+// DiffApplication renders the application the same way as the application controller does, and compares the
+// Application, ApplicationConfiguration, Components, workloads and traits with the ones running in the cluster
+func DiffApplication(ctx context.Context, c client.Client, dm discoverymapper.DiscoveryMapper, app *corev1alpha2.Application) ([]ObjectDiff, error) {
+	ctx = oamutil.SetNamespaceInCtx(ctx, app.Namespace)
+	// the application controller attaches the default traits of the envs before rendering
+	policies, err := env.GetPoliciesByNamespace(ctx, c, app.Namespace)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get the policies of the envs")
+	}
+	parsedApp := app.DeepCopy()
+	if err := env.InjectDefaultTraits(parsedApp, policies...); err != nil {
+		return nil, err
+	}
+	parser := appfile.NewApplicationParser(c, dm)
+	af, err := parser.GenerateAppFile(ctx, app.Name, parsedApp)
+	if err != nil {
+		return nil, errors.WithMessage(err, "generate appFile")
+	}
+	ac, comps, err := parser.GenerateApplicationConfiguration(af, app.Namespace)
+	if err != nil {
+		return nil, errors.WithMessage(err, "generate OAM objects")
+	}
+	return diffObjects(ctx, c, app, ac, comps)
+}
+
+// diffObjects compares the rendered objects of the application with the live ones
+func diffObjects(ctx context.Context, c client.Reader, app *corev1alpha2.Application, ac *corev1alpha2.ApplicationConfiguration,
+	comps []*corev1alpha2.Component) ([]ObjectDiff, error) {
+	var diffs []ObjectDiff
+	apiVersion := corev1alpha2.SchemeGroupVersion.String()
+
+	liveApp := &corev1alpha2.Application{}
+	found, err := getLive(ctx, c, app.Namespace, app.Name, liveApp)
+	if err != nil {
+		return nil, err
+	}
+	d, err := diffObject(objectDiff(apiVersion, corev1alpha2.ApplicationKind, app.Namespace, app.Name),
+		specOf(liveApp.Spec), specOf(app.Spec), found, false)
+	if err != nil {
+		return nil, err
+	}
+	diffs = appendDiff(diffs, d)
+
+	liveAC := &corev1alpha2.ApplicationConfiguration{}
+	found, err = getLive(ctx, c, ac.Namespace, ac.Name, liveAC)
+	if err != nil {
+		return nil, err
+	}
+	localACSpec := ac.Spec.DeepCopy()
+	withLiveRevisions(localACSpec, &liveAC.Spec)
+	d, err = diffObject(objectDiff(apiVersion, corev1alpha2.ApplicationConfigurationKind, ac.Namespace, ac.Name),
+		specOf(liveAC.Spec), specOf(localACSpec), found, false)
+	if err != nil {
+		return nil, err
+	}
+	diffs = appendDiff(diffs, d)
+
+	liveComps := &corev1alpha2.ComponentList{}
+	if err := c.List(ctx, liveComps, client.InNamespace(app.Namespace), client.MatchingLabels{oam.LabelAppName: app.Name}); err != nil {
+		return nil, errors.WithMessage(err, "list the live components")
+	}
+	liveCompByName := map[string]*corev1alpha2.Component{}
+	for i := range liveComps.Items {
+		liveCompByName[liveComps.Items[i].Name] = &liveComps.Items[i]
+	}
+	liveWorkloads := map[string]corev1alpha2.WorkloadStatus{}
+	for _, w := range liveAC.Status.Workloads {
+		liveWorkloads[w.ComponentName] = w
+	}
+
+	for _, comp := range comps {
+		liveComp, found := liveCompByName[comp.Name]
+		if !found {
+			liveComp = &corev1alpha2.Component{}
+		}
+		delete(liveCompByName, comp.Name)
+		d, err := diffObject(objectDiff(apiVersion, corev1alpha2.ComponentKind, comp.Namespace, comp.Name),
+			specOf(liveComp.Spec), specOf(comp.Spec), found, false)
+		if err != nil {
+			return nil, err
+		}
+		diffs = appendDiff(diffs, d)
+
+		var acComp corev1alpha2.ApplicationConfigurationComponent
+		for _, cc := range ac.Spec.Components {
+			if cc.ComponentName == comp.Name {
+				acComp = cc
+			}
+		}
+		rendered, err := diffRendered(ctx, c, app.Namespace, comp, acComp, liveWorkloads[comp.Name])
+		if err != nil {
+			return nil, err
+		}
+		delete(liveWorkloads, comp.Name)
+		diffs = append(diffs, rendered...)
+	}
+
+	// the components removed from the application
+	for _, liveComp := range liveComps.Items {
+		if _, ok := liveCompByName[liveComp.Name]; ok {
+			diffs = append(diffs, *removed(apiVersion, corev1alpha2.ComponentKind, liveComp.Namespace, liveComp.Name))
+		}
+	}
+	for _, w := range liveAC.Status.Workloads {
+		if _, ok := liveWorkloads[w.ComponentName]; !ok {
+			continue
+		}
+		diffs = append(diffs, *removed(w.Reference.APIVersion, w.Reference.Kind, app.Namespace, w.Reference.Name))
+		for _, tr := range w.Traits {
+			diffs = append(diffs, *removed(tr.Reference.APIVersion, tr.Reference.Kind, app.Namespace, tr.Reference.Name))
+		}
+	}
+	return diffs, nil
+}
+
+// diffRendered compares the workload and traits rendered from a component with the ones created by the
+// ApplicationConfiguration, the live ones are found by the workload status of the ApplicationConfiguration
+func diffRendered(ctx context.Context, c client.Reader, namespace string, comp *corev1alpha2.Component,
+	acComp corev1alpha2.ApplicationConfigurationComponent, liveWorkload corev1alpha2.WorkloadStatus) ([]ObjectDiff, error) {
+	var diffs []ObjectDiff
+	workload, err := oamutil.RawExtension2Unstructured(&comp.Spec.Workload)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "decode the workload of component %s", comp.Name)
+	}
+	if workload.GetName() == "" {
+		workload.SetName(comp.Name)
+	}
+	// the live workload and traits are matched by type
+	liveRefs := []runtimev1alpha1.TypedReference{liveWorkload.Reference}
+	for _, tr := range liveWorkload.Traits {
+		liveRefs = append(liveRefs, tr.Reference)
+	}
+	d, err := diffUnstructured(ctx, c, namespace, workload, &liveRefs)
+	if err != nil {
+		return nil, err
+	}
+	diffs = appendDiff(diffs, d)
+	for i := range acComp.Traits {
+		trait, err := oamutil.RawExtension2Unstructured(&acComp.Traits[i].Trait)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "decode the trait of component %s", comp.Name)
+		}
+		d, err := diffUnstructured(ctx, c, namespace, trait, &liveRefs)
+		if err != nil {
+			return nil, err
+		}
+		diffs = appendDiff(diffs, d)
+	}
+	// the live workload and traits that are no longer rendered
+	for _, ref := range liveRefs {
+		if ref.Kind != "" {
+			diffs = append(diffs, *removed(ref.APIVersion, ref.Kind, namespace, ref.Name))
+		}
+	}
+	return diffs, nil
+}
+
+// diffUnstructured compares a rendered object with the first live one of the same type, the live one is taken off
+// the references. Only the fields of the rendered object are compared since the live one has defaulted fields.
+func diffUnstructured(ctx context.Context, c client.Reader, namespace string, local *unstructured.Unstructured,
+	liveRefs *[]runtimev1alpha1.TypedReference) (*ObjectDiff, error) {
+	od := objectDiff(local.GetAPIVersion(), local.GetKind(), namespace, local.GetName())
+	live := &unstructured.Unstructured{}
+	found := false
+	for i, ref := range *liveRefs {
+		if ref.APIVersion != local.GetAPIVersion() || ref.Kind != local.GetKind() {
+			continue
+		}
+		od.Name = ref.Name
+		*liveRefs = append((*liveRefs)[:i], (*liveRefs)[i+1:]...)
+		live.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+		var err error
+		if found, err = getLive(ctx, c, namespace, ref.Name, live); err != nil {
+			return nil, err
+		}
+		break
+	}
+	localContent := local.DeepCopy().Object
+	liveContent := live.Object
+	for _, content := range []map[string]interface{}{localContent, liveContent} {
+		delete(content, "status")
+		unstructured.RemoveNestedField(content, "metadata", "name")
+		unstructured.RemoveNestedField(content, "metadata", "namespace")
+	}
+	return diffObject(od, liveContent, localContent, found, true)
+}
+
+// specOf wraps the spec so that the paths of the field diffs start with spec
+func specOf(spec interface{}) map[string]interface{} {
+	return map[string]interface{}{"spec": spec}
+}
+
+func objectDiff(apiVersion, kind, namespace, name string) *ObjectDiff {
+	return &ObjectDiff{APIVersion: apiVersion, Kind: kind, Namespace: namespace, Name: name}
+}
+
+func removed(apiVersion, kind, namespace, name string) *ObjectDiff {
+	od := objectDiff(apiVersion, kind, namespace, name)
+	od.Type = DiffRemoved
+	return od
+}
+
+func appendDiff(diffs []ObjectDiff, d *ObjectDiff) []ObjectDiff {
+	if d == nil {
+		return diffs
+	}
+	return append(diffs, *d)
+}
+
+// getLive gets the live object, it returns false if the object doesn't exist
+func getLive(ctx context.Context, c client.Reader, namespace, name string, obj oam.Object) (bool, error) {
+	if err := c.Get(ctx, apitypes.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.WithMessagef(err, "get the live object %s/%s", namespace, name)
+	}
+	return true, nil
+}
+
+// withLiveRevisions refers to the components by the revisions in the live ApplicationConfiguration, the application
+// controller replaces the component names with the revisions when applying
+func withLiveRevisions(local, live *corev1alpha2.ApplicationConfigurationSpec) {
+	for i := range local.Components {
+		if i >= len(live.Components) {
+			return
+		}
+		rev := live.Components[i].RevisionName
+		if local.Components[i].ComponentName != "" && strings.HasPrefix(rev, local.Components[i].ComponentName+"-v") {
+			local.Components[i].RevisionName = rev
+			local.Components[i].ComponentName = ""
+		}
+	}
+}
+
+// diffObject compares the local object with the live one, it returns nil if they are the same
+func diffObject(od *ObjectDiff, live, local interface{}, found, subset bool) (*ObjectDiff, error) {
+	if !found {
+		od.Type = DiffAdded
+		return od, nil
+	}
+	liveValue, err := toJSONValue(live)
+	if err != nil {
+		return nil, err
+	}
+	localValue, err := toJSONValue(local)
+	if err != nil {
+		return nil, err
+	}
+	od.Fields = diffValues("", liveValue, localValue, subset)
+	if len(od.Fields) == 0 {
+		return nil, nil
+	}
+	od.Type = DiffChanged
+	return od, nil
+}
+
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// diffValues compares two JSON values structurally. In the subset mode, the map keys and list items only existing
+// in the live value are ignored.
+func diffValues(path string, live, local interface{}, subset bool) []FieldDiff {
+	var diffs []FieldDiff
+	switch localValue := local.(type) {
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range sortedKeys(localValue) {
+			if v, ok := liveValue[k]; ok {
+				diffs = append(diffs, diffValues(joinPath(path, k), v, localValue[k], subset)...)
+			} else {
+				diffs = append(diffs, FieldDiff{Path: joinPath(path, k), Type: DiffAdded, Local: localValue[k]})
+			}
+		}
+		if subset {
+			return diffs
+		}
+		for _, k := range sortedKeys(liveValue) {
+			if _, ok := localValue[k]; !ok {
+				diffs = append(diffs, FieldDiff{Path: joinPath(path, k), Type: DiffRemoved, Live: liveValue[k]})
+			}
+		}
+		return diffs
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok {
+			break
+		}
+		for i := range localValue {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			if i < len(liveValue) {
+				diffs = append(diffs, diffValues(p, liveValue[i], localValue[i], subset)...)
+			} else {
+				diffs = append(diffs, FieldDiff{Path: p, Type: DiffAdded, Local: localValue[i]})
+			}
+		}
+		if subset {
+			return diffs
+		}
+		for i := len(localValue); i < len(liveValue); i++ {
+			diffs = append(diffs, FieldDiff{Path: fmt.Sprintf("%s[%d]", path, i), Type: DiffRemoved, Live: liveValue[i]})
+		}
+		return diffs
+	}
+	if !reflect.DeepEqual(live, local) {
+		diffs = append(diffs, FieldDiff{Path: path, Type: DiffChanged, Live: live, Local: local})
+	}
+	return diffs
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
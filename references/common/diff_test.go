@@ -0,0 +1,164 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	runtimev1alpha1 "github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestDiffValues(t *testing.T) {
+	testCases := map[string]struct {
+		live   string
+		local  string
+		subset bool
+		want   []FieldDiff
+	}{
+		"same": {
+			live:  `{"a":{"b":[1,2]}}`,
+			local: `{"a":{"b":[1,2]}}`,
+		},
+		"changed": {
+			live:  `{"a":{"b":"x"}}`,
+			local: `{"a":{"b":"y"}}`,
+			want:  []FieldDiff{{Path: "a.b", Type: DiffChanged, Live: "x", Local: "y"}},
+		},
+		"type changed": {
+			live:  `{"a":{"b":"x"}}`,
+			local: `{"a":["x"]}`,
+			want:  []FieldDiff{{Path: "a", Type: DiffChanged, Live: map[string]interface{}{"b": "x"}, Local: []interface{}{"x"}}},
+		},
+		"added and removed": {
+			live:  `{"a":1,"b":[1,2]}`,
+			local: `{"c":1,"b":[1]}`,
+			want: []FieldDiff{
+				{Path: "b[1]", Type: DiffRemoved, Live: float64(2)},
+				{Path: "c", Type: DiffAdded, Local: float64(1)},
+				{Path: "a", Type: DiffRemoved, Live: float64(1)},
+			},
+		},
+		"subset ignores the live only fields": {
+			live:   `{"a":1,"b":[{"x":1,"y":2},3]}`,
+			local:  `{"b":[{"x":1}]}`,
+			subset: true,
+		},
+		"subset still finds the local only fields": {
+			live:   `{"b":[{"x":1}]}`,
+			local:  `{"b":[{"x":2},3]}`,
+			subset: true,
+			want: []FieldDiff{
+				{Path: "b[0].x", Type: DiffChanged, Live: float64(1), Local: float64(2)},
+				{Path: "b[1]", Type: DiffAdded, Local: float64(3)},
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			live, err := toJSONValue(runtime.RawExtension{Raw: []byte(tc.live)})
+			assert.NoError(t, err)
+			local, err := toJSONValue(runtime.RawExtension{Raw: []byte(tc.local)})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, diffValues("", live, local, tc.subset))
+		})
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	ctx := context.Background()
+	workload := `{"apiVersion":"apps/v1","kind":"Deployment","spec":{"replicas":2,"template":{"spec":{"containers":[{"name":"web","image":"nginx:1.20"}]}}}}`
+	scaler := `{"apiVersion":"core.oam.dev/v1alpha2","kind":"ManualScalerTrait","spec":{"replicaCount":2}}`
+	app := &corev1alpha2.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"},
+		Spec: corev1alpha2.ApplicationSpec{Components: []corev1alpha2.ApplicationComponent{{
+			Name:         "web",
+			WorkloadType: "webservice",
+			Settings:     runtime.RawExtension{Raw: []byte(`{"image":"nginx:1.20"}`)},
+		}}},
+	}
+	ac := &corev1alpha2.ApplicationConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"},
+		Spec: corev1alpha2.ApplicationConfigurationSpec{Components: []corev1alpha2.ApplicationConfigurationComponent{{
+			ComponentName: "web",
+			Traits:        []corev1alpha2.ComponentTrait{{Trait: runtime.RawExtension{Raw: []byte(scaler)}}},
+		}}},
+	}
+	comp := &corev1alpha2.Component{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       corev1alpha2.ComponentSpec{Workload: runtime.RawExtension{Raw: []byte(workload)}},
+	}
+
+	// nothing is deployed
+	diffs, err := diffObjects(ctx, fake.NewFakeClientWithScheme(common.Scheme), app, ac, []*corev1alpha2.Component{comp})
+	assert.NoError(t, err)
+	assert.Equal(t, []ObjectDiff{
+		{APIVersion: "core.oam.dev/v1alpha2", Kind: "Application", Namespace: "default", Name: "myapp", Type: DiffAdded},
+		{APIVersion: "core.oam.dev/v1alpha2", Kind: "ApplicationConfiguration", Namespace: "default", Name: "myapp", Type: DiffAdded},
+		{APIVersion: "core.oam.dev/v1alpha2", Kind: "Component", Namespace: "default", Name: "web", Type: DiffAdded},
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web", Type: DiffAdded},
+		{APIVersion: "core.oam.dev/v1alpha2", Kind: "ManualScalerTrait", Namespace: "default", Type: DiffAdded},
+	}, diffs)
+
+	// the live app runs an older image with a route trait, and a removed component
+	liveApp := app.DeepCopy()
+	liveApp.Spec.Components[0].Settings = runtime.RawExtension{Raw: []byte(`{"image":"nginx:1.19"}`)}
+	liveComp := comp.DeepCopy()
+	liveComp.Labels = map[string]string{oam.LabelAppName: "myapp"}
+	liveComp.Spec.Workload = runtime.RawExtension{Raw: []byte(`{"apiVersion":"apps/v1","kind":"Deployment","spec":{"replicas":2,"template":{"spec":{"containers":[{"name":"web","image":"nginx:1.19"}]}}}}`)}
+	oldComp := &corev1alpha2.Component{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default", Labels: map[string]string{oam.LabelAppName: "myapp"}}}
+	liveAC := ac.DeepCopy()
+	liveAC.Spec.Components[0].ComponentName = ""
+	liveAC.Spec.Components[0].RevisionName = "web-v2"
+	liveAC.Status.Workloads = []corev1alpha2.WorkloadStatus{
+		{
+			ComponentName: "web",
+			Reference:     runtimev1alpha1.TypedReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			Traits: []corev1alpha2.WorkloadTrait{
+				{Reference: runtimev1alpha1.TypedReference{APIVersion: "core.oam.dev/v1alpha2", Kind: "ManualScalerTrait", Name: "web-scaler"}},
+				{Reference: runtimev1alpha1.TypedReference{APIVersion: "standard.oam.dev/v1alpha1", Kind: "Route", Name: "web-route"}},
+			},
+		},
+		{
+			ComponentName: "worker",
+			Reference:     runtimev1alpha1.TypedReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "worker"},
+		},
+	}
+	liveDeploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{oam.LabelAppName: "myapp"}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: pointer.Int32Ptr(2),
+		},
+	}
+	liveDeploy.Spec.Template.Spec.Containers = append(liveDeploy.Spec.Template.Spec.Containers, corev1.Container{Name: "web", Image: "nginx:1.19"})
+	liveScaler := &corev1alpha2.ManualScalerTrait{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-scaler", Namespace: "default"},
+		Spec:       corev1alpha2.ManualScalerTraitSpec{ReplicaCount: 2},
+	}
+	c := fake.NewFakeClientWithScheme(common.Scheme, liveApp, liveAC, liveComp, oldComp, liveDeploy, liveScaler)
+	diffs, err = diffObjects(ctx, c, app, ac, []*corev1alpha2.Component{comp})
+	assert.NoError(t, err)
+	assert.Equal(t, []ObjectDiff{
+		{APIVersion: "core.oam.dev/v1alpha2", Kind: "Application", Namespace: "default", Name: "myapp", Type: DiffChanged, Fields: []FieldDiff{
+			{Path: "spec.components[0].settings.image", Type: DiffChanged, Live: "nginx:1.19", Local: "nginx:1.20"},
+		}},
+		{APIVersion: "core.oam.dev/v1alpha2", Kind: "Component", Namespace: "default", Name: "web", Type: DiffChanged, Fields: []FieldDiff{
+			{Path: "spec.workload.spec.template.spec.containers[0].image", Type: DiffChanged, Live: "nginx:1.19", Local: "nginx:1.20"},
+		}},
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web", Type: DiffChanged, Fields: []FieldDiff{
+			{Path: "spec.template.spec.containers[0].image", Type: DiffChanged, Live: "nginx:1.19", Local: "nginx:1.20"},
+		}},
+		{APIVersion: "standard.oam.dev/v1alpha1", Kind: "Route", Namespace: "default", Name: "web-route", Type: DiffRemoved},
+		{APIVersion: "core.oam.dev/v1alpha2", Kind: "Component", Namespace: "default", Name: "worker", Type: DiffRemoved},
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "worker", Type: DiffRemoved},
+	}, diffs)
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/controller/common/rollout"
+	"github.com/oam-dev/kubevela/pkg/controller/utils"
+	"github.com/oam-dev/kubevela/pkg/oam"
+)
+
+// GetAppRollout finds the AppRollout of an application, it's either named after the application
+// or it rolls out a revision of the application
+func GetAppRollout(ctx context.Context, c client.Reader, namespace, appName string) (*corev1alpha2.AppRollout, error) {
+	var appRollout corev1alpha2.AppRollout
+	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: appName}, &appRollout)
+	if err == nil {
+		return &appRollout, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	var appRollouts corev1alpha2.AppRolloutList
+	if err := c.List(ctx, &appRollouts, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i, ar := range appRollouts.Items {
+		if utils.ExtractComponentName(ar.Spec.TargetAppRevisionName) == appName {
+			return &appRollouts.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no rollout found for application %s in namespace %s", appName, namespace)
+}
+
+// DecideRolloutBatch approves or rejects the current batch of the application rollout, that is the rollout plan
+// of the application itself if it has one, or the AppRollout of the application otherwise
+func DecideRolloutBatch(ctx context.Context, c client.Client, namespace, appName string,
+	decision v1alpha1.BatchApprovalDecision) (string, error) {
+	var app corev1alpha2.Application
+	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: appName}, &app)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	if err == nil && app.Spec.RolloutPlan != nil {
+		return decideBatch(ctx, c, &app, app.Spec.RolloutPlan, &app.Status.RolloutStatus, decision)
+	}
+	appRollout, err := GetAppRollout(ctx, c, namespace, appName)
+	if err != nil {
+		return "", err
+	}
+	return decideBatch(ctx, c, appRollout, &appRollout.Spec.RolloutPlan, &appRollout.Status.RolloutStatus, decision)
+}
+
+// decideBatch records the decision on the current batch in the object that owns the rollout plan
+func decideBatch(ctx context.Context, c client.Client, parent oam.Object, plan *v1alpha1.RolloutPlan,
+	status *v1alpha1.RolloutStatus, decision v1alpha1.BatchApprovalDecision) (string, error) {
+	if status.RollingState != v1alpha1.RollingInBatchesState {
+		return "", fmt.Errorf("rollout %s is not rolling in batches, its state is %s", parent.GetName(),
+			status.RollingState)
+	}
+	currentBatch := status.CurrentBatch
+	batches := plan.RolloutBatches
+	if int(currentBatch) >= len(batches) || !batches[currentBatch].RequireApproval {
+		return "", fmt.Errorf("batch %d of rollout %s does not require approval", currentBatch, parent.GetName())
+	}
+	patch := client.MergeFrom(parent.DeepCopyObject())
+	rollout.SetBatchApproval(parent, currentBatch, decision)
+	if err := c.Patch(ctx, parent, patch); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("batch %d of rollout %s is %s", currentBatch, parent.GetName(), decision), nil
+}
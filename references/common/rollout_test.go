@@ -0,0 +1,114 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1alpha2 "github.com/oam-dev/kubevela/apis/core.oam.dev/v1alpha2"
+	"github.com/oam-dev/kubevela/apis/standard.oam.dev/v1alpha1"
+	"github.com/oam-dev/kubevela/pkg/oam"
+	"github.com/oam-dev/kubevela/pkg/utils/common"
+)
+
+func TestDecideRolloutBatch(t *testing.T) {
+	ctx := context.TODO()
+	newAppRollout := func(name string, state v1alpha1.RollingState) *corev1alpha2.AppRollout {
+		return &corev1alpha2.AppRollout{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: corev1alpha2.AppRolloutSpec{
+				TargetAppRevisionName: "myapp-v2",
+				SourceAppRevisionName: "myapp-v1",
+				RolloutPlan: v1alpha1.RolloutPlan{
+					RolloutBatches: []v1alpha1.RolloutBatch{{RequireApproval: true}, {}},
+				},
+			},
+			Status: corev1alpha2.AppRolloutStatus{
+				RolloutStatus: v1alpha1.RolloutStatus{
+					RollingState:      state,
+					BatchRollingState: v1alpha1.BatchReadyState,
+				},
+			},
+		}
+	}
+
+	t.Run("approve the rollout named after the app", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(common.Scheme, newAppRollout("myapp", v1alpha1.RollingInBatchesState))
+		msg, err := DecideRolloutBatch(ctx, c, "default", "myapp", v1alpha1.BatchApproved)
+		assert.NoError(t, err)
+		assert.Contains(t, msg, "approved")
+		var appRollout corev1alpha2.AppRollout
+		assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "myapp"}, &appRollout))
+		assert.Equal(t, "0:approved", appRollout.GetAnnotations()[oam.AnnotationRolloutBatchApproval])
+	})
+
+	t.Run("reject the rollout of the app revision", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(common.Scheme, newAppRollout("rollout", v1alpha1.RollingInBatchesState))
+		_, err := DecideRolloutBatch(ctx, c, "default", "myapp", v1alpha1.BatchRejected)
+		assert.NoError(t, err)
+		var appRollout corev1alpha2.AppRollout
+		assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "rollout"}, &appRollout))
+		assert.Equal(t, "0:rejected", appRollout.GetAnnotations()[oam.AnnotationRolloutBatchApproval])
+	})
+
+	t.Run("approve the rollout plan of the app", func(t *testing.T) {
+		app := &corev1alpha2.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"},
+			Spec: corev1alpha2.ApplicationSpec{
+				RolloutPlan: &v1alpha1.RolloutPlan{
+					RolloutBatches: []v1alpha1.RolloutBatch{{}, {RequireApproval: true}},
+				},
+			},
+			Status: corev1alpha2.AppStatus{
+				RolloutStatus: v1alpha1.RolloutStatus{
+					RollingState: v1alpha1.RollingInBatchesState,
+					CurrentBatch: 1,
+				},
+			},
+		}
+		c := fake.NewFakeClientWithScheme(common.Scheme, app, newAppRollout("rollout", v1alpha1.RollingInBatchesState))
+		msg, err := DecideRolloutBatch(ctx, c, "default", "myapp", v1alpha1.BatchApproved)
+		assert.NoError(t, err)
+		assert.Contains(t, msg, "batch 1 of rollout myapp is approved")
+		var got corev1alpha2.Application
+		assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "myapp"}, &got))
+		assert.Equal(t, "1:approved", got.GetAnnotations()[oam.AnnotationRolloutBatchApproval])
+		var appRollout corev1alpha2.AppRollout
+		assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "rollout"}, &appRollout))
+		assert.NotContains(t, appRollout.GetAnnotations(), oam.AnnotationRolloutBatchApproval)
+	})
+
+	t.Run("the app without a rollout plan is rolled out by an AppRollout", func(t *testing.T) {
+		app := &corev1alpha2.Application{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"}}
+		c := fake.NewFakeClientWithScheme(common.Scheme, app, newAppRollout("rollout", v1alpha1.RollingInBatchesState))
+		_, err := DecideRolloutBatch(ctx, c, "default", "myapp", v1alpha1.BatchApproved)
+		assert.NoError(t, err)
+		var appRollout corev1alpha2.AppRollout
+		assert.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "rollout"}, &appRollout))
+		assert.Equal(t, "0:approved", appRollout.GetAnnotations()[oam.AnnotationRolloutBatchApproval])
+	})
+
+	t.Run("no rollout for the app", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(common.Scheme, newAppRollout("rollout", v1alpha1.RollingInBatchesState))
+		_, err := DecideRolloutBatch(ctx, c, "default", "other", v1alpha1.BatchApproved)
+		assert.Error(t, err)
+	})
+
+	t.Run("the rollout is not rolling in batches", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(common.Scheme, newAppRollout("myapp", v1alpha1.RolloutSucceedState))
+		_, err := DecideRolloutBatch(ctx, c, "default", "myapp", v1alpha1.BatchApproved)
+		assert.Error(t, err)
+	})
+
+	t.Run("the batch does not require approval", func(t *testing.T) {
+		appRollout := newAppRollout("myapp", v1alpha1.RollingInBatchesState)
+		appRollout.Status.CurrentBatch = 1
+		c := fake.NewFakeClientWithScheme(common.Scheme, appRollout)
+		_, err := DecideRolloutBatch(ctx, c, "default", "myapp", v1alpha1.BatchApproved)
+		assert.Error(t, err)
+	})
+}
@@ -16,7 +16,6 @@ import (
 	"github.com/oam-dev/kubevela/pkg/cue"
 	"github.com/oam-dev/kubevela/pkg/oam/discoverymapper"
 	"github.com/oam-dev/kubevela/pkg/oam/util"
-	"github.com/oam-dev/kubevela/pkg/utils/common"
 	"github.com/oam-dev/kubevela/pkg/utils/helm"
 	util2 "github.com/oam-dev/kubevela/pkg/utils/util"
 )
@@ -168,17 +167,14 @@ func HandleTemplate(in *runtime.RawExtension, schematic *corev1alpha2.Schematic,
 		return types.Capability{}, err
 	}
 	tmp.Name = name
-	// if spec.template is not empty it should has the highest priority
+	// spec.schematic.cue has the highest priority, its templateURI takes precedence over its template
 	if schematic != nil && schematic.CUE != nil {
 		tmp.CueTemplate = schematic.CUE.Template
-		tmp.CueTemplateURI = ""
+		tmp.CueTemplateURI = schematic.CUE.TemplateURI
+		tmp.CueTemplateDigest = schematic.CUE.TemplateDigest
 	}
-	if tmp.CueTemplateURI != "" {
-		b, err := common.HTTPGet(context.Background(), tmp.CueTemplateURI)
-		if err != nil {
-			return types.Capability{}, err
-		}
-		tmp.CueTemplate = string(b)
+	if err = util.ResolveCapabilityTemplate(context.Background(), &tmp); err != nil {
+		return types.Capability{}, err
 	}
 	if tmp.CueTemplate == "" {
 		return types.Capability{}, errors.New("template not exist in definition")